@@ -0,0 +1,130 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var lastID atomic.Int64
+
+// Buffered replies are written, when they reach the size, so big pipeline doesn't hold all its replies in memory
+const REPLY_BUFFER_FLUSH_SIZE = 64 * 1024
+
+// Client connection with its state, that is set by HELLO, connection starts with RESP2, like in Redis
+type Conn struct {
+	net.Conn
+	id       int64
+	protocol int
+	name     string
+	mut      sync.RWMutex
+
+	// Replies of read batch are buffered, so pipeline is replied by one write
+	// Data, that is written by other goroutines meanwhile, e.g. Pub/Sub message, is buffered in the same order
+	replies  resp.ReplyBuffer
+	batch    bool
+	writeMut sync.Mutex
+}
+
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		Conn:     conn,
+		id:       lastID.Add(1),
+		protocol: resp.PROTOCOL_RESP2,
+	}
+}
+
+func (c *Conn) ID() int64 {
+	return c.id
+}
+
+func (c *Conn) Protocol() int {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.protocol
+}
+
+func (c *Conn) SetProtocol(protocol int) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.protocol = protocol
+}
+
+func (c *Conn) Name() string {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	return c.name
+}
+
+func (c *Conn) SetName(name string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.name = name
+}
+
+// Replies are buffered until EndBatch, except the ones, that reach REPLY_BUFFER_FLUSH_SIZE
+func (c *Conn) StartBatch() {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+	c.batch = true
+}
+
+// Writes buffered replies and stops buffering
+func (c *Conn) EndBatch() error {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+	c.batch = false
+	return c.flush()
+}
+
+// Writes buffered replies, e.g. before command blocks, so client gets the replies, that are ready
+func (c *Conn) Flush() error {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+	return c.flush()
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+
+	if c.batch && c.replies.Len()+len(p) < REPLY_BUFFER_FLUSH_SIZE {
+		return c.replies.Write(p)
+	}
+	if err := c.flush(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+// Bulk payloads of value are written without copying, see resp.ReplyBuffer
+func (c *Conn) WriteValue(v resp.Value) error {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+
+	if err := c.replies.WriteValue(v); err != nil {
+		return err
+	}
+	if c.batch && c.replies.Len() < REPLY_BUFFER_FLUSH_SIZE {
+		return nil
+	}
+	return c.flush()
+}
+
+func (c *Conn) flush() error {
+	if c.replies.Len() == 0 {
+		return nil
+	}
+	_, err := c.replies.WriteTo(c.Conn)
+	return err
+}
+
+// Connections, that aren't accepted from clients, e.g. with master or AOF replay one, use RESP2
+func Protocol(conn net.Conn) int {
+	if c, ok := conn.(*Conn); ok {
+		return c.Protocol()
+	}
+	return resp.PROTOCOL_RESP2
+}
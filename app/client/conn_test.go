@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Connection, that records written data instead of sending it
+type recordConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	return c.written.Write(p)
+}
+
+func TestConnBatch(t *testing.T) {
+	rc := &recordConn{}
+	conn := NewConn(rc)
+
+	conn.StartBatch()
+	assert.NoError(t, conn.WriteValue(resp.SimpleString{Value: "OK"}))
+	assert.NoError(t, conn.WriteValue(resp.CreateBulkStringArray("a", "")))
+	_, err := conn.Write([]byte(":1\r\n"))
+	assert.NoError(t, err)
+	assert.Zero(t, rc.written.Len())
+
+	assert.NoError(t, conn.EndBatch())
+	assert.Equal(t, "+OK\r\n*2\r\n$1\r\na\r\n$0\r\n\r\n:1\r\n", rc.written.String())
+
+	// Without batch replies are written right away
+	rc.written.Reset()
+	assert.NoError(t, conn.WriteValue(resp.Integer{Value: 2}))
+	assert.Equal(t, ":2\r\n", rc.written.String())
+}
+
+func TestConnBatchFlush(t *testing.T) {
+	big := strings.Repeat("v", REPLY_BUFFER_FLUSH_SIZE)
+
+	tests := []struct {
+		Name  string
+		Write func(conn *Conn) error
+	}{
+		{
+			Name: "Explicit flush",
+			Write: func(conn *Conn) error {
+				return conn.Flush()
+			},
+		},
+		{
+			Name: "Buffer reaches flush size",
+			Write: func(conn *Conn) error {
+				return conn.WriteValue(resp.BulkString{Value: &big})
+			},
+		},
+		{
+			Name: "Big raw data",
+			Write: func(conn *Conn) error {
+				_, err := conn.Write([]byte(big))
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			rc := &recordConn{}
+			conn := NewConn(rc)
+
+			conn.StartBatch()
+			assert.NoError(t, conn.WriteValue(resp.SimpleString{Value: "OK"}))
+			assert.NoError(t, test.Write(conn))
+
+			// Data is written in order, before batch ends
+			written := rc.written.String()
+			assert.True(t, strings.HasPrefix(written, "+OK\r\n"), "expected replies in order, got: %.20q", written)
+
+			assert.NoError(t, conn.WriteValue(resp.Integer{Value: 1}))
+			assert.Equal(t, len(written), rc.written.Len())
+			assert.NoError(t, conn.EndBatch())
+			assert.True(t, strings.HasSuffix(rc.written.String(), ":1\r\n"))
+		})
+	}
+}
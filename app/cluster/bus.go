@@ -0,0 +1,407 @@
+package cluster
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
+)
+
+func (c *controller) acceptBusConnections(listener net.Listener) {
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("Error accepting cluster bus connection: %v\n", err)
+			continue
+		}
+		go c.handleBusConn(conn)
+	}
+}
+
+// Other node sends messages one by one and waits for PONG to each of them
+func (c *controller) handleBusConn(conn net.Conn) {
+	defer conn.Close()
+
+	var buf []byte
+	for {
+		value, rest, err := readValue(conn, buf, c.respController)
+		if err != nil {
+			return
+		}
+		buf = rest
+
+		m, err := decodeMessage(value)
+		if err != nil {
+			log.Printf("Cluster bus message from %s error: %v\n", utils.GetRemoteAddr(conn), err)
+			return
+		}
+		host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+		c.mut.Lock()
+		c.processMessage(m, host)
+		reply := c.createMessage(MESSAGE_TYPE_PONG)
+		c.mut.Unlock()
+
+		if err := utils.WriteCommand(reply.encode(), conn); err != nil {
+			return
+		}
+	}
+}
+
+func (c *controller) startTimer() {
+	ticker := time.NewTicker(CLUSTER_TIMER_PERIOD)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mut.Lock()
+		c.checkNodes()
+		c.updateState()
+		c.mut.Unlock()
+	}
+}
+
+// Caller holds mut
+func (c *controller) checkNodes() {
+	now := time.Now()
+	for _, n := range c.nodes {
+		if n.myself {
+			continue
+		}
+		if n.handshake && now.Sub(n.createdAt) > c.handshakeTimeout() {
+			c.removeNode(n)
+			continue
+		}
+		if !n.pingInProgress && now.Sub(n.lastPingAt) >= CLUSTER_PING_PERIOD {
+			c.sendPing(n)
+		}
+		if !n.handshake && !n.isFailing() && now.Sub(n.lastPongAt) > c.nodeTimeout() {
+			n.pfailSince = now
+			log.Printf("Cluster node %s is possibly failing\n", n.id)
+		}
+		if n.pfailSince.IsZero() || n.isFailed() {
+			continue
+		}
+		c.markAsFailedIfNeeded(n)
+	}
+}
+
+// Node is pinged with MEET during handshake, so the other node adds this one, even if it doesn't know it yet
+// Caller holds mut
+func (c *controller) sendPing(n *node) {
+	messageType := MESSAGE_TYPE_PING
+	if n.handshake {
+		messageType = MESSAGE_TYPE_MEET
+	}
+	n.pingInProgress = true
+	n.lastPingAt = time.Now()
+	m := c.createMessage(messageType)
+	l := n.link
+
+	go func() {
+		reply, err := l.request(m)
+
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		n.pingInProgress = false
+		if err != nil || n.removed {
+			return
+		}
+		if reply.messageType != MESSAGE_TYPE_PONG {
+			log.Printf("Cluster node %s replied with %s instead of PONG\n", n.busAddr(), reply.messageType)
+			return
+		}
+		c.processPong(n, reply)
+	}()
+}
+
+// Ping isn't waited for ping period, e.g. when slots are changed, so other nodes get the change sooner
+// Caller holds mut
+func (c *controller) pingAllSoon() {
+	for _, n := range c.nodes {
+		n.lastPingAt = time.Time{}
+	}
+}
+
+// Caller holds mut
+func (c *controller) broadcastFail(failed *node) {
+	m := c.createMessage(MESSAGE_TYPE_FAIL)
+	m.failed = failed.id
+	for _, n := range c.nodes {
+		if n.myself || n.handshake || n == failed {
+			continue
+		}
+		go n.link.request(m)
+	}
+}
+
+// Caller holds mut
+func (c *controller) createMessage(messageType string) *message {
+	slots := make([]int, 0)
+	for slot, owner := range c.slots {
+		if owner == c.myself {
+			slots = append(slots, slot)
+		}
+	}
+	return &message{
+		messageType:  messageType,
+		id:           c.myself.id,
+		host:         c.myself.host,
+		port:         c.myself.port,
+		busPort:      c.myself.busPort,
+		currentEpoch: c.currentEpoch,
+		configEpoch:  c.myself.configEpoch,
+		slots:        slotRanges(slots),
+		gossip:       c.createGossip(),
+	}
+}
+
+// Random known nodes are added, so every node is gossiped about soon, failing nodes are always added
+// Caller holds mut
+func (c *controller) createGossip() []gossipEntry {
+	candidates := make([]*node, 0, len(c.nodes))
+	gossip := make([]gossipEntry, 0)
+	for _, n := range c.nodes {
+		if n.myself || n.handshake {
+			continue
+		}
+		if n.isFailing() {
+			gossip = append(gossip, gossipEntry{id: n.id, host: n.host, port: n.port, busPort: n.busPort, failing: true})
+			continue
+		}
+		candidates = append(candidates, n)
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	wanted := max(CLUSTER_MIN_GOSSIP_ENTRIES, len(c.nodes)/10)
+	for _, n := range candidates[:min(wanted, len(candidates))] {
+		gossip = append(gossip, gossipEntry{id: n.id, host: n.host, port: n.port, busPort: n.busPort})
+	}
+	return gossip
+}
+
+// PONG to MEET or PING of this node, node in handshake gets its real ID
+// Caller holds mut
+func (c *controller) processPong(n *node, m *message) {
+	if n.handshake {
+		if known, ok := c.nodes[m.id]; ok {
+			// Node is already known, e.g. it is met twice or is gossiped by other node
+			c.removeNode(n)
+			known.setAddr(n.host, m.port, m.busPort, c.respController)
+			n = known
+		} else {
+			delete(c.nodes, n.id)
+			n.id = m.id
+			n.handshake = false
+			c.nodes[n.id] = n
+			log.Printf("Cluster node %s is met at %s\n", n.id, n.addr())
+		}
+	} else if n.id != m.id {
+		// Other node is restarted at the same address with the new ID, the old one is failing, until it is back
+		return
+	}
+	c.processHeader(n, m)
+}
+
+// MEET adds unknown sender, messages of other unknown nodes are only replied with PONG
+// Sender is reached at the address, the message came from, its own host can be unspecified, e.g. 0.0.0.0
+// Caller holds mut
+func (c *controller) processMessage(m *message, host string) {
+	if m.id == c.myself.id {
+		return
+	}
+	n, ok := c.nodes[m.id]
+	if !ok {
+		if m.messageType != MESSAGE_TYPE_MEET {
+			return
+		}
+		n = newNode(m.id, host, m.port, m.busPort, c.respController)
+		c.nodes[n.id] = n
+		log.Printf("Cluster node %s is added by MEET from %s\n", n.id, n.addr())
+	} else if m.messageType == MESSAGE_TYPE_MEET {
+		n.setAddr(host, m.port, m.busPort, c.respController)
+	}
+	c.processHeader(n, m)
+
+	if m.messageType == MESSAGE_TYPE_FAIL {
+		if failed, ok := c.nodes[m.failed]; ok && !failed.myself && !failed.isFailed() {
+			c.markAsFailed(failed)
+		}
+	}
+}
+
+// Any message means, that sender is alive, its slots with the greater config epoch replace the known ones
+// Caller holds mut
+func (c *controller) processHeader(n *node, m *message) {
+	n.lastPongAt = time.Now()
+	if n.isFailing() {
+		log.Printf("Cluster node %s is reachable again\n", n.id)
+	}
+	n.pfailSince = time.Time{}
+	n.failSince = time.Time{}
+
+	c.currentEpoch = max(c.currentEpoch, m.currentEpoch)
+	n.configEpoch = m.configEpoch
+	c.updateSlots(n, m.slotList())
+	c.handleConfigEpochCollision(n)
+
+	for _, entry := range m.gossip {
+		c.processGossip(n, entry)
+	}
+	c.updateState()
+}
+
+// Slot is moved to sender, when it is unassigned or its owner has the less config epoch
+// Slot, that is imported by this node, is changed only by SETSLOT, like in Redis
+// Caller holds mut
+func (c *controller) updateSlots(sender *node, slots []int) {
+	for _, slot := range slots {
+		owner := c.slots[slot]
+		if owner == sender || c.importingFrom[slot] != nil {
+			continue
+		}
+		if owner != nil && owner.configEpoch >= sender.configEpoch {
+			continue
+		}
+		c.slots[slot] = sender
+		if owner == c.myself {
+			c.migratingTo[slot] = nil
+			log.Printf("Slot %d is moved to cluster node %s\n", slot, sender.id)
+		}
+	}
+}
+
+// Masters with the same config epoch can't decide, who owns slot, so node with the less ID takes the new epoch
+// Caller holds mut
+func (c *controller) handleConfigEpochCollision(sender *node) {
+	if sender.configEpoch != c.myself.configEpoch || sender.id <= c.myself.id {
+		return
+	}
+	c.currentEpoch++
+	c.myself.configEpoch = c.currentEpoch
+}
+
+// Unknown node is met, failure report of master is added or removed
+// Caller holds mut
+func (c *controller) processGossip(sender *node, entry gossipEntry) {
+	if entry.id == c.myself.id {
+		return
+	}
+	n, ok := c.nodes[entry.id]
+	if !ok {
+		// Failing node is met, only when it is back and other nodes gossip it as alive
+		if sender.handshake || entry.failing {
+			return
+		}
+		c.startHandshake(entry.host, entry.port, entry.busPort)
+		return
+	}
+	if entry.failing {
+		n.failReports[sender.id] = time.Now()
+	} else {
+		delete(n.failReports, sender.id)
+	}
+}
+
+// Node is failed, when majority of masters with slots (with this one) report it, then it is broadcasted by FAIL
+// Caller holds mut
+func (c *controller) markAsFailedIfNeeded(n *node) {
+	validity := c.nodeTimeout() * CLUSTER_FAIL_REPORT_VALIDITY_MULT
+	reports := 1
+	for id, reportedAt := range n.failReports {
+		if time.Since(reportedAt) > validity {
+			delete(n.failReports, id)
+			continue
+		}
+		reports++
+	}
+	if reports < len(c.mastersWithSlots())/2+1 {
+		return
+	}
+	c.markAsFailed(n)
+	c.broadcastFail(n)
+}
+
+// Caller holds mut
+func (c *controller) markAsFailed(n *node) {
+	n.failSince = time.Now()
+	if n.pfailSince.IsZero() {
+		n.pfailSince = n.failSince
+	}
+	log.Printf("Cluster node %s is failed\n", n.id)
+	c.updateState()
+}
+
+// Caller holds mut
+func (c *controller) removeNode(n *node) {
+	n.remove()
+	delete(c.nodes, n.id)
+	for slot := range CLUSTER_SLOTS {
+		if c.slots[slot] == n {
+			c.slots[slot] = nil
+		}
+		if c.migratingTo[slot] == n {
+			c.migratingTo[slot] = nil
+		}
+		if c.importingFrom[slot] == n {
+			c.importingFrom[slot] = nil
+		}
+	}
+	for _, other := range c.nodes {
+		delete(other.failReports, n.id)
+	}
+}
+
+// Cluster is ok, when every slot is served by not failed node and this node sees majority of masters
+// Caller holds mut
+func (c *controller) updateState() {
+	state := CLUSTER_STATE_OK
+	for _, owner := range c.slots {
+		if owner == nil || owner.isFailed() {
+			state = CLUSTER_STATE_FAIL
+			break
+		}
+	}
+
+	masters := c.mastersWithSlots()
+	reachable := 0
+	for _, n := range masters {
+		if !n.isFailing() {
+			reachable++
+		}
+	}
+	if reachable < len(masters)/2+1 {
+		state = CLUSTER_STATE_FAIL
+	}
+
+	if state != c.state {
+		log.Printf("Cluster state changed: %s\n", state)
+		c.state = state
+	}
+}
+
+// Caller holds mut
+func (c *controller) mastersWithSlots() []*node {
+	seen := make(map[*node]bool)
+	masters := make([]*node, 0)
+	for _, owner := range c.slots {
+		if owner != nil && !seen[owner] {
+			seen[owner] = true
+			masters = append(masters, owner)
+		}
+	}
+	return masters
+}
+
+func (c *controller) nodeTimeout() time.Duration {
+	return time.Duration(c.args.ClusterNodeTimeout) * time.Millisecond
+}
+
+func (c *controller) handshakeTimeout() time.Duration {
+	return max(CLUSTER_HANDSHAKE_TIMEOUT, c.nodeTimeout())
+}
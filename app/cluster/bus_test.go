@@ -0,0 +1,165 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Controller isn't started, so nodes aren't pinged and state is changed only by tests
+func newTestController() *controller {
+	args := &config.Args{Host: "127.0.0.1", Port: 7000, ClusterEnabled: true, ClusterNodeTimeout: 1000}
+	c := newController(args, resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN), memory.NewMultiTypeStorage())
+	c.myself.id = "m"
+	c.nodes = map[string]*node{"m": c.myself}
+	return c
+}
+
+func addTestNode(c *controller, id string, configEpoch int) *node {
+	n := newNode(id, "127.0.0.1", 7001, 17001, c.respController)
+	n.configEpoch = configEpoch
+	c.nodes[id] = n
+	return n
+}
+
+func TestUpdateSlots(t *testing.T) {
+	tests := []struct {
+		Name              string
+		OwnerEpoch        int
+		SenderEpoch       int
+		Importing         bool
+		ExpectedMovedSlot bool
+	}{
+		{Name: "Sender has the greater epoch", OwnerEpoch: 1, SenderEpoch: 2, ExpectedMovedSlot: true},
+		{Name: "Sender has the same epoch", OwnerEpoch: 2, SenderEpoch: 2, ExpectedMovedSlot: false},
+		{Name: "Sender has the less epoch", OwnerEpoch: 3, SenderEpoch: 2, ExpectedMovedSlot: false},
+		{Name: "Slot is imported", OwnerEpoch: 1, SenderEpoch: 2, Importing: true, ExpectedMovedSlot: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			c := newTestController()
+			c.myself.configEpoch = test.OwnerEpoch
+			sender := addTestNode(c, "s", test.SenderEpoch)
+			c.slots[1] = c.myself
+			c.migratingTo[1] = sender
+			if test.Importing {
+				c.importingFrom[1] = addTestNode(c, "i", 0)
+			}
+
+			c.updateSlots(sender, []int{0, 1})
+			assert.Equal(t, sender, c.slots[0], "unassigned slot is always taken")
+			if test.ExpectedMovedSlot {
+				assert.Equal(t, sender, c.slots[1])
+				assert.Nil(t, c.migratingTo[1])
+			} else {
+				assert.Equal(t, c.myself, c.slots[1])
+			}
+		})
+	}
+}
+
+func TestHandleConfigEpochCollision(t *testing.T) {
+	c := newTestController()
+	c.currentEpoch = 5
+	c.myself.configEpoch = 5
+
+	// Node with the less ID doesn't change its epoch
+	c.handleConfigEpochCollision(addTestNode(c, "a", 5))
+	assert.Equal(t, 5, c.myself.configEpoch)
+
+	c.handleConfigEpochCollision(addTestNode(c, "z", 5))
+	assert.Equal(t, 6, c.myself.configEpoch)
+	assert.Equal(t, 6, c.currentEpoch)
+}
+
+func TestBumpConfigEpoch(t *testing.T) {
+	c := newTestController()
+	addTestNode(c, "a", 7)
+	c.currentEpoch = 3
+
+	c.bumpConfigEpoch()
+	assert.Equal(t, 8, c.myself.configEpoch)
+	assert.Equal(t, 8, c.currentEpoch)
+
+	// Node with the greatest epoch keeps it
+	c.bumpConfigEpoch()
+	assert.Equal(t, 8, c.myself.configEpoch)
+}
+
+func TestMarkAsFailedIfNeeded(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Reports        map[string]time.Duration
+		ExpectedFailed bool
+	}{
+		{Name: "No reports of other masters", Reports: map[string]time.Duration{}, ExpectedFailed: false},
+		{Name: "Majority reports", Reports: map[string]time.Duration{"b": 0}, ExpectedFailed: true},
+		{Name: "Report is expired", Reports: map[string]time.Duration{"b": 3 * time.Second}, ExpectedFailed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			c := newTestController()
+			failing := addTestNode(c, "a", 1)
+			other := addTestNode(c, "b", 2)
+			c.slots[0], c.slots[1], c.slots[2] = c.myself, failing, other
+			failing.pfailSince = time.Now()
+			for id, ago := range test.Reports {
+				failing.failReports[id] = time.Now().Add(-ago)
+			}
+
+			c.markAsFailedIfNeeded(failing)
+			assert.Equal(t, test.ExpectedFailed, failing.isFailed())
+		})
+	}
+}
+
+func TestUpdateState(t *testing.T) {
+	c := newTestController()
+	other := addTestNode(c, "a", 1)
+	for slot := range CLUSTER_SLOTS {
+		c.slots[slot] = c.myself
+	}
+	c.slots[CLUSTER_SLOTS-1] = nil
+
+	c.updateState()
+	assert.Equal(t, CLUSTER_STATE_FAIL, c.state, "slot isn't served")
+
+	c.slots[CLUSTER_SLOTS-1] = other
+	c.updateState()
+	assert.Equal(t, CLUSTER_STATE_OK, c.state)
+
+	other.pfailSince = time.Now()
+	c.updateState()
+	assert.Equal(t, CLUSTER_STATE_FAIL, c.state, "majority of masters isn't reachable")
+
+	other.pfailSince = time.Time{}
+	other.failSince = time.Now()
+	c.updateState()
+	assert.Equal(t, CLUSTER_STATE_FAIL, c.state, "slot is served by failed node")
+}
+
+func TestProcessPongOfHandshakeNode(t *testing.T) {
+	c := newTestController()
+	c.startHandshake("127.0.0.1", 7001, 17001)
+	var handshake *node
+	for _, n := range c.nodes {
+		if n.handshake {
+			handshake = n
+		}
+	}
+
+	c.processPong(handshake, &message{messageType: MESSAGE_TYPE_PONG, id: "a", host: "127.0.0.1", port: 7001, busPort: 17001, configEpoch: 2, slots: []SlotRange{{Start: 0, End: 10}}})
+	n, ok := c.nodes["a"]
+	assert.True(t, ok)
+	assert.False(t, n.handshake)
+	assert.Equal(t, 2, n.configEpoch)
+	assert.Equal(t, n, c.slots[10])
+	assert.Len(t, c.nodes, 2)
+}
@@ -0,0 +1,35 @@
+package cluster
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Fields of CLUSTER INFO
+type Info struct {
+	State         string
+	SlotsAssigned int
+	SlotsOK       int
+	SlotsPFail    int
+	SlotsFail     int
+	KnownNodes    int
+	// Number of masters, that serve at least one slot
+	Size         int
+	CurrentEpoch int
+	MyEpoch      int
+}
+
+func (i *Info) String() string {
+	data := []string{
+		"cluster_state:" + i.State,
+		"cluster_slots_assigned:" + strconv.Itoa(i.SlotsAssigned),
+		"cluster_slots_ok:" + strconv.Itoa(i.SlotsOK),
+		"cluster_slots_pfail:" + strconv.Itoa(i.SlotsPFail),
+		"cluster_slots_fail:" + strconv.Itoa(i.SlotsFail),
+		"cluster_known_nodes:" + strconv.Itoa(i.KnownNodes),
+		"cluster_size:" + strconv.Itoa(i.Size),
+		"cluster_current_epoch:" + strconv.Itoa(i.CurrentEpoch),
+		"cluster_my_epoch:" + strconv.Itoa(i.MyEpoch),
+	}
+	return strings.Join(data, "\r\n") + "\r\n"
+}
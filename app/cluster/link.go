@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Bus connection to other node is dialed on demand and closed on any error, so the next message dials it again
+type link struct {
+	addr           string
+	respController resp.Controller
+	conn           net.Conn
+	buf            []byte
+	closed         bool
+	// Messages are sent one by one, so replies aren't mixed
+	requestMut sync.Mutex
+	// Connection is closed, when node is removed, without waiting for the message in progress
+	connMut sync.Mutex
+}
+
+func newLink(addr string, respController resp.Controller) *link {
+	return &link{addr: addr, respController: respController}
+}
+
+func (l *link) isConnected() bool {
+	l.connMut.Lock()
+	defer l.connMut.Unlock()
+	return l.conn != nil
+}
+
+// Sends message and waits for PONG
+func (l *link) request(m *message) (*message, error) {
+	l.requestMut.Lock()
+	defer l.requestMut.Unlock()
+
+	conn, err := l.getConn()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := l.roundTrip(conn, m)
+	if err != nil {
+		l.closeConn(conn)
+		return nil, fmt.Errorf("%s message to %s error: %v", m.messageType, l.addr, err)
+	}
+	reply, err := decodeMessage(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s message reply from %s error: %v", m.messageType, l.addr, err)
+	}
+	return reply, nil
+}
+
+func (l *link) roundTrip(conn net.Conn, m *message) (resp.Value, error) {
+	err := conn.SetDeadline(time.Now().Add(CLUSTER_REQUEST_TIMEOUT))
+	if err != nil {
+		return nil, err
+	}
+	b, err := m.encode().Encode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	value, rest, err := readValue(conn, l.buf, l.respController)
+	l.buf = rest
+	return value, err
+}
+
+func (l *link) getConn() (net.Conn, error) {
+	l.connMut.Lock()
+	defer l.connMut.Unlock()
+
+	if l.closed {
+		return nil, fmt.Errorf("link to %s is closed", l.addr)
+	}
+	if l.conn != nil {
+		return l.conn, nil
+	}
+	conn, err := net.DialTimeout("tcp", l.addr, CLUSTER_REQUEST_TIMEOUT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %v", l.addr, err)
+	}
+	l.conn = conn
+	l.buf = nil
+	return conn, nil
+}
+
+func (l *link) closeConn(conn net.Conn) {
+	l.connMut.Lock()
+	defer l.connMut.Unlock()
+
+	conn.Close()
+	if l.conn == conn {
+		l.conn = nil
+	}
+}
+
+func (l *link) close() {
+	l.connMut.Lock()
+	defer l.connMut.Unlock()
+
+	l.closed = true
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+}
+
+// Reads from connection, until the buffer contains the whole RESP value, returns the value and the rest of the buffer
+// Invalid data is returned as error, as more bytes can't make it valid
+func readValue(conn net.Conn, buf []byte, respController resp.Controller) (resp.Value, []byte, error) {
+	tmp := make([]byte, 4096)
+	parser := respController.NewParser()
+	for {
+		if len(buf) > 0 {
+			rest, value, err := parser.Decode(buf)
+			if err == nil {
+				return value, rest, nil
+			}
+			if !errors.Is(err, resp.ErrIncomplete) {
+				return nil, nil, err
+			}
+		}
+		n, err := conn.Read(tmp)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf = append(buf, tmp[:n]...)
+	}
+}
@@ -0,0 +1,406 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+const (
+	// Cluster bus listens on client port plus this offset, like in Redis
+	CLUSTER_BUS_PORT_OFFSET = 10000
+	// Cluster timer pings nodes, detects failures and updates cluster state with this period
+	CLUSTER_TIMER_PERIOD = 100 * time.Millisecond
+	CLUSTER_PING_PERIOD  = time.Second
+	// Bus message, that isn't replied for this time, is failed
+	CLUSTER_REQUEST_TIMEOUT = time.Second
+	// Node, that is met, but doesn't reply, is forgotten after this time (or node timeout, if it is greater)
+	CLUSTER_HANDSHAKE_TIMEOUT = time.Second
+	// Failure reports are valid for node timeout multiplied by this
+	CLUSTER_FAIL_REPORT_VALIDITY_MULT = 2
+	// Message has gossip about at least this number of other nodes (or a tenth of known nodes), failing nodes are always added
+	CLUSTER_MIN_GOSSIP_ENTRIES = 3
+
+	CLUSTER_STATE_OK   = "ok"
+	CLUSTER_STATE_FAIL = "fail"
+)
+
+type Controller interface {
+	// Listens cluster bus and starts gossip with known nodes, it doesn't block
+	Start() error
+	MyID() string
+	// Cluster is down, when some slot isn't served or this node doesn't see majority of masters
+	IsUp() bool
+	Route(slot int) SlotRoute
+	// Adds node to cluster, its ID is got from the first PONG
+	Meet(host string, port, busPort int) error
+	AddSlots(slots []int) error
+	DelSlots(slots []int) error
+	// Sets slot state: MIGRATING or IMPORTING with node ID, STABLE or NODE with node ID to assign slot to it
+	SetSlot(slot int, state string, nodeID string) error
+	Nodes() []NodeInfo
+	Info() Info
+	CountKeysInSlot(slot int) int
+	GetKeysInSlot(slot, count int) []string
+	// Client sends ASKING before command, that is redirected by -ASK, the flag is reset by the next command
+	SetAsking(conn net.Conn)
+	ResetAsking(conn net.Conn) bool
+}
+
+// Where slot is served, it is used for -MOVED and -ASK redirections
+type SlotRoute struct {
+	// Empty, if slot isn't assigned
+	OwnerID   string
+	OwnerAddr string
+	Myself    bool
+	// Address of the node, that slot of this node is migrating to, empty if slot isn't migrating
+	MigratingAddr string
+	// Slot of other node is imported by this node
+	Importing bool
+}
+
+type controller struct {
+	args           *config.Args
+	respController resp.Controller
+	storage        memory.MultiTypeStorage
+	myself         *node
+	// The greatest epoch, that node knows about
+	currentEpoch int
+	// Keyed by node ID, node in handshake has random ID
+	nodes map[string]*node
+	// Slot owners, nil if slot isn't assigned
+	slots         [CLUSTER_SLOTS]*node
+	migratingTo   [CLUSTER_SLOTS]*node
+	importingFrom [CLUSTER_SLOTS]*node
+	state         string
+	// Nodes, slots and epochs are changed together
+	mut sync.Mutex
+	// Connections, that sent ASKING
+	asking    map[net.Conn]bool
+	askingMut sync.Mutex
+}
+
+func NewController(args *config.Args, respController resp.Controller, storage memory.MultiTypeStorage) Controller {
+	return newController(args, respController, storage)
+}
+
+func newController(args *config.Args, respController resp.Controller, storage memory.MultiTypeStorage) *controller {
+	myself := newNode(generateNodeID(), args.Host, args.Port, args.Port+CLUSTER_BUS_PORT_OFFSET, respController)
+	myself.myself = true
+	return &controller{
+		args:           args,
+		respController: respController,
+		storage:        storage,
+		myself:         myself,
+		nodes:          map[string]*node{myself.id: myself},
+		state:          CLUSTER_STATE_FAIL,
+		asking:         make(map[net.Conn]bool),
+	}
+}
+
+func (c *controller) Start() error {
+	address := net.JoinHostPort(c.args.Host, fmt.Sprint(c.myself.busPort))
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to bind cluster bus to address %s: %v", address, err)
+	}
+	go c.acceptBusConnections(listener)
+	go c.startTimer()
+	return nil
+}
+
+func (c *controller) MyID() string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.myself.id
+}
+
+func (c *controller) IsUp() bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.state == CLUSTER_STATE_OK
+}
+
+func (c *controller) Route(slot int) SlotRoute {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var route SlotRoute
+	if owner := c.slots[slot]; owner != nil {
+		route.OwnerID = owner.id
+		route.OwnerAddr = owner.addr()
+		route.Myself = owner.myself
+	}
+	if target := c.migratingTo[slot]; target != nil {
+		route.MigratingAddr = target.addr()
+	}
+	route.Importing = c.importingFrom[slot] != nil
+	return route
+}
+
+// Node in handshake is pinged with MEET by the timer, it is forgotten, if it doesn't reply during handshake timeout
+func (c *controller) Meet(host string, port, busPort int) error {
+	if port <= 0 || port > 65535 || busPort <= 0 || busPort > 65535 {
+		return fmt.Errorf("Invalid node address specified: %s:%d", host, port)
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return fmt.Errorf("Invalid node address specified: %s:%d", host, port)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.startHandshake(host, port, busPort)
+	return nil
+}
+
+// Caller holds mut
+func (c *controller) startHandshake(host string, port, busPort int) {
+	for _, n := range c.nodes {
+		if n.handshake && n.host == host && n.port == port && n.busPort == busPort {
+			return
+		}
+	}
+	n := newNode(generateNodeID(), host, port, busPort, c.respController)
+	n.handshake = true
+	c.nodes[n.id] = n
+}
+
+func (c *controller) AddSlots(slots []int) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for _, slot := range slots {
+		if c.slots[slot] != nil {
+			return fmt.Errorf("Slot %d is already busy", slot)
+		}
+	}
+	for _, slot := range slots {
+		c.slots[slot] = c.myself
+		// Imported slot becomes owned, like in Redis
+		c.importingFrom[slot] = nil
+	}
+	c.updateState()
+	c.pingAllSoon()
+	return nil
+}
+
+// Slot is unassigned only locally, other nodes still see it owned, until they get other owner with the greater epoch
+func (c *controller) DelSlots(slots []int) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for _, slot := range slots {
+		if c.slots[slot] == nil {
+			return fmt.Errorf("Slot %d is already unassigned", slot)
+		}
+	}
+	for _, slot := range slots {
+		c.slots[slot] = nil
+		c.migratingTo[slot] = nil
+		c.importingFrom[slot] = nil
+	}
+	c.updateState()
+	c.pingAllSoon()
+	return nil
+}
+
+// Slot is migrated by MIGRATING on source, IMPORTING on target, moving keys and NODE on target and then on source
+func (c *controller) SetSlot(slot int, state string, nodeID string) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var n *node
+	if state != "STABLE" {
+		var ok bool
+		n, ok = c.nodes[nodeID]
+		if !ok || n.handshake {
+			return fmt.Errorf("I don't know about node %s", nodeID)
+		}
+	}
+
+	switch state {
+	case "MIGRATING":
+		if c.slots[slot] != c.myself {
+			return fmt.Errorf("I'm not the owner of hash slot %d", slot)
+		}
+		if n.myself {
+			return fmt.Errorf("Target node can't be myself")
+		}
+		c.migratingTo[slot] = n
+	case "IMPORTING":
+		if c.slots[slot] == c.myself {
+			return fmt.Errorf("I'm already the owner of hash slot %d", slot)
+		}
+		if n.myself {
+			return fmt.Errorf("Source node can't be myself")
+		}
+		c.importingFrom[slot] = n
+	case "STABLE":
+		c.migratingTo[slot] = nil
+		c.importingFrom[slot] = nil
+	case "NODE":
+		if c.slots[slot] == c.myself && !n.myself && c.CountKeysInSlot(slot) > 0 {
+			return fmt.Errorf("Can't assign hashslot %d to a different node while I still hold keys for this hash slot.", slot)
+		}
+		if !n.myself {
+			c.migratingTo[slot] = nil
+		}
+		// Target of migration claims slot with the new epoch, so other nodes prefer it to the old owner
+		if n.myself && c.importingFrom[slot] != nil {
+			c.importingFrom[slot] = nil
+			c.bumpConfigEpoch()
+		}
+		c.slots[slot] = n
+	default:
+		return fmt.Errorf("Invalid CLUSTER SETSLOT action or number of arguments")
+	}
+	c.updateState()
+	c.pingAllSoon()
+	return nil
+}
+
+// Config epoch is bumped without agreement with other nodes, like in Redis, collisions are resolved by gossip
+// Caller holds mut
+func (c *controller) bumpConfigEpoch() {
+	maxEpoch := c.currentEpoch
+	for _, n := range c.nodes {
+		maxEpoch = max(maxEpoch, n.configEpoch)
+	}
+	if c.myself.configEpoch == 0 || c.myself.configEpoch != maxEpoch {
+		c.currentEpoch = maxEpoch + 1
+		c.myself.configEpoch = c.currentEpoch
+	}
+}
+
+// Node info is sorted by ID, this node is the first
+func (c *controller) Nodes() []NodeInfo {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	nodeSlots := make(map[*node][]int)
+	for slot, owner := range c.slots {
+		if owner != nil {
+			nodeSlots[owner] = append(nodeSlots[owner], slot)
+		}
+	}
+
+	infos := make([]NodeInfo, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		info := NodeInfo{
+			ID:          n.id,
+			Host:        n.host,
+			Port:        n.port,
+			BusPort:     n.busPort,
+			Myself:      n.myself,
+			Flags:       n.flags(),
+			ConfigEpoch: n.configEpoch,
+			LastPongAt:  n.lastPongAt,
+			Connected:   n.link.isConnected(),
+			Slots:       slotRanges(nodeSlots[n]),
+		}
+		if n.pingInProgress {
+			info.PingSentAt = n.lastPingAt
+		}
+		if n.myself {
+			info.Migrating = slotNodeIDs(c.migratingTo[:])
+			info.Importing = slotNodeIDs(c.importingFrom[:])
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Myself != infos[j].Myself {
+			return infos[i].Myself
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+func slotNodeIDs(nodes []*node) map[int]string {
+	ids := make(map[int]string)
+	for slot, n := range nodes {
+		if n != nil {
+			ids[slot] = n.id
+		}
+	}
+	return ids
+}
+
+func (c *controller) Info() Info {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	info := Info{
+		State:        c.state,
+		KnownNodes:   len(c.nodes),
+		Size:         len(c.mastersWithSlots()),
+		CurrentEpoch: c.currentEpoch,
+		MyEpoch:      c.myself.configEpoch,
+	}
+	for _, owner := range c.slots {
+		if owner == nil {
+			continue
+		}
+		info.SlotsAssigned++
+		switch {
+		case owner.isFailed():
+			info.SlotsFail++
+		case owner.isFailing():
+			info.SlotsPFail++
+		default:
+			info.SlotsOK++
+		}
+	}
+	return info
+}
+
+// Keys are scanned, storage doesn't index them by slot
+func (c *controller) CountKeysInSlot(slot int) int {
+	count := 0
+	for _, key := range c.storage.Keys() {
+		if KeySlot(key) == slot {
+			count++
+		}
+	}
+	return count
+}
+
+func (c *controller) GetKeysInSlot(slot, count int) []string {
+	keys := make([]string, 0)
+	for _, key := range c.storage.Keys() {
+		if KeySlot(key) == slot {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys[:min(count, len(keys))]
+}
+
+func (c *controller) SetAsking(conn net.Conn) {
+	c.askingMut.Lock()
+	defer c.askingMut.Unlock()
+	c.asking[conn] = true
+}
+
+func (c *controller) ResetAsking(conn net.Conn) bool {
+	c.askingMut.Lock()
+	defer c.askingMut.Unlock()
+
+	asking := c.asking[conn]
+	delete(c.asking, conn)
+	return asking
+}
+
+func generateNodeID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,160 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+const (
+	// Node checks that other node is alive, PONG is the reply to every message
+	MESSAGE_TYPE_PING = "PING"
+	MESSAGE_TYPE_PONG = "PONG"
+	// Node is added to cluster: receiver adds sender to known nodes, even if it doesn't know it yet
+	MESSAGE_TYPE_MEET = "MEET"
+	// Majority of masters agree that node is failing, receiver marks it as failed without waiting for its own reports
+	MESSAGE_TYPE_FAIL = "FAIL"
+)
+
+// Cluster bus message is sent as RESP array of bulk strings: type, header fields and then gossip entries or failed node ID
+// Every message has the sender header, so receiver updates sender state from any message, like in Redis
+type message struct {
+	messageType string
+	id          string
+	host        string
+	port        int
+	busPort     int
+	// The greatest epoch known by sender
+	currentEpoch int
+	configEpoch  int
+	slots        []SlotRange
+	// Some other nodes, known by sender, they are sent in PING, PONG and MEET
+	gossip []gossipEntry
+	// ID of failed node, it is sent in FAIL
+	failed string
+}
+
+const MESSAGE_HEADER_FIELDS = 8
+
+// Sender view of other node, receiver starts handshake with unknown nodes and counts failure reports
+type gossipEntry struct {
+	id      string
+	host    string
+	port    int
+	busPort int
+	failing bool
+}
+
+func (e gossipEntry) String() string {
+	return fmt.Sprintf("%s,%s,%d,%d,%t", e.id, e.host, e.port, e.busPort, e.failing)
+}
+
+func parseGossipEntry(s string) (gossipEntry, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 5 {
+		return gossipEntry{}, fmt.Errorf("gossip entry should have 5 fields, got: %d", len(fields))
+	}
+	port, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return gossipEntry{}, fmt.Errorf("gossip entry port should be decimal, got: %s", fields[2])
+	}
+	busPort, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return gossipEntry{}, fmt.Errorf("gossip entry bus port should be decimal, got: %s", fields[3])
+	}
+	failing, err := strconv.ParseBool(fields[4])
+	if err != nil {
+		return gossipEntry{}, fmt.Errorf("gossip entry failing flag should be boolean, got: %s", fields[4])
+	}
+	return gossipEntry{id: fields[0], host: fields[1], port: port, busPort: busPort, failing: failing}, nil
+}
+
+func (m *message) encode() resp.Value {
+	fields := []string{
+		m.messageType,
+		m.id,
+		m.host,
+		strconv.Itoa(m.port),
+		strconv.Itoa(m.busPort),
+		strconv.Itoa(m.currentEpoch),
+		strconv.Itoa(m.configEpoch),
+		formatSlotRanges(m.slots),
+	}
+	if m.messageType == MESSAGE_TYPE_FAIL {
+		fields = append(fields, m.failed)
+	}
+	for _, entry := range m.gossip {
+		fields = append(fields, entry.String())
+	}
+	return resp.CreateBulkStringArray(fields...)
+}
+
+func decodeMessage(value resp.Value) (*message, error) {
+	array, ok := value.(resp.Array)
+	if !ok {
+		return nil, fmt.Errorf("message should be RESP array, got %T", value)
+	}
+	fields := make([]string, 0, len(array.Value))
+	for i, item := range array.Value {
+		bulkString, ok := item.(resp.BulkString)
+		if !ok || bulkString.Value == nil {
+			return nil, fmt.Errorf("message field %d should be bulk string", i)
+		}
+		fields = append(fields, *bulkString.Value)
+	}
+	if len(fields) < MESSAGE_HEADER_FIELDS {
+		return nil, fmt.Errorf("message should have at least %d fields, got: %d", MESSAGE_HEADER_FIELDS, len(fields))
+	}
+
+	m := &message{messageType: fields[0], id: fields[1], host: fields[2]}
+	switch m.messageType {
+	case MESSAGE_TYPE_PING, MESSAGE_TYPE_PONG, MESSAGE_TYPE_MEET, MESSAGE_TYPE_FAIL:
+	default:
+		return nil, fmt.Errorf("unknown message type %q", m.messageType)
+	}
+
+	numbers := make([]int, 0, 4)
+	for _, i := range []int{3, 4, 5, 6} {
+		number, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("message field %d should be decimal, got: %s", i, fields[i])
+		}
+		numbers = append(numbers, number)
+	}
+	m.port, m.busPort, m.currentEpoch, m.configEpoch = numbers[0], numbers[1], numbers[2], numbers[3]
+
+	slots, err := parseSlotRanges(fields[7])
+	if err != nil {
+		return nil, err
+	}
+	m.slots = slotRanges(slots)
+
+	rest := fields[MESSAGE_HEADER_FIELDS:]
+	if m.messageType == MESSAGE_TYPE_FAIL {
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("FAIL message should have failed node ID")
+		}
+		m.failed = rest[0]
+		rest = rest[1:]
+	}
+	for _, s := range rest {
+		entry, err := parseGossipEntry(s)
+		if err != nil {
+			return nil, err
+		}
+		m.gossip = append(m.gossip, entry)
+	}
+	return m, nil
+}
+
+func (m *message) slotList() []int {
+	slots := make([]int, 0)
+	for _, r := range m.slots {
+		for slot := r.Start; slot <= r.End; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
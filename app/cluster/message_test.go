@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestMessageEncodeDecode(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Message *message
+	}{
+		{
+			Name:    "PING without slots and gossip",
+			Message: &message{messageType: MESSAGE_TYPE_PING, id: "a", host: "127.0.0.1", port: 7000, busPort: 17000, slots: []SlotRange{}},
+		},
+		{
+			Name: "PONG with slots and gossip",
+			Message: &message{
+				messageType:  MESSAGE_TYPE_PONG,
+				id:           "a",
+				host:         "127.0.0.1",
+				port:         7000,
+				busPort:      17000,
+				currentEpoch: 5,
+				configEpoch:  3,
+				slots:        []SlotRange{{Start: 0, End: 100}, {Start: 200, End: 200}},
+				gossip: []gossipEntry{
+					{id: "b", host: "127.0.0.1", port: 7001, busPort: 17001},
+					{id: "c", host: "127.0.0.2", port: 7002, busPort: 17002, failing: true},
+				},
+			},
+		},
+		{
+			Name:    "FAIL",
+			Message: &message{messageType: MESSAGE_TYPE_FAIL, id: "a", host: "127.0.0.1", port: 7000, busPort: 17000, slots: []SlotRange{}, failed: "b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			decoded, err := decodeMessage(test.Message.encode())
+			assert.NoError(t, err)
+			assert.Equal(t, test.Message, decoded)
+		})
+	}
+}
+
+func TestDecodeWrongMessage(t *testing.T) {
+	tooShort := (&message{messageType: MESSAGE_TYPE_PING, id: "a", host: "127.0.0.1"}).encode().(resp.Array)
+	tooShort.Value = tooShort.Value[:MESSAGE_HEADER_FIELDS-1]
+	_, err := decodeMessage(tooShort)
+	assert.Error(t, err)
+
+	unknownType := (&message{messageType: "HELLO", id: "a", host: "127.0.0.1"}).encode()
+	_, err = decodeMessage(unknownType)
+	assert.Error(t, err)
+
+	failWithoutNode := (&message{messageType: MESSAGE_TYPE_FAIL, id: "a", host: "127.0.0.1", failed: "b"}).encode().(resp.Array)
+	failWithoutNode.Value = failWithoutNode.Value[:MESSAGE_HEADER_FIELDS]
+	_, err = decodeMessage(failWithoutNode)
+	assert.Error(t, err)
+}
@@ -0,0 +1,169 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+const (
+	NODE_FLAG_MYSELF    = "myself"
+	NODE_FLAG_MASTER    = "master"
+	NODE_FLAG_PFAIL     = "fail?"
+	NODE_FLAG_FAIL      = "fail"
+	NODE_FLAG_HANDSHAKE = "handshake"
+)
+
+// Known node of cluster, fields are changed under controller mut
+type node struct {
+	id      string
+	host    string
+	port    int
+	busPort int
+	myself  bool
+	// Node is met, but its ID isn't known yet, it has random ID till the first PONG
+	handshake bool
+	createdAt time.Time
+	// Epoch of the last slots change, slot claimed by several nodes belongs to the one with the greater epoch
+	configEpoch int
+	// Bus connection, PING, MEET and FAIL are sent through it, PONG is its reply
+	link           *link
+	pingInProgress bool
+	lastPingAt     time.Time
+	// The last time, when node replied to PING or sent message itself, it is the creation time before that
+	lastPongAt time.Time
+	// Zero, if node isn't suspected to be failing
+	pfailSince time.Time
+	// Zero, if majority of masters haven't agreed that node is failing
+	failSince time.Time
+	// Other masters, that report node as failing, keyed by node ID
+	failReports map[string]time.Time
+	removed     bool
+}
+
+func newNode(id, host string, port, busPort int, respController resp.Controller) *node {
+	now := time.Now()
+	n := &node{
+		id:          id,
+		host:        host,
+		port:        port,
+		busPort:     busPort,
+		createdAt:   now,
+		lastPongAt:  now,
+		failReports: make(map[string]time.Time),
+	}
+	n.link = newLink(n.busAddr(), respController)
+	return n
+}
+
+func (n *node) addr() string {
+	return net.JoinHostPort(n.host, strconv.Itoa(n.port))
+}
+
+func (n *node) busAddr() string {
+	return net.JoinHostPort(n.host, strconv.Itoa(n.busPort))
+}
+
+func (n *node) isFailing() bool {
+	return !n.pfailSince.IsZero() || !n.failSince.IsZero()
+}
+
+func (n *node) isFailed() bool {
+	return !n.failSince.IsZero()
+}
+
+func (n *node) flags() []string {
+	flags := make([]string, 0, 3)
+	if n.myself {
+		flags = append(flags, NODE_FLAG_MYSELF)
+	}
+	flags = append(flags, NODE_FLAG_MASTER)
+	if n.isFailed() {
+		flags = append(flags, NODE_FLAG_FAIL)
+	} else if n.isFailing() {
+		flags = append(flags, NODE_FLAG_PFAIL)
+	}
+	if n.handshake {
+		flags = append(flags, NODE_FLAG_HANDSHAKE)
+	}
+	return flags
+}
+
+// Address is changed, when node is restarted on other host or port with the same ID
+func (n *node) setAddr(host string, port, busPort int, respController resp.Controller) {
+	if n.host == host && n.port == port && n.busPort == busPort {
+		return
+	}
+	n.host, n.port, n.busPort = host, port, busPort
+	n.link.close()
+	n.link = newLink(n.busAddr(), respController)
+}
+
+func (n *node) remove() {
+	n.removed = true
+	n.link.close()
+}
+
+// Node state for CLUSTER commands
+type NodeInfo struct {
+	ID          string
+	Host        string
+	Port        int
+	BusPort     int
+	Myself      bool
+	Flags       []string
+	ConfigEpoch int
+	// Zero, if there is no PING in progress
+	PingSentAt time.Time
+	LastPongAt time.Time
+	Connected  bool
+	Slots      []SlotRange
+	// Slots of this node, that are migrating to other nodes or are imported from them, keyed by slot
+	Migrating map[int]string
+	Importing map[int]string
+}
+
+func (info NodeInfo) HasFlag(flag string) bool {
+	return slices.Contains(info.Flags, flag)
+}
+
+// Node line of CLUSTER NODES: <id> <ip:port@cport> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot> ...
+func (info NodeInfo) String() string {
+	linkState := "disconnected"
+	if info.Connected || info.Myself {
+		linkState = "connected"
+	}
+	var pingSent, pongReceived int64
+	if !info.PingSentAt.IsZero() {
+		pingSent = info.PingSentAt.UnixMilli()
+	}
+	if !info.Myself {
+		pongReceived = info.LastPongAt.UnixMilli()
+	}
+
+	fields := []string{
+		info.ID,
+		fmt.Sprintf("%s:%d@%d", info.Host, info.Port, info.BusPort),
+		strings.Join(info.Flags, ","),
+		"-",
+		strconv.FormatInt(pingSent, 10),
+		strconv.FormatInt(pongReceived, 10),
+		strconv.Itoa(info.ConfigEpoch),
+		linkState,
+	}
+	for _, r := range info.Slots {
+		fields = append(fields, r.String())
+	}
+	for _, slot := range sortedSlots(info.Migrating) {
+		fields = append(fields, fmt.Sprintf("[%d->-%s]", slot, info.Migrating[slot]))
+	}
+	for _, slot := range sortedSlots(info.Importing) {
+		fields = append(fields, fmt.Sprintf("[%d-<-%s]", slot, info.Importing[slot]))
+	}
+	return strings.Join(fields, " ")
+}
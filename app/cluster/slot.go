@@ -0,0 +1,117 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const CLUSTER_SLOTS = 16384
+
+// CRC16 XMODEM (polynomial 0x1021, zero initial value), like in Redis cluster
+func crc16(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Only hash tag is hashed, if key has it, e.g. {user1000}.following and {user1000}.followers are in the same slot
+// Hash tag is the part between the first { and the first } after it, empty tag isn't used
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start != -1 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16([]byte(key)) & (CLUSTER_SLOTS - 1))
+}
+
+func ParseSlot(s string) (int, error) {
+	slot, err := strconv.Atoi(s)
+	if err != nil || slot < 0 || slot >= CLUSTER_SLOTS {
+		return 0, fmt.Errorf("Invalid or out of range slot")
+	}
+	return slot, nil
+}
+
+// Inclusive range of slots
+type SlotRange struct {
+	Start int
+	End   int
+}
+
+func (r SlotRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// Sorted slots are joined into ranges, e.g. 0 1 2 5 is 0-2 and 5
+func slotRanges(slots []int) []SlotRange {
+	ranges := make([]SlotRange, 0)
+	for _, slot := range slots {
+		if len(ranges) > 0 && ranges[len(ranges)-1].End == slot-1 {
+			ranges[len(ranges)-1].End = slot
+			continue
+		}
+		ranges = append(ranges, SlotRange{Start: slot, End: slot})
+	}
+	return ranges
+}
+
+// Ranges are written, like in CLUSTER NODES, e.g. 0-2,5, it is - for no slots, so the field is never empty
+func formatSlotRanges(ranges []SlotRange) string {
+	if len(ranges) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseSlotRanges(s string) ([]int, error) {
+	slots := make([]int, 0)
+	if s == "-" {
+		return slots, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		if !isRange {
+			endStr = startStr
+		}
+		start, err := ParseSlot(startStr)
+		if err != nil {
+			return nil, fmt.Errorf("wrong slot range %q", part)
+		}
+		end, err := ParseSlot(endStr)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("wrong slot range %q", part)
+		}
+		for slot := start; slot <= end; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+func sortedSlots(slotNodeIDs map[int]string) []int {
+	slots := make([]int, 0, len(slotNodeIDs))
+	for slot := range slotNodeIDs {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	return slots
+}
@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRC16(t *testing.T) {
+	assert.Equal(t, uint16(0x31C3), crc16([]byte("123456789")))
+	assert.Equal(t, uint16(0), crc16(nil))
+}
+
+func TestKeySlot(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Key      string
+		Expected int
+	}{
+		{Name: "Key without hash tag", Key: "foo", Expected: 12182},
+		{Name: "Other key without hash tag", Key: "hello", Expected: 866},
+		{Name: "Empty key", Key: "", Expected: 0},
+		{Name: "Hash tag", Key: "{user1000}.following", Expected: 3443},
+		{Name: "Same hash tag", Key: "{user1000}.followers", Expected: 3443},
+		{Name: "Only the first hash tag is used", Key: "foo{bar}{zap}", Expected: KeySlot("bar")},
+		{Name: "Empty hash tag isn't used", Key: "foo{}{bar}", Expected: int(crc16([]byte("foo{}{bar}")) % CLUSTER_SLOTS)},
+		{Name: "Hash tag is from the first {", Key: "foo{{bar}}zap", Expected: KeySlot("{bar")},
+		{Name: "Unclosed hash tag isn't used", Key: "foo{bar", Expected: int(crc16([]byte("foo{bar")) % CLUSTER_SLOTS)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, KeySlot(test.Key))
+		})
+	}
+}
+
+func TestSlotRanges(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Slots     []int
+		Formatted string
+	}{
+		{Name: "No slots", Slots: []int{}, Formatted: "-"},
+		{Name: "One slot", Slots: []int{5}, Formatted: "5"},
+		{Name: "Ranges and single slots", Slots: []int{0, 1, 2, 5, 7, 8, 16383}, Formatted: "0-2,5,7-8,16383"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			formatted := formatSlotRanges(slotRanges(test.Slots))
+			assert.Equal(t, test.Formatted, formatted)
+
+			slots, err := parseSlotRanges(formatted)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Slots, slots)
+		})
+	}
+
+	for _, wrong := range []string{"", "a", "5-1", "0-16384", "1,,2"} {
+		_, err := parseSlotRanges(wrong)
+		assert.Error(t, err, wrong)
+	}
+}
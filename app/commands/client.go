@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/client"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+// Server doesn't have passwords, so only default user is accepted with any password, like Redis without requirepass
+// Options are checked before anything is changed, so failed HELLO keeps protocol and name of client
+func (c *controller) hello(args []string, conn net.Conn) resp.Value {
+	cc, ok := conn.(*client.Conn)
+	if !ok {
+		return resp.SimpleError{Value: "ERR HELLO is served only to clients"}
+	}
+
+	protocol := cc.Protocol()
+	if len(args) > 0 {
+		var err error
+		protocol, err = strconv.Atoi(args[0])
+		if err != nil {
+			return resp.SimpleError{Value: "ERR Protocol version is not an integer or out of range"}
+		}
+		if protocol != resp.PROTOCOL_RESP2 && protocol != resp.PROTOCOL_RESP3 {
+			return resp.SimpleError{Value: "NOPROTO unsupported protocol version"}
+		}
+	}
+
+	var name *string
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				return resp.SimpleError{Value: fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i])}
+			}
+			if args[i+1] != "default" {
+				return resp.SimpleError{Value: "WRONGPASS invalid username-password pair or user is disabled."}
+			}
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				return resp.SimpleError{Value: fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i])}
+			}
+			if !isValidClientName(args[i+1]) {
+				return resp.SimpleError{Value: "ERR Client names cannot contain spaces, newlines or special characters."}
+			}
+			i++
+			name = &args[i]
+		default:
+			return resp.SimpleError{Value: fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i])}
+		}
+	}
+
+	cc.SetProtocol(protocol)
+	if name != nil {
+		cc.SetName(*name)
+	}
+
+	mode := "standalone"
+	if c.args.ClusterEnabled {
+		mode = "cluster"
+	}
+	role := "master"
+	if _, ok := c.replication().(replication.ReplicaController); ok {
+		role = "replica"
+	}
+	return resp.Map{Value: []resp.MapEntry{
+		{Key: resp.BulkString{Value: strPtr("server")}, Value: resp.BulkString{Value: strPtr("redis")}},
+		{Key: resp.BulkString{Value: strPtr("version")}, Value: resp.BulkString{Value: strPtr(rdb.REDIS_VERSION)}},
+		{Key: resp.BulkString{Value: strPtr("proto")}, Value: resp.Integer{Value: protocol}},
+		{Key: resp.BulkString{Value: strPtr("id")}, Value: resp.Integer{Value: int(cc.ID())}},
+		{Key: resp.BulkString{Value: strPtr("mode")}, Value: resp.BulkString{Value: &mode}},
+		{Key: resp.BulkString{Value: strPtr("role")}, Value: resp.BulkString{Value: &role}},
+		{Key: resp.BulkString{Value: strPtr("modules")}, Value: resp.Array{Value: []resp.Value{}}},
+	}}
+}
+
+// CLIENT ID | GETNAME | SETNAME name
+func (c *controller) client(args []string, conn net.Conn) resp.Value {
+	if len(args) < 1 {
+		return resp.SimpleError{Value: "CLIENT command must have at least 1 arg"}
+	}
+	cc, ok := conn.(*client.Conn)
+	if !ok {
+		return resp.SimpleError{Value: "ERR CLIENT is served only to clients"}
+	}
+
+	secondCommand := strings.ToUpper(args[0])
+	switch secondCommand {
+	case "ID":
+		return resp.Integer{Value: int(cc.ID())}
+	case "GETNAME":
+		name := cc.Name()
+		if name == "" {
+			return resp.BulkString{Value: nil}
+		}
+		return resp.BulkString{Value: &name}
+	case "SETNAME":
+		if len(args) != 2 {
+			return resp.SimpleError{Value: "CLIENT SETNAME command must have 1 arg"}
+		}
+		if !isValidClientName(args[1]) {
+			return resp.SimpleError{Value: "ERR Client names cannot contain spaces, newlines or special characters."}
+		}
+		cc.SetName(args[1])
+		return resp.SimpleString{Value: "OK"}
+	default:
+		return resp.SimpleError{Value: fmt.Sprintf("unknown command CLIENT '%s'", secondCommand)}
+	}
+}
+
+// Name is shown in space separated client list, so it has only printable chars without spaces, empty name resets it
+func isValidClientName(name string) bool {
+	for _, ch := range name {
+		if ch < '!' || ch > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func strPtr(s string) *string {
+	return &s
+}
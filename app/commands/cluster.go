@@ -0,0 +1,370 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Client command is served by this node, only if all its keys are in one slot, that this node serves, like in Redis cluster
+func (c *controller) redirectClientCommand(commandAndArgs []string, conn net.Conn) resp.Value {
+	if !c.args.ClusterEnabled {
+		return nil
+	}
+	command := strings.ToUpper(commandAndArgs[0])
+	if command == "ASKING" {
+		return nil
+	}
+	asking := c.clusterController.ResetAsking(conn) || command == "RESTORE-ASKING"
+
+	// Transaction is checked as a whole, it is discarded, if it can't be executed here
+	if command == "EXEC" && c.transactionController.InTransaction(conn) {
+		redirect := c.redirectKeys(c.queuedCommandsKeys(conn), asking)
+		if redirect != nil {
+			c.transactionController.RemoveConn(conn)
+		}
+		return redirect
+	}
+
+	keys := getCommandKeys(commandAndArgs)
+	// MIGRATE always works with keys of migrating or importing slot locally, so they can be moved freely, like in Redis
+	if command == "MIGRATE" && len(keys) > 0 {
+		route := c.clusterController.Route(cluster.KeySlot(keys[0]))
+		if route.MigratingAddr != "" || route.Importing {
+			return nil
+		}
+	}
+	return c.redirectKeys(keys, asking)
+}
+
+// Slot owned by other node is redirected by -MOVED, keys of migrating slot, that are already moved, are redirected by -ASK
+// Importing node serves slot only to client, that is redirected by -ASK and sent ASKING
+func (c *controller) redirectKeys(keys []string, asking bool) resp.Value {
+	if len(keys) == 0 {
+		return nil
+	}
+	slot := cluster.KeySlot(keys[0])
+	for _, key := range keys[1:] {
+		if cluster.KeySlot(key) != slot {
+			return resp.SimpleError{Value: "CROSSSLOT Keys in request don't hash to the same slot"}
+		}
+	}
+	if !c.clusterController.IsUp() {
+		return resp.SimpleError{Value: "CLUSTERDOWN The cluster is down"}
+	}
+
+	route := c.clusterController.Route(slot)
+	switch {
+	case route.OwnerID == "":
+		return resp.SimpleError{Value: "CLUSTERDOWN Hash slot not served"}
+	case !route.Myself:
+		if !route.Importing || !asking {
+			return resp.SimpleError{Value: fmt.Sprintf("MOVED %d %s", slot, route.OwnerAddr)}
+		}
+		// Some keys can still be on the source node
+		if len(keys) > 1 && c.countMissingKeys(keys) > 0 {
+			return resp.SimpleError{Value: "TRYAGAIN Multiple keys request during rehashing of slot"}
+		}
+	case route.MigratingAddr != "":
+		missing := c.countMissingKeys(keys)
+		if missing == 0 {
+			return nil
+		}
+		if missing < len(keys) {
+			return resp.SimpleError{Value: "TRYAGAIN Multiple keys request during rehashing of slot"}
+		}
+		return resp.SimpleError{Value: fmt.Sprintf("ASK %d %s", slot, route.MigratingAddr)}
+	}
+	return nil
+}
+
+func (c *controller) queuedCommandsKeys(conn net.Conn) []string {
+	commands, err := c.transactionController.GetQueue(conn)
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0)
+	for _, command := range commands {
+		array, ok := command.(resp.Array)
+		if !ok || len(array.Value) == 0 {
+			continue
+		}
+		commandAndArgs, err := ExtractCommandAndArgs(array.Value)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, getCommandKeys(commandAndArgs)...)
+	}
+	return keys
+}
+
+func (c *controller) countMissingKeys(keys []string) int {
+	missing := 0
+	for _, key := range keys {
+		if c.storage.Type(key) == memory.TYPE_NONE {
+			missing++
+		}
+	}
+	return missing
+}
+
+// Client, redirected by -ASK, sends ASKING before the command, so importing node serves it
+func (c *controller) asking(args []string, conn net.Conn) resp.Value {
+	if len(args) != 0 {
+		return resp.SimpleError{Value: "ASKING command doesn't have args"}
+	}
+	if !c.args.ClusterEnabled {
+		return resp.SimpleError{Value: "ERR This instance has cluster support disabled"}
+	}
+	c.clusterController.SetAsking(conn)
+	return resp.SimpleString{Value: "OK"}
+}
+
+func (c *controller) cluster(args []string) resp.Value {
+	if !c.args.ClusterEnabled {
+		return resp.SimpleError{Value: "ERR This instance has cluster support disabled"}
+	}
+	if len(args) < 1 {
+		return resp.SimpleError{Value: "CLUSTER command must have at least 1 arg"}
+	}
+
+	secondCommand := strings.ToUpper(args[0])
+	args = args[1:]
+	switch secondCommand {
+	case "MYID":
+		id := c.clusterController.MyID()
+		return resp.BulkString{Value: &id}
+	case "INFO":
+		info := c.clusterController.Info()
+		infoStr := info.String()
+		return resp.BulkString{Value: &infoStr}
+	case "NODES":
+		return c.clusterNodes()
+	case "MEET":
+		return c.clusterMeet(args)
+	case "ADDSLOTS", "DELSLOTS", "ADDSLOTSRANGE", "DELSLOTSRANGE":
+		return c.clusterAddOrDelSlots(secondCommand, args)
+	case "SETSLOT":
+		return c.clusterSetSlot(args)
+	case "SLOTS":
+		return c.clusterSlots()
+	case "SHARDS":
+		return c.clusterShards()
+	case "KEYSLOT":
+		if len(args) != 1 {
+			return resp.SimpleError{Value: "CLUSTER KEYSLOT command must have 1 arg"}
+		}
+		return resp.Integer{Value: cluster.KeySlot(args[0])}
+	case "COUNTKEYSINSLOT":
+		if len(args) != 1 {
+			return resp.SimpleError{Value: "CLUSTER COUNTKEYSINSLOT command must have 1 arg"}
+		}
+		slot, err := cluster.ParseSlot(args[0])
+		if err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+		}
+		return resp.Integer{Value: c.clusterController.CountKeysInSlot(slot)}
+	case "GETKEYSINSLOT":
+		return c.clusterGetKeysInSlot(args)
+	default:
+		return resp.SimpleError{Value: fmt.Sprintf("unknown command CLUSTER '%s'", secondCommand)}
+	}
+}
+
+func (c *controller) clusterNodes() resp.Value {
+	var nodes strings.Builder
+	for _, node := range c.clusterController.Nodes() {
+		nodes.WriteString(node.String())
+		nodes.WriteString("\n")
+	}
+	nodesStr := nodes.String()
+	return resp.BulkString{Value: &nodesStr}
+}
+
+// Bus port is the client port plus 10000 by default, like in Redis
+func (c *controller) clusterMeet(args []string) resp.Value {
+	if len(args) != 2 && len(args) != 3 {
+		return resp.SimpleError{Value: "CLUSTER MEET command must have 2 or 3 args"}
+	}
+	port, err := strconv.Atoi(args[1])
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR Invalid base port specified: %s", args[1])}
+	}
+	busPort := port + cluster.CLUSTER_BUS_PORT_OFFSET
+	if len(args) == 3 {
+		busPort, err = strconv.Atoi(args[2])
+		if err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("ERR Invalid bus port specified: %s", args[2])}
+		}
+	}
+
+	if err := c.clusterController.Meet(args[0], port, busPort); err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+	}
+	return resp.SimpleString{Value: "OK"}
+}
+
+func (c *controller) clusterAddOrDelSlots(secondCommand string, args []string) resp.Value {
+	if len(args) < 1 {
+		return resp.SimpleError{Value: fmt.Sprintf("CLUSTER %s command must have at least 1 arg", secondCommand)}
+	}
+	parsedArgs := make([]int, 0, len(args))
+	for _, arg := range args {
+		slot, err := cluster.ParseSlot(arg)
+		if err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+		}
+		parsedArgs = append(parsedArgs, slot)
+	}
+
+	// Range commands have start and end slot pairs
+	ranges := make([]cluster.SlotRange, 0, len(parsedArgs))
+	if strings.HasSuffix(secondCommand, "RANGE") {
+		if len(parsedArgs)%2 != 0 {
+			return resp.SimpleError{Value: fmt.Sprintf("CLUSTER %s command must have start and end slot pairs", secondCommand)}
+		}
+		for i := 0; i < len(parsedArgs); i += 2 {
+			if parsedArgs[i] > parsedArgs[i+1] {
+				return resp.SimpleError{Value: fmt.Sprintf("ERR start slot number %d is greater than end slot number %d", parsedArgs[i], parsedArgs[i+1])}
+			}
+			ranges = append(ranges, cluster.SlotRange{Start: parsedArgs[i], End: parsedArgs[i+1]})
+		}
+	} else {
+		for _, slot := range parsedArgs {
+			ranges = append(ranges, cluster.SlotRange{Start: slot, End: slot})
+		}
+	}
+
+	slots := make([]int, 0, len(ranges))
+	seen := make(map[int]bool, len(ranges))
+	for _, slotRange := range ranges {
+		for slot := slotRange.Start; slot <= slotRange.End; slot++ {
+			if seen[slot] {
+				return resp.SimpleError{Value: fmt.Sprintf("ERR Slot %d specified multiple times", slot)}
+			}
+			seen[slot] = true
+			slots = append(slots, slot)
+		}
+	}
+
+	var err error
+	if strings.HasPrefix(secondCommand, "ADDSLOTS") {
+		err = c.clusterController.AddSlots(slots)
+	} else {
+		err = c.clusterController.DelSlots(slots)
+	}
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+	}
+	return resp.SimpleString{Value: "OK"}
+}
+
+// CLUSTER SETSLOT <slot> MIGRATING|IMPORTING|NODE <node-id> or CLUSTER SETSLOT <slot> STABLE
+func (c *controller) clusterSetSlot(args []string) resp.Value {
+	if len(args) < 2 {
+		return resp.SimpleError{Value: "CLUSTER SETSLOT command must have at least 2 args"}
+	}
+	slot, err := cluster.ParseSlot(args[0])
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+	}
+
+	state := strings.ToUpper(args[1])
+	nodeID := ""
+	switch {
+	case state == "STABLE" && len(args) == 2:
+	case (state == "MIGRATING" || state == "IMPORTING" || state == "NODE") && len(args) == 3:
+		nodeID = args[2]
+	default:
+		return resp.SimpleError{Value: "ERR Invalid CLUSTER SETSLOT action or number of arguments"}
+	}
+
+	if err := c.clusterController.SetSlot(slot, state, nodeID); err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+	}
+	return resp.SimpleString{Value: "OK"}
+}
+
+// Every slot range is replied with its node: [start, end, [host, port, id]], ranges are sorted
+func (c *controller) clusterSlots() resp.Value {
+	type nodeSlotRange struct {
+		slotRange cluster.SlotRange
+		node      cluster.NodeInfo
+	}
+	ranges := make([]nodeSlotRange, 0)
+	for _, node := range c.clusterController.Nodes() {
+		for _, slotRange := range node.Slots {
+			ranges = append(ranges, nodeSlotRange{slotRange: slotRange, node: node})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].slotRange.Start < ranges[j].slotRange.Start
+	})
+
+	result := make([]resp.Value, 0, len(ranges))
+	for _, r := range ranges {
+		host, id := r.node.Host, r.node.ID
+		result = append(result, resp.Array{Value: []resp.Value{
+			resp.Integer{Value: r.slotRange.Start},
+			resp.Integer{Value: r.slotRange.End},
+			resp.Array{Value: []resp.Value{
+				resp.BulkString{Value: &host},
+				resp.Integer{Value: r.node.Port},
+				resp.BulkString{Value: &id},
+			}},
+		}})
+	}
+	return resp.Array{Value: result}
+}
+
+// Every master is a shard, it is described by slots and nodes field-value pairs, like in Redis
+func (c *controller) clusterShards() resp.Value {
+	shards := make([]resp.Value, 0)
+	for _, node := range c.clusterController.Nodes() {
+		if node.HasFlag(cluster.NODE_FLAG_HANDSHAKE) {
+			continue
+		}
+
+		slots := make([]resp.Value, 0, 2*len(node.Slots))
+		for _, slotRange := range node.Slots {
+			slots = append(slots, resp.Integer{Value: slotRange.Start}, resp.Integer{Value: slotRange.End})
+		}
+		health := "online"
+		if node.HasFlag(cluster.NODE_FLAG_FAIL) {
+			health = "failed"
+		}
+		nodeFields := resp.CreateBulkStringArray("id", node.ID, "port")
+		nodeFields.Value = append(nodeFields.Value, resp.Integer{Value: node.Port})
+		nodeFields.Value = append(nodeFields.Value, resp.CreateBulkStringArray("ip", node.Host, "endpoint", node.Host, "role", "master", "health", health).Value...)
+
+		slotsField, nodesField := "slots", "nodes"
+		shards = append(shards, resp.Array{Value: []resp.Value{
+			resp.BulkString{Value: &slotsField},
+			resp.Array{Value: slots},
+			resp.BulkString{Value: &nodesField},
+			resp.Array{Value: []resp.Value{nodeFields}},
+		}})
+	}
+	return resp.Array{Value: shards}
+}
+
+func (c *controller) clusterGetKeysInSlot(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.SimpleError{Value: "CLUSTER GETKEYSINSLOT command must have 2 args"}
+	}
+	slot, err := cluster.ParseSlot(args[0])
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+	}
+	count, err := strconv.Atoi(args[1])
+	if err != nil || count < 0 {
+		return resp.SimpleError{Value: "ERR Invalid number of keys"}
+	}
+	return resp.CreateBulkStringArray(c.clusterController.GetKeysInSlot(slot, count)...)
+}
@@ -5,20 +5,37 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/client"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
 )
 
 func (c *controller) ping(conn net.Conn) resp.Value {
 	subscribeModePong := "pong"
 	subscribeModeEmptyStr := ""
 
-	if c.pubsubController.InSubscribeMode(conn) {
+	// RESP3 client tells replies from messages by push type, so it gets usual reply
+	if c.pubsubController.InSubscribeMode(conn) && client.Protocol(conn) == resp.PROTOCOL_RESP2 {
 		return resp.Array{Value: []resp.Value{resp.BulkString{Value: &subscribeModePong}, resp.BulkString{Value: &subscribeModeEmptyStr}}}
 	}
 	return resp.SimpleString{Value: "PONG"}
 }
 
+// AUTH [username] password
+// Only default user is accepted with any password, so MIGRATE ... AUTH to rediska target succeeds
+func (*controller) auth(args []string) resp.Value {
+	if len(args) != 1 && len(args) != 2 {
+		return resp.SimpleError{Value: "AUTH command must have 1 or 2 args"}
+	}
+	if len(args) == 2 && args[0] != "default" {
+		return resp.SimpleError{Value: "WRONGPASS invalid username-password pair or user is disabled."}
+	}
+	return resp.SimpleString{Value: "OK"}
+}
+
 func (*controller) echo(args []string) resp.BulkString {
 	res := strings.Join(args, " ")
 	return resp.BulkString{Value: &res}
@@ -30,11 +47,12 @@ func (c *controller) keys(args []string) resp.Value {
 	}
 
 	pattern := args[0]
-	if pattern != "*" {
-		return resp.SimpleError{Value: "KEYS command error: only '*' pattern supported"}
+	keys := make([]string, 0)
+	for _, key := range c.storage.Keys() {
+		if utils.MatchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
 	}
-
-	keys := c.storage.Keys()
 	return resp.CreateBulkStringArray(keys...)
 }
 
@@ -47,6 +65,25 @@ func (c *controller) del(args, commandAndArgs []string) resp.Value {
 	return resp.SimpleString{Value: "OK"}
 }
 
+func (c *controller) pexpireat(args, commandAndArgs []string) resp.Value {
+	if len(args) != 2 {
+		return resp.SimpleError{Value: "PEXPIREAT command must have 2 args"}
+	}
+
+	key := args[0]
+	timestampMS, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return resp.SimpleError{Value: "ERR value is not an integer or out of range"}
+	}
+
+	if !c.storage.Expire(key, time.UnixMilli(timestampMS)) {
+		return resp.Integer{Value: 0}
+	}
+
+	c.propagateWriteCommand(commandAndArgs)
+	return resp.Integer{Value: 1}
+}
+
 func (c *controller) configGet(args []string) resp.Value {
 	if len(args) != 1 {
 		return resp.SimpleError{Value: "CONFIG GET command must have only 1 arg"}
@@ -61,19 +98,43 @@ func (c *controller) configGet(args []string) resp.Value {
 		value = append(value, strconv.Itoa(c.args.Port))
 	case "replicaof":
 		var replicaOfString string
-		if c.args.ReplicaOf != nil {
-			replicaOfString = c.args.ReplicaOf.String()
+		if replicaOf := c.roleSwitcher.ReplicaOfConfig(); replicaOf != nil {
+			replicaOfString = replicaOf.String()
 		}
 		value = append(value, replicaOfString)
 	case "dir":
 		value = append(value, c.args.DBDir)
 	case "dbfilename":
 		value = append(value, c.args.DBFilename)
+	case "appendonly":
+		value = append(value, config.FormatYesNo(c.args.AppendOnly))
+	case "appendfilename":
+		value = append(value, c.args.AppendFilename)
+	case "appendfsync":
+		value = append(value, c.args.AppendFsync)
+	case "repl-backlog-size":
+		value = append(value, strconv.Itoa(c.args.ReplBacklogSize))
+	case "repl-ping-replica-period":
+		value = append(value, strconv.Itoa(c.args.ReplPingReplicaPeriod))
+	case "replica-serve-stale-data":
+		value = append(value, config.FormatYesNo(c.args.ReplicaServeStaleData))
+	case "replica-read-only":
+		value = append(value, config.FormatYesNo(c.args.ReplicaReadOnly))
+	case "min-replicas-to-write":
+		value = append(value, strconv.Itoa(c.args.MinReplicasToWrite))
+	case "min-replicas-max-lag":
+		value = append(value, strconv.Itoa(c.args.MinReplicasMaxLag))
+	case "client-output-buffer-limit":
+		value = append(value, config.FormatClientOutputBufferLimit(c.args.ReplicaOutputBufferLimit))
+	case "save":
+		value = append(value, config.FormatSaveRules(c.args.SaveRules))
+	case "proto-max-bulk-len":
+		value = append(value, strconv.Itoa(c.args.ProtoMaxBulkLen))
 	default:
 		return resp.SimpleError{Value: fmt.Sprintf("CONFIG GET command unknown arg: %s", arg)}
 	}
 
-	return resp.CreateBulkStringArray(value...)
+	return resp.CreateBulkStringMap(value...)
 }
 
 func (c *controller) valuetype(args []string) resp.Value {
@@ -2,9 +2,11 @@ package commands
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
+	"github.com/codecrafters-io/redis-starter-go/app/client"
 	"github.com/codecrafters-io/redis-starter-go/app/geo"
 	"github.com/codecrafters-io/redis-starter-go/app/memory"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
@@ -32,7 +34,7 @@ func (c *controller) geoadd(args, commandAndArgs []string) resp.Value {
 	return resp.Integer{Value: insertedCount}
 }
 
-func (c *controller) geopos(args []string) resp.Value {
+func (c *controller) geopos(args []string, conn net.Conn) resp.Value {
 	if len(args) < 2 {
 		return resp.SimpleError{Value: "GEOPOS command must have at least 2 args"}
 	}
@@ -52,6 +54,10 @@ func (c *controller) geopos(args []string) resp.Value {
 		}
 
 		location := c.geoController.Decode(uint64(*score))
+		if client.Protocol(conn) == resp.PROTOCOL_RESP3 {
+			multipleRESPResponses = append(multipleRESPResponses, resp.Array{Value: []resp.Value{resp.Double{Value: location.Longitude}, resp.Double{Value: location.Latitude}}})
+			continue
+		}
 		longitudeString := strconv.FormatFloat(location.Longitude, 'f', -1, 64)
 		latitudeString := strconv.FormatFloat(location.Latitude, 'f', -1, 64)
 		multipleRESPResponses = append(multipleRESPResponses, resp.CreateBulkStringArray(longitudeString, latitudeString))
@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/memory"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
 
+// Blocked pop checks its list with this period, like timed wait of list storage
+const BLOCKING_POP_POLL_PERIOD = 25 * time.Millisecond
+
 func (c *controller) push(commandAndArgs []string) resp.Value {
 	commandName := strings.ToUpper(commandAndArgs[0])
 	args := commandAndArgs[1:]
@@ -71,6 +75,8 @@ func (c *controller) pop(commandAndArgs []string) resp.Value {
 	}
 }
 
+// Pop and its propagation are done under write lock, like other writes, so replica gets pop after the push, that filled the list
+// List is checked again after poll period until timeout, the lock isn't held while the command waits
 func (c *controller) bpop(commandAndArgs []string) resp.Value {
 	commandName := strings.ToUpper(commandAndArgs[0])
 	args := commandAndArgs[1:]
@@ -88,19 +94,34 @@ func (c *controller) bpop(commandAndArgs []string) resp.Value {
 		return resp.SimpleError{Value: fmt.Sprintf("%s command timeout (S) argument parseFloat error: %v", commandName, err)}
 	}
 
+	deadline := time.Now().Add(time.Duration(timeoutS * float64(time.Second)))
+	for {
+		if poppedValue := c.popAndPropagate(commandName, key); poppedValue != nil {
+			return resp.CreateBulkStringArray(key, *poppedValue)
+		}
+		if timeoutS != 0 && !time.Now().Before(deadline) {
+			return resp.Array{Value: nil}
+		}
+		time.Sleep(BLOCKING_POP_POLL_PERIOD)
+	}
+}
+
+// Blocking pop is propagated as usual pop, so it never blocks on replay
+func (c *controller) popAndPropagate(commandName, key string) *string {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+
+	// Negative timeout doesn't wait
 	var poppedValue *string
 	if commandName == "BRPOP" {
-		poppedValue = c.storage.ListStorage().Brpop(key, timeoutS)
+		poppedValue = c.storage.ListStorage().Brpop(key, -1)
 	} else {
-		poppedValue = c.storage.ListStorage().Blpop(key, timeoutS)
+		poppedValue = c.storage.ListStorage().Blpop(key, -1)
 	}
-
-	c.propagateWriteCommand(commandAndArgs)
-
-	if poppedValue == nil {
-		return resp.Array{Value: nil}
+	if poppedValue != nil {
+		c.propagateWriteCommand([]string{strings.TrimPrefix(commandName, "B"), key})
 	}
-	return resp.CreateBulkStringArray(key, *poppedValue)
+	return poppedValue
 }
 
 func (c *controller) lrange(args []string) resp.Value {
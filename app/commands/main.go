@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 
+	"github.com/codecrafters-io/redis-starter-go/app/client"
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
 	"github.com/codecrafters-io/redis-starter-go/app/config"
 	"github.com/codecrafters-io/redis-starter-go/app/geo"
 	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/migration"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/aof"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
 	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
 	"github.com/codecrafters-io/redis-starter-go/app/replication"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
@@ -17,15 +23,42 @@ import (
 
 type Controller interface {
 	HandleCommand(cmd resp.Value, conn net.Conn, writeResponseToConn bool) (resp.Value, error)
+	// Applies command from master and forwards its exact bytes to sub-replicas
+	HandleMasterCommand(cmd resp.Value, raw []byte, conn net.Conn)
+	// Swaps server role, it waits for write commands in progress, so each of them is propagated by one role
+	SetReplicationController(replicationController replication.BaseController)
+	// Deletes expired keys between write commands, their DEL is propagated
+	CleanExpiredKeys()
+	// Deletes random expired keys, like CleanExpiredKeys, but it is cheap enough to run often
+	ActiveExpireCycle()
+}
+
+// Server changes its role by REPLICAOF command
+type RoleSwitcher interface {
+	// Returns false, if server is already replica of this master
+	ReplicaOf(host string, port int) bool
+	ReplicaOfNoOne()
+	// Master of replica or nil, it is read under the same lock, that REPLICAOF changes it with
+	ReplicaOfConfig() *config.ReplicaOfConfig
 }
 
 type controller struct {
 	args                  *config.Args
 	storage               memory.MultiTypeStorage
 	replicationController replication.BaseController
+	replicationMut        sync.RWMutex
+	roleSwitcher          RoleSwitcher
 	pubsubController      pubsub.Controller
 	transactionController transaction.Controller
 	geoController         geo.Controller
+	rdbController         rdb.Controller
+	aofController         aof.Controller
+	clusterController     cluster.Controller
+	migrationController   migration.Controller
+	// Write commands hold it exclusively, so storage changes are propagated in the order they are made
+	// and full resync snapshot is taken between them
+	// Deletion of expired keys holds read lock, so its DEL isn't reordered with write of the same key
+	writeMut sync.RWMutex
 }
 
 func NewController(
@@ -35,39 +68,102 @@ func NewController(
 	pubsubController pubsub.Controller,
 	transactionController transaction.Controller,
 	geoController geo.Controller,
+	rdbController rdb.Controller,
+	aofController aof.Controller,
+	clusterController cluster.Controller,
+	migrationController migration.Controller,
+	roleSwitcher RoleSwitcher,
 ) Controller {
-	return &controller{
+	c := &controller{
 		args:                  args,
 		storage:               storage,
 		replicationController: replicationController,
 		pubsubController:      pubsubController,
 		transactionController: transactionController,
 		geoController:         geoController,
+		rdbController:         rdbController,
+		aofController:         aofController,
+		clusterController:     clusterController,
+		migrationController:   migrationController,
+		roleSwitcher:          roleSwitcher,
+	}
+	c.setExpiredKeysDeletion(replicationController)
+	return c
+}
+
+func (c *controller) SetReplicationController(replicationController replication.BaseController) {
+	c.writeMut.Lock()
+	c.replicationMut.Lock()
+	c.replicationController = replicationController
+	c.replicationMut.Unlock()
+	c.writeMut.Unlock()
+
+	c.setExpiredKeysDeletion(replicationController)
+	// Promoted replica deletes keys, that expired while it waited for master DEL
+	c.CleanExpiredKeys()
+}
+
+func (c *controller) CleanExpiredKeys() {
+	c.writeMut.RLock()
+	defer c.writeMut.RUnlock()
+
+	c.storage.CleanExpiredKeys()
+}
+
+func (c *controller) ActiveExpireCycle() {
+	c.writeMut.RLock()
+	defer c.writeMut.RUnlock()
+
+	c.storage.ActiveExpireCycle()
+}
+
+// Only master deletes expired keys, replica waits for DEL from master, so they never disagree
+func (c *controller) setExpiredKeysDeletion(replicationController replication.BaseController) {
+	if _, ok := replicationController.(replication.ReplicaController); ok {
+		c.storage.SetExpiredKeysDeletion(false, nil)
+		return
 	}
+	c.storage.SetExpiredKeysDeletion(true, c.propagateExpiredKey)
+}
+
+func (c *controller) replication() replication.BaseController {
+	c.replicationMut.RLock()
+	defer c.replicationMut.RUnlock()
+	return c.replicationController
 }
 
 func (c *controller) HandleCommand(cmd resp.Value, conn net.Conn, writeResponseToConn bool) (resp.Value, error) {
-	result := c.handleCommand(cmd, conn)
+	var result resp.Value
+	// Only clients get replies, commands in transaction are checked, when they are queued
+	if writeResponseToConn {
+		result = c.rejectClientCommand(cmd, conn)
+	}
+	if result == nil {
+		result = c.handleCommand(cmd, conn)
+	}
 	if writeResponseToConn && result != nil {
 		err := utils.WriteCommand(result, conn)
 		if err != nil {
 			return nil, err
 		}
 	}
-	c.updateMasterReplOffset(cmd, conn)
 	return result, nil
 }
 
-func (c *controller) updateMasterReplOffset(cmd resp.Value, conn net.Conn) error {
-	if r, ok := c.replicationController.(replication.ReplicaController); ok && r.GetMasterConn() == conn {
-		b, err := cmd.Encode()
-		if err != nil {
-			return err
-		}
-		l := len(b)
-		c.replicationController.IncrMasterReplOffset(l)
+// Command is applied and forwarded under write lock as a whole, so full resync snapshot of sub-replica matches replication offset
+func (c *controller) HandleMasterCommand(cmd resp.Value, raw []byte, conn net.Conn) {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+
+	c.handleCommand(cmd, conn)
+	if r, ok := c.replication().(replication.ReplicaController); ok {
+		r.Forward(raw)
 	}
-	return nil
+}
+
+func (c *controller) isMasterConn(conn net.Conn) bool {
+	r, ok := c.replication().(replication.ReplicaController)
+	return ok && r.GetMasterConn() == conn
 }
 
 func (c *controller) handleCommand(cmd resp.Value, conn net.Conn) resp.Value {
@@ -86,7 +182,7 @@ func (c *controller) handleArrayCommand(cmd resp.Array, conn net.Conn) resp.Valu
 		return resp.SimpleError{Value: "empty RESP command array"}
 	}
 
-	commandAndArgs, err := extractCommandAndArgs(cmd.Value)
+	commandAndArgs, err := ExtractCommandAndArgs(cmd.Value)
 	if err != nil {
 		return resp.SimpleError{Value: fmt.Sprintf("extract command and args from RESP array command error: %v", err)}
 	}
@@ -102,15 +198,45 @@ func (c *controller) handleArrayCommand(cmd resp.Array, conn net.Conn) resp.Valu
 		return resp.SimpleString{Value: "QUEUED"}
 	}
 
-	if c.pubsubController.InSubscribeMode(conn) && !c.pubsubController.IsSubscribeModeCommand(command) {
+	// RESP3 client gets messages as push frames, so it can run any command in subscribe mode
+	if c.pubsubController.InSubscribeMode(conn) && client.Protocol(conn) == resp.PROTOCOL_RESP2 && !c.pubsubController.IsSubscribeModeCommand(command) {
 		return resp.SimpleError{
 			Value: fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", strings.ToLower(command)),
 		}
 	}
 
+	// Storage change and its propagation must be atomic, otherwise the change can be lost or duplicated on full resync
+	// or two clients can change storage in one order and propagate changes in the other one
+	flags := commandTable[strings.ToUpper(command)]
+	// Commands from master already hold it
+	if flags.write && !flags.blocking && !c.isMasterConn(conn) {
+		c.writeMut.Lock()
+		defer c.writeMut.Unlock()
+	}
+	// Read of expired key deletes it and propagates DEL
+	if !flags.write && !flags.blocking && flags.hasKeys() {
+		c.writeMut.RLock()
+		defer c.writeMut.RUnlock()
+	}
+	if flags.write {
+		defer c.recordClientWrite(conn)
+	}
+	// Client gets replies to the previous commands of pipeline, while the command waits, like blocked client in Redis
+	if cc, ok := conn.(*client.Conn); ok && flags.blocking {
+		if err := cc.Flush(); err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("ERR reply write error: %v", err)}
+		}
+	}
+
 	switch strings.ToUpper(command) {
 	case "PING":
 		return c.ping(conn)
+	case "HELLO":
+		return c.hello(args, conn)
+	case "AUTH":
+		return c.auth(args)
+	case "CLIENT":
+		return c.client(args, conn)
 	case "ECHO":
 		return c.echo(args)
 	case "GET":
@@ -120,6 +246,9 @@ func (c *controller) handleArrayCommand(cmd resp.Array, conn net.Conn) resp.Valu
 	case "SET":
 		return c.set(args, commandAndArgs)
 	case "CONFIG":
+		if len(args) == 0 {
+			return resp.SimpleError{Value: "ERR wrong number of arguments for 'config' command"}
+		}
 		secondCommand := strings.ToUpper(args[0])
 		if secondCommand == "GET" {
 			return c.configGet(args[1:])
@@ -133,8 +262,10 @@ func (c *controller) handleArrayCommand(cmd resp.Array, conn net.Conn) resp.Valu
 		return c.replconf(args, conn)
 	case "PSYNC":
 		return c.psync(args, conn)
+	case "REPLICAOF", "SLAVEOF":
+		return c.replicaof(args)
 	case "WAIT":
-		return c.wait(args)
+		return c.wait(args, conn)
 	case "DEL":
 		return c.del(args, commandAndArgs)
 	case "RPUSH", "LPUSH":
@@ -147,14 +278,18 @@ func (c *controller) handleArrayCommand(cmd resp.Array, conn net.Conn) resp.Valu
 		return c.lrange(args)
 	case "LLEN":
 		return c.llen(args)
+	case "PEXPIREAT":
+		return c.pexpireat(args, commandAndArgs)
 	case "TYPE":
 		return c.valuetype(args)
 	case "XADD":
 		return c.xadd(args, commandAndArgs)
 	case "XRANGE":
 		return c.xrange(args)
+	case "XSETID":
+		return c.xsetid(args, commandAndArgs)
 	case "XREAD":
-		return c.xread(args)
+		return c.xread(args, conn)
 	case "SUBSCRIBE", "UNSUBSCRIBE":
 		return c.subcribeOrUnsubscribe(commandAndArgs, conn)
 	case "PUBLISH":
@@ -176,15 +311,33 @@ func (c *controller) handleArrayCommand(cmd resp.Array, conn net.Conn) resp.Valu
 	case "ZCARD":
 		return c.zcard(args)
 	case "ZSCORE":
-		return c.zscore(args)
+		return c.zscore(args, conn)
 	case "GEOADD":
 		return c.geoadd(args, commandAndArgs)
 	case "GEOPOS":
-		return c.geopos(args)
+		return c.geopos(args, conn)
 	case "GEODIST":
 		return c.geodist(args)
 	case "GEOSEARCH":
 		return c.geosearch(args)
+	case "SAVE":
+		return c.save(args)
+	case "BGSAVE":
+		return c.bgsave(args)
+	case "LASTSAVE":
+		return c.lastsave(args)
+	case "EXPORT":
+		return c.export(args)
+	case "CLUSTER":
+		return c.cluster(args)
+	case "ASKING":
+		return c.asking(args, conn)
+	case "DUMP":
+		return c.dump(args)
+	case "RESTORE", "RESTORE-ASKING":
+		return c.restore(args)
+	case "MIGRATE":
+		return c.migrate(args)
 	default:
 		return resp.SimpleError{Value: fmt.Sprintf("unknown command '%s'", command)}
 	}
@@ -199,9 +352,24 @@ func (c *controller) handleSimpleStringCommand(cmd resp.SimpleString, conn net.C
 	}
 }
 
+// Offset is saved after the command is propagated, it can include concurrent writes of other clients, then WAIT waits for them too
+func (c *controller) recordClientWrite(conn net.Conn) {
+	if m, ok := c.replication().(replication.MasterController); ok {
+		m.RecordClientWrite(utils.GetRemoteAddr(conn))
+	}
+}
+
+// Expired key deletion is written to AOF and replicas as DEL, like in Redis
+func (c *controller) propagateExpiredKey(key string) {
+	c.propagateWriteCommand([]string{"DEL", key})
+}
+
+// Write command is counted for RDB save rules, appended to AOF (both on master and replica) and propagated to replicas
+// Command must be deterministic to be replayed, e.g. relative expiration must be converted to absolute one
 func (c *controller) propagateWriteCommand(commandAndArgs []string) {
-	if m, ok := c.replicationController.(replication.MasterController); ok {
-		m.SetHasPendingWrites(true)
-		go m.Propagate(commandAndArgs)
+	c.rdbController.IncrDirty()
+	c.aofController.Append(commandAndArgs)
+	if m, ok := c.replication().(replication.MasterController); ok {
+		m.Propagate(commandAndArgs)
 	}
 }
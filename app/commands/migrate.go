@@ -0,0 +1,261 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Timeout of MIGRATE, when it isn't positive, like in Redis
+const MIGRATE_DEFAULT_TIMEOUT = time.Second
+
+type migrateArgs struct {
+	addr    string
+	keys    []string
+	timeout time.Duration
+	copy    bool
+	replace bool
+	// Nil, if AUTH isn't sent to target
+	password *string
+}
+
+func (c *controller) dump(args []string) resp.Value {
+	if len(args) != 1 {
+		return resp.SimpleError{Value: "DUMP command must have only 1 arg"}
+	}
+
+	key := args[0]
+	if c.storage.Type(key) == memory.TYPE_NONE {
+		return resp.BulkString{Value: nil}
+	}
+	payload, err := rdb.EncodeDump(c.storage.Snapshot(key), key)
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+	}
+
+	payloadStr := string(payload)
+	return resp.BulkString{Value: &payloadStr}
+}
+
+// RESTORE key ttl payload [REPLACE] [ABSTTL]
+// It is propagated with absolute expiration and REPLACE, so replica drops its expired key, that isn't deleted yet
+func (c *controller) restore(args []string) resp.Value {
+	if len(args) < 3 {
+		return resp.SimpleError{Value: "RESTORE command must have at least 3 args"}
+	}
+
+	key, payload := args[0], args[2]
+	var replace, absTTL bool
+	for _, arg := range args[3:] {
+		switch strings.ToUpper(arg) {
+		case "REPLACE":
+			replace = true
+		case "ABSTTL":
+			absTTL = true
+		default:
+			return resp.SimpleError{Value: "ERR syntax error"}
+		}
+	}
+
+	ttl, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return resp.SimpleError{Value: "ERR value is not an integer or out of range"}
+	}
+	if ttl < 0 {
+		return resp.SimpleError{Value: "ERR Invalid TTL value, must be >= 0"}
+	}
+	var expires time.Time
+	if ttl > 0 && absTTL {
+		expires = time.UnixMilli(ttl)
+	} else if ttl > 0 {
+		expires = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+
+	if !replace && c.storage.Type(key) != memory.TYPE_NONE {
+		return resp.SimpleError{Value: "BUSYKEY Target key name already exists."}
+	}
+
+	snapshot, err := rdb.DecodeDump(key, []byte(payload), expires)
+	if errors.Is(err, rdb.ErrWrongDumpFooter) {
+		return resp.SimpleError{Value: "ERR DUMP payload version or checksum are wrong"}
+	}
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR Bad data format: %v", err)}
+	}
+
+	c.storage.Del(key)
+	if err := c.storage.Restore(snapshot); err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR Bad data format: %v", err)}
+	}
+
+	var expiresMS int64
+	if !expires.IsZero() {
+		expiresMS = expires.UnixMilli()
+	}
+	c.propagateWriteCommand([]string{"RESTORE", key, strconv.FormatInt(expiresMS, 10), payload, "REPLACE", "ABSTTL"})
+	return resp.SimpleString{Value: "OK"}
+}
+
+// Keys are sent to target by RESTORE and are deleted here only after target replies OK to them, unless COPY is set
+// Other clients can't change keys, until target replies, so the deleted keys are the same, that target has
+func (c *controller) migrate(args []string) resp.Value {
+	if len(args) < 5 {
+		return resp.SimpleError{Value: "MIGRATE command must have at least 5 args"}
+	}
+	m, err := parseMigrateArgs(args)
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+	}
+
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+
+	// Target imports slot of keys in cluster mode, so it serves RESTORE-ASKING, like command after ASKING
+	restoreCommand := "RESTORE"
+	if c.args.ClusterEnabled {
+		restoreCommand = "RESTORE-ASKING"
+	}
+
+	commands := make([][]string, 0, len(m.keys)+1)
+	if m.password != nil {
+		commands = append(commands, []string{"AUTH", *m.password})
+	}
+	keys := make([]string, 0, len(m.keys))
+	for _, key := range m.keys {
+		if c.storage.Type(key) == memory.TYPE_NONE {
+			continue
+		}
+		snapshot := c.storage.Snapshot(key)
+		payload, err := rdb.EncodeDump(snapshot, key)
+		if err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}
+		}
+
+		var ttl int64
+		if expires := snapshotExpires(snapshot, key); !expires.IsZero() {
+			// Key, that expires right now, is still sent with the least TTL, as 0 means no expiration
+			ttl = max(1, time.Until(expires).Milliseconds())
+		}
+		command := []string{restoreCommand, key, strconv.FormatInt(ttl, 10), string(payload)}
+		if m.replace {
+			command = append(command, "REPLACE")
+		}
+		commands = append(commands, command)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return resp.SimpleString{Value: "NOKEY"}
+	}
+
+	replies, err := c.migrationController.Request(m.addr, m.timeout, commands)
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("IOERR %v", err)}
+	}
+	if m.password != nil {
+		if targetErr, ok := replies[0].(resp.SimpleError); ok {
+			return resp.SimpleError{Value: fmt.Sprintf("ERR Target instance replied with error: %s", targetErr.Value)}
+		}
+		replies = replies[1:]
+	}
+
+	var result resp.Value = resp.SimpleString{Value: "OK"}
+	migrated := make([]string, 0, len(keys))
+	for i, reply := range replies {
+		if targetErr, ok := reply.(resp.SimpleError); ok {
+			// The first error is returned, keys, that target accepted, are still deleted
+			if _, ok := result.(resp.SimpleString); ok {
+				result = resp.SimpleError{Value: fmt.Sprintf("ERR Target instance replied with error: %s", targetErr.Value)}
+			}
+			continue
+		}
+		migrated = append(migrated, keys[i])
+	}
+
+	if !m.copy && len(migrated) > 0 {
+		for _, key := range migrated {
+			c.storage.Del(key)
+		}
+		c.propagateWriteCommand(append([]string{"DEL"}, migrated...))
+	}
+	return result
+}
+
+// MIGRATE host port key|"" db timeout [COPY] [REPLACE] [AUTH password] [KEYS key ...]
+// Only database 0 exists, so other database can't be selected on target
+func parseMigrateArgs(args []string) (*migrateArgs, error) {
+	if len(args) < 5 {
+		return nil, fmt.Errorf("wrong number of arguments for 'migrate' command")
+	}
+
+	port, err := strconv.Atoi(args[1])
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("Invalid port: %s", args[1])
+	}
+	db, err := strconv.Atoi(args[3])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	if db != 0 {
+		return nil, fmt.Errorf("DB index is out of range")
+	}
+	timeoutMS, err := strconv.Atoi(args[4])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+
+	m := &migrateArgs{
+		addr:    net.JoinHostPort(args[0], args[1]),
+		keys:    []string{args[2]},
+		timeout: time.Duration(timeoutMS) * time.Millisecond,
+	}
+	if timeoutMS <= 0 {
+		m.timeout = MIGRATE_DEFAULT_TIMEOUT
+	}
+
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COPY":
+			m.copy = true
+		case "REPLACE":
+			m.replace = true
+		case "AUTH":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("syntax error")
+			}
+			i++
+			m.password = &args[i]
+		case "KEYS":
+			if args[2] != "" {
+				return nil, fmt.Errorf("When using MIGRATE KEYS option, the key argument must be set to the empty string")
+			}
+			m.keys = args[i+1:]
+			i = len(args)
+		default:
+			return nil, fmt.Errorf("syntax error")
+		}
+	}
+	return m, nil
+}
+
+// Keys of MIGRATE depend on KEYS option, so they are found by its args parsing, like getkeys_proc in Redis
+func migrateKeys(commandAndArgs []string) []string {
+	m, err := parseMigrateArgs(commandAndArgs[1:])
+	if err != nil {
+		return nil
+	}
+	return m.keys
+}
+
+func snapshotExpires(snapshot *memory.Snapshot, key string) time.Time {
+	if item, ok := snapshot.Strings[key]; ok {
+		return item.Expires
+	}
+	return snapshot.Expires[key]
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/export"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func (c *controller) save(args []string) resp.Value {
+	if len(args) != 0 {
+		return resp.SimpleError{Value: "SAVE command doesn't have args"}
+	}
+
+	if err := c.rdbController.Save(); err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %s", err)}
+	}
+	return resp.SimpleString{Value: "OK"}
+}
+
+func (c *controller) bgsave(args []string) resp.Value {
+	if len(args) != 0 {
+		return resp.SimpleError{Value: "BGSAVE command doesn't have args"}
+	}
+
+	if err := c.rdbController.BgSave(); err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %s", err)}
+	}
+	return resp.SimpleString{Value: "Background saving started"}
+}
+
+func (c *controller) lastsave(args []string) resp.Value {
+	if len(args) != 0 {
+		return resp.SimpleError{Value: "LASTSAVE command doesn't have args"}
+	}
+
+	return resp.Integer{Value: int(c.rdbController.LastSave().Unix())}
+}
+
+func (c *controller) persistenceInfo() string {
+	aofEnabled := 0
+	if c.args.AppendOnly {
+		aofEnabled = 1
+	}
+	return c.rdbController.Info().String() + fmt.Sprintf("aof_enabled:%d\r\n", aofEnabled)
+}
+
+// Replies with RESP command stream, that recreates matching keys, e.g. for redis-cli --pipe
+func (c *controller) export(args []string) resp.Value {
+	var filter export.Filter
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return resp.SimpleError{Value: "ERR syntax error"}
+		}
+
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			filter.Pattern = args[i+1]
+		case "TYPE":
+			filter.Type = strings.ToLower(args[i+1])
+		default:
+			return resp.SimpleError{Value: fmt.Sprintf("EXPORT command unknown arg: %s", args[i])}
+		}
+	}
+
+	b, err := export.Encode(export.Commands(c.storage.Snapshot(), filter))
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %s", err)}
+	}
+
+	exported := string(b)
+	return resp.BulkString{Value: &exported}
+}
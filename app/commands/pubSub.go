@@ -5,8 +5,10 @@ import (
 	"net"
 	"strings"
 
+	"github.com/codecrafters-io/redis-starter-go/app/client"
 	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
 )
 
 func (c *controller) subcribeOrUnsubscribe(commandAndArgs []string, conn net.Conn) resp.Value {
@@ -27,6 +29,18 @@ func (c *controller) subcribeOrUnsubscribe(commandAndArgs []string, conn net.Con
 		return pubsub.CreateRESPChannelAndLenResponse(strings.ToLower(commandName), gotResponses[0])
 	}
 
+	// RESP3 client gets push frame per channel, like in Redis, as it can't find push frames inside array reply
+	if client.Protocol(conn) == resp.PROTOCOL_RESP3 {
+		lastResponse := len(gotResponses) - 1
+		for _, gotResponse := range gotResponses[:lastResponse] {
+			err := utils.WriteCommand(pubsub.CreateRESPChannelAndLenResponse(strings.ToLower(commandName), gotResponse), conn)
+			if err != nil {
+				return resp.SimpleError{Value: fmt.Sprintf("%s write to client error: %v", strings.ToUpper(commandName), err)}
+			}
+		}
+		return pubsub.CreateRESPChannelAndLenResponse(strings.ToLower(commandName), gotResponses[lastResponse])
+	}
+
 	multipleRESPResponses := make([]resp.Value, 0)
 	for _, gotResponse := range gotResponses {
 		multipleRESPResponses = append(multipleRESPResponses, pubsub.CreateRESPChannelAndLenResponse(strings.ToLower(commandName), gotResponse))
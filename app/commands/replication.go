@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"log"
 	"net"
 	"strconv"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/utils"
 )
 
+// Sections are verbatim text for RESP3 client and bulk strings for RESP2 client
 func (c *controller) info(args []string) resp.Value {
 	if len(args) != 1 {
 		return resp.SimpleError{Value: "INFO command error: only 1 argument supported"}
@@ -20,13 +22,23 @@ func (c *controller) info(args []string) resp.Value {
 	section := args[0]
 	switch section {
 	case "replication":
-		replicationInfo := c.replicationController.Info().String()
-		return resp.BulkString{Value: &replicationInfo}
+		replicationInfo := c.replication().Info().String()
+		return resp.VerbatimString{Format: resp.VERBATIM_FORMAT_TEXT, Value: replicationInfo}
+	case "persistence":
+		persistenceInfo := c.persistenceInfo()
+		return resp.VerbatimString{Format: resp.VERBATIM_FORMAT_TEXT, Value: persistenceInfo}
+	case "cluster":
+		clusterInfo := "cluster_enabled:0\r\n"
+		if c.args.ClusterEnabled {
+			clusterInfo = "cluster_enabled:1\r\n"
+		}
+		return resp.VerbatimString{Format: resp.VERBATIM_FORMAT_TEXT, Value: clusterInfo}
 	default:
 		return resp.SimpleError{Value: fmt.Sprintf("INFO unsupported section: %s", section)}
 	}
 }
 
+// Master and replica accept sub-replicas, replica also answers GETACK of its master
 func (c *controller) replconf(args []string, conn net.Conn) resp.Value {
 	if len(args) != 2 {
 		return resp.SimpleError{Value: "REPLCONF command error: only 2 more arguments supported"}
@@ -36,52 +48,51 @@ func (c *controller) replconf(args []string, conn net.Conn) resp.Value {
 
 	secondCommand := args[0]
 	arg := args[1]
-	switch replicationController := c.replicationController.(type) {
-	case replication.MasterController:
-		switch strings.ToLower(secondCommand) {
-		case "listening-port":
-			replicationController.AddReplicaConn(conn)
-			return resp.SimpleString{Value: "OK"}
-		case "capa":
-			if arg != "psync2" {
-				return resp.SimpleError{Value: fmt.Sprintf("REPLCONF capa unsupported argument: %s", arg)}
-			}
-			return resp.SimpleString{Value: "OK"}
-		case "ack":
-			ackOffset, err := strconv.Atoi(arg)
-			if err != nil {
-				return resp.SimpleError{Value: fmt.Sprintf("REPLCONF ACK master offset atoi error: %s", secondCommand)}
-			}
-			replicationController.SendAck(addr, ackOffset)
-			return nil
-		default:
-			return resp.SimpleError{Value: fmt.Sprintf("REPLCONF master unsupported second command: %s", secondCommand)}
+	replicationController := c.replication()
+	switch strings.ToLower(secondCommand) {
+	case "listening-port":
+		listeningPort, err := strconv.Atoi(arg)
+		if err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("REPLCONF listening-port atoi error: %v", err)}
 		}
-	case replication.ReplicaController:
-		switch strings.ToUpper(secondCommand) {
-		case "GETACK":
-			if arg != "*" {
-				return resp.SimpleError{Value: fmt.Sprintf("REPLCONF GETACK replica unsupported argument: %s", arg)}
-			}
-			if replicationController.GetMasterConn() != conn {
-				return resp.SimpleError{Value: "REPLCONF GETACK * can be send only by master"}
-			}
-
-			// No syncing with propagated write commands from master
-			// Ideally, we should wait here, until replica won't have any write commands from master in processing
-			// Or we need to compare that Ack.offset >= master.MasterReplOffset and only than push Ack to ackedReplicas (wait.go)
-			response := resp.CreateBulkStringArray("REPLCONF", "ACK", strconv.Itoa(replicationController.Info().MasterReplOffset))
-			if err := utils.WriteCommand(response, conn); err != nil {
-				return resp.SimpleError{Value: fmt.Sprintf("REPLCONF GETACK * write to master error: %v", err)}
-			}
-			return nil
+		replicationController.AddReplicaConn(conn, listeningPort)
+		return resp.SimpleString{Value: "OK"}
+	case "capa":
+		if arg != "psync2" {
+			return resp.SimpleError{Value: fmt.Sprintf("REPLCONF capa unsupported argument: %s", arg)}
+		}
+		return resp.SimpleString{Value: "OK"}
+	case "ack":
+		ackOffset, err := strconv.Atoi(arg)
+		if err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("REPLCONF ACK master offset atoi error: %s", secondCommand)}
 		}
-		return resp.SimpleError{Value: fmt.Sprintf("REPLCONF isn't supported for replica: %s", secondCommand)}
+		replicationController.SendAck(addr, ackOffset)
+		return nil
+	case "getack":
+		rc, ok := replicationController.(replication.ReplicaController)
+		if !ok {
+			return resp.SimpleError{Value: "REPLCONF GETACK isn't supported for master"}
+		}
+		if arg != "*" {
+			return resp.SimpleError{Value: fmt.Sprintf("REPLCONF GETACK replica unsupported argument: %s", arg)}
+		}
+		if rc.GetMasterConn() != conn {
+			return resp.SimpleError{Value: "REPLCONF GETACK * can be send only by master"}
+		}
+
+		// Commands from master are processed in order, so all commands before GETACK are processed already
+		response := resp.CreateBulkStringArray("REPLCONF", "ACK", strconv.Itoa(rc.Info().MasterReplOffset))
+		if err := utils.WriteCommand(response, conn); err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("REPLCONF GETACK * write to master error: %v", err)}
+		}
+		return nil
 	default:
-		return resp.SimpleError{Value: fmt.Sprintf("REPLCONF command detected unknown type assertion: %T", replicationController)}
+		return resp.SimpleError{Value: fmt.Sprintf("REPLCONF unsupported second command: %s", secondCommand)}
 	}
 }
 
+// Replica serves its sub-replicas with the replication ID and offsets of its master, so they can continue with any node of the chain
 func (c *controller) psync(args []string, conn net.Conn) resp.Value {
 	if len(args) != 2 {
 		return resp.SimpleError{Value: "PSYNC command error: only 2 argument supported"}
@@ -90,34 +101,87 @@ func (c *controller) psync(args []string, conn net.Conn) resp.Value {
 	requestedReplID := args[0]
 	requestedReplOffset := args[1]
 
-	switch replicationController := c.replicationController.(type) {
-	case replication.MasterController:
-		if requestedReplID == "?" && requestedReplOffset == "-1" {
-			if !replicationController.IsReplica(conn) {
-				return resp.SimpleError{Value: "PSYNC command error: failed to send FULLRESYNC, because no such replica exists"}
-			}
-
-			response := "FULLRESYNC" + " " + replicationController.Info().MasterReplID + " " + "0"
-			if err := utils.WriteCommand(resp.SimpleString{Value: response}, conn); err != nil {
-				return resp.SimpleError{Value: fmt.Sprintf("PSYNC command error: failed to send FULLRESYNC: %v", err)}
-			}
-			replicationController.SendRDBFile(conn)
+	replicationController := c.replication()
+	if rc, ok := replicationController.(replication.ReplicaController); ok && rc.LinkState() != replication.MASTER_LINK_STATE_CONNECTED {
+		return resp.SimpleError{Value: "NOMASTERLINK Can't SYNC while not connected with my master"}
+	}
+
+	addr := utils.GetRemoteAddr(conn)
+	if !replicationController.IsReplica(conn) {
+		return resp.SimpleError{Value: "PSYNC command error: failed to send FULLRESYNC, because no such replica exists"}
+	}
+
+	psyncOffset, err := strconv.Atoi(requestedReplOffset)
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("PSYNC command replication offset atoi error: %v", err)}
+	}
+
+	// PSYNC ? -1 always leads to full resync
+	if requestedReplID != "?" {
+		continued, err := replicationController.ContinueResync(conn, requestedReplID, psyncOffset)
+		if err != nil {
+			log.Printf("Partial resync with replica %s error: %v", addr, err)
+			conn.Close()
 			return nil
 		}
-		return resp.SimpleError{Value: fmt.Sprintf("PSYNC master unsupported replication id: %s and replication offset: %s", requestedReplID, requestedReplOffset)}
-	case replication.ReplicaController:
-		return resp.SimpleError{Value: "PSYNC isn't supported for replica"}
-	default:
-		return resp.SimpleError{Value: fmt.Sprintf("PSYNC command detected unknown type assertion: %T", replicationController)}
+		if continued {
+			return nil
+		}
+		log.Printf("Partial resync with replica %s isn't possible for replication id: %s and offset: %d, full resync is started", addr, requestedReplID, psyncOffset)
+	}
+
+	c.writeMut.Lock()
+	snapshot := c.storage.Snapshot()
+	snapshotOffset := replicationController.StartFullResync(conn)
+	c.writeMut.Unlock()
+
+	response := "FULLRESYNC" + " " + replicationController.Info().MasterReplID + " " + strconv.Itoa(snapshotOffset)
+	if err := utils.WriteCommand(resp.SimpleString{Value: response}, conn); err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("PSYNC command error: failed to send FULLRESYNC: %v", err)}
+	}
+
+	// Replica connection is closed, so it is removed from replicas and can sync again
+	if err := replicationController.SendRDBFile(conn, snapshot); err != nil {
+		log.Printf("Full resync with replica %s error: %v", addr, err)
+		conn.Close()
 	}
+	return nil
 }
 
-func (c *controller) wait(args []string) resp.Value {
+// REPLICAOF NO ONE promotes replica to master, REPLICAOF host port makes server replica of the master
+func (c *controller) replicaof(args []string) resp.Value {
+	if len(args) != 2 {
+		return resp.SimpleError{Value: "REPLICAOF command error: only 2 arguments supported"}
+	}
+
+	// Cluster node is always master, replicas of cluster nodes aren't supported
+	if c.args.ClusterEnabled {
+		return resp.SimpleError{Value: "ERR REPLICAOF not allowed in cluster mode."}
+	}
+
+	host := args[0]
+	if strings.ToUpper(host) == "NO" && strings.ToUpper(args[1]) == "ONE" {
+		c.roleSwitcher.ReplicaOfNoOne()
+		return resp.SimpleString{Value: "OK"}
+	}
+
+	port, err := strconv.Atoi(args[1])
+	if err != nil || port < 0 || port > 65535 {
+		return resp.SimpleError{Value: "ERR Invalid master port"}
+	}
+	if !c.roleSwitcher.ReplicaOf(host, port) {
+		return resp.SimpleString{Value: "OK Already connected to specified master"}
+	}
+	return resp.SimpleString{Value: "OK"}
+}
+
+// Replica is counted, when it has acked the offset of the client's last write, so the write is on the replica
+func (c *controller) wait(args []string, conn net.Conn) resp.Value {
 	if len(args) != 2 {
 		return resp.SimpleError{Value: "WAIT command error: only 2 more arguments supported"}
 	}
 
-	if mc, ok := c.replicationController.(replication.MasterController); ok {
+	if mc, ok := c.replication().(replication.MasterController); ok {
 		numReplicas, err := strconv.Atoi(args[0])
 		if err != nil {
 			return resp.SimpleError{Value: fmt.Sprintf("WAIT command number of replicas atoi error: %v", err)}
@@ -126,47 +190,19 @@ func (c *controller) wait(args []string) resp.Value {
 		if err != nil {
 			return resp.SimpleError{Value: fmt.Sprintf("WAIT command timeout (MS) atoi error: %v", err)}
 		}
-
-		if numReplicas == 0 {
-			return resp.Integer{Value: 0}
-		}
-
-		replicas := mc.GetReplicas()
-		if !mc.HasPendingWrites() {
-			return resp.Integer{Value: len(replicas)}
+		if timeoutMS < 0 {
+			return resp.SimpleError{Value: "ERR timeout is negative"}
 		}
 
-		if numReplicas > len(replicas) {
-			numReplicas = len(replicas)
+		writeOffset := mc.GetClientWriteOffset(utils.GetRemoteAddr(conn))
+		acked := mc.WaitAcks(writeOffset, numReplicas, -1)
+		if acked >= numReplicas {
+			return resp.Integer{Value: acked}
 		}
 
 		mc.Propagate([]string{"REPLCONF", "GETACK", "*"})
-
-		timer := time.After(time.Millisecond * time.Duration(timeoutMS))
-		ackedReplicas := make(map[string]bool)
-
-	loop:
-		for len(ackedReplicas) < numReplicas {
-			select {
-			case <-timer:
-				break loop
-			case ack := <-mc.GetAcksCh():
-				if !ackedReplicas[ack.Addr] {
-					ackedReplicas[ack.Addr] = true
-				}
-			}
-		}
-
-		// If write commands are very slow, race condition can appear
-		// Thus, replica will send REPLCONF ACK ... faster, then replying to propagated command
-		// This will lead to a case where all ACKS are delivered but the replicas still work on propagated command
-		// Ideally, we need to wait on replica side and send REPLCONF ACK ... only when there are no more propagated write commands from master
-		// Or we need to compare that Ack.offset >= master.MasterReplOffset and only than push Ack to ackedReplicas
-		if len(ackedReplicas) == len(mc.GetReplicas()) {
-			mc.SetHasPendingWrites(false)
-		}
-
-		return resp.Integer{Value: len(ackedReplicas)}
+		acked = mc.WaitAcks(writeOffset, numReplicas, time.Millisecond*time.Duration(timeoutMS))
+		return resp.Integer{Value: acked}
 	}
 
 	return resp.SimpleError{Value: "WAIT cannot be used with replica instances"}
@@ -2,9 +2,11 @@ package commands
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
+	"github.com/codecrafters-io/redis-starter-go/app/client"
 	"github.com/codecrafters-io/redis-starter-go/app/memory"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
@@ -114,7 +116,7 @@ func (c *controller) zcard(args []string) resp.Value {
 	return resp.Integer{Value: card}
 }
 
-func (c *controller) zscore(args []string) resp.Value {
+func (c *controller) zscore(args []string, conn net.Conn) resp.Value {
 	if len(args) != 2 {
 		return resp.SimpleError{Value: "ZSCORE command must have 2 args"}
 	}
@@ -130,6 +132,9 @@ func (c *controller) zscore(args []string) resp.Value {
 		return resp.BulkString{Value: nil}
 	}
 
+	if client.Protocol(conn) == resp.PROTOCOL_RESP3 {
+		return resp.Double{Value: *score}
+	}
 	floatString := strconv.FormatFloat(*score, 'e', -1, 64)
 	return resp.BulkString{Value: &floatString}
 }
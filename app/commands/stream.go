@@ -2,8 +2,10 @@ package commands
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 
+	"github.com/codecrafters-io/redis-starter-go/app/client"
 	"github.com/codecrafters-io/redis-starter-go/app/memory"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
@@ -29,7 +31,9 @@ func (c *controller) xadd(args, commandAndArgs []string) resp.Value {
 		return resp.SimpleError{Value: fmt.Sprintf("ERR %s", err)}
 	}
 
-	c.propagateWriteCommand(commandAndArgs)
+	// Generated ID is propagated, so entry gets the same ID on replay
+	propagatedCommandAndArgs := append([]string{commandAndArgs[0], streamKey, gotStreamID}, args[2:]...)
+	c.propagateWriteCommand(propagatedCommandAndArgs)
 	return resp.BulkString{Value: &gotStreamID}
 }
 
@@ -53,7 +57,28 @@ func (c *controller) xrange(args []string) resp.Value {
 	return resp.Array{Value: getRESPEntriesWithStreamID(gotEntries)}
 }
 
-func (c *controller) xread(args []string) resp.Value {
+func (c *controller) xsetid(args, commandAndArgs []string) resp.Value {
+	if len(args) != 2 {
+		return resp.SimpleError{Value: "XSETID command must have 2 args"}
+	}
+
+	streamKey := args[0]
+	lastStreamID := args[1]
+	if c.storage.KeyExistsWithOtherType(streamKey, memory.TYPE_STREAM) {
+		return resp.SimpleError{Value: "WRONGTYPE Operation against a key holding the wrong kind of value"}
+	}
+
+	err := c.storage.StreamStorage().Xsetid(streamKey, lastStreamID)
+	if err != nil {
+		return resp.SimpleError{Value: fmt.Sprintf("ERR %s", err)}
+	}
+
+	c.propagateWriteCommand(commandAndArgs)
+	return resp.SimpleString{Value: "OK"}
+}
+
+// RESP3 client gets map of stream keys to their entries, RESP2 client gets array of key and entries pairs
+func (c *controller) xread(args []string, conn net.Conn) resp.Value {
 	if len(args) < 3 {
 		return resp.SimpleError{Value: "XREAD command must have at least 3 args"}
 	}
@@ -94,6 +119,21 @@ func (c *controller) xread(args []string) resp.Value {
 		return resp.SimpleError{Value: fmt.Sprintf("ERR %s", err)}
 	}
 
+	if len(gotEntries) == 0 {
+		return resp.Array{Value: nil}
+	}
+
+	if client.Protocol(conn) == resp.PROTOCOL_RESP3 {
+		respStreamsWithEntries := make([]resp.MapEntry, 0, len(gotEntries))
+		for _, streamWithEntry := range gotEntries {
+			respStreamsWithEntries = append(respStreamsWithEntries, resp.MapEntry{
+				Key:   resp.BulkString{Value: &streamWithEntry.StreamKey},
+				Value: resp.Array{Value: getRESPEntriesWithStreamID(streamWithEntry.EntriesWithStreamID)},
+			})
+		}
+		return resp.Map{Value: respStreamsWithEntries}
+	}
+
 	respStreamsWithEntries := make([]resp.Value, 0)
 	for _, streamWithEntry := range gotEntries {
 		respStreamWithEntries := make([]resp.Value, 2)
@@ -103,10 +143,6 @@ func (c *controller) xread(args []string) resp.Value {
 
 		respStreamsWithEntries = append(respStreamsWithEntries, resp.Array{Value: respStreamWithEntries})
 	}
-
-	if len(respStreamsWithEntries) == 0 {
-		return resp.Array{Value: nil}
-	}
 	return resp.Array{Value: respStreamsWithEntries}
 }
 
@@ -118,7 +154,7 @@ func parseEntryFields(rawFields []string) (map[string]string, error) {
 		return nil, fmt.Errorf("XADD wrong entry fields count, need even count, detected count: %d", rawEntryFieldsLen)
 	}
 
-	for i := 0; i < len(rawFields)-1; i++ {
+	for i := 0; i < len(rawFields)-1; i += 2 {
 		entryFields[rawFields[i]] = rawFields[i+1]
 	}
 	return entryFields, nil
@@ -32,6 +32,10 @@ func (c *controller) set(args, commandAndArgs []string) resp.Value {
 	if len(args) < 2 {
 		return resp.SimpleError{Value: "SET command must have at least 2 args"}
 	}
+	// Only expiration option is supported, it has a value
+	if len(args) != 2 && len(args) != 4 {
+		return resp.SimpleError{Value: "ERR syntax error"}
+	}
 
 	key := args[0]
 	value := args[1]
@@ -48,7 +52,7 @@ func (c *controller) set(args, commandAndArgs []string) resp.Value {
 			return resp.SimpleError{Value: fmt.Sprintf("SET command get expiry error: %v", err)}
 		}
 		c.storage.StringStorage().SetWithExpiry(key, value, expiry)
-		c.propagateWriteCommand(commandAndArgs)
+		c.propagateWriteCommand([]string{commandAndArgs[0], key, value, "PXAT", strconv.FormatInt(expiry.UnixMilli(), 10)})
 		return resp.SimpleString{Value: "OK"}
 	}
 
@@ -72,6 +76,7 @@ func (c *controller) incr(args []string) resp.Value {
 		return resp.SimpleError{Value: fmt.Sprintf("ERR %s", err)}
 	}
 
+	c.propagateWriteCommand([]string{"INCR", key})
 	return resp.Integer{Value: incremented}
 }
 
@@ -89,6 +94,10 @@ func getExpireTime(expireMark, expireDuration string) (time.Time, error) {
 		return time.Now().Add(time.Second * expireDurationValue), nil
 	case "PX":
 		return time.Now().Add(time.Millisecond * expireDurationValue), nil
+	case "EXAT":
+		return time.Unix(int64(atoiExpireDuration), 0), nil
+	case "PXAT":
+		return time.UnixMilli(int64(atoiExpireDuration)), nil
 	default:
 		return time.Time{}, fmt.Errorf("unknown expire mark: %s", upperCasedExpireMark)
 	}
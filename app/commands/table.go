@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+type commandFlags struct {
+	// Command can change storage, it is propagated and rejected by read-only replica or master without enough good replicas
+	write bool
+	// Command can wait for data, so it can't hold the write lock and buffered replies are written before it
+	blocking bool
+	// Replica with down link to master replies to the command, even if replica-serve-stale-data is disabled
+	stale bool
+	// Keys of command are routed to their hash slots in cluster mode
+	keys keySpec
+	// Keys are found by args parsing, when their positions depend on options, like getkeys_proc in Redis
+	getKeys func(commandAndArgs []string) []string
+}
+
+func (flags commandFlags) hasKeys() bool {
+	return flags.keys != keySpec{} || flags.getKeys != nil
+}
+
+// Positions of keys in command and args, like in Redis command table, e.g. DEL has keys from 1 to the last arg
+type keySpec struct {
+	first int
+	// Negative position is counted from the end, e.g. -2 skips timeout of BLPOP
+	last int
+	step int
+	// Keys are the first half of args after the keyword, e.g. XREAD ... STREAMS key1 key2 id1 id2
+	keyword string
+}
+
+var (
+	firstKey      = keySpec{first: 1, last: 1, step: 1}
+	allKeys       = keySpec{first: 1, last: -1, step: 1}
+	allButLastKey = keySpec{first: 1, last: -2, step: 1}
+	streamsKeys   = keySpec{keyword: "STREAMS"}
+)
+
+// Every handled command is classified, like in Redis command table
+var commandTable = map[string]commandFlags{
+	"PING":      {stale: true},
+	"HELLO":     {stale: true},
+	"AUTH":      {stale: true},
+	"CLIENT":    {stale: true},
+	"ECHO":      {},
+	"GET":       {keys: firstKey},
+	"INCR":      {write: true, keys: firstKey},
+	"SET":       {write: true, keys: firstKey},
+	"CONFIG":    {stale: true},
+	"KEYS":      {},
+	"INFO":      {stale: true},
+	"REPLCONF":  {stale: true},
+	"PSYNC":     {},
+	"REPLICAOF": {stale: true},
+	"SLAVEOF":   {stale: true},
+	"WAIT":      {blocking: true},
+	"DEL":       {write: true, keys: allKeys},
+	"RPUSH":     {write: true, keys: firstKey},
+	"LPUSH":     {write: true, keys: firstKey},
+	"RPOP":      {write: true, keys: firstKey},
+	"LPOP":      {write: true, keys: firstKey},
+	// Blocking pops are propagated as non blocking ones
+	"BRPOP":       {write: true, blocking: true, keys: allButLastKey},
+	"BLPOP":       {write: true, blocking: true, keys: allButLastKey},
+	"LRANGE":      {keys: firstKey},
+	"LLEN":        {keys: firstKey},
+	"PEXPIREAT":   {write: true, keys: firstKey},
+	"TYPE":        {keys: firstKey},
+	"XADD":        {write: true, keys: firstKey},
+	"XRANGE":      {keys: firstKey},
+	"XSETID":      {write: true, keys: firstKey},
+	"XREAD":       {blocking: true, keys: streamsKeys},
+	"SUBSCRIBE":   {stale: true},
+	"UNSUBSCRIBE": {stale: true},
+	"PUBLISH":     {stale: true},
+	"MULTI":       {},
+	"EXEC":        {},
+	"DISCARD":     {},
+	"ZADD":        {write: true, keys: firstKey},
+	"ZREM":        {write: true, keys: firstKey},
+	"ZRANK":       {keys: firstKey},
+	"ZRANGE":      {keys: firstKey},
+	"ZCARD":       {keys: firstKey},
+	"ZSCORE":      {keys: firstKey},
+	"GEOADD":      {write: true, keys: firstKey},
+	"GEOPOS":      {keys: firstKey},
+	"GEODIST":     {keys: firstKey},
+	"GEOSEARCH":   {keys: firstKey},
+	"SAVE":        {},
+	"BGSAVE":      {},
+	"LASTSAVE":    {},
+	"EXPORT":      {},
+	"CLUSTER":     {stale: true},
+	"ASKING":      {stale: true},
+	"DUMP":        {keys: firstKey},
+	"RESTORE":     {write: true, keys: firstKey},
+	// RESTORE sent by MIGRATE in cluster mode, importing node serves it without ASKING
+	"RESTORE-ASKING": {write: true, keys: firstKey},
+	// MIGRATE waits for target, it holds the write lock exclusively by itself, so keys aren't changed meanwhile
+	"MIGRATE": {write: true, blocking: true, getKeys: migrateKeys},
+}
+
+// Keys of unknown command or command with wrong number of args are skipped, the command itself replies with error
+func getCommandKeys(commandAndArgs []string) []string {
+	flags := commandTable[strings.ToUpper(commandAndArgs[0])]
+	if flags.getKeys != nil {
+		return flags.getKeys(commandAndArgs)
+	}
+	spec := flags.keys
+	if spec.keyword != "" {
+		for i, arg := range commandAndArgs {
+			if strings.EqualFold(arg, spec.keyword) {
+				rest := commandAndArgs[i+1:]
+				return rest[:len(rest)/2]
+			}
+		}
+		return nil
+	}
+	if spec.first == 0 {
+		return nil
+	}
+
+	last := spec.last
+	if last < 0 {
+		last += len(commandAndArgs)
+	}
+	keys := make([]string, 0)
+	for i := spec.first; i <= last && i < len(commandAndArgs); i += spec.step {
+		keys = append(keys, commandAndArgs[i])
+	}
+	return keys
+}
+
+// Client command can be redirected to other cluster node or rejected because of server role and replication state
+// Commands from master and AOF aren't checked, because they are already accepted
+func (c *controller) rejectClientCommand(cmd resp.Value, conn net.Conn) resp.Value {
+	array, ok := cmd.(resp.Array)
+	if !ok || len(array.Value) == 0 {
+		return nil
+	}
+	commandAndArgs, err := ExtractCommandAndArgs(array.Value)
+	if err != nil {
+		return nil
+	}
+	if redirect := c.redirectClientCommand(commandAndArgs, conn); redirect != nil {
+		return redirect
+	}
+	flags, ok := commandTable[strings.ToUpper(commandAndArgs[0])]
+	if !ok {
+		return nil
+	}
+
+	switch replicationController := c.replication().(type) {
+	case replication.ReplicaController:
+		if !flags.stale && !c.args.ReplicaServeStaleData && replicationController.LinkState() != replication.MASTER_LINK_STATE_CONNECTED {
+			return resp.SimpleError{Value: "MASTERDOWN Link with MASTER is down and replica-serve-stale-data is set to 'no'."}
+		}
+		if flags.write && c.args.ReplicaReadOnly {
+			return resp.SimpleError{Value: "READONLY You can't write against a read only replica."}
+		}
+	case replication.MasterController:
+		if flags.write && c.args.MinReplicasToWrite > 0 {
+			maxLag := time.Duration(c.args.MinReplicasMaxLag) * time.Second
+			if replicationController.CountGoodReplicas(maxLag) < c.args.MinReplicasToWrite {
+				return resp.SimpleError{Value: "NOREPLICAS Not enough good replicas to write."}
+			}
+		}
+	}
+	return nil
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
 
-func extractCommandAndArgs(commandAndArgs []resp.Value) ([]string, error) {
+func ExtractCommandAndArgs(commandAndArgs []resp.Value) ([]string, error) {
 	result := make([]string, 0, len(commandAndArgs))
 
 	for i, unit := range commandAndArgs {
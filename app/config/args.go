@@ -9,28 +9,138 @@ import (
 )
 
 type Args struct {
-	Host       string
-	Port       int
-	DBDir      string
-	DBFilename string
-	ReplicaOf  *replicaOfConfig
+	Host           string
+	Port           int
+	DBDir          string
+	DBFilename     string
+	ReplicaOf      *ReplicaOfConfig
+	AppendOnly     bool
+	AppendFilename string
+	AppendFsync    string
+	ExportCommands string
+	SaveRules      []SaveRule
+	// In bytes
+	ReplBacklogSize int
+	// Master pings replicas with this period, in seconds
+	ReplPingReplicaPeriod int
+	// Replica replies to data commands, when link with master is down
+	ReplicaServeStaleData bool
+	// Replica rejects write commands from clients
+	ReplicaReadOnly bool
+	// Master rejects write commands, when there are less good replicas, zero disables the check
+	MinReplicasToWrite int
+	// Replica is good, when its last ack is not older than this, in seconds
+	MinReplicasMaxLag int
+	// Replica is disconnected, when its output buffer exceeds the limit
+	ReplicaOutputBufferLimit OutputBufferLimit
+	// Server runs as sentinel: it doesn't serve data, but monitors masters and promotes replicas
+	Sentinel         bool
+	SentinelMonitors []SentinelMonitor
+	// Instance is subjectively down, when it doesn't reply to PING for this time, in milliseconds
+	SentinelDownAfter int
+	// Failover is aborted after this time and isn't retried for master during twice this time, in milliseconds
+	SentinelFailoverTimeout int
+	// Server is a node of cluster: keys are split by hash slots and served by different nodes
+	ClusterEnabled bool
+	// Node is failing, when it doesn't reply to PING for this time, in milliseconds
+	ClusterNodeTimeout int
+	// Bulk string in request can't be longer, in bytes
+	ProtoMaxBulkLen int
 }
 
-type replicaOfConfig struct {
+// Master, monitored by sentinel, quorum is the number of sentinels, that must agree that master is down
+type SentinelMonitor struct {
+	Name   string
+	Host   string
+	Port   int
+	Quorum int
+}
+
+// Hard limit is exceeded immediately, soft limit is exceeded when output buffer is over it for SoftSeconds
+// Zero limit means no limit
+type OutputBufferLimit struct {
+	// In bytes
+	HardBytes int
+	// In bytes
+	SoftBytes   int
+	SoftSeconds int
+}
+
+// BGSAVE is started, when there are at least Changes since the last save and Seconds have passed
+type SaveRule struct {
+	Seconds int
+	Changes int
+}
+
+const (
+	APPENDFSYNC_ALWAYS   = "always"
+	APPENDFSYNC_EVERYSEC = "everysec"
+	APPENDFSYNC_NO       = "no"
+	DEFAULT_SAVE_RULES   = "3600 1 300 100 60 10000"
+	// Class is replica (or slave alias), like in Redis, other client classes aren't supported
+	DEFAULT_CLIENT_OUTPUT_BUFFER_LIMIT = "replica 256mb 64mb 60"
+)
+
+// Memory units, like in Redis config: k is 1000 bytes, kb is 1024 bytes and so on
+var memoryUnits = map[string]int{
+	"":   1,
+	"b":  1,
+	"k":  1000,
+	"kb": 1024,
+	"m":  1000 * 1000,
+	"mb": 1024 * 1024,
+	"g":  1000 * 1000 * 1000,
+	"gb": 1024 * 1024 * 1024,
+}
+
+type ReplicaOfConfig struct {
 	Host string
 	Port int
 }
 
-func (replcfg *replicaOfConfig) String() string {
+func (replcfg *ReplicaOfConfig) String() string {
 	return fmt.Sprintf("%s %d", replcfg.Host, replcfg.Port)
 }
 
+// Flag can be passed several times, e.g. to monitor several masters
+type sentinelMonitorFlags []string
+
+func (f *sentinelMonitorFlags) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *sentinelMonitorFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 func NewArgs() *Args {
 	host := flag.String("host", "127.0.0.1", "The host of redis server")
 	port := flag.Int("port", 6379, "The port of redis server")
-	dir := flag.String("dir", "", "The path to RDB")
-	filename := flag.String("dbfilename", "", "The filename of RDB")
+	// Defaults are the same, as in Redis, so server without args persists to ./dump.rdb
+	dir := flag.String("dir", ".", "The path to RDB and AOF")
+	filename := flag.String("dbfilename", "dump.rdb", "The filename of RDB")
 	replicaOf := flag.String("replicaof", "", "The host and port of master server")
+	appendOnly := flag.String("appendonly", "no", "Enables AOF persistence: yes or no")
+	appendFilename := flag.String("appendfilename", "appendonly.aof", "The filename of AOF (it is stored in dir)")
+	appendFsync := flag.String("appendfsync", APPENDFSYNC_EVERYSEC, "AOF fsync policy: always, everysec or no")
+	save := flag.String("save", DEFAULT_SAVE_RULES, "RDB save rules as '<seconds> <changes>' pairs, empty string disables them")
+	replBacklogSize := flag.String("repl-backlog-size", "1mb", "The size of replication backlog, e.g. 1mb or 65536")
+	replPingReplicaPeriod := flag.Int("repl-ping-replica-period", 10, "Master pings replicas with this period, in seconds")
+	replicaServeStaleData := flag.String("replica-serve-stale-data", "yes", "Replica replies to data commands, when link with master is down: yes or no")
+	replicaReadOnly := flag.String("replica-read-only", "yes", "Replica rejects write commands from clients: yes or no")
+	minReplicasToWrite := flag.Int("min-replicas-to-write", 0, "Master rejects write commands, when there are less good replicas, 0 disables the check")
+	minReplicasMaxLag := flag.Int("min-replicas-max-lag", 10, "Replica is good, when its last ack is not older than this, in seconds")
+	clientOutputBufferLimit := flag.String("client-output-buffer-limit", DEFAULT_CLIENT_OUTPUT_BUFFER_LIMIT, "Output buffer limit of replicas as 'replica <hard> <soft> <soft-seconds>'")
+	exportCommands := flag.String("export-commands", "", "Writes RESP command stream of the loaded dataset to the file and exits")
+	sentinel := flag.Bool("sentinel", false, "Runs server as sentinel, that monitors masters and promotes replicas")
+	var sentinelMonitors sentinelMonitorFlags
+	flag.Var(&sentinelMonitors, "sentinel-monitor", "Master, monitored by sentinel, as '<name> <host> <port> <quorum>', can be passed several times")
+	sentinelDownAfter := flag.Int("sentinel-down-after-milliseconds", 30000, "Instance is down for sentinel, when it doesn't reply for this time, in milliseconds")
+	sentinelFailoverTimeout := flag.Int("sentinel-failover-timeout", 180000, "Sentinel aborts failover after this time, in milliseconds")
+	clusterEnabled := flag.String("cluster-enabled", "no", "Runs server as cluster node: yes or no")
+	clusterNodeTimeout := flag.Int("cluster-node-timeout", 15000, "Cluster node is failing, when it doesn't reply for this time, in milliseconds")
+	protoMaxBulkLen := flag.String("proto-max-bulk-len", "512mb", "Bulk string in request can't be longer, e.g. 512mb, at least 1mb")
 
 	flag.Parse()
 
@@ -39,16 +149,233 @@ func NewArgs() *Args {
 		log.Fatalf("wrong replicaof argument format: %v\n", err)
 	}
 
+	appendOnlyBool, err := parseYesNo(*appendOnly)
+	if err != nil {
+		log.Fatalf("wrong appendonly argument format: %v\n", err)
+	}
+
+	switch *appendFsync {
+	case APPENDFSYNC_ALWAYS, APPENDFSYNC_EVERYSEC, APPENDFSYNC_NO:
+	default:
+		log.Fatalf("wrong appendfsync argument format: unknown policy %s\n", *appendFsync)
+	}
+
+	saveRules, err := parseSaveRules(*save)
+	if err != nil {
+		log.Fatalf("wrong save argument format: %v\n", err)
+	}
+
+	replBacklogSizeBytes, err := ParseMemory(*replBacklogSize)
+	if err != nil {
+		log.Fatalf("wrong repl-backlog-size argument format: %v\n", err)
+	}
+
+	if *replPingReplicaPeriod <= 0 {
+		log.Fatalf("wrong repl-ping-replica-period argument format: period should be positive, got: %d\n", *replPingReplicaPeriod)
+	}
+
+	replicaServeStaleDataBool, err := parseYesNo(*replicaServeStaleData)
+	if err != nil {
+		log.Fatalf("wrong replica-serve-stale-data argument format: %v\n", err)
+	}
+
+	replicaReadOnlyBool, err := parseYesNo(*replicaReadOnly)
+	if err != nil {
+		log.Fatalf("wrong replica-read-only argument format: %v\n", err)
+	}
+
+	if *minReplicasToWrite < 0 || *minReplicasMaxLag < 0 {
+		log.Fatalf("wrong min-replicas-to-write or min-replicas-max-lag argument format: they should be non-negative\n")
+	}
+
+	replicaOutputBufferLimit, err := parseClientOutputBufferLimit(*clientOutputBufferLimit)
+	if err != nil {
+		log.Fatalf("wrong client-output-buffer-limit argument format: %v\n", err)
+	}
+
+	monitors, err := parseSentinelMonitors(sentinelMonitors)
+	if err != nil {
+		log.Fatalf("wrong sentinel-monitor argument format: %v\n", err)
+	}
+	if *sentinel && len(monitors) == 0 {
+		log.Fatalf("sentinel should monitor at least one master, pass --sentinel-monitor\n")
+	}
+
+	if *sentinelDownAfter <= 0 || *sentinelFailoverTimeout <= 0 {
+		log.Fatalf("wrong sentinel-down-after-milliseconds or sentinel-failover-timeout argument format: they should be positive\n")
+	}
+
+	clusterEnabledBool, err := parseYesNo(*clusterEnabled)
+	if err != nil {
+		log.Fatalf("wrong cluster-enabled argument format: %v\n", err)
+	}
+	if clusterEnabledBool && (*sentinel || replicaOfConfig != nil) {
+		log.Fatalf("cluster node can't be sentinel or replica, don't pass --sentinel or --replicaof with --cluster-enabled\n")
+	}
+	if *clusterNodeTimeout <= 0 {
+		log.Fatalf("wrong cluster-node-timeout argument format: timeout should be positive, got: %d\n", *clusterNodeTimeout)
+	}
+
+	protoMaxBulkLenBytes, err := ParseMemory(*protoMaxBulkLen)
+	if err != nil {
+		log.Fatalf("wrong proto-max-bulk-len argument format: %v\n", err)
+	}
+	// Minimum is the same as in Redis
+	if protoMaxBulkLenBytes < 1024*1024 {
+		log.Fatalf("wrong proto-max-bulk-len argument format: it should be at least 1mb, got: %d\n", protoMaxBulkLenBytes)
+	}
+
 	return &Args{
-		Host:       *host,
-		Port:       *port,
-		DBDir:      *dir,
-		DBFilename: *filename,
-		ReplicaOf:  replicaOfConfig,
+		Host:                     *host,
+		Port:                     *port,
+		DBDir:                    *dir,
+		DBFilename:               *filename,
+		ReplicaOf:                replicaOfConfig,
+		AppendOnly:               appendOnlyBool,
+		AppendFilename:           *appendFilename,
+		AppendFsync:              *appendFsync,
+		ExportCommands:           *exportCommands,
+		SaveRules:                saveRules,
+		ReplBacklogSize:          replBacklogSizeBytes,
+		ReplPingReplicaPeriod:    *replPingReplicaPeriod,
+		ReplicaServeStaleData:    replicaServeStaleDataBool,
+		ReplicaReadOnly:          replicaReadOnlyBool,
+		MinReplicasToWrite:       *minReplicasToWrite,
+		MinReplicasMaxLag:        *minReplicasMaxLag,
+		ReplicaOutputBufferLimit: replicaOutputBufferLimit,
+		Sentinel:                 *sentinel,
+		SentinelMonitors:         monitors,
+		SentinelDownAfter:        *sentinelDownAfter,
+		SentinelFailoverTimeout:  *sentinelFailoverTimeout,
+		ClusterEnabled:           clusterEnabledBool,
+		ClusterNodeTimeout:       *clusterNodeTimeout,
+		ProtoMaxBulkLen:          protoMaxBulkLenBytes,
+	}
+}
+
+// Parses memory amount with optional unit, e.g. 1mb, 64kb or 1024
+func ParseMemory(value string) (int, error) {
+	value = strings.ToLower(value)
+	digitsEnd := strings.IndexFunc(value, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if digitsEnd == -1 {
+		digitsEnd = len(value)
+	}
+
+	unit, ok := memoryUnits[value[digitsEnd:]]
+	if !ok {
+		return 0, fmt.Errorf("unknown memory unit: %s", value[digitsEnd:])
+	}
+	amount, err := strconv.Atoi(value[:digitsEnd])
+	if err != nil {
+		return 0, fmt.Errorf("memory amount should be decimal: %v", err)
+	}
+	return amount * unit, nil
+}
+
+func parseClientOutputBufferLimit(value string) (OutputBufferLimit, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return OutputBufferLimit{}, fmt.Errorf("provide class, hard limit, soft limit and soft seconds, e.g: 'replica 256mb 64mb 60'")
+	}
+	class := strings.ToLower(fields[0])
+	if class != "replica" && class != "slave" {
+		return OutputBufferLimit{}, fmt.Errorf("only replica class is supported, got: %s", fields[0])
+	}
+
+	hardBytes, err := ParseMemory(fields[1])
+	if err != nil {
+		return OutputBufferLimit{}, fmt.Errorf("hard limit error: %v", err)
+	}
+	softBytes, err := ParseMemory(fields[2])
+	if err != nil {
+		return OutputBufferLimit{}, fmt.Errorf("soft limit error: %v", err)
+	}
+	softSeconds, err := strconv.Atoi(fields[3])
+	if err != nil || softSeconds < 0 {
+		return OutputBufferLimit{}, fmt.Errorf("soft seconds should be non-negative decimal, got: %s", fields[3])
+	}
+	return OutputBufferLimit{HardBytes: hardBytes, SoftBytes: softBytes, SoftSeconds: softSeconds}, nil
+}
+
+func FormatClientOutputBufferLimit(limit OutputBufferLimit) string {
+	return fmt.Sprintf("replica %d %d %d", limit.HardBytes, limit.SoftBytes, limit.SoftSeconds)
+}
+
+func parseSentinelMonitors(values []string) ([]SentinelMonitor, error) {
+	monitors := make([]SentinelMonitor, 0, len(values))
+	names := make(map[string]bool, len(values))
+	for _, value := range values {
+		fields := strings.Fields(value)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("provide name, host, port and quorum, e.g: 'mymaster 127.0.0.1 6379 2'")
+		}
+		port, err := strconv.Atoi(fields[2])
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("port should be decimal in range 1-65535, got: %s", fields[2])
+		}
+		quorum, err := strconv.Atoi(fields[3])
+		if err != nil || quorum <= 0 {
+			return nil, fmt.Errorf("quorum should be positive decimal, got: %s", fields[3])
+		}
+		if names[fields[0]] {
+			return nil, fmt.Errorf("duplicated master name: %s", fields[0])
+		}
+		names[fields[0]] = true
+		monitors = append(monitors, SentinelMonitor{Name: fields[0], Host: fields[1], Port: port, Quorum: quorum})
+	}
+	return monitors, nil
+}
+
+func parseSaveRules(value string) ([]SaveRule, error) {
+	fields := strings.Fields(value)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("provide pairs of seconds and changes, e.g: '900 1 300 10'")
+	}
+
+	saveRules := make([]SaveRule, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err := strconv.Atoi(fields[i])
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("seconds should be non-negative decimal, got: %s", fields[i])
+		}
+		changes, err := strconv.Atoi(fields[i+1])
+		if err != nil || changes < 0 {
+			return nil, fmt.Errorf("changes should be non-negative decimal, got: %s", fields[i+1])
+		}
+		saveRules = append(saveRules, SaveRule{Seconds: seconds, Changes: changes})
+	}
+	return saveRules, nil
+}
+
+func FormatSaveRules(saveRules []SaveRule) string {
+	fields := make([]string, 0, len(saveRules)*2)
+	for _, saveRule := range saveRules {
+		fields = append(fields, strconv.Itoa(saveRule.Seconds), strconv.Itoa(saveRule.Changes))
+	}
+	return strings.Join(fields, " ")
+}
+
+func parseYesNo(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected yes or no, got: %s", value)
+	}
+}
+
+func FormatYesNo(value bool) string {
+	if value {
+		return "yes"
 	}
+	return "no"
 }
 
-func configReplicaOf(replicaOf *string) (*replicaOfConfig, error) {
+func configReplicaOf(replicaOf *string) (*ReplicaOfConfig, error) {
 	if *replicaOf == "" {
 		return nil, nil
 	}
@@ -69,5 +396,5 @@ func configReplicaOf(replicaOf *string) (*replicaOfConfig, error) {
 		return nil, fmt.Errorf("port should be decimal: %v", err)
 	}
 
-	return &replicaOfConfig{Host: masterHost, Port: atoiMasterPort}, nil
+	return &ReplicaOfConfig{Host: masterHost, Port: atoiMasterPort}, nil
 }
@@ -17,16 +17,23 @@ type ListStorage interface {
 	Blpop(key string, timeoutS float64) *string
 	Lpush(key string, values ...string) int
 	Rpush(key string, values ...string) int
+	Snapshot(keys ...string) map[string][]string
 }
 
 type listStorage struct {
 	data  map[string]*doublylinkedlist.List
 	rwMut sync.RWMutex
 	cond  *sync.Cond
+	// Expired list isn't read, even if it isn't deleted yet
+	expired func(key string) bool
 }
 
 func NewListStorage() ListStorage {
-	ls := &listStorage{data: make(map[string]*doublylinkedlist.List)}
+	return newListStorage(neverExpired)
+}
+
+func newListStorage(expired func(key string) bool) *listStorage {
+	ls := &listStorage{data: make(map[string]*doublylinkedlist.List), expired: expired}
 	ls.cond = sync.NewCond(&ls.rwMut)
 	return ls
 }
@@ -56,7 +63,17 @@ func (ls *listStorage) Del(key string) {
 	delete(ls.data, key)
 }
 
+func (ls *listStorage) Flush() {
+	ls.rwMut.Lock()
+	defer ls.rwMut.Unlock()
+	clear(ls.data)
+}
+
 func (ls *listStorage) Llen(key string) int {
+	if ls.expired(key) {
+		return 0
+	}
+
 	ls.rwMut.RLock()
 	defer ls.rwMut.RUnlock()
 
@@ -68,11 +85,14 @@ func (ls *listStorage) Llen(key string) int {
 }
 
 func (ls *listStorage) Lrange(key string, startIdx, stopIdx int) []string {
+	values := make([]string, 0)
+	if ls.expired(key) {
+		return values
+	}
+
 	ls.rwMut.RLock()
 	defer ls.rwMut.RUnlock()
 
-	values := make([]string, 0)
-
 	list, ok := ls.data[key]
 	if !ok {
 		return values
@@ -122,6 +142,23 @@ func (ls *listStorage) Rpush(key string, values ...string) int {
 	return ls.push(doublylinkedlist.InsertInTheEnd, key, values...)
 }
 
+// Only given keys are copied, if any, otherwise all keys
+func (ls *listStorage) Snapshot(keys ...string) map[string][]string {
+	ls.rwMut.RLock()
+	defer ls.rwMut.RUnlock()
+
+	data := selectKeys(ls.data, keys)
+	snapshot := make(map[string][]string, len(data))
+	for key, list := range data {
+		values := make([]string, 0, list.Len)
+		for cur := list.Head; cur != nil; cur = cur.Next {
+			values = append(values, cur.Val)
+		}
+		snapshot[key] = values
+	}
+	return snapshot
+}
+
 func (ls *listStorage) pop(key string, count int, popFn func(list *doublylinkedlist.List) *doublylinkedlist.Node) []string {
 	ls.rwMut.Lock()
 	defer ls.rwMut.Unlock()
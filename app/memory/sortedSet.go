@@ -12,6 +12,11 @@ type sortedSet struct {
 	skipList *skiplist.List
 }
 
+type SortedSetMember struct {
+	Member string
+	Score  float64
+}
+
 type SortedSetStorage interface {
 	baseStorage
 	Zadd(key string, scores []float64, members []string) int
@@ -20,15 +25,22 @@ type SortedSetStorage interface {
 	Zrange(key string, startIdx, stopIdx int, withScores bool) []string
 	Zcard(key string) int
 	Zscore(key string, member string) *float64
+	Snapshot(keys ...string) map[string][]SortedSetMember
 }
 
 type sortedSetStorage struct {
 	data  map[string]*sortedSet
 	rwMut sync.RWMutex
+	// Expired sorted set isn't read, even if it isn't deleted yet
+	expired func(key string) bool
 }
 
 func NewSortedSetStorage() SortedSetStorage {
-	return &sortedSetStorage{data: make(map[string]*sortedSet)}
+	return newSortedSetStorage(neverExpired)
+}
+
+func newSortedSetStorage(expired func(key string) bool) *sortedSetStorage {
+	return &sortedSetStorage{data: make(map[string]*sortedSet), expired: expired}
 }
 
 func (s *sortedSetStorage) Zadd(key string, scores []float64, members []string) int {
@@ -80,6 +92,10 @@ func (s *sortedSetStorage) Zrem(key string, members []string) int {
 }
 
 func (s *sortedSetStorage) Zrank(key string, member string) int {
+	if s.expired(key) {
+		return -1
+	}
+
 	s.rwMut.RLock()
 	defer s.rwMut.RUnlock()
 
@@ -98,11 +114,14 @@ func (s *sortedSetStorage) Zrank(key string, member string) int {
 }
 
 func (s *sortedSetStorage) Zrange(key string, startIdx, stopIdx int, withScores bool) []string {
+	values := make([]string, 0)
+	if s.expired(key) {
+		return values
+	}
+
 	s.rwMut.RLock()
 	defer s.rwMut.RUnlock()
 
-	values := make([]string, 0)
-
 	sortedSet, ok := s.data[key]
 	if !ok {
 		return values
@@ -133,6 +152,10 @@ func (s *sortedSetStorage) Zrange(key string, startIdx, stopIdx int, withScores
 }
 
 func (s *sortedSetStorage) Zcard(key string) int {
+	if s.expired(key) {
+		return 0
+	}
+
 	s.rwMut.RLock()
 	defer s.rwMut.RUnlock()
 
@@ -145,6 +168,10 @@ func (s *sortedSetStorage) Zcard(key string) int {
 }
 
 func (s *sortedSetStorage) Zscore(key string, member string) *float64 {
+	if s.expired(key) {
+		return nil
+	}
+
 	s.rwMut.RLock()
 	defer s.rwMut.RUnlock()
 
@@ -160,6 +187,24 @@ func (s *sortedSetStorage) Zscore(key string, member string) *float64 {
 	return &score
 }
 
+// Only given keys are copied, if any, otherwise all keys
+// Members are returned in ascending order, as they are stored in skip list
+func (s *sortedSetStorage) Snapshot(keys ...string) map[string][]SortedSetMember {
+	s.rwMut.RLock()
+	defer s.rwMut.RUnlock()
+
+	data := selectKeys(s.data, keys)
+	snapshot := make(map[string][]SortedSetMember, len(data))
+	for key, sortedSet := range data {
+		members := make([]SortedSetMember, 0, sortedSet.skipList.Len)
+		for cur := sortedSet.skipList.Head.Tower[0]; cur != nil; cur = cur.Tower[0] {
+			members = append(members, SortedSetMember{Member: cur.Member, Score: cur.Score})
+		}
+		snapshot[key] = members
+	}
+	return snapshot
+}
+
 func (s *sortedSetStorage) Keys() []string {
 	s.rwMut.RLock()
 	defer s.rwMut.RUnlock()
@@ -184,3 +229,9 @@ func (s *sortedSetStorage) Del(key string) {
 	defer s.rwMut.Unlock()
 	delete(s.data, key)
 }
+
+func (s *sortedSetStorage) Flush() {
+	s.rwMut.Lock()
+	defer s.rwMut.Unlock()
+	clear(s.data)
+}
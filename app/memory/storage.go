@@ -1,9 +1,17 @@
 package memory
 
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
 type baseStorage interface {
 	Keys() []string
 	Has(key string) bool
 	Del(key string)
+	Flush()
 }
 
 type MultiTypeStorage interface {
@@ -15,10 +23,48 @@ type MultiTypeStorage interface {
 	StreamStorage() StreamStorage
 	StringStorage() StringStorage
 	SortedSetStorage() SortedSetStorage
+	// Only given keys are copied, if any, otherwise all keys
+	Snapshot(keys ...string) *Snapshot
+	Restore(snapshot *Snapshot) error
+	Expire(key string, expires time.Time) bool
+	Flush()
+	// Deletes expired keys of all types, it does nothing, if expired keys deletion is disabled
+	CleanExpiredKeys()
+	// Deletes expired strings by random samples, other keys are deleted by their expiration timers
+	ActiveExpireCycle()
+	// Replica disables deletion, it keeps expired keys hidden from reads until master deletes them
+	// onExpiredDelete is called for each expired key deleted by storage, e.g. to propagate DEL
+	SetExpiredKeysDeletion(deleteExpired bool, onExpiredDelete func(key string))
+}
+
+// String items keep their expiration by themselves
+// Expires holds expiration of list, sorted set and stream keys
+type Snapshot struct {
+	Strings    map[string]String
+	Lists      map[string][]string
+	SortedSets map[string][]SortedSetMember
+	Streams    map[string]StreamSnapshot
+	Expires    map[string]time.Time
+}
+
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		Strings:    make(map[string]String),
+		Lists:      make(map[string][]string),
+		SortedSets: make(map[string][]SortedSetMember),
+		Streams:    make(map[string]StreamSnapshot),
+		Expires:    make(map[string]time.Time),
+	}
 }
 
 type multiTypeStorage struct {
 	storages map[string]baseStorage
+	// Expiration of non string keys, such keys are deleted by timer
+	expires         map[string]time.Time
+	deleteExpired   bool
+	onExpiredDelete func(key string)
+	expiresMut      sync.Mutex
+	now             func() time.Time
 }
 
 const (
@@ -30,26 +76,47 @@ const (
 )
 
 func NewMultiTypeStorage() MultiTypeStorage {
-	return &multiTypeStorage{
-		storages: map[string]baseStorage{
-			TYPE_STRING:     NewStringStorage(),
-			TYPE_LIST:       NewListStorage(),
-			TYPE_STREAM:     NewStreamStorage(),
-			TYPE_SORTED_SET: NewSortedSetStorage(),
-		},
+	return newMultiTypeStorage(time.Now)
+}
+
+// Clock is replaced in tests
+func newMultiTypeStorage(now func() time.Time) *multiTypeStorage {
+	s := &multiTypeStorage{
+		expires:       make(map[string]time.Time),
+		deleteExpired: true,
+		now:           now,
+	}
+	s.storages = map[string]baseStorage{
+		TYPE_STRING:     newStringStorage(now),
+		TYPE_LIST:       newListStorage(s.expired),
+		TYPE_STREAM:     newStreamStorage(s.expired),
+		TYPE_SORTED_SET: newSortedSetStorage(s.expired),
 	}
+	return s
+}
+
+func neverExpired(string) bool {
+	return false
 }
 
 func (s *multiTypeStorage) Keys() []string {
 	allStorageKeys := make([]string, 0)
 	allStorageKeys = append(allStorageKeys, s.StringStorage().Keys()...)
-	allStorageKeys = append(allStorageKeys, s.ListStorage().Keys()...)
-	allStorageKeys = append(allStorageKeys, s.StreamStorage().Keys()...)
-	allStorageKeys = append(allStorageKeys, s.SortedSetStorage().Keys()...)
+	for _, storageType := range []string{TYPE_LIST, TYPE_STREAM, TYPE_SORTED_SET} {
+		for _, key := range s.storages[storageType].Keys() {
+			if !s.expired(key) {
+				allStorageKeys = append(allStorageKeys, key)
+			}
+		}
+	}
 	return allStorageKeys
 }
 
 func (s *multiTypeStorage) Del(key string) {
+	s.expiresMut.Lock()
+	delete(s.expires, key)
+	s.expiresMut.Unlock()
+
 	if s.StringStorage().Has(key) {
 		s.StringStorage().Del(key)
 	} else if s.ListStorage().Has(key) {
@@ -62,27 +129,196 @@ func (s *multiTypeStorage) Del(key string) {
 }
 
 func (s *multiTypeStorage) Type(key string) string {
-	if s.StringStorage().Has(key) {
-		return TYPE_STRING
+	for _, storageType := range []string{TYPE_STRING, TYPE_LIST, TYPE_STREAM, TYPE_SORTED_SET} {
+		if s.has(storageType, key) {
+			return storageType
+		}
 	}
-	if s.ListStorage().Has(key) {
-		return TYPE_LIST
+	return TYPE_NONE
+}
+
+// Expired key doesn't exist for reads, even if it isn't deleted yet
+func (s *multiTypeStorage) has(storageType, key string) bool {
+	if storageType == TYPE_STRING {
+		_, ok := s.StringStorage().Get(key)
+		return ok
+	}
+	return s.storages[storageType].Has(key) && !s.expired(key)
+}
+
+// Each storage is copied under its own lock, so the snapshot isn't point-in-time across storages
+func (s *multiTypeStorage) Snapshot(keys ...string) *Snapshot {
+	s.expiresMut.Lock()
+	expires := maps.Clone(selectKeys(s.expires, keys))
+	s.expiresMut.Unlock()
+
+	snapshot := &Snapshot{
+		Strings:    s.StringStorage().Snapshot(keys...),
+		Lists:      s.ListStorage().Snapshot(keys...),
+		SortedSets: s.SortedSetStorage().Snapshot(keys...),
+		Streams:    s.StreamStorage().Snapshot(keys...),
+		Expires:    expires,
+	}
+
+	// Key could be emptied (e.g. by LPOP) without DEL, so its expiration is dropped
+	// Expired keys, which aren't deleted yet (e.g. on replica), aren't saved
+	now := s.now()
+	maps.DeleteFunc(snapshot.Expires, func(key string, expires time.Time) bool {
+		_, isList := snapshot.Lists[key]
+		_, isSortedSet := snapshot.SortedSets[key]
+		_, isStream := snapshot.Streams[key]
+		if expires.After(now) {
+			return !isList && !isSortedSet && !isStream
+		}
+		delete(snapshot.Lists, key)
+		delete(snapshot.SortedSets, key)
+		delete(snapshot.Streams, key)
+		return true
+	})
+	return snapshot
+}
+
+// Storage is expected to be empty, e.g. on RDB file load, otherwise lists are appended to existing ones
+// Already expired keys are skipped
+func (s *multiTypeStorage) Restore(snapshot *Snapshot) error {
+	for key, item := range snapshot.Strings {
+		if s.StringStorage().ItemHasExpiration(&item) {
+			s.StringStorage().SetWithExpiry(key, item.Value, item.Expires)
+		} else {
+			s.StringStorage().Set(key, item.Value)
+		}
 	}
-	if s.StreamStorage().Has(key) {
-		return TYPE_STREAM
+
+	for key, values := range snapshot.Lists {
+		if len(values) > 0 {
+			s.ListStorage().Rpush(key, values...)
+		}
 	}
-	if s.SortedSetStorage().Has(key) {
-		return TYPE_STREAM
+
+	for key, members := range snapshot.SortedSets {
+		scores := make([]float64, 0, len(members))
+		names := make([]string, 0, len(members))
+		for _, member := range members {
+			scores = append(scores, member.Score)
+			names = append(names, member.Member)
+		}
+		s.SortedSetStorage().Zadd(key, scores, names)
 	}
-	return TYPE_NONE
+
+	for key, stream := range snapshot.Streams {
+		if err := s.StreamStorage().Restore(key, stream); err != nil {
+			return fmt.Errorf("restore stream %s error: %v", key, err)
+		}
+	}
+
+	for key, expires := range snapshot.Expires {
+		s.setExpiry(key, expires)
+	}
+	return nil
+}
+
+// Deletes all keys, e.g. before replica storage is replaced by full resync
+// Expired keys, which aren't deleted yet, are deleted too
+func (s *multiTypeStorage) Flush() {
+	s.expiresMut.Lock()
+	clear(s.expires)
+	s.expiresMut.Unlock()
+
+	for _, storage := range s.storages {
+		storage.Flush()
+	}
+}
+
+// Returns false if there is no such key
+func (s *multiTypeStorage) Expire(key string, expires time.Time) bool {
+	if item, ok := s.StringStorage().Get(key); ok {
+		s.StringStorage().SetWithExpiry(key, item.Value, expires)
+		return true
+	}
+
+	if !s.has(TYPE_LIST, key) && !s.has(TYPE_STREAM, key) && !s.has(TYPE_SORTED_SET, key) {
+		return false
+	}
+	s.setExpiry(key, expires)
+	return true
+}
+
+// Expiration in the past deletes the key, it is explicit write, so replica does it too
+func (s *multiTypeStorage) setExpiry(key string, expires time.Time) {
+	if !expires.After(s.now()) {
+		s.Del(key)
+		return
+	}
+
+	s.expiresMut.Lock()
+	s.expires[key] = expires
+	s.expiresMut.Unlock()
+
+	time.AfterFunc(expires.Sub(s.now()), func() {
+		s.deleteIfExpired(key, expires)
+	})
+}
+
+// Returns true for non string key, which expiration has come
+func (s *multiTypeStorage) expired(key string) bool {
+	s.expiresMut.Lock()
+	defer s.expiresMut.Unlock()
+
+	expires, ok := s.expires[key]
+	return ok && !expires.After(s.now())
+}
+
+// Key could be deleted and created again before the timer fires, so expiration is checked once more
+func (s *multiTypeStorage) deleteIfExpired(key string, expires time.Time) {
+	s.expiresMut.Lock()
+	registered, ok := s.expires[key]
+	deleteExpired := s.deleteExpired && ok && registered.Equal(expires) && !registered.After(s.now())
+	onExpiredDelete := s.onExpiredDelete
+	if deleteExpired {
+		delete(s.expires, key)
+	}
+	s.expiresMut.Unlock()
+
+	if !deleteExpired {
+		return
+	}
+	s.Del(key)
+	if onExpiredDelete != nil {
+		onExpiredDelete(key)
+	}
+}
+
+func (s *multiTypeStorage) ActiveExpireCycle() {
+	s.StringStorage().ActiveExpireCycle()
+}
+
+func (s *multiTypeStorage) CleanExpiredKeys() {
+	s.StringStorage().CleanExpiredKeys()
+
+	s.expiresMut.Lock()
+	expires := maps.Clone(s.expires)
+	s.expiresMut.Unlock()
+
+	for key, expires := range expires {
+		s.deleteIfExpired(key, expires)
+	}
+}
+
+func (s *multiTypeStorage) SetExpiredKeysDeletion(deleteExpired bool, onExpiredDelete func(key string)) {
+	s.expiresMut.Lock()
+	s.deleteExpired = deleteExpired
+	s.onExpiredDelete = onExpiredDelete
+	s.expiresMut.Unlock()
+
+	s.StringStorage().(*stringStorage).setExpiredKeysDeletion(deleteExpired, onExpiredDelete)
 }
 
 func (s *multiTypeStorage) KeyExistsWithOtherType(key string, allowedType string) bool {
-	for storageKey, storage := range s.storages {
-		if storageKey == allowedType {
+	for storageType := range s.storages {
+		if storageType == allowedType {
 			continue
 		}
-		if storage.Has(key) {
+		if s.has(storageType, key) {
 			return true
 		}
 	}
@@ -0,0 +1,225 @@
+package memory
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiTypeStorageSnapshotRestore(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+
+	storage := NewMultiTypeStorage()
+	storage.StringStorage().Set("string", "value")
+	storage.StringStorage().SetWithExpiry("expiringString", "value", expires)
+	storage.ListStorage().Rpush("list", "a", "b", "c")
+	storage.SortedSetStorage().Zadd("zset", []float64{2, 1}, []string{"b", "a"})
+	_, err := storage.StreamStorage().Xadd("stream", "1-1", map[string]string{"field": "value"})
+	assert.NoError(t, err)
+
+	snapshot := storage.Snapshot()
+	snapshot.Expires["list"] = expires
+
+	restored := NewMultiTypeStorage()
+	err = restored.Restore(snapshot)
+	assert.NoError(t, err)
+
+	assert.Equal(t, snapshot, restored.Snapshot())
+	assert.Equal(t, []string{"a", "b", "c"}, restored.ListStorage().Lrange("list", 0, -1))
+	assert.Equal(t, []SortedSetMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}}, restored.SortedSetStorage().Snapshot()["zset"])
+}
+
+func TestMultiTypeStorageSnapshotKeys(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+
+	storage := NewMultiTypeStorage()
+	storage.StringStorage().Set("string", "value")
+	storage.ListStorage().Rpush("list", "a", "b")
+	storage.ListStorage().Rpush("otherList", "c")
+	storage.SortedSetStorage().Zadd("zset", []float64{1}, []string{"a"})
+	assert.True(t, storage.Expire("list", expires))
+	assert.True(t, storage.Expire("zset", expires))
+
+	expected := NewSnapshot()
+	expected.Strings["string"] = String{Value: "value"}
+	expected.Lists["list"] = []string{"a", "b"}
+	expected.Expires["list"] = expires
+	assert.Equal(t, expected, storage.Snapshot("string", "list", "missing"))
+
+	assert.Equal(t, NewSnapshot(), storage.Snapshot("missing"))
+}
+
+func TestMultiTypeStorageRestoreExpiration(t *testing.T) {
+	t.Run("already expired keys are skipped", func(t *testing.T) {
+		snapshot := NewSnapshot()
+		snapshot.Lists["list"] = []string{"a"}
+		snapshot.Expires["list"] = time.Now().Add(-time.Second)
+
+		storage := NewMultiTypeStorage()
+		err := storage.Restore(snapshot)
+		assert.NoError(t, err)
+		assert.False(t, storage.ListStorage().Has("list"))
+	})
+
+	t.Run("key is deleted when expiration comes", func(t *testing.T) {
+		snapshot := NewSnapshot()
+		snapshot.SortedSets["zset"] = []SortedSetMember{{Member: "a", Score: 1}}
+		snapshot.Expires["zset"] = time.Now().Add(50 * time.Millisecond)
+
+		storage := NewMultiTypeStorage()
+		err := storage.Restore(snapshot)
+		assert.NoError(t, err)
+		assert.True(t, storage.SortedSetStorage().Has("zset"))
+
+		assert.Eventually(t, func() bool {
+			return !storage.SortedSetStorage().Has("zset")
+		}, time.Second, 10*time.Millisecond)
+		assert.Empty(t, storage.Snapshot().Expires)
+	})
+
+	t.Run("recreated key isn't deleted by old timer", func(t *testing.T) {
+		snapshot := NewSnapshot()
+		snapshot.Lists["list"] = []string{"a"}
+		snapshot.Expires["list"] = time.Now().Add(50 * time.Millisecond)
+
+		storage := NewMultiTypeStorage()
+		err := storage.Restore(snapshot)
+		assert.NoError(t, err)
+
+		storage.Del("list")
+		storage.ListStorage().Rpush("list", "b")
+
+		time.Sleep(100 * time.Millisecond)
+		assert.True(t, storage.ListStorage().Has("list"))
+	})
+}
+
+func TestMultiTypeStorageExpire(t *testing.T) {
+	storage := NewMultiTypeStorage()
+	storage.StringStorage().Set("str", "value")
+	storage.ListStorage().Rpush("list", "a")
+	expires := time.Now().Add(time.Hour)
+
+	assert.True(t, storage.Expire("str", expires))
+	item, ok := storage.StringStorage().Get("str")
+	assert.True(t, ok)
+	assert.Equal(t, expires, item.Expires)
+
+	assert.True(t, storage.Expire("list", expires))
+	assert.Equal(t, map[string]time.Time{"list": expires}, storage.Snapshot().Expires)
+
+	assert.False(t, storage.Expire("missing", expires))
+
+	assert.True(t, storage.Expire("list", time.Now().Add(-time.Second)))
+	assert.False(t, storage.ListStorage().Has("list"))
+}
+
+func TestMultiTypeStorageType(t *testing.T) {
+	storage := NewMultiTypeStorage()
+	storage.StringStorage().Set("str", "value")
+	storage.ListStorage().Rpush("list", "a")
+	storage.SortedSetStorage().Zadd("zset", []float64{1}, []string{"a"})
+	storage.StreamStorage().Xadd("stream", "1-0", map[string]string{"a": "b"})
+
+	assert.Equal(t, TYPE_STRING, storage.Type("str"))
+	assert.Equal(t, TYPE_LIST, storage.Type("list"))
+	assert.Equal(t, TYPE_SORTED_SET, storage.Type("zset"))
+	assert.Equal(t, TYPE_STREAM, storage.Type("stream"))
+	assert.Equal(t, TYPE_NONE, storage.Type("missing"))
+}
+
+func TestMultiTypeStorageFlush(t *testing.T) {
+	storage := NewMultiTypeStorage()
+	storage.StringStorage().Set("str", "value")
+	storage.ListStorage().Rpush("list", "a")
+	storage.Expire("list", time.Now().Add(time.Hour))
+	storage.SortedSetStorage().Zadd("zset", []float64{1}, []string{"a"})
+	storage.StreamStorage().Xadd("stream", "1-0", map[string]string{"a": "b"})
+
+	storage.Flush()
+
+	assert.Empty(t, storage.Keys())
+	assert.Empty(t, storage.Snapshot().Expires)
+}
+
+type fakeClock struct {
+	now time.Time
+	mut sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestMultiTypeStorageExpiredKeysDeletion(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	// Timers don't fire during test, so keys are deleted only by fake clock checks
+	expires := clock.Now().Add(time.Hour)
+
+	replica := newMultiTypeStorage(clock.Now)
+	replica.SetExpiredKeysDeletion(false, nil)
+
+	// Master DEL is applied to replica, as if it is propagated
+	master := newMultiTypeStorage(clock.Now)
+	var deletedKeys []string
+	master.SetExpiredKeysDeletion(true, func(key string) {
+		deletedKeys = append(deletedKeys, key)
+		replica.Del(key)
+	})
+
+	for _, storage := range []*multiTypeStorage{master, replica} {
+		storage.StringStorage().SetWithExpiry("str", "value", expires)
+		storage.StringStorage().Set("persistent", "value")
+		storage.ListStorage().Rpush("list", "a")
+		storage.Expire("list", expires)
+		storage.SortedSetStorage().Zadd("zset", []float64{1}, []string{"a"})
+		storage.Expire("zset", expires)
+	}
+	clock.Advance(2 * time.Hour)
+
+	// Replica hides expired keys, but doesn't delete them
+	_, ok := replica.StringStorage().Get("str")
+	assert.False(t, ok)
+	assert.Equal(t, TYPE_NONE, replica.Type("list"))
+	assert.Equal(t, 0, replica.ListStorage().Llen("list"))
+	assert.Equal(t, 0, replica.SortedSetStorage().Zcard("zset"))
+	assert.Equal(t, []string{"persistent"}, replica.Keys())
+	assert.Equal(t, []string{"persistent"}, slices.Collect(maps.Keys(replica.Snapshot().Strings)))
+	assert.Empty(t, replica.Snapshot().Lists)
+	replica.CleanExpiredKeys()
+	assert.True(t, replica.StringStorage().Has("str"))
+	assert.True(t, replica.ListStorage().Has("list"))
+	assert.True(t, replica.SortedSetStorage().Has("zset"))
+
+	// Master deletes expired key on access
+	_, ok = master.StringStorage().Get("str")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"str"}, deletedKeys)
+	assert.False(t, replica.StringStorage().Has("str"))
+
+	// Other expired keys are deleted by cleanup
+	master.CleanExpiredKeys()
+	assert.ElementsMatch(t, []string{"str", "list", "zset"}, deletedKeys)
+	assert.False(t, replica.ListStorage().Has("list"))
+	assert.False(t, replica.SortedSetStorage().Has("zset"))
+	assert.Equal(t, master.Snapshot(), replica.Snapshot())
+
+	// Promoted replica deletes expired keys by itself
+	replica.StringStorage().SetWithExpiry("str", "value", clock.Now().Add(time.Second))
+	clock.Advance(time.Minute)
+	replica.SetExpiredKeysDeletion(true, nil)
+	replica.CleanExpiredKeys()
+	assert.False(t, replica.StringStorage().Has("str"))
+}
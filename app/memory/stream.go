@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"maps"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,15 +32,24 @@ type StreamStorage interface {
 	Xadd(streamKey string, requestedStreamID string, entryFields map[string]string) (string, error)
 	Xrange(streamKey string, startID string, endID string) ([]EntryWithStreamID, error)
 	Xread(streamKeys []string, startIDs []string, timeoutMS int) ([]StreamWithEntries, error)
+	Snapshot(keys ...string) map[string]StreamSnapshot
+	Restore(streamKey string, snapshot StreamSnapshot) error
+	Xsetid(streamKey string, lastID string) error
 }
 
 type streamStorage struct {
 	data  map[string]*stream
 	rwMut sync.RWMutex
+	// Expired stream isn't read, even if it isn't deleted yet
+	expired func(key string) bool
 }
 
 func NewStreamStorage() StreamStorage {
-	return &streamStorage{data: make(map[string]*stream)}
+	return newStreamStorage(neverExpired)
+}
+
+func newStreamStorage(expired func(key string) bool) *streamStorage {
+	return &streamStorage{data: make(map[string]*stream), expired: expired}
 }
 
 func (ss *streamStorage) Xadd(streamKey string, requestedStreamID string, entryFields map[string]string) (string, error) {
@@ -74,6 +84,10 @@ type EntryWithStreamID struct {
 }
 
 func (ss *streamStorage) Xrange(streamKey string, startID string, endID string) ([]EntryWithStreamID, error) {
+	if ss.expired(streamKey) {
+		return []EntryWithStreamID{}, nil
+	}
+
 	stream := ss.getOrCreateStream(streamKey)
 	stream.rwMut.RLock()
 	defer stream.rwMut.RUnlock()
@@ -171,6 +185,78 @@ func (ss *streamStorage) Xread(streamKeys []string, startIDs []string, timeoutMS
 	}
 }
 
+type StreamSnapshot struct {
+	TopEntryID string
+	Entries    []EntryWithStreamID
+}
+
+// Only given keys are copied, if any, otherwise all keys
+// Entries are returned in ascending stream ID order
+func (ss *streamStorage) Snapshot(keys ...string) map[string]StreamSnapshot {
+	ss.rwMut.RLock()
+	streams := maps.Clone(selectKeys(ss.data, keys))
+	ss.rwMut.RUnlock()
+
+	snapshot := make(map[string]StreamSnapshot, len(streams))
+	for key, stream := range streams {
+		stream.rwMut.RLock()
+		entriesWithStreamID := make([]EntryWithStreamID, 0, len(stream.data))
+		for streamID, entry := range stream.data {
+			entriesWithStreamID = append(entriesWithStreamID, EntryWithStreamID{StreamID: streamID, Entry: maps.Clone(entry)})
+		}
+		topEntryID := stream.topEntry.streamID
+		stream.rwMut.RUnlock()
+
+		slices.SortFunc(entriesWithStreamID, func(a, b EntryWithStreamID) int {
+			return CompareStreamIDs(a.StreamID, b.StreamID)
+		})
+		snapshot[key] = StreamSnapshot{TopEntryID: topEntryID, Entries: entriesWithStreamID}
+	}
+	return snapshot
+}
+
+func (ss *streamStorage) Restore(streamKey string, snapshot StreamSnapshot) error {
+	topTimeMS, topSeqNum, err := ParseStreamID(snapshot.TopEntryID)
+	if err != nil {
+		return fmt.Errorf("top entry ID parse error: %v", err)
+	}
+
+	stream := ss.getOrCreateStream(streamKey)
+	stream.rwMut.Lock()
+	defer stream.rwMut.Unlock()
+
+	for _, entryWithStreamID := range snapshot.Entries {
+		stream.data[entryWithStreamID.StreamID] = maps.Clone(entryWithStreamID.Entry)
+	}
+	stream.topEntry = topEntry{streamID: snapshot.TopEntryID, timeMS: topTimeMS, seqNum: topSeqNum}
+	return nil
+}
+
+// Last ID can't be smaller than the top entry of the stream
+func (ss *streamStorage) Xsetid(streamKey string, lastID string) error {
+	if !ss.Has(streamKey) {
+		return fmt.Errorf("no such key")
+	}
+
+	timeMS, seqNum, err := ParseStreamID(lastID)
+	if err != nil {
+		return fmt.Errorf("Invalid stream ID specified as stream command argument")
+	}
+
+	stream := ss.getOrCreateStream(streamKey)
+	stream.rwMut.Lock()
+	defer stream.rwMut.Unlock()
+
+	for streamID := range stream.data {
+		if CompareStreamIDs(streamID, lastID) > 0 {
+			return fmt.Errorf("The ID specified in XSETID is smaller than the target stream top item")
+		}
+	}
+
+	stream.topEntry = topEntry{streamID: fmt.Sprintf("%d-%d", timeMS, seqNum), timeMS: timeMS, seqNum: seqNum}
+	return nil
+}
+
 func (ss *streamStorage) Keys() []string {
 	ss.rwMut.RLock()
 	defer ss.rwMut.RUnlock()
@@ -196,6 +282,12 @@ func (ss *streamStorage) Del(key string) {
 	delete(ss.data, key)
 }
 
+func (ss *streamStorage) Flush() {
+	ss.rwMut.Lock()
+	defer ss.rwMut.Unlock()
+	clear(ss.data)
+}
+
 func (ss *streamStorage) getOrCreateStream(streamKey string) *stream {
 	ss.rwMut.RLock()
 	if stream, ok := ss.data[streamKey]; ok {
@@ -222,6 +314,10 @@ func (ss *streamStorage) readStreamsSync(streamKeys []string, startIDs []string)
 	streamsWithEntries := make([]StreamWithEntries, 0)
 
 	for i, streamKey := range streamKeys {
+		if ss.expired(streamKey) {
+			continue
+		}
+
 		stream := ss.getOrCreateStream(streamKey)
 		stream.rwMut.RLock()
 
@@ -365,6 +461,39 @@ func (s *stream) validateAndParseIntervalStreamID(rawStreamID string, isStart bo
 	}
 }
 
+func ParseStreamID(streamID string) (int64, int, error) {
+	rawMSTime, rawSeqNum, ok := strings.Cut(streamID, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("detected wrong stream id format, need <millisecondsTime>-<sequenceNumber>, got: %s", streamID)
+	}
+
+	timeMS, err := strconv.ParseInt(rawMSTime, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("milliseconds time parse int error: %v", err)
+	}
+	seqNum, err := strconv.Atoi(rawSeqNum)
+	if err != nil {
+		return 0, 0, fmt.Errorf("sequence number atoi error: %v", err)
+	}
+	return timeMS, seqNum, nil
+}
+
+// Stream IDs that can't be parsed are considered equal
+func CompareStreamIDs(a, b string) int {
+	aTimeMS, aSeqNum, aErr := ParseStreamID(a)
+	bTimeMS, bSeqNum, bErr := ParseStreamID(b)
+	if aErr != nil || bErr != nil {
+		return 0
+	}
+	if aTimeMS != bTimeMS {
+		if aTimeMS < bTimeMS {
+			return -1
+		}
+		return 1
+	}
+	return aSeqNum - bSeqNum
+}
+
 func generateStreamID() (streamID string, timeMS int64, seqNum int) {
 	seqNum = 0
 	timeMS = time.Now().Local().UnixMilli()
@@ -374,3 +374,34 @@ func TestStreamStorageXreadBlocking(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestStreamStorageXsetid(t *testing.T) {
+	ss := NewStreamStorage()
+	_, err := ss.Xadd("mystream", "5-0", map[string]string{"a": "b"})
+	assert.NoError(t, err)
+
+	t.Run("no such key", func(t *testing.T) {
+		assert.Error(t, ss.Xsetid("missing", "10-0"))
+	})
+
+	t.Run("smaller than top item", func(t *testing.T) {
+		err := ss.Xsetid("mystream", "4-0")
+		assert.Error(t, err)
+		assert.Equal(t, "The ID specified in XSETID is smaller than the target stream top item", err.Error())
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		assert.Error(t, ss.Xsetid("mystream", "invalid"))
+	})
+
+	t.Run("next XADD must be greater than set ID", func(t *testing.T) {
+		assert.NoError(t, ss.Xsetid("mystream", "10-0"))
+
+		_, err := ss.Xadd("mystream", "7-0", map[string]string{"a": "b"})
+		assert.Error(t, err)
+
+		id, err := ss.Xadd("mystream", "10-*", map[string]string{"a": "b"})
+		assert.NoError(t, err)
+		assert.Equal(t, "10-1", id)
+	})
+}
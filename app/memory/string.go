@@ -7,6 +7,15 @@ import (
 	"time"
 )
 
+const (
+	// Active expire cycle checks this many random keys with expiration at once, like Redis
+	ACTIVE_EXPIRE_CYCLE_KEYS_PER_LOOP = 20
+	// Sampling is repeated, while more than this percent of checked keys are expired
+	ACTIVE_EXPIRE_CYCLE_ACCEPTABLE_STALE = 10
+	// Cycle stops after this time, so it doesn't delay commands for long
+	ACTIVE_EXPIRE_CYCLE_TIME_LIMIT = 25 * time.Millisecond
+)
+
 type String struct {
 	Value   string
 	Expires time.Time
@@ -19,18 +28,34 @@ type StringStorage interface {
 	Incr(key string) (int, error)
 	SetWithExpiry(key, value string, expires time.Time)
 	CleanExpiredKeys()
+	ActiveExpireCycle()
 	ItemExpired(item *String) bool
 	ItemHasExpiration(item *String) bool
+	Snapshot(keys ...string) map[string]String
 }
 
 type stringStorage struct {
-	data  map[string]String
-	rwMut sync.RWMutex
+	data map[string]String
+	// Keys with expiration, they are sampled by active expire cycle, like expires dict in Redis
+	expiring map[string]struct{}
+	rwMut    sync.RWMutex
+	now      func() time.Time
+	// Replica keeps expired keys until master deletes them, they are only hidden from reads
+	deleteExpired bool
+	// Called for each deleted expired key after the lock is released
+	onExpiredDelete func(key string)
 }
 
 func NewStringStorage() StringStorage {
+	return newStringStorage(time.Now)
+}
+
+func newStringStorage(now func() time.Time) *stringStorage {
 	return &stringStorage{
-		data: make(map[string]String, 0),
+		data:          make(map[string]String, 0),
+		expiring:      make(map[string]struct{}),
+		now:           now,
+		deleteExpired: true,
 	}
 }
 
@@ -48,20 +73,11 @@ func (ss *stringStorage) Keys() []string {
 	}
 	ss.rwMut.RUnlock()
 
-	if len(expiredKeys) > 0 {
-		ss.rwMut.Lock()
-		for _, key := range expiredKeys {
-			// Repeat checking because of small non-blocking window between RUnlock() and Lock()
-			if item, ok := ss.data[key]; ok && ss.ItemExpired(&item) {
-				delete(ss.data, key)
-			}
-		}
-		ss.rwMut.Unlock()
-	}
-
+	ss.deleteExpiredKeys(expiredKeys)
 	return keys
 }
 
+// Expired keys are present too, so they can be deleted by master DEL
 func (ss *stringStorage) Has(key string) bool {
 	ss.rwMut.RLock()
 	defer ss.rwMut.RUnlock()
@@ -73,54 +89,50 @@ func (ss *stringStorage) Del(key string) {
 	ss.rwMut.Lock()
 	defer ss.rwMut.Unlock()
 	delete(ss.data, key)
+	delete(ss.expiring, key)
+}
+
+func (ss *stringStorage) Flush() {
+	ss.rwMut.Lock()
+	defer ss.rwMut.Unlock()
+	clear(ss.data)
+	clear(ss.expiring)
 }
 
 func (ss *stringStorage) Get(key string) (*String, bool) {
 	ss.rwMut.RLock()
 	item, ok := ss.data[key]
+	ss.rwMut.RUnlock()
 	if !ok {
-		ss.rwMut.RUnlock()
 		return nil, false
 	}
 
 	if ss.ItemExpired(&item) {
-		ss.rwMut.RUnlock()
-		ss.rwMut.Lock()
-		defer ss.rwMut.Unlock()
-
-		// Repeat checking because of small non-blocking window between RUnlock() and Lock()
-		item, ok = ss.data[key]
-		if !ok {
-			return nil, false
-		}
-		if ss.ItemExpired(&item) {
-			delete(ss.data, key)
-			return nil, false
-		}
-
-		return &item, true
+		ss.deleteExpiredKeys([]string{key})
+		return nil, false
 	}
-
-	ss.rwMut.RUnlock()
-	return &item, ok
+	return &item, true
 }
 
 func (ss *stringStorage) Set(key, value string) {
 	ss.rwMut.Lock()
 	defer ss.rwMut.Unlock()
 	ss.data[key] = String{Value: value}
+	delete(ss.expiring, key)
 }
 
 func (ss *stringStorage) SetWithExpiry(key, value string, expires time.Time) {
 	ss.rwMut.Lock()
 	defer ss.rwMut.Unlock()
 
-	if !expires.After(time.Now()) || expires.IsZero() {
+	if !expires.After(ss.now()) || expires.IsZero() {
 		delete(ss.data, key)
+		delete(ss.expiring, key)
 		return
 	}
 
 	ss.data[key] = String{Value: value, Expires: expires}
+	ss.expiring[key] = struct{}{}
 }
 
 func (ss *stringStorage) Incr(key string) (int, error) {
@@ -141,19 +153,109 @@ func (ss *stringStorage) Incr(key string) (int, error) {
 	return incremented, nil
 }
 
+// Does nothing on replica, expired keys are deleted by master
 func (ss *stringStorage) CleanExpiredKeys() {
-	ss.rwMut.Lock()
-	defer ss.rwMut.Unlock()
-
+	ss.rwMut.RLock()
+	var expiredKeys []string
 	for key, item := range ss.data {
 		if ss.ItemExpired(&item) {
+			expiredKeys = append(expiredKeys, key)
+		}
+	}
+	ss.rwMut.RUnlock()
+
+	ss.deleteExpiredKeys(expiredKeys)
+}
+
+// Expired keys, that nobody reads, are deleted soon after expiration, so their DEL reaches replicas
+// Random keys with expiration are checked, sampling is repeated while many of them are expired, like in Redis
+func (ss *stringStorage) ActiveExpireCycle() {
+	start := time.Now()
+	for time.Since(start) < ACTIVE_EXPIRE_CYCLE_TIME_LIMIT {
+		sampled, expiredKeys := ss.sampleExpiredKeys()
+		ss.deleteExpiredKeys(expiredKeys)
+		if len(expiredKeys)*100 <= sampled*ACTIVE_EXPIRE_CYCLE_ACCEPTABLE_STALE {
+			return
+		}
+	}
+}
+
+// Map iteration order is random, so the first keys are random sample
+// Replica doesn't sample, because it doesn't delete expired keys
+func (ss *stringStorage) sampleExpiredKeys() (int, []string) {
+	ss.rwMut.RLock()
+	defer ss.rwMut.RUnlock()
+
+	if !ss.deleteExpired {
+		return 0, nil
+	}
+	sampled := 0
+	var expiredKeys []string
+	for key := range ss.expiring {
+		if sampled == ACTIVE_EXPIRE_CYCLE_KEYS_PER_LOOP {
+			break
+		}
+		sampled++
+		if item := ss.data[key]; ss.ItemExpired(&item) {
+			expiredKeys = append(expiredKeys, key)
+		}
+	}
+	return sampled, expiredKeys
+}
+
+// Keys are checked again, because they could be set again after they were found expired
+func (ss *stringStorage) deleteExpiredKeys(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	ss.rwMut.Lock()
+	if !ss.deleteExpired {
+		ss.rwMut.Unlock()
+		return
+	}
+	deletedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if item, ok := ss.data[key]; ok && ss.ItemExpired(&item) {
 			delete(ss.data, key)
+			delete(ss.expiring, key)
+			deletedKeys = append(deletedKeys, key)
+		}
+	}
+	onExpiredDelete := ss.onExpiredDelete
+	ss.rwMut.Unlock()
+
+	if onExpiredDelete != nil {
+		for _, key := range deletedKeys {
+			onExpiredDelete(key)
+		}
+	}
+}
+
+func (ss *stringStorage) setExpiredKeysDeletion(deleteExpired bool, onExpiredDelete func(key string)) {
+	ss.rwMut.Lock()
+	defer ss.rwMut.Unlock()
+	ss.deleteExpired = deleteExpired
+	ss.onExpiredDelete = onExpiredDelete
+}
+
+// Only given keys are copied, if any, otherwise all keys
+func (ss *stringStorage) Snapshot(keys ...string) map[string]String {
+	ss.rwMut.RLock()
+	defer ss.rwMut.RUnlock()
+
+	data := selectKeys(ss.data, keys)
+	snapshot := make(map[string]String, len(data))
+	for key, item := range data {
+		if !ss.ItemExpired(&item) {
+			snapshot[key] = item
 		}
 	}
+	return snapshot
 }
 
 func (ss *stringStorage) ItemExpired(item *String) bool {
-	return ss.ItemHasExpiration(item) && item.Expires.Before(time.Now())
+	return ss.ItemHasExpiration(item) && item.Expires.Before(ss.now())
 }
 
 func (ss *stringStorage) ItemHasExpiration(item *String) bool {
@@ -136,6 +136,48 @@ func TestStringStorageCleanExpiredKeys(t *testing.T) {
 	})
 }
 
+// Expired keys are deleted without reads, persistent and not expired keys are kept
+func TestStringStorageActiveExpireCycle(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	storage := newStringStorage(clock.Now)
+	var deletedKeys []string
+	storage.setExpiredKeysDeletion(true, func(key string) {
+		deletedKeys = append(deletedKeys, key)
+	})
+
+	var expiredKeys []string
+	for i := range 100 {
+		key := fmt.Sprintf("expired:%d", i)
+		storage.SetWithExpiry(key, "value", clock.Now().Add(time.Second))
+		expiredKeys = append(expiredKeys, key)
+		storage.Set(fmt.Sprintf("persistent:%d", i), "value")
+	}
+	clock.Advance(time.Minute)
+
+	t.Run("replica keeps expired keys", func(t *testing.T) {
+		storage.setExpiredKeysDeletion(false, nil)
+		storage.ActiveExpireCycle()
+		assert.True(t, storage.Has("expired:0"))
+		storage.setExpiredKeysDeletion(true, func(key string) {
+			deletedKeys = append(deletedKeys, key)
+		})
+	})
+
+	t.Run("all sampled keys are expired", func(t *testing.T) {
+		storage.ActiveExpireCycle()
+		assert.ElementsMatch(t, expiredKeys, deletedKeys)
+		assert.Len(t, storage.Keys(), 100)
+	})
+
+	t.Run("no expired keys", func(t *testing.T) {
+		deletedKeys = nil
+		storage.SetWithExpiry("later", "value", clock.Now().Add(time.Hour))
+		storage.ActiveExpireCycle()
+		assert.Empty(t, deletedKeys)
+		assert.True(t, storage.Has("later"))
+	})
+}
+
 func TestStringStorageDel(t *testing.T) {
 	storage := NewStringStorage()
 
@@ -29,3 +29,17 @@ func handleRangeIndexes(startIdx, stopIdx, l int) (int, int, error) {
 
 	return startIdx, stopIdx, nil
 }
+
+// Returns data itself, when no keys are given, otherwise only given keys, which exist
+func selectKeys[V any](data map[string]V, keys []string) map[string]V {
+	if len(keys) == 0 {
+		return data
+	}
+	selected := make(map[string]V, len(keys))
+	for _, key := range keys {
+		if value, ok := data[key]; ok {
+			selected[key] = value
+		}
+	}
+	return selected
+}
@@ -0,0 +1,231 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+const (
+	// Cached connection to target is closed, if it isn't used for this time, like in Redis
+	MIGRATE_SOCKET_CACHE_TTL = 10 * time.Second
+	// The least recently used connection is closed, when the cache is full
+	MIGRATE_SOCKET_CACHE_ITEMS   = 64
+	MIGRATE_CACHE_CLEANUP_PERIOD = time.Second
+)
+
+type Controller interface {
+	// Sends commands to target in one batch over cached connection and returns their replies in the same order
+	// Timeout limits connecting and every read or write, connection is closed on any error, so the next request dials again
+	Request(addr string, timeout time.Duration, commands [][]string) ([]resp.Value, error)
+}
+
+// Connection to target is used by one MIGRATE at a time, so replies aren't mixed
+type cachedConn struct {
+	conn       net.Conn
+	buf        []byte
+	lastUsedAt time.Time
+	// Connection is closed and removed from cache, the next request creates the new one
+	closed bool
+	mut    sync.Mutex
+}
+
+type controller struct {
+	respController resp.Controller
+	conns          map[string]*cachedConn
+	mut            sync.Mutex
+	cleanupOnce    sync.Once
+}
+
+func NewController(respController resp.Controller) Controller {
+	return &controller{
+		respController: respController,
+		conns:          make(map[string]*cachedConn),
+	}
+}
+
+func (c *controller) Request(addr string, timeout time.Duration, commands [][]string) ([]resp.Value, error) {
+	c.cleanupOnce.Do(func() {
+		go c.closeIdleConnsPeriodically()
+	})
+
+	cc, err := c.acquireConn(addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.mut.Unlock()
+
+	replies, err := cc.roundTrip(commands, timeout, c.respController)
+	if err != nil {
+		c.closeConn(addr, cc)
+		return nil, err
+	}
+	cc.lastUsedAt = time.Now()
+	return replies, nil
+}
+
+// Returns locked connection, it is dialed, if target doesn't have cached one
+func (c *controller) acquireConn(addr string, timeout time.Duration) (*cachedConn, error) {
+	for {
+		c.mut.Lock()
+		cc, ok := c.conns[addr]
+		if !ok {
+			if len(c.conns) >= MIGRATE_SOCKET_CACHE_ITEMS {
+				c.evictLeastRecentlyUsed()
+			}
+			cc = &cachedConn{}
+			c.conns[addr] = cc
+		}
+		c.mut.Unlock()
+
+		cc.mut.Lock()
+		// Connection is closed by other request or by cleanup, while it was waited for
+		if cc.closed {
+			cc.mut.Unlock()
+			continue
+		}
+		if cc.conn == nil {
+			conn, err := net.DialTimeout("tcp", addr, timeout)
+			if err != nil {
+				c.closeConn(addr, cc)
+				cc.mut.Unlock()
+				return nil, fmt.Errorf("error or timeout connecting to the client: %v", err)
+			}
+			cc.conn = conn
+			cc.lastUsedAt = time.Now()
+		}
+		return cc, nil
+	}
+}
+
+// Commands are pipelined, every reply is waited for timeout at most
+func (cc *cachedConn) roundTrip(commands [][]string, timeout time.Duration, respController resp.Controller) ([]resp.Value, error) {
+	var b []byte
+	for _, commandAndArgs := range commands {
+		encoded, err := resp.CreateBulkStringArray(commandAndArgs...).Encode()
+		if err != nil {
+			return nil, fmt.Errorf("encode %s command error: %v", commandAndArgs[0], err)
+		}
+		b = append(b, encoded...)
+	}
+
+	if err := cc.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("error or timeout writing to target instance: %v", err)
+	}
+	if _, err := cc.conn.Write(b); err != nil {
+		return nil, fmt.Errorf("error or timeout writing to target instance: %v", err)
+	}
+
+	replies := make([]resp.Value, 0, len(commands))
+	for range commands {
+		if err := cc.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, fmt.Errorf("error or timeout reading from target instance: %v", err)
+		}
+		value, rest, err := readValue(cc.conn, cc.buf, respController)
+		if err != nil {
+			return nil, fmt.Errorf("error or timeout reading from target instance: %v", err)
+		}
+		cc.buf = rest
+		replies = append(replies, value)
+	}
+	return replies, nil
+}
+
+// Connection is nil, until it is dialed by the request, that created it
+// Caller holds cc mut
+func (cc *cachedConn) close() {
+	cc.closed = true
+	if cc.conn != nil {
+		cc.conn.Close()
+	}
+}
+
+// Caller holds cc mut
+func (c *controller) closeConn(addr string, cc *cachedConn) {
+	cc.close()
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.conns[addr] == cc {
+		delete(c.conns, addr)
+	}
+}
+
+// Connections in use are skipped, so the cache can exceed its size, until they are released
+// Caller holds mut
+func (c *controller) evictLeastRecentlyUsed() {
+	var lruAddr string
+	var lru *cachedConn
+	for addr, cc := range c.conns {
+		if !cc.mut.TryLock() {
+			continue
+		}
+		if lru == nil || cc.lastUsedAt.Before(lru.lastUsedAt) {
+			if lru != nil {
+				lru.mut.Unlock()
+			}
+			lruAddr, lru = addr, cc
+			continue
+		}
+		cc.mut.Unlock()
+	}
+	if lru == nil {
+		return
+	}
+
+	lru.close()
+	delete(c.conns, lruAddr)
+	lru.mut.Unlock()
+}
+
+func (c *controller) closeIdleConnsPeriodically() {
+	ticker := time.NewTicker(MIGRATE_CACHE_CLEANUP_PERIOD)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.closeIdleConns()
+	}
+}
+
+func (c *controller) closeIdleConns() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for addr, cc := range c.conns {
+		if !cc.mut.TryLock() {
+			continue
+		}
+		if time.Since(cc.lastUsedAt) > MIGRATE_SOCKET_CACHE_TTL {
+			cc.close()
+			delete(c.conns, addr)
+		}
+		cc.mut.Unlock()
+	}
+}
+
+// Reads from connection, until the buffer contains the whole RESP value, returns the value and the rest of the buffer
+// Invalid data is returned as error, as more bytes can't make it valid
+func readValue(conn net.Conn, buf []byte, respController resp.Controller) (resp.Value, []byte, error) {
+	tmp := make([]byte, 4096)
+	parser := respController.NewParser()
+	for {
+		if len(buf) > 0 {
+			rest, value, err := parser.Decode(buf)
+			if err == nil {
+				return value, rest, nil
+			}
+			if !errors.Is(err, resp.ErrIncomplete) {
+				return nil, nil, err
+			}
+		}
+		n, err := conn.Read(tmp)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf = append(buf, tmp[:n]...)
+	}
+}
@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Target replies +OK to every command, if it is not silent, and counts accepted connections
+type testTarget struct {
+	listener net.Listener
+	accepted atomic.Int32
+	silent   bool
+}
+
+func newTestTarget(t *testing.T, silent bool) *testTarget {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	target := &testTarget{listener: listener, silent: silent}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			target.accepted.Add(1)
+			go target.serve(conn)
+		}
+	}()
+	return target
+}
+
+func (target *testTarget) serve(conn net.Conn) {
+	defer conn.Close()
+
+	respController := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN)
+	var buf []byte
+	for {
+		_, rest, err := readValue(conn, buf, respController)
+		if err != nil {
+			return
+		}
+		buf = rest
+		if target.silent {
+			continue
+		}
+		if _, err := conn.Write([]byte("+OK\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func (target *testTarget) addr() string {
+	return target.listener.Addr().String()
+}
+
+func TestRequestReusesConn(t *testing.T) {
+	target := newTestTarget(t, false)
+	c := NewController(resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN)).(*controller)
+
+	for range 3 {
+		replies, err := c.Request(target.addr(), time.Second, [][]string{{"AUTH", "pw"}, {"RESTORE", "key", "0", "payload"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []resp.Value{resp.SimpleString{Value: "OK"}, resp.SimpleString{Value: "OK"}}, replies)
+	}
+	assert.Equal(t, int32(1), target.accepted.Load())
+	assert.Len(t, c.conns, 1)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	target := newTestTarget(t, true)
+	c := NewController(resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN)).(*controller)
+
+	_, err := c.Request(target.addr(), 50*time.Millisecond, [][]string{{"RESTORE", "key", "0", "payload"}})
+	assert.ErrorContains(t, err, "error or timeout reading from target instance")
+	assert.Empty(t, c.conns)
+}
+
+func TestRequestConnectError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	c := NewController(resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN)).(*controller)
+	_, err = c.Request(addr, 50*time.Millisecond, [][]string{{"RESTORE", "key", "0", "payload"}})
+	assert.ErrorContains(t, err, "error or timeout connecting")
+	assert.Empty(t, c.conns)
+}
+
+func TestCloseIdleConns(t *testing.T) {
+	target := newTestTarget(t, false)
+	c := NewController(resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN)).(*controller)
+
+	_, err := c.Request(target.addr(), time.Second, [][]string{{"PING"}})
+	assert.NoError(t, err)
+
+	c.mut.Lock()
+	c.conns[target.addr()].lastUsedAt = time.Now().Add(-MIGRATE_SOCKET_CACHE_TTL - time.Second)
+	c.mut.Unlock()
+	c.closeIdleConns()
+	assert.Empty(t, c.conns)
+
+	_, err = c.Request(target.addr(), time.Second, [][]string{{"PING"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), target.accepted.Load())
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	c := NewController(resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN)).(*controller)
+	now := time.Now()
+	c.conns["old"] = &cachedConn{lastUsedAt: now.Add(-time.Minute)}
+	c.conns["new"] = &cachedConn{lastUsedAt: now}
+	inUse := &cachedConn{lastUsedAt: now.Add(-time.Hour)}
+	inUse.mut.Lock()
+	c.conns["inUse"] = inUse
+
+	c.evictLeastRecentlyUsed()
+	assert.NotContains(t, c.conns, "old")
+	assert.Contains(t, c.conns, "new")
+	assert.Contains(t, c.conns, "inUse")
+}
@@ -0,0 +1,271 @@
+package aof
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+const FSYNC_INTERVAL = time.Second
+
+type Controller interface {
+	// Replays AOF file, returns false if there is no AOF file
+	// Storage is expected to be empty, AOF must be loaded before it is opened
+	Load(replay func(commandAndArgs []string)) (bool, error)
+	// Opens AOF file for appending, if new file is created, it starts with RDB preamble of current storage
+	Open() error
+	// Appends write command, it is no-op until AOF is opened
+	Append(commandAndArgs []string)
+	// Replaces AOF with RDB preamble of current storage, e.g. when replica storage is replaced by full resync
+	// It is no-op until AOF is opened
+	Rewrite() error
+	Close() error
+}
+
+type controller struct {
+	args    *config.Args
+	storage memory.MultiTypeStorage
+	file    *os.File
+	// Pending fsync for everysec policy
+	dirty bool
+	done  chan struct{}
+	mut   sync.Mutex
+}
+
+func NewController(args *config.Args, storage memory.MultiTypeStorage) Controller {
+	return &controller{
+		args:    args,
+		storage: storage,
+		done:    make(chan struct{}),
+	}
+}
+
+func (c *controller) Load(replay func(commandAndArgs []string)) (bool, error) {
+	path := c.path()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("AOF file read error: %v", err)
+	}
+
+	pos := 0
+	if bytes.HasPrefix(b, []byte("REDIS")) {
+		snapshot, size, err := rdb.DecodePrefix(b)
+		if err != nil {
+			return false, fmt.Errorf("AOF RDB preamble decode error: %v", err)
+		}
+		err = c.storage.Restore(snapshot)
+		if err != nil {
+			return false, fmt.Errorf("AOF RDB preamble restore error: %v", err)
+		}
+		pos = size
+	}
+
+	commandsCount := 0
+	for pos < len(b) {
+		commandAndArgs, size, err := parseRecord(b[pos:])
+		if errors.Is(err, errTruncatedRecord) {
+			log.Printf("AOF file %s ends with truncated record at offset %d, it is truncated to the last valid record\n", path, pos)
+			err = os.Truncate(path, int64(pos))
+			if err != nil {
+				return false, fmt.Errorf("AOF file truncate error: %v", err)
+			}
+			break
+		}
+		if err != nil {
+			return false, fmt.Errorf("bad AOF file format at offset %d: %v", pos, err)
+		}
+
+		replay(commandAndArgs)
+		commandsCount++
+		pos += size
+	}
+
+	log.Printf("AOF file loaded, %d commands replayed\n", commandsCount)
+	return true, nil
+}
+
+func (c *controller) Open() error {
+	file, err := os.OpenFile(c.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("AOF file open error: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("AOF file stat error: %v", err)
+	}
+
+	// Otherwise storage, loaded from RDB file, would be lost on the next start
+	if info.Size() == 0 && len(c.storage.Keys()) > 0 {
+		err = writeRDBPreamble(file, c.storage.Snapshot())
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	c.mut.Lock()
+	c.file = file
+	c.mut.Unlock()
+
+	if c.args.AppendFsync == config.APPENDFSYNC_EVERYSEC {
+		go c.fsyncEverySecond()
+	}
+	return nil
+}
+
+func (c *controller) Append(commandAndArgs []string) {
+	b, err := resp.CreateBulkStringArray(commandAndArgs...).Encode()
+	if err != nil {
+		log.Printf("AOF encode command error: %v\n", err)
+		return
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.file == nil {
+		return
+	}
+
+	_, err = c.file.Write(b)
+	if err != nil {
+		log.Printf("AOF write error: %v\n", err)
+		return
+	}
+
+	switch c.args.AppendFsync {
+	case config.APPENDFSYNC_ALWAYS:
+		err = c.file.Sync()
+		if err != nil {
+			log.Printf("AOF fsync error: %v\n", err)
+		}
+	case config.APPENDFSYNC_EVERYSEC:
+		c.dirty = true
+	}
+}
+
+// AOF is written into temp file first and then renamed, so it is never left half-written
+func (c *controller) Rewrite() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.args.DBDir, "temp-rewriteaof-*.aof")
+	if err != nil {
+		return fmt.Errorf("AOF rewrite create temp file error: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	// Temp file is created with 0600 permissions, AOF keeps the ones it is opened with
+	err = tmp.Chmod(0644)
+	if err == nil {
+		err = writeRDBPreamble(tmp, c.storage.Snapshot())
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("AOF rewrite close temp file error: %v", err)
+	}
+	if err = os.Rename(tmpPath, c.path()); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("AOF rewrite rename temp file error: %v", err)
+	}
+
+	file, err := os.OpenFile(c.path(), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("AOF file open error: %v", err)
+	}
+	c.file.Close()
+	c.file = file
+	c.dirty = false
+
+	log.Printf("AOF file %s is rewritten\n", c.path())
+	return nil
+}
+
+func (c *controller) Close() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	close(c.done)
+
+	err := c.file.Sync()
+	if err != nil {
+		c.file.Close()
+		c.file = nil
+		return fmt.Errorf("AOF fsync error: %v", err)
+	}
+	err = c.file.Close()
+	c.file = nil
+	return err
+}
+
+// Fsync is done without lock, so appends aren't blocked by slow disk
+func (c *controller) fsyncEverySecond() {
+	ticker := time.NewTicker(FSYNC_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.mut.Lock()
+		file := c.file
+		dirty := c.dirty
+		c.dirty = false
+		c.mut.Unlock()
+
+		if file == nil || !dirty {
+			continue
+		}
+		err := file.Sync()
+		if err != nil && !errors.Is(err, os.ErrClosed) {
+			log.Printf("AOF fsync error: %v\n", err)
+		}
+	}
+}
+
+func (c *controller) path() string {
+	return filepath.Join(c.args.DBDir, c.args.AppendFilename)
+}
+
+func writeRDBPreamble(file *os.File, snapshot *memory.Snapshot) error {
+	b, err := rdb.Encode(snapshot)
+	if err != nil {
+		return fmt.Errorf("AOF RDB preamble encode error: %v", err)
+	}
+
+	_, err = file.Write(b)
+	if err != nil {
+		return fmt.Errorf("AOF RDB preamble write error: %v", err)
+	}
+	return file.Sync()
+}
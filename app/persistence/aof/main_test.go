@@ -0,0 +1,195 @@
+package aof
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+func newTestArgs(t *testing.T, fsync string) *config.Args {
+	return &config.Args{
+		DBDir:          t.TempDir(),
+		AppendOnly:     true,
+		AppendFilename: "appendonly.aof",
+		AppendFsync:    fsync,
+	}
+}
+
+func loadCommands(t *testing.T, c Controller) ([][]string, bool) {
+	replayed := make([][]string, 0)
+	loaded, err := c.Load(func(commandAndArgs []string) {
+		replayed = append(replayed, commandAndArgs)
+	})
+	assert.NoError(t, err)
+	return replayed, loaded
+}
+
+func TestAppendAndLoad(t *testing.T) {
+	for _, fsync := range []string{config.APPENDFSYNC_ALWAYS, config.APPENDFSYNC_EVERYSEC, config.APPENDFSYNC_NO} {
+		t.Run(fsync, func(t *testing.T) {
+			args := newTestArgs(t, fsync)
+
+			c := NewController(args, memory.NewMultiTypeStorage())
+			replayed, loaded := loadCommands(t, c)
+			assert.False(t, loaded)
+			assert.Empty(t, replayed)
+
+			c.Append([]string{"SET", "ignored", "before open"})
+			assert.NoError(t, c.Open())
+			c.Append([]string{"SET", "key", "value"})
+			c.Append([]string{"RPUSH", "list", "a", "b"})
+			assert.NoError(t, c.Close())
+
+			replayed, loaded = loadCommands(t, NewController(args, memory.NewMultiTypeStorage()))
+			assert.True(t, loaded)
+			assert.Equal(t, [][]string{{"SET", "key", "value"}, {"RPUSH", "list", "a", "b"}}, replayed)
+		})
+	}
+}
+
+func TestAppendAndLoadBinarySafe(t *testing.T) {
+	args := newTestArgs(t, config.APPENDFSYNC_ALWAYS)
+	commands := [][]string{
+		{"SET", "", ""},
+		{"RPUSH", "list", "\x00", "", "a\r\nb", "*1\r\n$4\r\nPING\r\n"},
+		{"SET", "blob", strings.Repeat("\x00\xff\r\n", 1024*1024)},
+	}
+
+	c := NewController(args, memory.NewMultiTypeStorage())
+	assert.NoError(t, c.Open())
+	for _, command := range commands {
+		c.Append(command)
+	}
+	assert.NoError(t, c.Close())
+
+	replayed, loaded := loadCommands(t, NewController(args, memory.NewMultiTypeStorage()))
+	assert.True(t, loaded)
+	assert.Equal(t, commands, replayed)
+}
+
+func TestLoadTruncatedTail(t *testing.T) {
+	args := newTestArgs(t, config.APPENDFSYNC_ALWAYS)
+	path := filepath.Join(args.DBDir, args.AppendFilename)
+
+	valid := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$3\r\nval\r\n"
+	err := os.WriteFile(path, []byte(valid+"*3\r\n$3\r\nSET\r\n$3\r\nke"), 0644)
+	assert.NoError(t, err)
+
+	c := NewController(args, memory.NewMultiTypeStorage())
+	replayed, loaded := loadCommands(t, c)
+	assert.True(t, loaded)
+	assert.Equal(t, [][]string{{"SET", "key", "val"}}, replayed)
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, valid, string(b))
+
+	// New commands are appended right after the last valid record
+	assert.NoError(t, c.Open())
+	c.Append([]string{"DEL", "key"})
+	assert.NoError(t, c.Close())
+
+	replayed, _ = loadCommands(t, NewController(args, memory.NewMultiTypeStorage()))
+	assert.Equal(t, [][]string{{"SET", "key", "val"}, {"DEL", "key"}}, replayed)
+}
+
+func TestLoadCorruptedRecord(t *testing.T) {
+	args := newTestArgs(t, config.APPENDFSYNC_ALWAYS)
+	path := filepath.Join(args.DBDir, args.AppendFilename)
+
+	content := []byte("*1\r\n$4\r\nPING\r\ngarbage*1\r\n$4\r\nPING\r\n")
+	err := os.WriteFile(path, content, 0644)
+	assert.NoError(t, err)
+
+	_, err = NewController(args, memory.NewMultiTypeStorage()).Load(func([]string) {})
+	assert.ErrorContains(t, err, "offset 14")
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, content, b)
+}
+
+// Huge count or length can't be complete in file, so record is truncated like short tail, nothing is allocated by them
+func TestLoadHugeLength(t *testing.T) {
+	valid := "*1\r\n$4\r\nPING\r\n"
+	tests := []struct {
+		name   string
+		record string
+	}{
+		{name: "Huge args count", record: "*999999999999\r\n$4\r\nPING\r\n"},
+		{name: "Huge arg length", record: "*1\r\n$999999999999\r\nPING\r\n"},
+		{name: "Overflowing arg length", record: "*1\r\n$9223372036854775807\r\nPING\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := newTestArgs(t, config.APPENDFSYNC_ALWAYS)
+			path := filepath.Join(args.DBDir, args.AppendFilename)
+			err := os.WriteFile(path, []byte(valid+test.record), 0644)
+			assert.NoError(t, err)
+
+			replayed, loaded := loadCommands(t, NewController(args, memory.NewMultiTypeStorage()))
+			assert.True(t, loaded)
+			assert.Equal(t, [][]string{{"PING"}}, replayed)
+
+			b, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, valid, string(b))
+		})
+	}
+}
+
+func TestRDBPreamble(t *testing.T) {
+	args := newTestArgs(t, config.APPENDFSYNC_ALWAYS)
+
+	storage := memory.NewMultiTypeStorage()
+	storage.StringStorage().Set("fromRDB", "value")
+	storage.ListStorage().Rpush("list", "a")
+
+	c := NewController(args, storage)
+	assert.NoError(t, c.Open())
+	c.Append([]string{"RPUSH", "list", "b"})
+	assert.NoError(t, c.Close())
+
+	restored := memory.NewMultiTypeStorage()
+	replayed, loaded := loadCommands(t, NewController(args, restored))
+	assert.True(t, loaded)
+	assert.Equal(t, [][]string{{"RPUSH", "list", "b"}}, replayed)
+
+	item, ok := restored.StringStorage().Get("fromRDB")
+	assert.True(t, ok)
+	assert.Equal(t, "value", item.Value)
+	assert.Equal(t, []string{"a"}, restored.ListStorage().Lrange("list", 0, -1))
+}
+
+func TestRewrite(t *testing.T) {
+	args := newTestArgs(t, config.APPENDFSYNC_ALWAYS)
+
+	storage := memory.NewMultiTypeStorage()
+	c := NewController(args, storage)
+	assert.NoError(t, c.Open())
+	c.Append([]string{"SET", "stale", "value"})
+
+	// Storage is replaced without commands, e.g. by full resync
+	storage.Del("stale")
+	storage.StringStorage().Set("key", "value")
+	assert.NoError(t, c.Rewrite())
+	c.Append([]string{"RPUSH", "list", "a"})
+	assert.NoError(t, c.Close())
+
+	restored := memory.NewMultiTypeStorage()
+	replayed, loaded := loadCommands(t, NewController(args, restored))
+	assert.True(t, loaded)
+	assert.Equal(t, [][]string{{"RPUSH", "list", "a"}}, replayed)
+	assert.False(t, restored.StringStorage().Has("stale"))
+
+	item, ok := restored.StringStorage().Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "value", item.Value)
+}
@@ -0,0 +1,71 @@
+package aof
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errTruncatedRecord = errors.New("truncated AOF record")
+
+// Record is a command written as RESP array of bulk strings: *<count>\r\n$<len>\r\n<arg>\r\n...
+// Returns command with args and record size in bytes
+func parseRecord(b []byte) ([]string, int, error) {
+	pos := 0
+
+	readLen := func(prefix byte) (int, error) {
+		if pos >= len(b) {
+			return 0, errTruncatedRecord
+		}
+		if b[pos] != prefix {
+			return 0, fmt.Errorf("expected '%c', got: %q", prefix, b[pos])
+		}
+
+		lineEnd := bytes.Index(b[pos:], []byte("\r\n"))
+		if lineEnd == -1 {
+			return 0, errTruncatedRecord
+		}
+		l, err := strconv.Atoi(string(b[pos+1 : pos+lineEnd]))
+		if err != nil {
+			return 0, fmt.Errorf("length atoi error: %v", err)
+		}
+		if l < 0 {
+			return 0, fmt.Errorf("negative length: %d", l)
+		}
+
+		pos += lineEnd + 2
+		return l, nil
+	}
+
+	argsCount, err := readLen('*')
+	if err != nil {
+		return nil, 0, err
+	}
+	if argsCount == 0 {
+		return nil, 0, fmt.Errorf("empty command")
+	}
+	// Every argument takes some bytes, so bigger count can't be complete, it isn't used for allocation
+	if argsCount > len(b)-pos {
+		return nil, 0, errTruncatedRecord
+	}
+
+	commandAndArgs := make([]string, 0, argsCount)
+	for range argsCount {
+		argLen, err := readLen('$')
+		if err != nil {
+			return nil, 0, err
+		}
+		// Length is compared with the rest of bytes, because adding to it can overflow
+		if argLen > len(b)-pos-2 {
+			return nil, 0, errTruncatedRecord
+		}
+		if b[pos+argLen] != '\r' || b[pos+argLen+1] != '\n' {
+			return nil, 0, fmt.Errorf("argument isn't terminated by '\\r\\n'")
+		}
+
+		commandAndArgs = append(commandAndArgs, string(b[pos:pos+argLen]))
+		pos += argLen + 2
+	}
+	return commandAndArgs, pos, nil
+}
@@ -0,0 +1,90 @@
+package aof
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRecord(t *testing.T) {
+	tests := []struct {
+		name         string
+		buffer       []byte
+		expected     []string
+		expectedSize int
+		expectedErr  error
+		anyErr       bool
+	}{
+		{
+			name:         "Valid record",
+			buffer:       []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$3\r\nval\r\n"),
+			expected:     []string{"SET", "key", "val"},
+			expectedSize: 31,
+		},
+		{
+			name:         "Valid record followed by another one",
+			buffer:       []byte("*1\r\n$4\r\nPING\r\n*1\r\n"),
+			expected:     []string{"PING"},
+			expectedSize: 14,
+		},
+		{
+			name:         "Binary safe argument",
+			buffer:       []byte("*2\r\n$3\r\nGET\r\n$4\r\na\r\nb\r\n"),
+			expected:     []string{"GET", "a\r\nb"},
+			expectedSize: 23,
+		},
+		{
+			name:         "Empty and NUL arguments",
+			buffer:       []byte("*3\r\n$3\r\nSET\r\n$0\r\n\r\n$1\r\n\x00\r\n"),
+			expected:     []string{"SET", "", "\x00"},
+			expectedSize: 26,
+		},
+		{
+			name:        "Truncated count line",
+			buffer:      []byte("*3\r"),
+			expectedErr: errTruncatedRecord,
+		},
+		{
+			name:        "Truncated argument",
+			buffer:      []byte("*3\r\n$3\r\nSET\r\n$3\r\nke"),
+			expectedErr: errTruncatedRecord,
+		},
+		{
+			name:        "Missing arguments",
+			buffer:      []byte("*3\r\n$3\r\nSET\r\n"),
+			expectedErr: errTruncatedRecord,
+		},
+		{
+			name:   "Not an array",
+			buffer: []byte("+OK\r\n"),
+			anyErr: true,
+		},
+		{
+			name:   "Wrong argument length",
+			buffer: []byte("*1\r\n$2\r\nPING\r\n"),
+			anyErr: true,
+		},
+		{
+			name:   "Invalid count",
+			buffer: []byte("*x\r\n"),
+			anyErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, size, err := parseRecord(test.buffer)
+			switch {
+			case test.expectedErr != nil:
+				assert.ErrorIs(t, err, test.expectedErr)
+			case test.anyErr:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, errTruncatedRecord)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, result)
+				assert.Equal(t, test.expectedSize, size)
+			}
+		})
+	}
+}
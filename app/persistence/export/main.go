@@ -0,0 +1,216 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
+)
+
+// Big keys are split into several commands, each one is bounded by items count and size
+const (
+	MAX_ITEMS_PER_COMMAND = 64
+	MAX_BYTES_PER_COMMAND = 1024 * 1024
+)
+
+// Empty pattern and type match every key
+type Filter struct {
+	Pattern string
+	Type    string
+}
+
+func (f Filter) match(key, valueType string) bool {
+	if f.Type != "" && f.Type != valueType {
+		return false
+	}
+	return f.Pattern == "" || utils.MatchPattern(f.Pattern, key)
+}
+
+// Returns commands, that recreate snapshot keys, keys are ordered by name
+// Already expired keys are skipped
+func Commands(snapshot *memory.Snapshot, filter Filter) [][]string {
+	now := time.Now()
+	commands := make([][]string, 0)
+
+	for _, key := range sortedKeys(snapshot) {
+		if item, ok := snapshot.Strings[key]; ok {
+			if !filter.match(key, memory.TYPE_STRING) || isExpired(item.Expires, now) {
+				continue
+			}
+			commands = append(commands, stringCommand(key, item))
+			continue
+		}
+
+		expires := snapshot.Expires[key]
+		if isExpired(expires, now) {
+			continue
+		}
+
+		var keyCommands [][]string
+		if values, ok := snapshot.Lists[key]; ok && filter.match(key, memory.TYPE_LIST) {
+			keyCommands = listCommands(key, values)
+		} else if members, ok := snapshot.SortedSets[key]; ok && filter.match(key, memory.TYPE_SORTED_SET) {
+			keyCommands = sortedSetCommands(key, members)
+		} else if stream, ok := snapshot.Streams[key]; ok && filter.match(key, memory.TYPE_STREAM) {
+			keyCommands = streamCommands(key, stream)
+		}
+		if len(keyCommands) == 0 {
+			continue
+		}
+
+		commands = append(commands, keyCommands...)
+		if !expires.IsZero() {
+			commands = append(commands, []string{"PEXPIREAT", key, strconv.FormatInt(expires.UnixMilli(), 10)})
+		}
+	}
+	return commands
+}
+
+// Encodes commands as RESP arrays of bulk strings, e.g. for redis-cli --pipe
+func Encode(commands [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, commandAndArgs := range commands {
+		b, err := resp.CreateBulkStringArray(commandAndArgs...).Encode()
+		if err != nil {
+			return nil, fmt.Errorf("encode command %s error: %v", commandAndArgs[0], err)
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+func WriteFile(path string, snapshot *memory.Snapshot, filter Filter) error {
+	b, err := Encode(Commands(snapshot, filter))
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, b, 0644)
+	if err != nil {
+		return fmt.Errorf("write export file error: %v", err)
+	}
+	return nil
+}
+
+func stringCommand(key string, item memory.String) []string {
+	if item.Expires.IsZero() {
+		return []string{"SET", key, item.Value}
+	}
+	return []string{"SET", key, item.Value, "PXAT", strconv.FormatInt(item.Expires.UnixMilli(), 10)}
+}
+
+func listCommands(key string, values []string) [][]string {
+	return batchCommands([]string{"RPUSH", key}, len(values), func(i int) []string {
+		return []string{values[i]}
+	})
+}
+
+func sortedSetCommands(key string, members []memory.SortedSetMember) [][]string {
+	return batchCommands([]string{"ZADD", key}, len(members), func(i int) []string {
+		return []string{formatScore(members[i].Score), members[i].Member}
+	})
+}
+
+// Each entry is added with its explicit ID, then last ID of the stream is restored
+// Stream without entries can't be recreated by XADD, so it is skipped
+func streamCommands(key string, stream memory.StreamSnapshot) [][]string {
+	if len(stream.Entries) == 0 {
+		return nil
+	}
+
+	commands := make([][]string, 0, len(stream.Entries)+1)
+	for _, entry := range stream.Entries {
+		fields := make([]string, 0, len(entry.Entry))
+		for field := range entry.Entry {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+
+		command := []string{"XADD", key, entry.StreamID}
+		for _, field := range fields {
+			command = append(command, field, entry.Entry[field])
+		}
+		commands = append(commands, command)
+	}
+
+	lastEntryID := stream.Entries[len(stream.Entries)-1].StreamID
+	if memory.CompareStreamIDs(stream.TopEntryID, lastEntryID) > 0 {
+		commands = append(commands, []string{"XSETID", key, stream.TopEntryID})
+	}
+	return commands
+}
+
+// Items are appended to the command until one of the limits is reached, each command has at least one item
+func batchCommands(prefix []string, itemsCount int, item func(i int) []string) [][]string {
+	commands := make([][]string, 0)
+	var command []string
+	commandItems := 0
+	commandBytes := 0
+
+	for i := range itemsCount {
+		args := item(i)
+		argsBytes := 0
+		for _, arg := range args {
+			argsBytes += len(arg)
+		}
+
+		if command != nil && (commandItems == MAX_ITEMS_PER_COMMAND || commandBytes+argsBytes > MAX_BYTES_PER_COMMAND) {
+			commands = append(commands, command)
+			command = nil
+		}
+		if command == nil {
+			command = slices.Clone(prefix)
+			commandItems = 0
+			commandBytes = 0
+		}
+
+		command = append(command, args...)
+		commandItems++
+		commandBytes += argsBytes
+	}
+
+	if command != nil {
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	default:
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+}
+
+func isExpired(expires time.Time, now time.Time) bool {
+	return !expires.IsZero() && !expires.After(now)
+}
+
+func sortedKeys(snapshot *memory.Snapshot) []string {
+	keys := make([]string, 0, len(snapshot.Strings)+len(snapshot.Lists)+len(snapshot.SortedSets)+len(snapshot.Streams))
+	for key := range snapshot.Strings {
+		keys = append(keys, key)
+	}
+	for key := range snapshot.Lists {
+		keys = append(keys, key)
+	}
+	for key := range snapshot.SortedSets {
+		keys = append(keys, key)
+	}
+	for key := range snapshot.Streams {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommands(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	expiresMS := strconv.FormatInt(expires.UnixMilli(), 10)
+	expired := time.Now().Add(-time.Hour)
+
+	snapshot := memory.NewSnapshot()
+	snapshot.Strings["str"] = memory.String{Value: "value"}
+	snapshot.Strings["str:ttl"] = memory.String{Value: "value", Expires: expires}
+	snapshot.Strings["str:expired"] = memory.String{Value: "value", Expires: expired}
+	snapshot.Lists["list"] = []string{"a", "b", "c"}
+	snapshot.SortedSets["zset"] = []memory.SortedSetMember{{Member: "low", Score: math.Inf(-1)}, {Member: "mid", Score: 1.5}, {Member: "high", Score: math.Inf(1)}}
+	snapshot.Streams["stream"] = memory.StreamSnapshot{
+		TopEntryID: "5-0",
+		Entries: []memory.EntryWithStreamID{
+			{StreamID: "1-1", Entry: map[string]string{"b": "2", "a": "1"}},
+			{StreamID: "2-0", Entry: map[string]string{"c": "3"}},
+		},
+	}
+	snapshot.Streams["stream:empty"] = memory.StreamSnapshot{TopEntryID: "0-0"}
+	snapshot.Expires["list"] = expires
+	snapshot.Lists["list:expired"] = []string{"a"}
+	snapshot.Expires["list:expired"] = expired
+
+	tests := []struct {
+		Name     string
+		Filter   Filter
+		Expected [][]string
+	}{
+		{
+			Name:   "All keys",
+			Filter: Filter{},
+			Expected: [][]string{
+				{"RPUSH", "list", "a", "b", "c"},
+				{"PEXPIREAT", "list", expiresMS},
+				{"SET", "str", "value"},
+				{"SET", "str:ttl", "value", "PXAT", expiresMS},
+				{"XADD", "stream", "1-1", "a", "1", "b", "2"},
+				{"XADD", "stream", "2-0", "c", "3"},
+				{"XSETID", "stream", "5-0"},
+				{"ZADD", "zset", "-inf", "low", "1.5", "mid", "inf", "high"},
+			},
+		},
+		{
+			Name:   "Pattern",
+			Filter: Filter{Pattern: "str*"},
+			Expected: [][]string{
+				{"SET", "str", "value"},
+				{"SET", "str:ttl", "value", "PXAT", expiresMS},
+				{"XADD", "stream", "1-1", "a", "1", "b", "2"},
+				{"XADD", "stream", "2-0", "c", "3"},
+				{"XSETID", "stream", "5-0"},
+			},
+		},
+		{
+			Name:   "Pattern and type",
+			Filter: Filter{Pattern: "str*", Type: memory.TYPE_STRING},
+			Expected: [][]string{
+				{"SET", "str", "value"},
+				{"SET", "str:ttl", "value", "PXAT", expiresMS},
+			},
+		},
+		{
+			Name:     "No matching keys",
+			Filter:   Filter{Type: "hash"},
+			Expected: [][]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, Commands(snapshot, test.Filter))
+		})
+	}
+}
+
+func TestCommandsBigKeys(t *testing.T) {
+	t.Run("List is split by items count", func(t *testing.T) {
+		snapshot := memory.NewSnapshot()
+		values := make([]string, MAX_ITEMS_PER_COMMAND*2+1)
+		for i := range values {
+			values[i] = fmt.Sprint(i)
+		}
+		snapshot.Lists["list"] = values
+
+		commands := Commands(snapshot, Filter{})
+
+		assert.Len(t, commands, 3)
+		assert.Len(t, commands[0], MAX_ITEMS_PER_COMMAND+2)
+		assert.Len(t, commands[1], MAX_ITEMS_PER_COMMAND+2)
+		assert.Equal(t, []string{"RPUSH", "list", fmt.Sprint(MAX_ITEMS_PER_COMMAND * 2)}, commands[2])
+	})
+
+	t.Run("Sorted set is split by size", func(t *testing.T) {
+		snapshot := memory.NewSnapshot()
+		member := strings.Repeat("m", MAX_BYTES_PER_COMMAND/2)
+		snapshot.SortedSets["zset"] = []memory.SortedSetMember{{Member: member + "1", Score: 1}, {Member: member + "2", Score: 2}, {Member: member + "3", Score: 3}}
+
+		commands := Commands(snapshot, Filter{})
+
+		assert.Len(t, commands, 3)
+		for i, command := range commands {
+			assert.Equal(t, []string{"ZADD", "zset", fmt.Sprint(i + 1), member + fmt.Sprint(i+1)}, command)
+		}
+	})
+
+	t.Run("Item bigger than the limit gets its own command", func(t *testing.T) {
+		snapshot := memory.NewSnapshot()
+		big := strings.Repeat("v", MAX_BYTES_PER_COMMAND+1)
+		snapshot.Lists["list"] = []string{"a", big, "b"}
+
+		commands := Commands(snapshot, Filter{})
+
+		assert.Equal(t, [][]string{{"RPUSH", "list", "a"}, {"RPUSH", "list", big}, {"RPUSH", "list", "b"}}, commands)
+	})
+}
+
+func TestEncode(t *testing.T) {
+	b, err := Encode([][]string{{"SET", "key", "value"}, {"RPUSH", "list", "a"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$1\r\na\r\n"), b)
+}
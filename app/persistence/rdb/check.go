@@ -0,0 +1,62 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"maps"
+)
+
+// Report of RDB file check, it is filled as far as the file could be decoded
+type Report struct {
+	Name      string
+	Version   int
+	Metadata  map[string]string
+	Databases []DatabaseReport
+	// Zero checksum means that checksum checking is disabled
+	Checksum uint64
+}
+
+type DatabaseReport struct {
+	DBSelector int
+	// Counts, declared in RESIZEDB
+	KeysCount               int
+	KeysWithExpirationCount int
+	// Counts of really decoded keys
+	DecodedKeysCount               int
+	DecodedKeysWithExpirationCount int
+	TypesCount                     map[string]int
+}
+
+// Checks RDB file with checksum verification, unlike Decode, all databases are reported
+// Returned error is *DecodeError, so offset of corruption can be found
+func Check(b []byte) (*Report, error) {
+	file, err := decodeFile(b)
+
+	report := &Report{}
+	if file.header != nil {
+		report.Name = file.header.name
+		report.Version = file.header.version
+	}
+	if file.metadata != nil {
+		report.Metadata = maps.Clone(file.metadata.data)
+	}
+	for _, database := range file.databases {
+		decodedKeysCount := 0
+		for _, count := range database.typesCount {
+			decodedKeysCount += count
+		}
+
+		report.Databases = append(report.Databases, DatabaseReport{
+			DBSelector:                     database.dbSelector,
+			KeysCount:                      database.keysCount,
+			KeysWithExpirationCount:        database.keysWithExpirationCount,
+			DecodedKeysCount:               decodedKeysCount,
+			DecodedKeysWithExpirationCount: database.decodedKeysWithExpirationCount,
+			TypesCount:                     maps.Clone(database.typesCount),
+		})
+	}
+	if file.end != nil {
+		report.Checksum = binary.LittleEndian.Uint64([]byte(file.end.checksum))
+	}
+
+	return report, err
+}
@@ -0,0 +1,132 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+func TestCheck(t *testing.T) {
+	snapshot := memory.NewSnapshot()
+	snapshot.Strings["string"] = memory.String{Value: "value", Expires: time.Now().Add(time.Hour)}
+	snapshot.Lists["list"] = []string{"a", "b"}
+	snapshot.SortedSets["zset"] = []memory.SortedSetMember{{Member: "a", Score: 1}}
+	snapshot.Expires["zset"] = time.Now().Add(time.Hour)
+
+	b, err := Encode(snapshot)
+	assert.NoError(t, err)
+
+	t.Run("valid file", func(t *testing.T) {
+		report, err := Check(b)
+		assert.NoError(t, err)
+		assert.Equal(t, "REDIS", report.Name)
+		assert.Equal(t, RDB_VERSION, report.Version)
+		assert.Equal(t, REDIS_VERSION, report.Metadata["redis-ver"])
+		assert.NotZero(t, report.Checksum)
+		assert.Equal(t, []DatabaseReport{
+			{
+				DBSelector:                     0,
+				KeysCount:                      3,
+				KeysWithExpirationCount:        2,
+				DecodedKeysCount:               3,
+				DecodedKeysWithExpirationCount: 2,
+				TypesCount: map[string]int{
+					memory.TYPE_STRING:     1,
+					memory.TYPE_LIST:       1,
+					memory.TYPE_SORTED_SET: 1,
+				},
+			},
+		}, report.Databases)
+	})
+
+	t.Run("corrupted byte is found by checksum", func(t *testing.T) {
+		corrupted := append([]byte{}, b...)
+		corrupted[len(corrupted)-12] ^= 0xFF
+
+		report, err := Check(corrupted)
+		var decodeErr *DecodeError
+		assert.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, len(b)-8, decodeErr.Offset)
+		assert.Equal(t, RDB_VERSION, report.Version)
+	})
+
+	t.Run("corrupted value type", func(t *testing.T) {
+		typeOffset := len(b) - 9 - len(snapshotTail(snapshot))
+		corrupted := append([]byte{}, b...)
+		corrupted[typeOffset] = 0x63
+
+		report, err := Check(corrupted)
+		var decodeErr *DecodeError
+		assert.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, typeOffset, decodeErr.Offset)
+		assert.Equal(t, REDIS_VERSION, report.Metadata["redis-ver"])
+		// Keys before the corrupted one are reported
+		assert.Equal(t, []DatabaseReport{
+			{
+				DBSelector:                     0,
+				KeysCount:                      3,
+				KeysWithExpirationCount:        2,
+				DecodedKeysCount:               2,
+				DecodedKeysWithExpirationCount: 1,
+				TypesCount: map[string]int{
+					memory.TYPE_STRING: 1,
+					memory.TYPE_LIST:   1,
+				},
+			},
+		}, report.Databases)
+	})
+
+	t.Run("corrupted listpack entry", func(t *testing.T) {
+		entryOffset := bytes.Index(b, []byte{LISTPACK_6BIT_STR | 1, 'a', 2})
+		corrupted := append([]byte{}, b...)
+		corrupted[entryOffset] = 0xF5
+
+		_, err := Check(corrupted)
+		var decodeErr *DecodeError
+		assert.True(t, errors.As(err, &decodeErr))
+		// Offset is in file, not in listpack, unknown encoding is found after its byte is read
+		assert.Equal(t, entryOffset+1, decodeErr.Offset)
+	})
+
+	t.Run("corrupted list length", func(t *testing.T) {
+		lengthOffset := bytes.Index(b, []byte{QUICKLIST_2_ENCODING, 4, 'l', 'i', 's', 't'}) + 6
+		corrupted := append([]byte{}, b[:lengthOffset]...)
+		corrupted = binary.BigEndian.AppendUint64(append(corrupted, LENGTH_64BIT), 1<<40)
+		corrupted = append(corrupted, b[lengthOffset+1:]...)
+
+		report, err := Check(corrupted)
+		var decodeErr *DecodeError
+		assert.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, lengthOffset, decodeErr.Offset)
+		assert.Equal(t, 1, report.Databases[0].DecodedKeysCount)
+	})
+
+	t.Run("truncated file", func(t *testing.T) {
+		_, err := Check(b[:len(b)-20])
+		var decodeErr *DecodeError
+		assert.True(t, errors.As(err, &decodeErr))
+		assert.LessOrEqual(t, decodeErr.Offset, len(b)-20)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		_, err := Check(nil)
+		var decodeErr *DecodeError
+		assert.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, 0, decodeErr.Offset)
+	})
+}
+
+// Encoded last key (sorted set is written last, because there are no streams)
+func snapshotTail(snapshot *memory.Snapshot) []byte {
+	var enc encoder
+	enc.buf.WriteByte(ZSET_2_ENCODING)
+	enc.encodeString("zset")
+	enc.encodeSortedSet(snapshot.SortedSets["zset"])
+	return enc.buf.Bytes()
+}
@@ -0,0 +1,13 @@
+package rdb
+
+import "hash/crc64"
+
+// Redis uses CRC-64-Jones: reflected polynomial 0xad93d23594c935a9, zero initial value and no final xor
+// Standard library reflects polynomial too, but inverts crc before and after update, so it is inverted back here
+const CRC64_JONES_REFLECTED_POLY = 0x95AC9329AC4BC9B5
+
+var crc64JonesTable = crc64.MakeTable(CRC64_JONES_REFLECTED_POLY)
+
+func crc64Jones(crc uint64, b []byte) uint64 {
+	return ^crc64.Update(^crc, crc64JonesTable, b)
+}
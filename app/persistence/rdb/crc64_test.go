@@ -0,0 +1,36 @@
+package rdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRC64Jones(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       []byte
+		expected uint64
+	}{
+		{
+			name:     "Redis check value",
+			in:       []byte("123456789"),
+			expected: 0xe9c6d914c4b8d9ca,
+		},
+		{
+			name:     "Empty input",
+			in:       []byte{},
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, crc64Jones(0, test.in))
+		})
+	}
+
+	t.Run("Incremental update", func(t *testing.T) {
+		assert.Equal(t, crc64Jones(0, []byte("123456789")), crc64Jones(crc64Jones(0, []byte("1234")), []byte("56789")))
+	})
+}
@@ -1,8 +1,10 @@
 package rdb
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"time"
 
@@ -15,44 +17,82 @@ type decoder struct {
 	len int
 }
 
-func Decode(b []byte) (map[string]memory.String, error) {
+// Only the first database is returned, Redis keeps only one database in RDB file by default
+func Decode(b []byte) (*memory.Snapshot, error) {
+	file, err := decodeFile(b)
+	if err != nil {
+		return nil, err
+	}
+
+	keysCount := 0
+	for _, database := range file.databases {
+		for _, count := range database.typesCount {
+			keysCount += count
+		}
+		if database.typesCount[TYPE_SET] > 0 || database.typesCount[TYPE_HASH] > 0 {
+			log.Printf("Skip %d sets and %d hashes of database #%d, such types aren't supported\n", database.typesCount[TYPE_SET], database.typesCount[TYPE_HASH], database.dbSelector)
+		}
+	}
+	log.Printf("RDB file loaded, %d keys in %d databases\n", keysCount, len(file.databases))
+
+	if len(file.databases) == 0 {
+		return memory.NewSnapshot(), nil
+	}
+	return file.databases[0].snapshot, nil
+}
+
+// Partly decoded file is returned with error, so it can be reported what was decoded before the corruption
+func decodeFile(b []byte) (*file, error) {
+	file := &file{}
 	if len(b) == 0 || b == nil {
-		return nil, fmt.Errorf("empty RDB file")
+		return file, &DecodeError{Offset: 0, Err: fmt.Errorf("empty RDB file")}
 	}
 
 	dec := decoder{b: b, pos: 0, len: len(b)}
+	var err error
 
-	header, err := dec.decodeHeader()
+	file.header, err = dec.decodeHeader()
 	if err != nil {
-		return nil, fmt.Errorf("decode header error: %v", err)
+		return file, &DecodeError{Offset: dec.pos, Err: fmt.Errorf("decode header error: %v", err)}
 	}
-	fmt.Println(header)
 
-	metadata, err := dec.decodeMetadata()
+	file.metadata, err = dec.decodeMetadata()
 	if err != nil {
-		return nil, fmt.Errorf("decode metadata error: %v", err)
+		return file, &DecodeError{Offset: dec.pos, Err: fmt.Errorf("decode metadata error: %v", err)}
 	}
-	fmt.Println(metadata)
 
-	databases, err := dec.decodeDatabases()
+	file.databases, err = dec.decodeDatabases()
 	if err != nil {
-		return nil, fmt.Errorf("decode database error: %v", err)
+		// Nested decoders know more exact offset, e.g. of value type byte, than where decoding stopped
+		offset := dec.pos
+		var offsetErr *offsetError
+		if errors.As(err, &offsetErr) {
+			offset = offsetErr.offset
+		}
+		return file, &DecodeError{Offset: offset, Err: fmt.Errorf("decode database error: %v", err)}
 	}
-	for _, database := range databases {
-		fmt.Println(database)
+
+	file.end, err = dec.decodeEnd()
+	if err != nil {
+		return file, &DecodeError{Offset: dec.pos, Err: fmt.Errorf("decode end error: %v", err)}
 	}
 
-	end, err := dec.decodeEnd()
+	file.size = dec.pos
+	return file, nil
+}
+
+// Decodes RDB file, which is followed by other data (e.g. RDB preamble of AOF file)
+// Returns the first database and size of RDB file in bytes
+func DecodePrefix(b []byte) (*memory.Snapshot, int, error) {
+	file, err := decodeFile(b)
 	if err != nil {
-		return nil, fmt.Errorf("decode end error: %v", err)
+		return nil, 0, err
 	}
-	fmt.Println(end)
 
-	var items map[string]memory.String = nil
-	if len(databases) > 0 {
-		items = databases[0].items
+	if len(file.databases) == 0 {
+		return memory.NewSnapshot(), file.size, nil
 	}
-	return items, nil
+	return file.databases[0].snapshot, file.size, nil
 }
 
 func (dec *decoder) decodeHeader() (*header, error) {
@@ -103,54 +143,52 @@ func (dec *decoder) decodeMetadata() (*metadata, error) {
 	return &metadata{data: data}, nil
 }
 
+// Partly decoded databases are returned with error, the last one has keys, that were decoded before the corruption
 func (dec *decoder) decodeDatabases() ([]*database, error) {
 	var databases []*database
 
 	for {
 		opCode, err := dec.traverseUInt8()
 		if err != nil {
-			return nil, err
+			return databases, err
 		}
 		if opCode == OP_EOF || opCode != OP_SELECTDB {
 			dec.pos--
 			break
 		}
 
-		var database database
+		database := newDatabase()
+		databases = append(databases, database)
 		database.dbSelector, _, err = dec.decodeLength()
 		if err != nil {
-			return nil, fmt.Errorf("database decodeLength error: %v", err)
+			return databases, fmt.Errorf("database decodeLength error: %v", err)
 		}
 
 		resizeDbOpCode, err := dec.traverseUInt8()
 		if err != nil {
-			return nil, fmt.Errorf("database resize db op code error: %v", err)
+			return databases, fmt.Errorf("database resize db op code error: %v", err)
 		}
 		if resizeDbOpCode != OP_RESIZEDB {
-			return nil, fmt.Errorf("database resize db op code isn't detected, got: %d", resizeDbOpCode)
+			return databases, fmt.Errorf("database resize db op code isn't detected, got: %d", resizeDbOpCode)
 		}
 
 		database.keysCount, _, err = dec.decodeLength()
 		if err != nil {
-			return nil, fmt.Errorf("database keys count error: %v", err)
+			return databases, fmt.Errorf("database keys count error: %v", err)
 		}
 
 		database.keysWithExpirationCount, _, err = dec.decodeLength()
 		if err != nil {
-			return nil, fmt.Errorf("database keys with expiration count error: %v", err)
+			return databases, fmt.Errorf("database keys with expiration count error: %v", err)
 		}
 
-		database.items = make(map[string]memory.String, database.keysCount)
-		err = dec.decodeKeyValuePairs(&database)
+		err = dec.decodeKeyValuePairs(database)
 		if err != nil {
 			if errors.Is(err, rdbEOF) {
-				databases = append(databases, &database)
 				break
 			}
-			return nil, fmt.Errorf("decodeKeyValuePairs error: %v", err)
+			return databases, fmt.Errorf("decodeKeyValuePairs error: %w", err)
 		}
-
-		databases = append(databases, &database)
 	}
 
 	return databases, nil
@@ -165,11 +203,21 @@ func (dec *decoder) decodeEnd() (*end, error) {
 		return nil, fmt.Errorf("decode file end wrong op code: %d", opCode)
 	}
 
+	// Checksum covers the whole file, including EOF op code
+	expectedChecksum := crc64Jones(0, dec.b[:dec.pos])
+
 	checksum, err := dec.traverseStringLen(8)
 	if err != nil {
 		return nil, err
 	}
 
+	// Zero checksum means that checksum checking is disabled
+	actualChecksum := binary.LittleEndian.Uint64([]byte(checksum))
+	if actualChecksum != 0 && actualChecksum != expectedChecksum {
+		dec.pos -= 8
+		return nil, fmt.Errorf("wrong checksum, expected: %016x, got: %016x", expectedChecksum, actualChecksum)
+	}
+
 	return &end{checksum: checksum}, nil
 }
 
@@ -182,9 +230,9 @@ func (dec *decoder) decodeKeyValuePairs(db *database) error {
 
 		switch timeStampOpCode {
 		case OP_EXPIRETIME:
-			dec.decodeKeyValueS(db)
+			err = dec.decodeKeyValueS(db)
 		case OP_EXPIRETIMEMS:
-			dec.decodeKeyValueMS(db)
+			err = dec.decodeKeyValueMS(db)
 		case OP_SELECTDB:
 			dec.pos--
 			return nil
@@ -193,7 +241,10 @@ func (dec *decoder) decodeKeyValuePairs(db *database) error {
 			return rdbEOF
 		default:
 			dec.pos--
-			dec.decodeKeyValue(db, time.Time{})
+			err = dec.decodeKeyValue(db, time.Time{})
+		}
+		if err != nil {
+			return err
 		}
 	}
 }
@@ -207,7 +258,7 @@ func (dec *decoder) decodeKeyValueMS(db *database) error {
 
 	err = dec.decodeKeyValue(db, expires)
 	if err != nil {
-		return fmt.Errorf("decode key value error: %v", err)
+		return fmt.Errorf("decode key value error: %w", err)
 	}
 
 	return nil
@@ -222,13 +273,14 @@ func (dec *decoder) decodeKeyValueS(db *database) error {
 
 	err = dec.decodeKeyValue(db, expires)
 	if err != nil {
-		return fmt.Errorf("decode key value error: %v", err)
+		return fmt.Errorf("decode key value error: %w", err)
 	}
 
 	return nil
 }
 
 func (dec *decoder) decodeKeyValue(db *database, expires time.Time) error {
+	typeOffset := dec.pos
 	valueType, err := dec.traverseUInt8()
 	if err != nil {
 		return fmt.Errorf("value type decode error: %v", err)
@@ -236,27 +288,68 @@ func (dec *decoder) decodeKeyValue(db *database, expires time.Time) error {
 
 	key, err := dec.decodeString()
 	if err != nil {
-		return fmt.Errorf("key decode string error: %v", err)
+		return fmt.Errorf("key decode string error: %w", err)
 	}
 
-	value, err := dec.decodeValue(valueType)
-	if err != nil {
-		return fmt.Errorf("decode value error: %v", err)
+	err = dec.decodeValue(db, valueType, key, expires)
+	if errors.Is(err, errUnsupportedValueType) {
+		return &offsetError{offset: typeOffset, err: err}
 	}
-
-	db.items[key] = memory.String{Value: value, Expires: expires}
-	return nil
+	return err
 }
 
-func (dec *decoder) decodeValue(valueType uint8) (string, error) {
+// Value of known type is added to database snapshot by key
+func (dec *decoder) decodeValue(db *database, valueType uint8, key string, expires time.Time) error {
 	switch valueType {
 	case STRING_ENCODING:
 		value, err := dec.decodeString()
 		if err != nil {
-			return "", fmt.Errorf("decode string error: %v", err)
+			return fmt.Errorf("decode string error: %w", err)
+		}
+		db.snapshot.Strings[key] = memory.String{Value: value, Expires: expires}
+		db.countKey(memory.TYPE_STRING, expires)
+		return nil
+	case LIST_ENCODING, LIST_ZIPLIST_ENCODING, QUICKLIST_ENCODING, QUICKLIST_2_ENCODING:
+		values, err := dec.decodeList(valueType)
+		if err != nil {
+			return fmt.Errorf("decode list %s error: %w", key, err)
+		}
+		db.snapshot.Lists[key] = values
+		db.countKey(memory.TYPE_LIST, expires)
+	case ZSET_ENCODING, ZSET_2_ENCODING, ZSET_ZIPLIST_ENCODING, ZSET_LISTPACK_ENCODING:
+		members, err := dec.decodeSortedSet(valueType)
+		if err != nil {
+			return fmt.Errorf("decode sorted set %s error: %w", key, err)
+		}
+		db.snapshot.SortedSets[key] = members
+		db.countKey(memory.TYPE_SORTED_SET, expires)
+	case STREAM_LISTPACKS_ENCODING, STREAM_LISTPACKS_2_ENCODING, STREAM_LISTPACKS_3_ENCODING:
+		stream, err := dec.decodeStream(valueType)
+		if err != nil {
+			return fmt.Errorf("decode stream %s error: %w", key, err)
+		}
+		db.snapshot.Streams[key] = stream
+		db.countKey(memory.TYPE_STREAM, expires)
+	case SET_ENCODING, SET_INTSET_ENCODING, SET_LISTPACK_ENCODING:
+		_, err := dec.decodeSet(valueType)
+		if err != nil {
+			return fmt.Errorf("decode set %s error: %w", key, err)
 		}
-		return value, nil
+		db.countKey(TYPE_SET, expires)
+		return nil
+	case HASH_ENCODING, HASH_ZIPLIST_ENCODING, HASH_LISTPACK_ENCODING:
+		_, err := dec.decodeHash(valueType)
+		if err != nil {
+			return fmt.Errorf("decode hash %s error: %w", key, err)
+		}
+		db.countKey(TYPE_HASH, expires)
+		return nil
 	default:
-		return "", fmt.Errorf("unsupported value type: %d", valueType)
+		return fmt.Errorf("%w: %d", errUnsupportedValueType, valueType)
 	}
+
+	if !expires.IsZero() {
+		db.snapshot.Expires[key] = expires
+	}
+	return nil
 }
@@ -1,6 +1,9 @@
 package rdb
 
 import (
+	"encoding/binary"
+	"errors"
+	"math"
 	"testing"
 	"time"
 
@@ -13,7 +16,7 @@ func TestDecode(t *testing.T) {
 	tests := []struct {
 		name        string
 		buffer      []byte
-		expected    map[string]memory.String
+		expected    *memory.Snapshot
 		expectedErr bool
 	}{
 		{
@@ -23,9 +26,17 @@ func TestDecode(t *testing.T) {
 				[]byte{OP_AUX, 0x03, 'k', 'e', 'y', 0x05, 'v', 'a', 'l', 'u', 'e'}...),
 				[]byte{OP_SELECTDB, 0x00, OP_RESIZEDB, 0x01, 0x00, STRING_ENCODING, 0x03, 'k', 'e', 'y', 0x03, 'v', 'a', 'l'}...),
 				[]byte{OP_EOF, 0, 0, 0, 0, 0, 0, 0, 0}...),
-			expected: map[string]memory.String{
+			expected: snapshotWithStrings(map[string]memory.String{
 				"key": {Value: "val", Expires: time.Time{}},
-			},
+			}),
+			expectedErr: false,
+		},
+		{
+			name: "Valid RDB file without databases",
+			buffer: append(append([]byte{},
+				[]byte{'R', 'E', 'D', 'I', 'S', '0', '0', '1', '1'}...),
+				[]byte{OP_EOF, 0, 0, 0, 0, 0, 0, 0, 0}...),
+			expected:    memory.NewSnapshot(),
 			expectedErr: false,
 		},
 		{
@@ -163,9 +174,10 @@ func TestDecodeDatabases(t *testing.T) {
 					dbSelector:              0,
 					keysCount:               1,
 					keysWithExpirationCount: 0,
-					items: map[string]memory.String{
+					snapshot: snapshotWithStrings(map[string]memory.String{
 						"key": {Value: "val", Expires: time.Time{}},
-					},
+					}),
+					typesCount: map[string]int{memory.TYPE_STRING: 1},
 				},
 			},
 			expectedErr: false,
@@ -177,9 +189,30 @@ func TestDecodeDatabases(t *testing.T) {
 			expectedErr: false,
 		},
 		{
-			name:        "Invalid resize opcode",
-			buffer:      []byte{OP_SELECTDB, 0x3A, 0x00},
-			expected:    nil,
+			name:   "Invalid resize opcode",
+			buffer: []byte{OP_SELECTDB, 0x3A, 0x00},
+			expected: []*database{
+				{
+					dbSelector: 0x3A,
+					snapshot:   memory.NewSnapshot(),
+					typesCount: map[string]int{},
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name:   "Corrupted key is reported after decoded keys",
+			buffer: []byte{OP_SELECTDB, 0x00, OP_RESIZEDB, 0x02, 0x00, STRING_ENCODING, 0x01, 'a', 0x01, '1', 0x63, 0x01, 'b'},
+			expected: []*database{
+				{
+					dbSelector: 0,
+					keysCount:  2,
+					snapshot: snapshotWithStrings(map[string]memory.String{
+						"a": {Value: "1", Expires: time.Time{}},
+					}),
+					typesCount: map[string]int{memory.TYPE_STRING: 1},
+				},
+			},
 			expectedErr: true,
 		},
 	}
@@ -219,6 +252,20 @@ func TestDecodeEnd(t *testing.T) {
 			expected:    nil,
 			expectedErr: true,
 		},
+		{
+			name:   "Valid checksum",
+			buffer: binary.LittleEndian.AppendUint64([]byte{OP_EOF}, crc64Jones(0, []byte{OP_EOF})),
+			expected: &end{
+				checksum: string(binary.LittleEndian.AppendUint64(nil, crc64Jones(0, []byte{OP_EOF}))),
+			},
+			expectedErr: false,
+		},
+		{
+			name:        "Wrong checksum",
+			buffer:      []byte{OP_EOF, 1, 0, 0, 0, 0, 0, 0, 0},
+			expected:    nil,
+			expectedErr: true,
+		},
 		{
 			name:        "Short checksum",
 			buffer:      []byte{OP_EOF, 0, 0, 0},
@@ -328,7 +375,7 @@ func TestDecodeKeyValueFunctions(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			db := &database{items: make(map[string]memory.String)}
+			db := newDatabase()
 			dec := &decoder{b: test.buffer, pos: 0, len: len(test.buffer)}
 			err := test.runTest(dec, db)
 			if test.expectedErr {
@@ -337,7 +384,7 @@ func TestDecodeKeyValueFunctions(t *testing.T) {
 				assert.NoError(t, err)
 				if len(test.expectedItems) > 0 {
 					for key, expectedItem := range test.expectedItems {
-						item, exists := db.items[key]
+						item, exists := db.snapshot.Strings[key]
 						assert.True(t, exists)
 						assert.Equal(t, expectedItem.Value, item.Value)
 						assert.Equal(t, expectedItem.Expires, item.Expires)
@@ -348,40 +395,194 @@ func TestDecodeKeyValueFunctions(t *testing.T) {
 	}
 }
 
-func TestDecodeValue(t *testing.T) {
+func TestDecodeKeyValueTypes(t *testing.T) {
 	tests := []struct {
 		name        string
 		buffer      []byte
-		valueType   uint8
-		expected    string
+		expires     time.Time
+		expected    *memory.Snapshot
 		expectedErr bool
 	}{
 		{
-			name:        "Valid string value",
-			buffer:      []byte{0x03, 'v', 'a', 'l'},
-			valueType:   STRING_ENCODING,
-			expected:    "val",
-			expectedErr: false,
+			name:   "Linked list",
+			buffer: []byte{LIST_ENCODING, 0x01, 'l', 0x02, 0x01, 'a', 0xC0, 0x07},
+			expected: &memory.Snapshot{
+				Strings:    map[string]memory.String{},
+				Lists:      map[string][]string{"l": {"a", "7"}},
+				SortedSets: map[string][]memory.SortedSetMember{},
+				Streams:    map[string]memory.StreamSnapshot{},
+				Expires:    map[string]time.Time{},
+			},
+		},
+		{
+			name: "Quicklist 2 with listpack node and expiration",
+			buffer: []byte{
+				QUICKLIST_2_ENCODING, 0x01, 'l', 0x01, QUICKLIST_CONTAINER_PACKED, 0x0C,
+				0x0C, 0x00, 0x00, 0x00, 0x02, 0x00, 0x81, 'a', 0x02, 0x05, 0x01, LISTPACK_EOF,
+			},
+			expires: time.UnixMilli(1000),
+			expected: &memory.Snapshot{
+				Strings:    map[string]memory.String{},
+				Lists:      map[string][]string{"l": {"a", "5"}},
+				SortedSets: map[string][]memory.SortedSetMember{},
+				Streams:    map[string]memory.StreamSnapshot{},
+				Expires:    map[string]time.Time{"l": time.UnixMilli(1000)},
+			},
+		},
+		{
+			name:   "Sorted set with string scores",
+			buffer: []byte{ZSET_ENCODING, 0x01, 'z', 0x02, 0x01, 'a', 0x03, '1', '.', '5', 0x01, 'b', 0xFE},
+			expected: &memory.Snapshot{
+				Strings: map[string]memory.String{},
+				Lists:   map[string][]string{},
+				SortedSets: map[string][]memory.SortedSetMember{
+					"z": {{Member: "a", Score: 1.5}, {Member: "b", Score: math.Inf(1)}},
+				},
+				Streams: map[string]memory.StreamSnapshot{},
+				Expires: map[string]time.Time{},
+			},
+		},
+		{
+			name:   "Sorted set 2 with binary scores",
+			buffer: []byte{ZSET_2_ENCODING, 0x01, 'z', 0x01, 0x01, 'a', 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x3F},
+			expected: &memory.Snapshot{
+				Strings:    map[string]memory.String{},
+				Lists:      map[string][]string{},
+				SortedSets: map[string][]memory.SortedSetMember{"z": {{Member: "a", Score: 1.5}}},
+				Streams:    map[string]memory.StreamSnapshot{},
+				Expires:    map[string]time.Time{},
+			},
+		},
+		{
+			name: "Ziplist list",
+			buffer: []byte{
+				LIST_ZIPLIST_ENCODING, 0x01, 'l', 0x10,
+				0x10, 0x00, 0x00, 0x00, 0x0D, 0x00, 0x00, 0x00, 0x02, 0x00,
+				0x00, 0x01, 'a', 0x03, 0xF3, ZIPLIST_END,
+			},
+			expected: &memory.Snapshot{
+				Strings:    map[string]memory.String{},
+				Lists:      map[string][]string{"l": {"a", "2"}},
+				SortedSets: map[string][]memory.SortedSetMember{},
+				Streams:    map[string]memory.StreamSnapshot{},
+				Expires:    map[string]time.Time{},
+			},
+		},
+		{
+			name: "Listpack sorted set with invalid score",
+			buffer: []byte{
+				ZSET_LISTPACK_ENCODING, 0x01, 'z', 0x12,
+				0x12, 0x00, 0x00, 0x00, 0x04, 0x00,
+				0x81, 'a', 0x02, 0x01, 0x01, 0x81, 'b', 0x02, 0x81, 'c', 0x02, LISTPACK_EOF,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Listpack sorted set with integer and float scores",
+			buffer: []byte{
+				ZSET_LISTPACK_ENCODING, 0x01, 'z', 0x14,
+				0x14, 0x00, 0x00, 0x00, 0x04, 0x00,
+				0x81, 'a', 0x02, 0x01, 0x01, 0x81, 'b', 0x02, 0x83, '2', '.', '5', 0x04, LISTPACK_EOF,
+			},
+			expected: &memory.Snapshot{
+				Strings:    map[string]memory.String{},
+				Lists:      map[string][]string{},
+				SortedSets: map[string][]memory.SortedSetMember{"z": {{Member: "a", Score: 1}, {Member: "b", Score: 2.5}}},
+				Streams:    map[string]memory.StreamSnapshot{},
+				Expires:    map[string]time.Time{},
+			},
+		},
+		{
+			name: "Intset set is skipped",
+			buffer: []byte{
+				SET_INTSET_ENCODING, 0x01, 's', 0x0C,
+				0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00,
+			},
+			expires:  time.UnixMilli(1000),
+			expected: memory.NewSnapshot(),
+		},
+		{
+			name:     "Hash is skipped",
+			buffer:   []byte{HASH_ENCODING, 0x01, 'h', 0x01, 0x01, 'f', 0x01, 'v'},
+			expected: memory.NewSnapshot(),
 		},
 		{
 			name:        "Unsupported value type",
-			buffer:      []byte{},
-			valueType:   0xFF,
-			expected:    "",
+			buffer:      []byte{0xFF, 0x01, 'k'},
+			expected:    memory.NewSnapshot(),
+			expectedErr: true,
+		},
+		{
+			name:        "Truncated list",
+			buffer:      []byte{LIST_ENCODING, 0x01, 'l', 0x02, 0x01, 'a'},
+			expected:    memory.NewSnapshot(),
 			expectedErr: true,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
+			db := newDatabase()
 			dec := &decoder{b: test.buffer, pos: 0, len: len(test.buffer)}
-			result, err := dec.decodeValue(test.valueType)
-			assert.Equal(t, test.expected, result)
+			err := dec.decodeKeyValue(db, test.expires)
 			if test.expectedErr {
-				assert.NotNil(t, err)
+				assert.Error(t, err)
 			} else {
-				assert.Nil(t, err)
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, db.snapshot)
 			}
 		})
 	}
 }
+
+func snapshotWithStrings(strings map[string]memory.String) *memory.Snapshot {
+	snapshot := memory.NewSnapshot()
+	snapshot.Strings = strings
+	return snapshot
+}
+
+// Corrupt length is reported at its offset, instead of allocating or slicing by it
+func TestDecodeValueCorruptLength(t *testing.T) {
+	length64 := func(length uint64) []byte {
+		return binary.BigEndian.AppendUint64([]byte{LENGTH_64BIT}, length)
+	}
+	concat := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, part := range parts {
+			b = append(b, part...)
+		}
+		return b
+	}
+	// Ziplist with one string entry of 0xFFFFFFFF bytes
+	ziplist := concat([]byte{0x11, 0x00, 0x00, 0x00, 0x0A, 0x00, 0x00, 0x00, 0x01, 0x00}, []byte{0x00, ZIPLIST_STR_32BIT, 0xFF, 0xFF, 0xFF, 0xFF, ZIPLIST_END})
+	// Listpack with one string entry of 0xFFFFFFFF bytes
+	listpack := []byte{0x0C, 0x00, 0x00, 0x00, 0x01, 0x00, LISTPACK_32BIT_STR, 0xFF, 0xFF, 0xFF, 0xFF, LISTPACK_EOF}
+
+	tests := []struct {
+		name           string
+		valueType      uint8
+		buffer         []byte
+		expectedOffset int
+	}{
+		{name: "List length", valueType: LIST_ENCODING, buffer: length64(1 << 40), expectedOffset: 0},
+		{name: "Negative list length", valueType: LIST_ENCODING, buffer: length64(1 << 63), expectedOffset: 0},
+		{name: "List element length", valueType: LIST_ENCODING, buffer: concat([]byte{0x01}, length64(1<<62)), expectedOffset: 1},
+		{name: "Quicklist nodes count", valueType: QUICKLIST_2_ENCODING, buffer: length64(1 << 40), expectedOffset: 0},
+		{name: "Sorted set length", valueType: ZSET_2_ENCODING, buffer: length64(1 << 40), expectedOffset: 0},
+		{name: "Hash length", valueType: HASH_ENCODING, buffer: length64(1 << 40), expectedOffset: 0},
+		{name: "LZF compressed length", valueType: STRING_ENCODING, buffer: concat([]byte{0xC0 | SPECIAL_ENCODING_LZF}, length64(1<<40)), expectedOffset: 1},
+		{name: "LZF uncompressed length", valueType: STRING_ENCODING, buffer: concat([]byte{0xC0 | SPECIAL_ENCODING_LZF, 0x02}, length64(1<<40), []byte{0x00, 'a'}), expectedOffset: 2},
+		{name: "Ziplist entry length", valueType: LIST_ZIPLIST_ENCODING, buffer: concat([]byte{byte(len(ziplist))}, ziplist), expectedOffset: 1 + 10 + 1},
+		{name: "Listpack entry length", valueType: QUICKLIST_2_ENCODING, buffer: concat([]byte{0x01, QUICKLIST_CONTAINER_PACKED, byte(len(listpack))}, listpack), expectedOffset: 3 + 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dec := &decoder{b: test.buffer, pos: 0, len: len(test.buffer)}
+			err := dec.decodeValue(newDatabase(), test.valueType, "key", time.Time{})
+			var offsetErr *offsetError
+			assert.True(t, errors.As(err, &offsetErr))
+			assert.Equal(t, test.expectedOffset, offsetErr.offset)
+		})
+	}
+}
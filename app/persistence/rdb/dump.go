@@ -0,0 +1,82 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+// 2 bytes of RDB version and 8 bytes of CRC64
+const DUMP_FOOTER_SIZE = 10
+
+// Payload isn't decoded, if its footer is wrong, other errors mean, that the value itself is corrupted
+var ErrWrongDumpFooter = errors.New("DUMP payload version or checksum are wrong")
+
+// Encodes value of key like DUMP does: value type, value, RDB version and CRC64 of all previous bytes
+// Key and its expiration aren't written, they are sent with payload separately, e.g. in RESTORE
+func EncodeDump(snapshot *memory.Snapshot, key string) ([]byte, error) {
+	var enc encoder
+
+	if item, ok := snapshot.Strings[key]; ok {
+		enc.buf.WriteByte(STRING_ENCODING)
+		enc.encodeString(item.Value)
+	} else if values, ok := snapshot.Lists[key]; ok {
+		enc.buf.WriteByte(QUICKLIST_2_ENCODING)
+		enc.encodeQuicklist(values)
+	} else if members, ok := snapshot.SortedSets[key]; ok {
+		enc.buf.WriteByte(ZSET_2_ENCODING)
+		enc.encodeSortedSet(members)
+	} else if stream, ok := snapshot.Streams[key]; ok {
+		enc.buf.WriteByte(STREAM_LISTPACKS_3_ENCODING)
+		err := enc.encodeStream(stream)
+		if err != nil {
+			return nil, fmt.Errorf("encode stream %s error: %v", key, err)
+		}
+	} else {
+		return nil, fmt.Errorf("no such key in snapshot: %s", key)
+	}
+
+	enc.writeUInt16(RDB_VERSION)
+	enc.writeUInt64(crc64Jones(0, enc.buf.Bytes()))
+	return enc.buf.Bytes(), nil
+}
+
+// Decodes DUMP payload into snapshot with the only key, zero expires means, that key doesn't expire
+// Payload of the greater RDB version is rejected, like in Redis, as its encodings could be unknown
+func DecodeDump(key string, payload []byte, expires time.Time) (*memory.Snapshot, error) {
+	if len(payload) <= DUMP_FOOTER_SIZE {
+		return nil, fmt.Errorf("%w: payload is too short: %d bytes", ErrWrongDumpFooter, len(payload))
+	}
+	footerStart := len(payload) - DUMP_FOOTER_SIZE
+
+	version := binary.LittleEndian.Uint16(payload[footerStart:])
+	if version > RDB_VERSION {
+		return nil, fmt.Errorf("%w: unsupported RDB version: %d", ErrWrongDumpFooter, version)
+	}
+	expectedChecksum := crc64Jones(0, payload[:footerStart+2])
+	checksum := binary.LittleEndian.Uint64(payload[footerStart+2:])
+	if checksum != expectedChecksum {
+		return nil, fmt.Errorf("%w: expected checksum: %016x, got: %016x", ErrWrongDumpFooter, expectedChecksum, checksum)
+	}
+
+	dec := decoder{b: payload[:footerStart], pos: 0, len: footerStart}
+	valueType, err := dec.traverseUInt8()
+	if err != nil {
+		return nil, fmt.Errorf("value type decode error: %v", err)
+	}
+	db := newDatabase()
+	err = dec.decodeValue(db, valueType, key, expires)
+	if err != nil {
+		return nil, err
+	}
+	if dec.pos != dec.len {
+		return nil, fmt.Errorf("%d bytes are left after value", dec.len-dec.pos)
+	}
+	if db.typesCount[TYPE_SET] > 0 || db.typesCount[TYPE_HASH] > 0 {
+		return nil, fmt.Errorf("unsupported value type: %d", valueType)
+	}
+	return db.snapshot, nil
+}
@@ -0,0 +1,119 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+func TestDump(t *testing.T) {
+	expires := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+
+	snapshot := &memory.Snapshot{
+		Strings: map[string]memory.String{
+			"key": {Value: "val"},
+		},
+		Lists: map[string][]string{
+			"list": {"a", "1", "-100"},
+		},
+		SortedSets: map[string][]memory.SortedSetMember{
+			"zset": {{Member: "a", Score: -1.5}, {Member: "b", Score: 3.25}},
+		},
+		Streams: map[string]memory.StreamSnapshot{
+			"stream": {TopEntryID: "1-2", Entries: []memory.EntryWithStreamID{
+				{StreamID: "1-1", Entry: map[string]string{"f": "v"}},
+				{StreamID: "1-2", Entry: map[string]string{"f": "v2"}},
+			}},
+		},
+		Expires: map[string]time.Time{},
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		expected func(expected *memory.Snapshot)
+	}{
+		{
+			name: "string",
+			key:  "key",
+			expected: func(expected *memory.Snapshot) {
+				expected.Strings["key"] = memory.String{Value: "val", Expires: expires}
+			},
+		},
+		{
+			name: "list",
+			key:  "list",
+			expected: func(expected *memory.Snapshot) {
+				expected.Lists["list"] = snapshot.Lists["list"]
+				expected.Expires["list"] = expires
+			},
+		},
+		{
+			name: "sorted set",
+			key:  "zset",
+			expected: func(expected *memory.Snapshot) {
+				expected.SortedSets["zset"] = snapshot.SortedSets["zset"]
+				expected.Expires["zset"] = expires
+			},
+		},
+		{
+			name: "stream",
+			key:  "stream",
+			expected: func(expected *memory.Snapshot) {
+				expected.Streams["stream"] = snapshot.Streams["stream"]
+				expected.Expires["stream"] = expires
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := EncodeDump(snapshot, test.key)
+			assert.NoError(t, err)
+			assert.Equal(t, uint16(RDB_VERSION), binary.LittleEndian.Uint16(payload[len(payload)-DUMP_FOOTER_SIZE:]))
+
+			expected := memory.NewSnapshot()
+			test.expected(expected)
+			decoded, err := DecodeDump(test.key, payload, expires)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, decoded)
+		})
+	}
+}
+
+func TestDumpErrors(t *testing.T) {
+	snapshot := memory.NewSnapshot()
+	snapshot.Strings["key"] = memory.String{Value: "val"}
+
+	_, err := EncodeDump(snapshot, "missing")
+	assert.Error(t, err)
+
+	payload, err := EncodeDump(snapshot, "key")
+	assert.NoError(t, err)
+
+	t.Run("wrong checksum", func(t *testing.T) {
+		corrupted := append([]byte{}, payload...)
+		corrupted[1] ^= 0xff
+		_, err := DecodeDump("key", corrupted, time.Time{})
+		assert.ErrorIs(t, err, ErrWrongDumpFooter)
+		assert.ErrorContains(t, err, "expected checksum")
+	})
+
+	t.Run("greater version", func(t *testing.T) {
+		body := append([]byte{}, payload[:len(payload)-DUMP_FOOTER_SIZE]...)
+		body = binary.LittleEndian.AppendUint16(body, RDB_VERSION+1)
+		body = binary.LittleEndian.AppendUint64(body, crc64Jones(0, body))
+		_, err := DecodeDump("key", body, time.Time{})
+		assert.ErrorIs(t, err, ErrWrongDumpFooter)
+		assert.ErrorContains(t, err, "unsupported RDB version")
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		_, err := DecodeDump("key", payload[len(payload)-DUMP_FOOTER_SIZE:], time.Time{})
+		assert.ErrorIs(t, err, ErrWrongDumpFooter)
+	})
+}
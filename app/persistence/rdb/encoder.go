@@ -0,0 +1,276 @@
+package rdb
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+const (
+	QUICKLIST_NODE_MAX_BYTES    = 8 * 1024
+	QUICKLIST_CONTAINER_PACKED  = 2
+	STREAM_NODE_MAX_ENTRIES     = 100
+	STREAM_ITEM_FLAG_NONE       = 0
+	STREAM_ITEM_FLAG_SAMEFIELDS = 2
+)
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// Encodes snapshot of storage into RDB file (only database #0 is written)
+// Snapshot expiration is expected to be only for existing keys
+func Encode(snapshot *memory.Snapshot) ([]byte, error) {
+	var enc encoder
+
+	enc.encodeHeader()
+	enc.encodeMetadata()
+
+	err := enc.encodeDatabase(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("encode database error: %v", err)
+	}
+
+	enc.encodeEnd()
+	return enc.buf.Bytes(), nil
+}
+
+func (enc *encoder) encodeHeader() {
+	enc.buf.WriteString(fmt.Sprintf("REDIS%04d", RDB_VERSION))
+}
+
+func (enc *encoder) encodeMetadata() {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	enc.encodeAux("redis-ver", REDIS_VERSION)
+	enc.encodeAux("redis-bits", "64")
+	enc.encodeAux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	enc.encodeAux("used-mem", strconv.FormatUint(memStats.Alloc, 10))
+	enc.encodeAux("aof-base", "0")
+}
+
+func (enc *encoder) encodeAux(key, value string) {
+	enc.buf.WriteByte(OP_AUX)
+	enc.encodeString(key)
+	enc.encodeString(value)
+}
+
+func (enc *encoder) encodeDatabase(snapshot *memory.Snapshot) error {
+	keysCount := len(snapshot.Strings) + len(snapshot.Lists) + len(snapshot.SortedSets) + len(snapshot.Streams)
+	if keysCount == 0 {
+		return nil
+	}
+
+	keysWithExpirationCount := len(snapshot.Expires)
+	for _, item := range snapshot.Strings {
+		if !item.Expires.IsZero() {
+			keysWithExpirationCount++
+		}
+	}
+
+	enc.buf.WriteByte(OP_SELECTDB)
+	enc.encodeLength(0)
+	enc.buf.WriteByte(OP_RESIZEDB)
+	enc.encodeLength(uint64(keysCount))
+	enc.encodeLength(uint64(keysWithExpirationCount))
+
+	for _, key := range sortedKeys(snapshot.Strings) {
+		item := snapshot.Strings[key]
+		enc.encodeExpiry(item.Expires)
+		enc.buf.WriteByte(STRING_ENCODING)
+		enc.encodeString(key)
+		enc.encodeString(item.Value)
+	}
+
+	for _, key := range sortedKeys(snapshot.Lists) {
+		enc.encodeExpiry(snapshot.Expires[key])
+		enc.buf.WriteByte(QUICKLIST_2_ENCODING)
+		enc.encodeString(key)
+		enc.encodeQuicklist(snapshot.Lists[key])
+	}
+
+	for _, key := range sortedKeys(snapshot.SortedSets) {
+		enc.encodeExpiry(snapshot.Expires[key])
+		enc.buf.WriteByte(ZSET_2_ENCODING)
+		enc.encodeString(key)
+		enc.encodeSortedSet(snapshot.SortedSets[key])
+	}
+
+	for _, key := range sortedKeys(snapshot.Streams) {
+		enc.encodeExpiry(snapshot.Expires[key])
+		enc.buf.WriteByte(STREAM_LISTPACKS_3_ENCODING)
+		enc.encodeString(key)
+		err := enc.encodeStream(snapshot.Streams[key])
+		if err != nil {
+			return fmt.Errorf("encode stream %s error: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// Checksum covers the whole file, including EOF op code
+func (enc *encoder) encodeEnd() {
+	enc.buf.WriteByte(OP_EOF)
+	enc.writeUInt64(crc64Jones(0, enc.buf.Bytes()))
+}
+
+func (enc *encoder) encodeExpiry(expires time.Time) {
+	if expires.IsZero() {
+		return
+	}
+	enc.buf.WriteByte(OP_EXPIRETIMEMS)
+	enc.writeUInt64(uint64(expires.UnixMilli()))
+}
+
+func (enc *encoder) encodeQuicklist(values []string) {
+	nodes := make([][]byte, 0)
+
+	lp := newListpackBuilder()
+	for _, value := range values {
+		lp.appendString(value)
+		if lp.size() >= QUICKLIST_NODE_MAX_BYTES {
+			nodes = append(nodes, lp.bytes())
+			lp = newListpackBuilder()
+		}
+	}
+	if lp.len() > 0 {
+		nodes = append(nodes, lp.bytes())
+	}
+
+	enc.encodeLength(uint64(len(nodes)))
+	for _, node := range nodes {
+		enc.encodeLength(QUICKLIST_CONTAINER_PACKED)
+		enc.encodeBytes(node)
+	}
+}
+
+func (enc *encoder) encodeSortedSet(members []memory.SortedSetMember) {
+	enc.encodeLength(uint64(len(members)))
+	for _, member := range members {
+		enc.encodeString(member.Member)
+		enc.writeFloat64(member.Score)
+	}
+}
+
+// Stream is written as a radix tree of listpacks, every listpack holds up to STREAM_NODE_MAX_ENTRIES entries
+// The first entry of every node is a "master entry", other entries store their IDs and fields relatively to it
+func (enc *encoder) encodeStream(stream memory.StreamSnapshot) error {
+	type node struct {
+		masterTimeMS int64
+		masterSeqNum int
+		listpack     []byte
+	}
+
+	nodes := make([]node, 0)
+	for chunk := range slices.Chunk(stream.Entries, STREAM_NODE_MAX_ENTRIES) {
+		masterTimeMS, masterSeqNum, err := memory.ParseStreamID(chunk[0].StreamID)
+		if err != nil {
+			return err
+		}
+		listpack, err := encodeStreamNodeListpack(chunk, masterTimeMS, masterSeqNum)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, node{masterTimeMS: masterTimeMS, masterSeqNum: masterSeqNum, listpack: listpack})
+	}
+
+	lastTimeMS, lastSeqNum, err := memory.ParseStreamID(stream.TopEntryID)
+	if err != nil {
+		return err
+	}
+	var firstTimeMS int64
+	var firstSeqNum int
+	if len(nodes) > 0 {
+		firstTimeMS, firstSeqNum = nodes[0].masterTimeMS, nodes[0].masterSeqNum
+	}
+
+	enc.encodeLength(uint64(len(nodes)))
+	for _, node := range nodes {
+		enc.encodeBytes(encodeStreamID(node.masterTimeMS, node.masterSeqNum))
+		enc.encodeBytes(node.listpack)
+	}
+
+	enc.encodeLength(uint64(len(stream.Entries)))
+	enc.encodeLength(uint64(lastTimeMS))
+	enc.encodeLength(uint64(lastSeqNum))
+	enc.encodeLength(uint64(firstTimeMS))
+	enc.encodeLength(uint64(firstSeqNum))
+	// Max deleted entry ID, entries are never deleted from stream
+	enc.encodeLength(0)
+	enc.encodeLength(0)
+	// Entries added
+	enc.encodeLength(uint64(len(stream.Entries)))
+	// Consumer groups count
+	enc.encodeLength(0)
+	return nil
+}
+
+func encodeStreamNodeListpack(entries []memory.EntryWithStreamID, masterTimeMS int64, masterSeqNum int) ([]byte, error) {
+	masterFields := sortedKeys(entries[0].Entry)
+
+	lp := newListpackBuilder()
+	lp.appendInt(int64(len(entries)))
+	lp.appendInt(0)
+	lp.appendInt(int64(len(masterFields)))
+	for _, field := range masterFields {
+		lp.appendString(field)
+	}
+	lp.appendInt(0)
+
+	for _, entry := range entries {
+		timeMS, seqNum, err := memory.ParseStreamID(entry.StreamID)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := sortedKeys(entry.Entry)
+		sameFields := slices.Equal(fields, masterFields)
+
+		if sameFields {
+			lp.appendInt(STREAM_ITEM_FLAG_SAMEFIELDS)
+		} else {
+			lp.appendInt(STREAM_ITEM_FLAG_NONE)
+		}
+		lp.appendInt(timeMS - masterTimeMS)
+		lp.appendInt(int64(seqNum - masterSeqNum))
+
+		if sameFields {
+			for _, field := range fields {
+				lp.appendString(entry.Entry[field])
+			}
+			lp.appendInt(int64(len(fields) + 3))
+		} else {
+			lp.appendInt(int64(len(fields)))
+			for _, field := range fields {
+				lp.appendString(field)
+				lp.appendString(entry.Entry[field])
+			}
+			lp.appendInt(int64(len(fields)*2 + 4))
+		}
+	}
+
+	return lp.bytes(), nil
+}
+
+func encodeStreamID(timeMS int64, seqNum int) []byte {
+	b := make([]byte, 0, 16)
+	b = appendUInt64BigEndian(b, uint64(timeMS))
+	b = appendUInt64BigEndian(b, uint64(seqNum))
+	return b
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
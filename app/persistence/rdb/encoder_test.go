@@ -0,0 +1,268 @@
+package rdb
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+func TestEncodeLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   uint64
+		expected []byte
+	}{
+		{
+			name:     "6-bit length",
+			length:   58,
+			expected: []byte{0x3A},
+		},
+		{
+			name:     "14-bit length",
+			length:   2748,
+			expected: []byte{0x4A, 0xBC},
+		},
+		{
+			name:     "32-bit length",
+			length:   123456,
+			expected: []byte{0x80, 0x00, 0x01, 0xE2, 0x40},
+		},
+		{
+			name:     "64-bit length",
+			length:   1 << 40,
+			expected: []byte{0x81, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var enc encoder
+			enc.encodeLength(test.length)
+			assert.Equal(t, test.expected, enc.buf.Bytes())
+
+			dec := &decoder{b: enc.buf.Bytes(), pos: 0, len: enc.buf.Len()}
+			length, isSpecial, err := dec.decodeLength()
+			assert.NoError(t, err)
+			assert.False(t, isSpecial)
+			assert.Equal(t, int(test.length), length)
+		})
+	}
+}
+
+func TestEncodeString(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		expected []byte
+	}{
+		{
+			name:     "Regular string",
+			in:       "hello",
+			expected: []byte{0x05, 'h', 'e', 'l', 'l', 'o'},
+		},
+		{
+			name:     "Empty string",
+			in:       "",
+			expected: []byte{0x00},
+		},
+		{
+			name:     "8-bit integer string",
+			in:       "123",
+			expected: []byte{0xC0, 0x7B},
+		},
+		{
+			name:     "Negative 8-bit integer string",
+			in:       "-1",
+			expected: []byte{0xC0, 0xFF},
+		},
+		{
+			name:     "16-bit integer string",
+			in:       "4660",
+			expected: []byte{0xC1, 0x34, 0x12},
+		},
+		{
+			name:     "32-bit integer string",
+			in:       "305419896",
+			expected: []byte{0xC2, 0x78, 0x56, 0x34, 0x12},
+		},
+		{
+			name:     "Long compressible string",
+			in:       "abcabcabcabcabcabcabcabc",
+			expected: []byte{0xC3, 0x07, 0x18, 0x02, 'a', 'b', 'c', 0xE0, 0x0C, 0x02},
+		},
+		{
+			name:     "Long incompressible string",
+			in:       "abcdefghijklmnopqrstuvwxyz",
+			expected: append([]byte{0x1A}, "abcdefghijklmnopqrstuvwxyz"...),
+		},
+		{
+			name:     "Not canonical integer string",
+			in:       "007",
+			expected: []byte{0x03, '0', '0', '7'},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var enc encoder
+			enc.encodeString(test.in)
+			assert.Equal(t, test.expected, enc.buf.Bytes())
+
+			dec := &decoder{b: enc.buf.Bytes(), pos: 0, len: enc.buf.Len()}
+			decoded, err := dec.decodeString()
+			assert.NoError(t, err)
+			assert.Equal(t, test.in, decoded)
+		})
+	}
+}
+
+func TestListpackBuilder(t *testing.T) {
+	lp := newListpackBuilder()
+	lp.appendString("a")
+	lp.appendString("1")
+	lp.appendInt(-1)
+	lp.appendInt(1000000)
+
+	expected := []byte{
+		0x14, 0x00, 0x00, 0x00, 0x04, 0x00,
+		0x81, 'a', 0x02,
+		0x01, 0x01,
+		0xDF, 0xFF, 0x02,
+		0xF2, 0x40, 0x42, 0x0F, 0x04,
+		LISTPACK_EOF,
+	}
+	assert.Equal(t, 4, lp.len())
+	assert.Equal(t, expected, lp.bytes())
+}
+
+func TestEncode(t *testing.T) {
+	expires := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+
+	longList := make([]string, 0, 3000)
+	for i := range 3000 {
+		longList = append(longList, fmt.Sprintf("value-%d", i))
+	}
+
+	streamEntries := make([]memory.EntryWithStreamID, 0, 150)
+	for i := range 150 {
+		entry := map[string]string{"temperature": strconv.Itoa(i), "humidity": "50"}
+		if i%10 == 0 {
+			entry = map[string]string{"other": "field"}
+		}
+		streamEntries = append(streamEntries, memory.EntryWithStreamID{StreamID: fmt.Sprintf("1526919030474-%d", i), Entry: entry})
+	}
+
+	snapshot := &memory.Snapshot{
+		Strings: map[string]memory.String{
+			"key1": {Value: "val1"},
+			"key2": {Value: "42", Expires: expires},
+		},
+		Lists: map[string][]string{
+			"list":     {"a", "1", "-100", "b", strings.Repeat("long value ", 100)},
+			"longList": longList,
+		},
+		SortedSets: map[string][]memory.SortedSetMember{
+			"zset": {{Member: "a", Score: -1.5}, {Member: "b", Score: 0}, {Member: "c", Score: 3.25}},
+		},
+		Streams: map[string]memory.StreamSnapshot{
+			"stream":      {TopEntryID: "1526919030474-149", Entries: streamEntries},
+			"emptyStream": {TopEntryID: "5-3", Entries: []memory.EntryWithStreamID{}},
+		},
+		Expires: map[string]time.Time{
+			"list":   expires,
+			"zset":   expires,
+			"stream": expires,
+		},
+	}
+
+	b, err := Encode(snapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, "REDIS0011", string(b[:9]))
+
+	decoded, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, decoded)
+}
+
+// Values are stored as they are: empty strings, NUL bytes, CRLF and blobs, that LZF can't compress
+func TestEncodeBinarySafe(t *testing.T) {
+	blob := make([]byte, 2*1024*1024)
+	rand.New(rand.NewSource(1)).Read(blob)
+	binaryValues := []string{"", "\x00", "a\x00b\r\n", "\xff\xfe", "007", "-0", string(blob), strings.Repeat("\x00", 100000)}
+
+	snapshot := memory.NewSnapshot()
+	for i, value := range binaryValues {
+		key := fmt.Sprintf("key\x00\r\n%d", i)
+		snapshot.Strings[key] = memory.String{Value: value}
+	}
+	snapshot.Strings[""] = memory.String{Value: ""}
+	snapshot.Lists["list"] = binaryValues
+	for i, value := range binaryValues {
+		snapshot.SortedSets["zset"] = append(snapshot.SortedSets["zset"], memory.SortedSetMember{Member: value, Score: float64(i)})
+	}
+	entries := make([]memory.EntryWithStreamID, 0, len(binaryValues))
+	for i, value := range binaryValues {
+		entries = append(entries, memory.EntryWithStreamID{StreamID: fmt.Sprintf("1-%d", i), Entry: map[string]string{value: value, "\x00": ""}})
+	}
+	snapshot.Streams["stream"] = memory.StreamSnapshot{TopEntryID: fmt.Sprintf("1-%d", len(binaryValues)-1), Entries: entries}
+
+	b, err := Encode(snapshot)
+	assert.NoError(t, err)
+
+	decoded, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, decoded)
+
+	expected := memory.NewSnapshot()
+	expected.Lists["list"] = snapshot.Lists["list"]
+	expected.SortedSets["zset"] = snapshot.SortedSets["zset"]
+	expected.Streams["stream"] = snapshot.Streams["stream"]
+	dumped := memory.NewSnapshot()
+	for _, key := range []string{"list", "zset", "stream"} {
+		payload, err := EncodeDump(snapshot, key)
+		assert.NoError(t, err)
+		keySnapshot, err := DecodeDump(key, payload, time.Time{})
+		assert.NoError(t, err)
+		maps.Copy(dumped.Lists, keySnapshot.Lists)
+		maps.Copy(dumped.SortedSets, keySnapshot.SortedSets)
+		maps.Copy(dumped.Streams, keySnapshot.Streams)
+	}
+	assert.Equal(t, expected, dumped)
+}
+
+func TestEncodeEmptySnapshot(t *testing.T) {
+	snapshot := memory.NewMultiTypeStorage().Snapshot()
+
+	b, err := Encode(snapshot)
+	assert.NoError(t, err)
+
+	decoded, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, memory.NewSnapshot(), decoded)
+}
+
+func TestWriteRDBFile(t *testing.T) {
+	dir := t.TempDir()
+
+	err := WriteRDBFile(dir, "dump.rdb", []byte("first"))
+	assert.NoError(t, err)
+	err = WriteRDBFile(dir, "dump.rdb", []byte("second"))
+	assert.NoError(t, err)
+
+	b, err := ReadRDBFile(dir, "dump.rdb")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), b)
+
+	dirEntries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, dirEntries, 1)
+}
@@ -0,0 +1,65 @@
+package rdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Intset is a sorted array of integers with the same width:
+// <encoding uint32> <length uint32> <contents>
+// Encoding is the byte size of every integer (2, 4 or 8), all values are little-endian
+const (
+	INTSET_HEADER_SIZE = 8
+	INTSET_ENC_INT16   = 2
+	INTSET_ENC_INT32   = 4
+	INTSET_ENC_INT64   = 8
+)
+
+// Integers are returned as their decimal string representation
+func decodeIntset(b []byte) (values []string, err error) {
+	dec := &decoder{b: b, pos: 0, len: len(b)}
+	// Offset is in blob, caller moves it to offset in file
+	defer func() {
+		var offsetErr *offsetError
+		if err != nil && !errors.As(err, &offsetErr) {
+			err = &offsetError{offset: dec.pos, err: err}
+		}
+	}()
+
+	encoding, err := dec.traverseUInt32()
+	if err != nil {
+		return nil, fmt.Errorf("intset encoding decode error: %v", err)
+	}
+	length, err := dec.traverseUInt32()
+	if err != nil {
+		return nil, fmt.Errorf("intset length decode error: %v", err)
+	}
+
+	switch encoding {
+	case INTSET_ENC_INT16, INTSET_ENC_INT32, INTSET_ENC_INT64:
+	default:
+		return nil, fmt.Errorf("unknown intset encoding: %d", encoding)
+	}
+	if INTSET_HEADER_SIZE+int(encoding)*int(length) != len(b) {
+		return nil, fmt.Errorf("intset size mismatch, length: %d, encoding: %d, got bytes: %d", length, encoding, len(b))
+	}
+
+	values = make([]string, 0, length)
+	for range length {
+		var value int64
+		switch encoding {
+		case INTSET_ENC_INT16:
+			v, _ := dec.traverseUInt16()
+			value = int64(int16(v))
+		case INTSET_ENC_INT32:
+			v, _ := dec.traverseUInt32()
+			value = int64(int32(v))
+		case INTSET_ENC_INT64:
+			v, _ := dec.traverseUInt64()
+			value = int64(v)
+		}
+		values = append(values, strconv.FormatInt(value, 10))
+	}
+	return values, nil
+}
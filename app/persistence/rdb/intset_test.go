@@ -0,0 +1,59 @@
+package rdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeIntset(t *testing.T) {
+	tests := []struct {
+		name        string
+		buffer      []byte
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:     "16-bit integers",
+			buffer:   []byte{0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x34, 0x12},
+			expected: []string{"-1", "4660"},
+		},
+		{
+			name:     "32-bit integers",
+			buffer:   []byte{0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x78, 0x56, 0x34, 0x12},
+			expected: []string{"305419896"},
+		},
+		{
+			name:     "64-bit integers",
+			buffer:   []byte{0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80},
+			expected: []string{"-9223372036854775808"},
+		},
+		{
+			name:     "Empty intset",
+			buffer:   []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: []string{},
+		},
+		{
+			name:        "Unknown encoding",
+			buffer:      []byte{0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expectedErr: true,
+		},
+		{
+			name:        "Size mismatch",
+			buffer:      []byte{0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0xFF, 0xFF},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := decodeIntset(test.buffer)
+			if test.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, result)
+			}
+		})
+	}
+}
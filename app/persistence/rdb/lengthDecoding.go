@@ -18,11 +18,20 @@ func (dec *decoder) decodeLength() (int, bool, error) {
 		}
 		return int(lenByte&0x3F)<<8 | int(nextLenByte), false, nil
 	case 2:
-		len, err := dec.traverseUInt32()
-		if err != nil {
-			return 0, false, err
+		switch lenByte {
+		case LENGTH_32BIT:
+			len, err := dec.traverseUInt32BigEndian()
+			if err != nil {
+				return 0, false, err
+			}
+			return int(len), false, nil
+		case LENGTH_64BIT:
+			len, err := dec.traverseUInt64BigEndian()
+			if err != nil {
+				return 0, false, err
+			}
+			return int(len), false, nil
 		}
-		return int(len), false, nil
 	case 3:
 		remainingBits := lenByte & 0x3F
 		val, err := dec.traverseSpecialString(remainingBits)
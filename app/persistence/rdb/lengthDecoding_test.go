@@ -31,7 +31,7 @@ func TestDecodeLength(t *testing.T) {
 		},
 		{
 			name:              "decodeLength: Case 2 - 32-bit length",
-			buffer:            []byte{0x80, 0x40, 0xE2, 0x01, 0x00},
+			buffer:            []byte{0x80, 0x00, 0x01, 0xE2, 0x40},
 			expectedLength:    123456,
 			expectedIsSpecial: false,
 			expectedErr:       nil,
@@ -0,0 +1,19 @@
+package rdb
+
+import "math"
+
+func (enc *encoder) encodeLength(length uint64) {
+	switch {
+	case length < 1<<6:
+		enc.buf.WriteByte(byte(length))
+	case length < 1<<14:
+		enc.buf.WriteByte(byte(length>>8) | 0x40)
+		enc.buf.WriteByte(byte(length))
+	case length <= math.MaxUint32:
+		enc.buf.WriteByte(LENGTH_32BIT)
+		enc.buf.Write(appendUInt32BigEndian(nil, uint32(length)))
+	default:
+		enc.buf.WriteByte(LENGTH_64BIT)
+		enc.buf.Write(appendUInt64BigEndian(nil, length))
+	}
+}
@@ -0,0 +1,138 @@
+package rdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+const QUICKLIST_CONTAINER_PLAIN = 1
+
+func (dec *decoder) decodeList(valueType uint8) ([]string, error) {
+	switch valueType {
+	case LIST_ENCODING:
+		return dec.decodeLinkedList()
+	case LIST_ZIPLIST_ENCODING:
+		return dec.decodeZiplistBlob()
+	case QUICKLIST_ENCODING:
+		return dec.decodeQuicklist()
+	case QUICKLIST_2_ENCODING:
+		return dec.decodeQuicklist2()
+	default:
+		return nil, fmt.Errorf("unsupported list value type: %d", valueType)
+	}
+}
+
+func (dec *decoder) decodeLinkedList() ([]string, error) {
+	len, err := dec.decodeCount()
+	if err != nil {
+		return nil, fmt.Errorf("list length decode error: %w", err)
+	}
+
+	values := make([]string, 0, len)
+	for range len {
+		value, err := dec.decodeString()
+		if err != nil {
+			return nil, fmt.Errorf("list value decode error: %w", err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (dec *decoder) decodeZiplistBlob() ([]string, error) {
+	start := dec.pos
+	ziplist, err := dec.decodeString()
+	if err != nil {
+		return nil, fmt.Errorf("ziplist blob decode error: %w", err)
+	}
+	values, err := decodeZiplist([]byte(ziplist))
+	return values, dec.blobError(err, start, ziplist)
+}
+
+func (dec *decoder) decodeListpackBlob() ([]string, error) {
+	start := dec.pos
+	listpack, err := dec.decodeString()
+	if err != nil {
+		return nil, fmt.Errorf("listpack blob decode error: %w", err)
+	}
+	values, err := decodeListpack([]byte(listpack))
+	return values, dec.blobError(err, start, listpack)
+}
+
+func (dec *decoder) decodeIntsetBlob() ([]string, error) {
+	start := dec.pos
+	intset, err := dec.decodeString()
+	if err != nil {
+		return nil, fmt.Errorf("intset blob decode error: %w", err)
+	}
+	values, err := decodeIntset([]byte(intset))
+	return values, dec.blobError(err, start, intset)
+}
+
+// Offset in blob, that was just decoded from string at start, is moved to offset in file
+// Compressed or integer encoded string doesn't keep blob bytes, so error gets offset of the string
+func (dec *decoder) blobError(err error, start int, blob string) error {
+	var offsetErr *offsetError
+	if !errors.As(err, &offsetErr) {
+		return err
+	}
+	if dec.b[start]>>6 == 3 {
+		offsetErr.offset = start
+	} else {
+		offsetErr.offset += dec.pos - len(blob)
+	}
+	return err
+}
+
+// Quicklist is a linked list of ziplists
+func (dec *decoder) decodeQuicklist() ([]string, error) {
+	nodesCount, err := dec.decodeCount()
+	if err != nil {
+		return nil, fmt.Errorf("quicklist nodes count decode error: %w", err)
+	}
+
+	values := make([]string, 0)
+	for range nodesCount {
+		nodeValues, err := dec.decodeZiplistBlob()
+		if err != nil {
+			return nil, fmt.Errorf("quicklist node ziplist decode error: %w", err)
+		}
+		values = append(values, nodeValues...)
+	}
+	return values, nil
+}
+
+// Quicklist 2 is a linked list of listpacks, big values are stored as plain nodes
+func (dec *decoder) decodeQuicklist2() ([]string, error) {
+	nodesCount, err := dec.decodeCount()
+	if err != nil {
+		return nil, fmt.Errorf("quicklist nodes count decode error: %w", err)
+	}
+
+	values := make([]string, 0)
+	for range nodesCount {
+		container, _, err := dec.decodeLength()
+		if err != nil {
+			return nil, fmt.Errorf("quicklist node container decode error: %v", err)
+		}
+		nodeStart := dec.pos
+		node, err := dec.decodeString()
+		if err != nil {
+			return nil, fmt.Errorf("quicklist node decode error: %w", err)
+		}
+
+		switch container {
+		case QUICKLIST_CONTAINER_PLAIN:
+			values = append(values, node)
+		case QUICKLIST_CONTAINER_PACKED:
+			nodeValues, err := decodeListpack([]byte(node))
+			if err != nil {
+				return nil, fmt.Errorf("quicklist node listpack decode error: %w", dec.blobError(err, nodeStart, node))
+			}
+			values = append(values, nodeValues...)
+		default:
+			return nil, fmt.Errorf("unknown quicklist node container: %d", container)
+		}
+	}
+	return values, nil
+}
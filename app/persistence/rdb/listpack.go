@@ -0,0 +1,250 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Listpack is a compact serialized list of strings and integers:
+// <total-bytes uint32> <elements-count uint16> <entry> ... <entry> <0xFF>
+// Every entry is <encoding-type><entry-data><backlen>, backlen lets to traverse listpack from the end
+const (
+	LISTPACK_HEADER_SIZE = 6
+	LISTPACK_EOF         = 0xFF
+
+	LISTPACK_7BIT_UINT = 0x00
+	LISTPACK_6BIT_STR  = 0x80
+	LISTPACK_13BIT_INT = 0xC0
+	LISTPACK_12BIT_STR = 0xE0
+	LISTPACK_32BIT_STR = 0xF0
+	LISTPACK_16BIT_INT = 0xF1
+	LISTPACK_24BIT_INT = 0xF2
+	LISTPACK_32BIT_INT = 0xF3
+	LISTPACK_64BIT_INT = 0xF4
+)
+
+type listpackBuilder struct {
+	entries []byte
+	count   int
+}
+
+func newListpackBuilder() *listpackBuilder {
+	return &listpackBuilder{entries: make([]byte, 0)}
+}
+
+func (lp *listpackBuilder) appendString(s string) {
+	if value, ok := listpackStringToInt(s); ok {
+		lp.appendInt(value)
+		return
+	}
+
+	start := len(lp.entries)
+	l := len(s)
+	switch {
+	case l < 1<<6:
+		lp.entries = append(lp.entries, LISTPACK_6BIT_STR|byte(l))
+	case l < 1<<12:
+		lp.entries = append(lp.entries, LISTPACK_12BIT_STR|byte(l>>8), byte(l))
+	default:
+		lp.entries = append(lp.entries, LISTPACK_32BIT_STR)
+		lp.entries = binary.LittleEndian.AppendUint32(lp.entries, uint32(l))
+	}
+	lp.entries = append(lp.entries, s...)
+	lp.appendBacklen(len(lp.entries) - start)
+}
+
+func (lp *listpackBuilder) appendInt(value int64) {
+	start := len(lp.entries)
+	switch {
+	case value >= 0 && value <= 127:
+		lp.entries = append(lp.entries, LISTPACK_7BIT_UINT|byte(value))
+	case value >= -(1<<12) && value < 1<<12:
+		u := uint16(value) & 0x1FFF
+		lp.entries = append(lp.entries, LISTPACK_13BIT_INT|byte(u>>8), byte(u))
+	case value >= math.MinInt16 && value <= math.MaxInt16:
+		lp.entries = append(lp.entries, LISTPACK_16BIT_INT)
+		lp.entries = binary.LittleEndian.AppendUint16(lp.entries, uint16(value))
+	case value >= -(1<<23) && value < 1<<23:
+		u := uint32(value)
+		lp.entries = append(lp.entries, LISTPACK_24BIT_INT, byte(u), byte(u>>8), byte(u>>16))
+	case value >= math.MinInt32 && value <= math.MaxInt32:
+		lp.entries = append(lp.entries, LISTPACK_32BIT_INT)
+		lp.entries = binary.LittleEndian.AppendUint32(lp.entries, uint32(value))
+	default:
+		lp.entries = append(lp.entries, LISTPACK_64BIT_INT)
+		lp.entries = binary.LittleEndian.AppendUint64(lp.entries, uint64(value))
+	}
+	lp.appendBacklen(len(lp.entries) - start)
+}
+
+// Backlen is written in reverse order: the last byte has the lowest 7 bits
+// And every byte, except the first one, has the highest bit set
+func (lp *listpackBuilder) appendBacklen(l int) {
+	switch {
+	case l <= 127:
+		lp.entries = append(lp.entries, byte(l))
+	case l < 16383:
+		lp.entries = append(lp.entries, byte(l>>7), byte(l&127)|128)
+	case l < 2097151:
+		lp.entries = append(lp.entries, byte(l>>14), byte((l>>7)&127)|128, byte(l&127)|128)
+	case l < 268435455:
+		lp.entries = append(lp.entries, byte(l>>21), byte((l>>14)&127)|128, byte((l>>7)&127)|128, byte(l&127)|128)
+	default:
+		lp.entries = append(lp.entries, byte(l>>28), byte((l>>21)&127)|128, byte((l>>14)&127)|128, byte((l>>7)&127)|128, byte(l&127)|128)
+	}
+	lp.count++
+}
+
+func (lp *listpackBuilder) len() int {
+	return lp.count
+}
+
+func (lp *listpackBuilder) size() int {
+	return LISTPACK_HEADER_SIZE + len(lp.entries) + 1
+}
+
+func (lp *listpackBuilder) bytes() []byte {
+	// Elements count is saturated, real count is found by traversing the listpack
+	count := min(lp.count, math.MaxUint16)
+
+	b := make([]byte, 0, lp.size())
+	b = binary.LittleEndian.AppendUint32(b, uint32(lp.size()))
+	b = binary.LittleEndian.AppendUint16(b, uint16(count))
+	b = append(b, lp.entries...)
+	b = append(b, LISTPACK_EOF)
+	return b
+}
+
+// Same rule as in original Redis: only canonical decimal representation is stored as an integer
+func listpackStringToInt(s string) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(value, 10) != s {
+		return 0, false
+	}
+	return value, true
+}
+
+// Integers are returned as their decimal string representation
+func decodeListpack(b []byte) (values []string, err error) {
+	dec := &decoder{b: b, pos: 0, len: len(b)}
+	// Offset is in blob, caller moves it to offset in file
+	defer func() {
+		var offsetErr *offsetError
+		if err != nil && !errors.As(err, &offsetErr) {
+			err = &offsetError{offset: dec.pos, err: err}
+		}
+	}()
+
+	totalBytes, err := dec.traverseUInt32()
+	if err != nil {
+		return nil, fmt.Errorf("listpack total bytes decode error: %v", err)
+	}
+	if int(totalBytes) != len(b) {
+		return nil, fmt.Errorf("listpack total bytes mismatch, header: %d, got: %d", totalBytes, len(b))
+	}
+	if _, err = dec.traverseUInt16(); err != nil {
+		return nil, fmt.Errorf("listpack elements count decode error: %v", err)
+	}
+
+	values = make([]string, 0)
+	for {
+		encoding, err := dec.traverseUInt8()
+		if err != nil {
+			return nil, fmt.Errorf("listpack entry decode error: %v", err)
+		}
+		if encoding == LISTPACK_EOF {
+			break
+		}
+		dec.pos--
+
+		start := dec.pos
+		value, err := dec.decodeListpackEntry()
+		if err != nil {
+			return nil, fmt.Errorf("listpack entry decode error: %w", err)
+		}
+		if _, err = dec.traverseStringLen(listpackBacklenSize(dec.pos - start)); err != nil {
+			return nil, fmt.Errorf("listpack entry backlen decode error: %v", err)
+		}
+		values = append(values, value)
+	}
+
+	if dec.pos != dec.len {
+		return nil, fmt.Errorf("listpack has %d bytes after end mark", dec.len-dec.pos)
+	}
+	return values, nil
+}
+
+func (dec *decoder) decodeListpackEntry() (string, error) {
+	start := dec.pos
+	encoding, err := dec.traverseUInt8()
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case encoding&0x80 == LISTPACK_7BIT_UINT:
+		return strconv.Itoa(int(encoding & 0x7F)), nil
+	case encoding&0xC0 == LISTPACK_6BIT_STR:
+		return dec.traverseLengthString(int(encoding&0x3F), start)
+	case encoding&0xE0 == LISTPACK_13BIT_INT:
+		next, err := dec.traverseUInt8()
+		if err != nil {
+			return "", err
+		}
+		value := int(encoding&0x1F)<<8 | int(next)
+		if value >= 1<<12 {
+			value -= 1 << 13
+		}
+		return strconv.Itoa(value), nil
+	case encoding&0xF0 == LISTPACK_12BIT_STR:
+		next, err := dec.traverseUInt8()
+		if err != nil {
+			return "", err
+		}
+		return dec.traverseLengthString(int(encoding&0x0F)<<8|int(next), start)
+	}
+
+	switch encoding {
+	case LISTPACK_16BIT_INT:
+		value, err := dec.traverseUInt16()
+		return strconv.Itoa(int(int16(value))), err
+	case LISTPACK_24BIT_INT:
+		value, err := dec.traverseInt24()
+		return strconv.Itoa(value), err
+	case LISTPACK_32BIT_INT:
+		value, err := dec.traverseUInt32()
+		return strconv.Itoa(int(int32(value))), err
+	case LISTPACK_64BIT_INT:
+		value, err := dec.traverseUInt64()
+		return strconv.FormatInt(int64(value), 10), err
+	case LISTPACK_32BIT_STR:
+		len, err := dec.traverseUInt32()
+		if err != nil {
+			return "", err
+		}
+		return dec.traverseLengthString(int(len), start)
+	default:
+		return "", fmt.Errorf("unknown listpack entry encoding: %#x", encoding)
+	}
+}
+
+func listpackBacklenSize(l int) int {
+	switch {
+	case l <= 127:
+		return 1
+	case l < 16383:
+		return 2
+	case l < 2097151:
+		return 3
+	case l < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
@@ -0,0 +1,33 @@
+package rdb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeListpack(t *testing.T) {
+	t.Run("builder round trip", func(t *testing.T) {
+		values := []string{"a", "-1", "1000000", "-9000000000", strings.Repeat("x", 100), strings.Repeat("y", 5000), "", "007"}
+
+		lp := newListpackBuilder()
+		for _, value := range values {
+			lp.appendString(value)
+		}
+
+		result, err := decodeListpack(lp.bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, values, result)
+	})
+
+	t.Run("total bytes mismatch", func(t *testing.T) {
+		_, err := decodeListpack([]byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, LISTPACK_EOF})
+		assert.Error(t, err)
+	})
+
+	t.Run("truncated entry", func(t *testing.T) {
+		_, err := decodeListpack([]byte{0x09, 0x00, 0x00, 0x00, 0x01, 0x00, 0x85, 'a', LISTPACK_EOF})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,157 @@
+package rdb
+
+import "fmt"
+
+// LZF is a fast compression algorithm, Redis uses it to compress long strings in RDB file
+// Compressed data is a sequence of literal runs and back references:
+// 000LLLLL <L+1 literal bytes>
+// LLLooooo oooooooo (back reference of L+2 bytes, o+1 bytes back)
+// 111ooooo LLLLLLLL oooooooo (back reference of L+7+2 bytes, o+1 bytes back)
+const (
+	LZF_HASH_LOG     = 14
+	LZF_MAX_LITERAL  = 1 << 5
+	LZF_MAX_OFFSET   = 1 << 13
+	LZF_MAX_REF      = (1 << 8) + (1 << 3)
+	LZF_MIN_REF      = 3
+	LZF_SHORT_REF    = 7
+	LZF_MIN_STR_SIZE = 20
+)
+
+func lzfDecompress(in []byte, outLen int) ([]byte, error) {
+	if outLen < 0 || outLen > len(in)*LZF_MAX_REF {
+		return nil, fmt.Errorf("lzf output length %d can't be decompressed from %d bytes", outLen, len(in))
+	}
+	out := make([]byte, 0, outLen)
+
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+
+		if ctrl < LZF_MAX_LITERAL {
+			runLen := ctrl + 1
+			if i+runLen > len(in) {
+				return nil, fmt.Errorf("lzf literal run is out of input bounds")
+			}
+			if len(out)+runLen > outLen {
+				return nil, fmt.Errorf("lzf output is bigger than expected length %d", outLen)
+			}
+			out = append(out, in[i:i+runLen]...)
+			i += runLen
+			continue
+		}
+
+		refLen := ctrl >> 5
+		if refLen == LZF_SHORT_REF {
+			if i >= len(in) {
+				return nil, fmt.Errorf("lzf back reference length is out of input bounds")
+			}
+			refLen += int(in[i])
+			i++
+		}
+		refLen += 2
+
+		if i >= len(in) {
+			return nil, fmt.Errorf("lzf back reference offset is out of input bounds")
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 {
+			return nil, fmt.Errorf("lzf back reference points before output start")
+		}
+		if len(out)+refLen > outLen {
+			return nil, fmt.Errorf("lzf output is bigger than expected length %d", outLen)
+		}
+		// Reference can overlap with bytes being copied, so it is copied byte by byte
+		for k := range refLen {
+			out = append(out, out[ref+k])
+		}
+	}
+
+	if len(out) != outLen {
+		return nil, fmt.Errorf("lzf output length mismatch, expected: %d, got: %d", outLen, len(out))
+	}
+	return out, nil
+}
+
+// Returns nil if data can't be compressed to less than maxLen bytes
+func lzfCompress(in []byte, maxLen int) []byte {
+	var hashTable [1 << LZF_HASH_LOG]int
+	out := make([]byte, 0, len(in))
+
+	literalCtrlIdx := len(out)
+	out = append(out, 0)
+	literalLen := 0
+
+	appendLiteral := func(b byte) {
+		out = append(out, b)
+		literalLen++
+		if literalLen == LZF_MAX_LITERAL {
+			out[literalCtrlIdx] = LZF_MAX_LITERAL - 1
+			literalCtrlIdx = len(out)
+			out = append(out, 0)
+			literalLen = 0
+		}
+	}
+
+	ip := 0
+	for ip+LZF_MIN_REF <= len(in) {
+		if len(out) > maxLen {
+			return nil
+		}
+
+		h := lzfHash(in[ip], in[ip+1], in[ip+2])
+		// Positions are stored as pos+1, so 0 means empty slot
+		ref := hashTable[h] - 1
+		hashTable[h] = ip + 1
+
+		offset := ip - ref - 1
+		if ref < 0 || offset >= LZF_MAX_OFFSET || in[ref] != in[ip] || in[ref+1] != in[ip+1] || in[ref+2] != in[ip+2] {
+			appendLiteral(in[ip])
+			ip++
+			continue
+		}
+
+		maxRefLen := min(len(in)-ip, LZF_MAX_REF)
+		refLen := LZF_MIN_REF
+		for refLen < maxRefLen && in[ref+refLen] == in[ip+refLen] {
+			refLen++
+		}
+
+		if literalLen == 0 {
+			out = out[:literalCtrlIdx]
+		} else {
+			out[literalCtrlIdx] = byte(literalLen - 1)
+		}
+
+		encodedLen := refLen - 2
+		if encodedLen < LZF_SHORT_REF {
+			out = append(out, byte(encodedLen<<5|offset>>8), byte(offset))
+		} else {
+			out = append(out, byte(LZF_SHORT_REF<<5|offset>>8), byte(encodedLen-LZF_SHORT_REF), byte(offset))
+		}
+		ip += refLen
+
+		literalCtrlIdx = len(out)
+		out = append(out, 0)
+		literalLen = 0
+	}
+
+	for ; ip < len(in); ip++ {
+		appendLiteral(in[ip])
+	}
+	if literalLen == 0 {
+		out = out[:literalCtrlIdx]
+	} else {
+		out[literalCtrlIdx] = byte(literalLen - 1)
+	}
+
+	if len(out) > maxLen {
+		return nil
+	}
+	return out
+}
+
+func lzfHash(b0, b1, b2 byte) int {
+	v := uint32(b0)<<16 | uint32(b1)<<8 | uint32(b2)
+	return int((v * 2654435761) >> (32 - LZF_HASH_LOG))
+}
@@ -0,0 +1,128 @@
+package rdb
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLZFDecompress(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          []byte
+		outLen      int
+		expected    []byte
+		expectedErr bool
+	}{
+		{
+			name:     "Literal run",
+			in:       []byte{0x02, 'a', 'b', 'c'},
+			outLen:   3,
+			expected: []byte("abc"),
+		},
+		{
+			name:     "Short back reference",
+			in:       []byte{0x02, 'a', 'b', 'c', 0x20, 0x02},
+			outLen:   6,
+			expected: []byte("abcabc"),
+		},
+		{
+			name:     "Long overlapping back reference",
+			in:       []byte{0x02, 'a', 'b', 'c', 0xE0, 0x0C, 0x02},
+			outLen:   24,
+			expected: []byte(strings.Repeat("abc", 8)),
+		},
+		{
+			name:        "Back reference before output start",
+			in:          []byte{0x00, 'a', 0x20, 0x05},
+			outLen:      4,
+			expectedErr: true,
+		},
+		{
+			name:        "Truncated literal run",
+			in:          []byte{0x05, 'a', 'b'},
+			outLen:      6,
+			expectedErr: true,
+		},
+		{
+			name:        "Length mismatch",
+			in:          []byte{0x02, 'a', 'b', 'c'},
+			outLen:      4,
+			expectedErr: true,
+		},
+		{
+			name:        "Output overflow",
+			in:          []byte{0x02, 'a', 'b', 'c', 0xE0, 0x0C, 0x02},
+			outLen:      10,
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := lzfDecompress(test.in, test.outLen)
+			if test.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, result)
+			}
+		})
+	}
+}
+
+func TestLZFCompress(t *testing.T) {
+	random := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name           string
+		in             []byte
+		expectedNil    bool
+		expectedShrink bool
+	}{
+		{
+			name:           "Repeated pattern",
+			in:             []byte(strings.Repeat("abc", 8)),
+			expectedShrink: true,
+		},
+		{
+			name:           "Long text",
+			in:             []byte(strings.Repeat("hello world, hello rediska! ", 1000)),
+			expectedShrink: true,
+		},
+		{
+			name:           "Mixed data",
+			in:             append(append([]byte(strings.Repeat("x", 300)), random[:100]...), []byte(strings.Repeat("yz", 300))...),
+			expectedShrink: true,
+		},
+		{
+			name:        "Random data",
+			in:          random,
+			expectedNil: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compressed := lzfCompress(test.in, len(test.in)-4)
+			if test.expectedNil {
+				assert.Nil(t, compressed)
+				return
+			}
+			if test.expectedShrink {
+				assert.Less(t, len(compressed), len(test.in))
+			}
+
+			decompressed, err := lzfDecompress(compressed, len(test.in))
+			assert.NoError(t, err)
+			assert.Equal(t, test.in, decompressed)
+		})
+	}
+
+	t.Run("Repeated pattern exact output", func(t *testing.T) {
+		assert.Equal(t, []byte{0x02, 'a', 'b', 'c', 0xE0, 0x0C, 0x02}, lzfCompress([]byte(strings.Repeat("abc", 8)), 20))
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/memory"
 )
@@ -33,21 +34,47 @@ func (m *metadata) String() string {
 }
 
 // No division on 2 maps: expired and unexpired
-// Supports only storage String values
+// Keys count and keys with expiration count are taken from RESIZEDB, decoded keys are counted separately
 type database struct {
-	dbSelector              int
-	keysCount               int
-	keysWithExpirationCount int
-	items                   map[string]memory.String
+	dbSelector                     int
+	keysCount                      int
+	keysWithExpirationCount        int
+	snapshot                       *memory.Snapshot
+	typesCount                     map[string]int
+	decodedKeysWithExpirationCount int
+}
+
+func newDatabase() *database {
+	return &database{
+		snapshot:   memory.NewSnapshot(),
+		typesCount: make(map[string]int),
+	}
+}
+
+func (d *database) countKey(valueType string, expires time.Time) {
+	d.typesCount[valueType]++
+	if !expires.IsZero() {
+		d.decodedKeysWithExpirationCount++
+	}
 }
 
 func (d *database) String() string {
 	var b bytes.Buffer
 	b.WriteString(fmt.Sprintf("DATABASE #%d\n", d.dbSelector))
 	b.WriteString(fmt.Sprintf("Keys count: %d, Keys with expiration count: %d\n", d.keysCount, d.keysWithExpirationCount))
-	for key, value := range d.items {
+	b.WriteString(fmt.Sprintf("Decoded types count: %v\n", d.typesCount))
+	for key, value := range d.snapshot.Strings {
 		b.WriteString(fmt.Sprintf("Key: %s, Value: %+v\n", key, value))
 	}
+	for key, values := range d.snapshot.Lists {
+		b.WriteString(fmt.Sprintf("Key: %s, List: %q, Expires: %v\n", key, values, d.snapshot.Expires[key]))
+	}
+	for key, members := range d.snapshot.SortedSets {
+		b.WriteString(fmt.Sprintf("Key: %s, Sorted set: %+v, Expires: %v\n", key, members, d.snapshot.Expires[key]))
+	}
+	for key, stream := range d.snapshot.Streams {
+		b.WriteString(fmt.Sprintf("Key: %s, Stream entries count: %d, Top entry ID: %s, Expires: %v\n", key, len(stream.Entries), stream.TopEntryID, d.snapshot.Expires[key]))
+	}
 	return b.String()
 }
 
@@ -59,8 +86,47 @@ func (e *end) String() string {
 	return fmt.Sprintf("END\nChecksum: %x\n", e.checksum)
 }
 
+type file struct {
+	header    *header
+	metadata  *metadata
+	databases []*database
+	end       *end
+	size      int
+}
+
+// Error with offset of RDB file byte where decoding has failed
+type DecodeError struct {
+	Offset int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%v (offset %d)", e.Err, e.Offset)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Error with offset, that is known better than where decoding has stopped, e.g. offset of value type byte
+// It isn't printed, DecodeError of the whole file gets this offset
+type offsetError struct {
+	offset int
+	err    error
+}
+
+func (e *offsetError) Error() string {
+	return e.err.Error()
+}
+
+func (e *offsetError) Unwrap() error {
+	return e.err
+}
+
 var rdbEOF error = errors.New("EOF")
 
+var errUnsupportedValueType = errors.New("unsupported value type")
+
 const (
 	OP_EOF          = 0xFF
 	OP_SELECTDB     = 0xFE
@@ -70,13 +136,54 @@ const (
 	OP_AUX          = 0xFA
 )
 
-const STRING_ENCODING = 0
+const (
+	STRING_ENCODING             = 0
+	LIST_ENCODING               = 1
+	SET_ENCODING                = 2
+	ZSET_ENCODING               = 3
+	HASH_ENCODING               = 4
+	ZSET_2_ENCODING             = 5
+	LIST_ZIPLIST_ENCODING       = 10
+	SET_INTSET_ENCODING         = 11
+	ZSET_ZIPLIST_ENCODING       = 12
+	HASH_ZIPLIST_ENCODING       = 13
+	QUICKLIST_ENCODING          = 14
+	STREAM_LISTPACKS_ENCODING   = 15
+	HASH_LISTPACK_ENCODING      = 16
+	ZSET_LISTPACK_ENCODING      = 17
+	QUICKLIST_2_ENCODING        = 18
+	STREAM_LISTPACKS_2_ENCODING = 19
+	SET_LISTPACK_ENCODING       = 20
+	STREAM_LISTPACKS_3_ENCODING = 21
+)
+
+// Sets and hashes are decoded, but there are no such storages
+const (
+	TYPE_SET  = "set"
+	TYPE_HASH = "hash"
+)
+
+const (
+	LENGTH_32BIT = 0x80
+	LENGTH_64BIT = 0x81
+)
+
+const (
+	SPECIAL_ENCODING_INT8  = 0
+	SPECIAL_ENCODING_INT16 = 1
+	SPECIAL_ENCODING_INT32 = 2
+	SPECIAL_ENCODING_LZF   = 3
+)
+
+const (
+	RDB_VERSION   = 11
+	REDIS_VERSION = "7.2.0"
+)
 
 const EMPTY_DB_HEX = "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fa056374696d65c26d08bc65fa08757365642d6d656dc2b0c41000fa08616f662d62617365c000fff06e3bfec0ff5aa2"
 
 func IsFileExists(dir, filename string) bool {
 	path := filepath.Join(dir, filename)
-	fmt.Println(path)
 	if _, err := os.Stat(path); !os.IsNotExist(err) {
 		return true
 	}
@@ -88,3 +195,34 @@ func ReadRDBFile(dir, filename string) ([]byte, error) {
 	data, err := os.ReadFile(path)
 	return data, err
 }
+
+// File is written into temp file first and then renamed
+// So a crash in the middle of writing never leaves half-written RDB file
+func WriteRDBFile(dir, filename string, b []byte) error {
+	tmp, err := os.CreateTemp(dir, "temp-*.rdb")
+	if err != nil {
+		return fmt.Errorf("create temp file error: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("write temp file error: %v", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("fsync temp file error: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("close temp file error: %v", err)
+	}
+
+	if err = os.Rename(tmpPath, filepath.Join(dir, filename)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("rename temp file error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package rdb
+
+import "fmt"
+
+// There are no set and hash storages, so such values are decoded only to move through the RDB file
+func (dec *decoder) decodeSet(valueType uint8) ([]string, error) {
+	switch valueType {
+	case SET_ENCODING:
+		return dec.decodeLinkedList()
+	case SET_INTSET_ENCODING:
+		return dec.decodeIntsetBlob()
+	case SET_LISTPACK_ENCODING:
+		return dec.decodeListpackBlob()
+	default:
+		return nil, fmt.Errorf("unsupported set value type: %d", valueType)
+	}
+}
+
+// Hash fields and values are returned as a flat list: field, value, field, value...
+func (dec *decoder) decodeHash(valueType uint8) ([]string, error) {
+	switch valueType {
+	case HASH_ENCODING:
+		len, err := dec.decodeCount()
+		if err != nil {
+			return nil, fmt.Errorf("hash length decode error: %w", err)
+		}
+
+		values := make([]string, 0, len*2)
+		for range len * 2 {
+			value, err := dec.decodeString()
+			if err != nil {
+				return nil, fmt.Errorf("hash field decode error: %w", err)
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case HASH_ZIPLIST_ENCODING:
+		return dec.decodeZiplistBlob()
+	case HASH_LISTPACK_ENCODING:
+		return dec.decodeListpackBlob()
+	default:
+		return nil, fmt.Errorf("unsupported hash value type: %d", valueType)
+	}
+}
@@ -0,0 +1,244 @@
+package rdb
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+const (
+	SAVE_RULES_CHECK_INTERVAL = 100 * time.Millisecond
+	// Failed BGSAVE isn't retried by save rules immediately, otherwise it would be retried on every check
+	BGSAVE_RETRY_DELAY = 5 * time.Second
+	BGSAVE_STATUS_OK   = "ok"
+	BGSAVE_STATUS_ERR  = "err"
+)
+
+type Controller interface {
+	Save() error
+	BgSave() error
+	LastSave() time.Time
+	// Counts write commands since the last successful save
+	IncrDirty()
+	// Used after storage is loaded, e.g. AOF replay isn't a change
+	ResetDirty()
+	Info() *Info
+	// Starts BGSAVE in background, when one of the save rules fires
+	StartSaveRules()
+	// Stops save rules, waits for BGSAVE in progress and saves storage, if save rules are configured
+	Close() error
+}
+
+type Info struct {
+	ChangesSinceLastSave int
+	BgSaveInProgress     bool
+	LastSave             time.Time
+	LastBgSaveStatus     string
+}
+
+func (i *Info) String() string {
+	bgSaveInProgress := 0
+	if i.BgSaveInProgress {
+		bgSaveInProgress = 1
+	}
+
+	data := []string{
+		"rdb_changes_since_last_save:" + strconv.Itoa(i.ChangesSinceLastSave),
+		"rdb_bgsave_in_progress:" + strconv.Itoa(bgSaveInProgress),
+		"rdb_last_save_time:" + strconv.FormatInt(i.LastSave.Unix(), 10),
+		"rdb_last_bgsave_status:" + i.LastBgSaveStatus,
+	}
+	return strings.Join(data, "\r\n") + "\r\n"
+}
+
+type controller struct {
+	args             *config.Args
+	storage          memory.MultiTypeStorage
+	lastSave         time.Time
+	bgSaveInProgress bool
+	bgSaveDone       *sync.Cond
+	lastBgSaveTry    time.Time
+	lastBgSaveStatus string
+	dirty            int
+	done             chan struct{}
+	closeOnce        sync.Once
+	mut              sync.Mutex
+}
+
+func NewController(args *config.Args, storage memory.MultiTypeStorage) Controller {
+	c := &controller{
+		args:             args,
+		storage:          storage,
+		lastSave:         time.Now(),
+		lastBgSaveStatus: BGSAVE_STATUS_OK,
+		done:             make(chan struct{}),
+	}
+	c.bgSaveDone = sync.NewCond(&c.mut)
+	return c
+}
+
+func (c *controller) Save() error {
+	c.mut.Lock()
+	if c.bgSaveInProgress {
+		c.mut.Unlock()
+		return fmt.Errorf("Background save already in progress")
+	}
+	dirtyBeforeSave := c.dirty
+	c.mut.Unlock()
+
+	return c.save(c.storage.Snapshot(), dirtyBeforeSave)
+}
+
+// Storage snapshot is taken synchronously (it is only a copy under storage locks)
+// Encoding and writing to disk are done in background, so clients aren't blocked
+func (c *controller) BgSave() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return c.bgSave()
+}
+
+// Must be called under lock
+func (c *controller) bgSave() error {
+	if c.bgSaveInProgress {
+		return fmt.Errorf("Background save already in progress")
+	}
+	c.bgSaveInProgress = true
+	c.lastBgSaveTry = time.Now()
+
+	// Changes, made after the snapshot, are still counted as dirty after the save
+	dirtyBeforeSave := c.dirty
+	snapshot := c.storage.Snapshot()
+	go func() {
+		err := c.save(snapshot, dirtyBeforeSave)
+
+		c.mut.Lock()
+		if err != nil {
+			log.Printf("Background saving error: %v", err)
+			c.lastBgSaveStatus = BGSAVE_STATUS_ERR
+		} else {
+			log.Printf("Background saving terminated with success")
+			c.lastBgSaveStatus = BGSAVE_STATUS_OK
+		}
+		c.bgSaveInProgress = false
+		c.bgSaveDone.Broadcast()
+		c.mut.Unlock()
+	}()
+	return nil
+}
+
+func (c *controller) LastSave() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.lastSave
+}
+
+func (c *controller) IncrDirty() {
+	c.mut.Lock()
+	c.dirty++
+	c.mut.Unlock()
+}
+
+func (c *controller) ResetDirty() {
+	c.mut.Lock()
+	c.dirty = 0
+	c.mut.Unlock()
+}
+
+func (c *controller) Info() *Info {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return &Info{
+		ChangesSinceLastSave: c.dirty,
+		BgSaveInProgress:     c.bgSaveInProgress,
+		LastSave:             c.lastSave,
+		LastBgSaveStatus:     c.lastBgSaveStatus,
+	}
+}
+
+func (c *controller) StartSaveRules() {
+	if len(c.args.SaveRules) == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(SAVE_RULES_CHECK_INTERVAL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.done:
+				return
+			case now := <-ticker.C:
+				c.checkSaveRules(now)
+			}
+		}
+	}()
+}
+
+func (c *controller) checkSaveRules(now time.Time) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.bgSaveInProgress {
+		return
+	}
+	if c.lastBgSaveStatus == BGSAVE_STATUS_ERR && now.Sub(c.lastBgSaveTry) < BGSAVE_RETRY_DELAY {
+		return
+	}
+
+	for _, saveRule := range c.args.SaveRules {
+		if c.dirty >= saveRule.Changes && c.dirty > 0 && now.Sub(c.lastSave) >= time.Duration(saveRule.Seconds)*time.Second {
+			log.Printf("%d changes in %d seconds. Saving...", saveRule.Changes, saveRule.Seconds)
+			c.bgSave()
+			return
+		}
+	}
+}
+
+func (c *controller) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+
+	if len(c.args.SaveRules) == 0 {
+		return nil
+	}
+
+	c.mut.Lock()
+	for c.bgSaveInProgress {
+		c.bgSaveDone.Wait()
+	}
+	dirtyBeforeSave := c.dirty
+	c.mut.Unlock()
+
+	log.Printf("Saving the final RDB snapshot before exiting")
+	return c.save(c.storage.Snapshot(), dirtyBeforeSave)
+}
+
+func (c *controller) save(snapshot *memory.Snapshot, dirtyBeforeSave int) error {
+	b, err := Encode(snapshot)
+	if err != nil {
+		return fmt.Errorf("RDB encode error: %v", err)
+	}
+
+	err = WriteRDBFile(c.args.DBDir, c.args.DBFilename, b)
+	if err != nil {
+		return fmt.Errorf("RDB file write error: %v", err)
+	}
+
+	c.mut.Lock()
+	c.lastSave = time.Now()
+	c.dirty -= dirtyBeforeSave
+	c.mut.Unlock()
+
+	log.Printf("DB saved on disk")
+	return nil
+}
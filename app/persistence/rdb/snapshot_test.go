@@ -0,0 +1,114 @@
+package rdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+func newTestController(t *testing.T, saveRules []config.SaveRule) (*controller, memory.MultiTypeStorage) {
+	args := &config.Args{DBDir: t.TempDir(), DBFilename: "dump.rdb", SaveRules: saveRules}
+	storage := memory.NewMultiTypeStorage()
+	return NewController(args, storage).(*controller), storage
+}
+
+func waitBgSave(t *testing.T, c *controller) {
+	assert.Eventually(t, func() bool {
+		return !c.Info().BgSaveInProgress
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSaveRules(t *testing.T) {
+	tests := []struct {
+		Name          string
+		SaveRules     []config.SaveRule
+		Changes       int
+		Elapsed       time.Duration
+		ShouldBgSave  bool
+		ExpectedDirty int
+	}{
+		{Name: "Not enough changes", SaveRules: []config.SaveRule{{Seconds: 60, Changes: 10}}, Changes: 9, Elapsed: time.Hour, ShouldBgSave: false, ExpectedDirty: 9},
+		{Name: "Not enough time", SaveRules: []config.SaveRule{{Seconds: 60, Changes: 10}}, Changes: 10, Elapsed: 59 * time.Second, ShouldBgSave: false, ExpectedDirty: 10},
+		{Name: "Rule fires", SaveRules: []config.SaveRule{{Seconds: 60, Changes: 10}}, Changes: 10, Elapsed: 60 * time.Second, ShouldBgSave: true, ExpectedDirty: 0},
+		{Name: "One of several rules fires", SaveRules: []config.SaveRule{{Seconds: 900, Changes: 1}, {Seconds: 60, Changes: 3}}, Changes: 3, Elapsed: 61 * time.Second, ShouldBgSave: true, ExpectedDirty: 0},
+		{Name: "No changes", SaveRules: []config.SaveRule{{Seconds: 0, Changes: 0}}, Changes: 0, Elapsed: time.Hour, ShouldBgSave: false, ExpectedDirty: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			c, storage := newTestController(t, test.SaveRules)
+			storage.StringStorage().Set("key", "value")
+			for range test.Changes {
+				c.IncrDirty()
+			}
+
+			c.checkSaveRules(c.LastSave().Add(test.Elapsed))
+			waitBgSave(t, c)
+
+			assert.Equal(t, test.ExpectedDirty, c.Info().ChangesSinceLastSave)
+			assert.Equal(t, test.ShouldBgSave, IsFileExists(c.args.DBDir, c.args.DBFilename))
+		})
+	}
+}
+
+func TestSaveRulesFailedBgSave(t *testing.T) {
+	c, _ := newTestController(t, []config.SaveRule{{Seconds: 0, Changes: 1}})
+	c.args.DBDir = filepath.Join(c.args.DBDir, "missing")
+	c.IncrDirty()
+
+	c.checkSaveRules(time.Now())
+	waitBgSave(t, c)
+
+	info := c.Info()
+	assert.Equal(t, BGSAVE_STATUS_ERR, info.LastBgSaveStatus)
+	assert.Equal(t, 1, info.ChangesSinceLastSave)
+
+	// Failed BGSAVE isn't retried until retry delay passes
+	c.checkSaveRules(time.Now())
+	assert.False(t, c.Info().BgSaveInProgress)
+
+	assert.NoError(t, os.Mkdir(c.args.DBDir, 0755))
+	c.checkSaveRules(time.Now().Add(BGSAVE_RETRY_DELAY))
+	waitBgSave(t, c)
+
+	info = c.Info()
+	assert.Equal(t, BGSAVE_STATUS_OK, info.LastBgSaveStatus)
+	assert.Equal(t, 0, info.ChangesSinceLastSave)
+}
+
+func TestInfoString(t *testing.T) {
+	info := &Info{ChangesSinceLastSave: 3, BgSaveInProgress: true, LastSave: time.Unix(1700000000, 0), LastBgSaveStatus: BGSAVE_STATUS_OK}
+
+	assert.Equal(t, "rdb_changes_since_last_save:3\r\nrdb_bgsave_in_progress:1\r\nrdb_last_save_time:1700000000\r\nrdb_last_bgsave_status:ok\r\n", info.String())
+}
+
+func TestClose(t *testing.T) {
+	t.Run("final save with save rules", func(t *testing.T) {
+		c, storage := newTestController(t, []config.SaveRule{{Seconds: 3600, Changes: 1}})
+		storage.StringStorage().Set("key", "value")
+		c.IncrDirty()
+
+		assert.NoError(t, c.Close())
+
+		b, err := ReadRDBFile(c.args.DBDir, c.args.DBFilename)
+		assert.NoError(t, err)
+		snapshot, err := Decode(b)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", snapshot.Strings["key"].Value)
+		assert.Equal(t, 0, c.Info().ChangesSinceLastSave)
+	})
+
+	t.Run("no final save without save rules", func(t *testing.T) {
+		c, storage := newTestController(t, nil)
+		storage.StringStorage().Set("key", "value")
+
+		assert.NoError(t, c.Close())
+		assert.False(t, IsFileExists(c.args.DBDir, c.args.DBFilename))
+	})
+}
@@ -0,0 +1,102 @@
+package rdb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+const (
+	ZSET_SCORE_NAN     = 253
+	ZSET_SCORE_POS_INF = 254
+	ZSET_SCORE_NEG_INF = 255
+)
+
+func (dec *decoder) decodeSortedSet(valueType uint8) ([]memory.SortedSetMember, error) {
+	switch valueType {
+	case ZSET_ZIPLIST_ENCODING:
+		values, err := dec.decodeZiplistBlob()
+		if err != nil {
+			return nil, err
+		}
+		return packedSortedSetMembers(values)
+	case ZSET_LISTPACK_ENCODING:
+		values, err := dec.decodeListpackBlob()
+		if err != nil {
+			return nil, err
+		}
+		return packedSortedSetMembers(values)
+	}
+
+	len, err := dec.decodeCount()
+	if err != nil {
+		return nil, fmt.Errorf("sorted set length decode error: %w", err)
+	}
+
+	members := make([]memory.SortedSetMember, 0, len)
+	for range len {
+		member, err := dec.decodeString()
+		if err != nil {
+			return nil, fmt.Errorf("sorted set member decode error: %w", err)
+		}
+
+		var score float64
+		if valueType == ZSET_2_ENCODING {
+			score, err = dec.traverseFloat64()
+		} else {
+			score, err = dec.decodeStringScore()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sorted set score decode error: %v", err)
+		}
+
+		members = append(members, memory.SortedSetMember{Member: member, Score: score})
+	}
+	return members, nil
+}
+
+// Old sorted set format keeps score as a string with 1 byte length, special lengths are reserved for NaN and infinities
+func (dec *decoder) decodeStringScore() (float64, error) {
+	len, err := dec.traverseUInt8()
+	if err != nil {
+		return 0, err
+	}
+
+	switch len {
+	case ZSET_SCORE_NAN:
+		return math.NaN(), nil
+	case ZSET_SCORE_POS_INF:
+		return math.Inf(1), nil
+	case ZSET_SCORE_NEG_INF:
+		return math.Inf(-1), nil
+	}
+
+	raw, err := dec.traverseStringLen(int(len))
+	if err != nil {
+		return 0, err
+	}
+	score, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("score parse float error: %v", err)
+	}
+	return score, nil
+}
+
+// Small sorted sets are packed as a flat list: member, score, member, score...
+func packedSortedSetMembers(values []string) ([]memory.SortedSetMember, error) {
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("packed sorted set has odd values count: %d", len(values))
+	}
+
+	members := make([]memory.SortedSetMember, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		score, err := strconv.ParseFloat(values[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("packed sorted set score parse error: %v", err)
+		}
+		members = append(members, memory.SortedSetMember{Member: values[i], Score: score})
+	}
+	return members, nil
+}
@@ -0,0 +1,260 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+)
+
+const STREAM_ITEM_FLAG_DELETED = 1
+
+// Consumer groups are decoded only to be skipped, storage doesn't support them
+func (dec *decoder) decodeStream(valueType uint8) (memory.StreamSnapshot, error) {
+	var stream memory.StreamSnapshot
+
+	nodesCount, _, err := dec.decodeLength()
+	if err != nil {
+		return stream, fmt.Errorf("stream nodes count decode error: %v", err)
+	}
+
+	stream.Entries = make([]memory.EntryWithStreamID, 0)
+	for range nodesCount {
+		masterID, err := dec.decodeString()
+		if err != nil {
+			return stream, fmt.Errorf("stream node master ID decode error: %w", err)
+		}
+		if len(masterID) != 16 {
+			return stream, fmt.Errorf("stream node master ID must have 16 bytes, got: %d", len(masterID))
+		}
+		masterTimeMS := int64(binary.BigEndian.Uint64([]byte(masterID[:8])))
+		masterSeqNum := int(binary.BigEndian.Uint64([]byte(masterID[8:])))
+
+		listpackStart := dec.pos
+		listpack, err := dec.decodeString()
+		if err != nil {
+			return stream, fmt.Errorf("stream node listpack decode error: %w", err)
+		}
+		entries, err := decodeStreamNodeListpack([]byte(listpack), masterTimeMS, masterSeqNum)
+		if err != nil {
+			return stream, fmt.Errorf("stream node decode error: %w", dec.blobError(err, listpackStart, listpack))
+		}
+		stream.Entries = append(stream.Entries, entries...)
+	}
+
+	// Entries count
+	if _, _, err = dec.decodeLength(); err != nil {
+		return stream, fmt.Errorf("stream length decode error: %v", err)
+	}
+	lastTimeMS, lastSeqNum, err := dec.decodeStreamID()
+	if err != nil {
+		return stream, fmt.Errorf("stream last ID decode error: %v", err)
+	}
+	stream.TopEntryID = fmt.Sprintf("%d-%d", lastTimeMS, lastSeqNum)
+
+	if valueType >= STREAM_LISTPACKS_2_ENCODING {
+		// First ID, max deleted entry ID and entries added
+		if _, _, err = dec.decodeStreamID(); err != nil {
+			return stream, fmt.Errorf("stream first ID decode error: %v", err)
+		}
+		if _, _, err = dec.decodeStreamID(); err != nil {
+			return stream, fmt.Errorf("stream max deleted entry ID decode error: %v", err)
+		}
+		if _, _, err = dec.decodeLength(); err != nil {
+			return stream, fmt.Errorf("stream entries added decode error: %v", err)
+		}
+	}
+
+	if err = dec.skipStreamConsumerGroups(valueType); err != nil {
+		return stream, fmt.Errorf("stream consumer groups decode error: %v", err)
+	}
+	return stream, nil
+}
+
+func (dec *decoder) decodeStreamID() (int64, int, error) {
+	timeMS, _, err := dec.decodeLength()
+	if err != nil {
+		return 0, 0, err
+	}
+	seqNum, _, err := dec.decodeLength()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(timeMS), seqNum, nil
+}
+
+func (dec *decoder) skipStreamConsumerGroups(valueType uint8) error {
+	groupsCount, _, err := dec.decodeLength()
+	if err != nil {
+		return err
+	}
+
+	for range groupsCount {
+		if _, err = dec.decodeString(); err != nil {
+			return fmt.Errorf("group name decode error: %v", err)
+		}
+		if _, _, err = dec.decodeStreamID(); err != nil {
+			return fmt.Errorf("group last ID decode error: %v", err)
+		}
+		if valueType >= STREAM_LISTPACKS_2_ENCODING {
+			if _, _, err = dec.decodeLength(); err != nil {
+				return fmt.Errorf("group entries read decode error: %v", err)
+			}
+		}
+
+		// Pending entries list: raw 16 bytes ID, delivery time and delivery count
+		pendingCount, _, err := dec.decodeLength()
+		if err != nil {
+			return fmt.Errorf("group pending entries count decode error: %v", err)
+		}
+		for range pendingCount {
+			if _, err = dec.traverseStringLen(16); err != nil {
+				return fmt.Errorf("pending entry ID decode error: %v", err)
+			}
+			if _, err = dec.traverseUInt64(); err != nil {
+				return fmt.Errorf("pending entry delivery time decode error: %v", err)
+			}
+			if _, _, err = dec.decodeLength(); err != nil {
+				return fmt.Errorf("pending entry delivery count decode error: %v", err)
+			}
+		}
+
+		consumersCount, _, err := dec.decodeLength()
+		if err != nil {
+			return fmt.Errorf("group consumers count decode error: %v", err)
+		}
+		for range consumersCount {
+			if _, err = dec.decodeString(); err != nil {
+				return fmt.Errorf("consumer name decode error: %v", err)
+			}
+			if _, err = dec.traverseUInt64(); err != nil {
+				return fmt.Errorf("consumer seen time decode error: %v", err)
+			}
+			if valueType >= STREAM_LISTPACKS_3_ENCODING {
+				if _, err = dec.traverseUInt64(); err != nil {
+					return fmt.Errorf("consumer active time decode error: %v", err)
+				}
+			}
+
+			consumerPendingCount, _, err := dec.decodeLength()
+			if err != nil {
+				return fmt.Errorf("consumer pending entries count decode error: %v", err)
+			}
+			if _, err = dec.traverseStringLen(16 * consumerPendingCount); err != nil {
+				return fmt.Errorf("consumer pending entries decode error: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Node listpack starts with master entry: count, deleted, master fields count, master fields and 0 terminator
+// Every entry is: flags, ms-diff, seq-diff, [fields count], fields and values (or only values for same fields), lp-count
+func decodeStreamNodeListpack(b []byte, masterTimeMS int64, masterSeqNum int) ([]memory.EntryWithStreamID, error) {
+	elements, err := decodeListpack(b)
+	if err != nil {
+		return nil, err
+	}
+	lp := &streamListpackReader{elements: elements}
+
+	count, err := lp.nextInt()
+	if err != nil {
+		return nil, fmt.Errorf("count decode error: %v", err)
+	}
+	deleted, err := lp.nextInt()
+	if err != nil {
+		return nil, fmt.Errorf("deleted count decode error: %v", err)
+	}
+	masterFieldsCount, err := lp.nextInt()
+	if err != nil {
+		return nil, fmt.Errorf("master fields count decode error: %v", err)
+	}
+	masterFields, err := lp.nextStrings(int(masterFieldsCount))
+	if err != nil {
+		return nil, fmt.Errorf("master fields decode error: %v", err)
+	}
+	if _, err = lp.nextInt(); err != nil {
+		return nil, fmt.Errorf("master entry terminator decode error: %v", err)
+	}
+
+	// Every entry has a few elements, so count, that is bigger than elements count, is corrupt
+	if count < 0 || count > int64(len(lp.elements)) {
+		return nil, fmt.Errorf("entries count %d is bigger than %d listpack elements", count, len(lp.elements))
+	}
+	entries := make([]memory.EntryWithStreamID, 0, count)
+	for range count + deleted {
+		flags, err := lp.nextInt()
+		if err != nil {
+			return nil, fmt.Errorf("entry flags decode error: %v", err)
+		}
+		timeMSDiff, err := lp.nextInt()
+		if err != nil {
+			return nil, fmt.Errorf("entry ms-diff decode error: %v", err)
+		}
+		seqNumDiff, err := lp.nextInt()
+		if err != nil {
+			return nil, fmt.Errorf("entry seq-diff decode error: %v", err)
+		}
+
+		entry := make(map[string]string)
+		if flags&STREAM_ITEM_FLAG_SAMEFIELDS != 0 {
+			values, err := lp.nextStrings(len(masterFields))
+			if err != nil {
+				return nil, fmt.Errorf("entry values decode error: %v", err)
+			}
+			for i, field := range masterFields {
+				entry[field] = values[i]
+			}
+		} else {
+			fieldsCount, err := lp.nextInt()
+			if err != nil {
+				return nil, fmt.Errorf("entry fields count decode error: %v", err)
+			}
+			fieldsAndValues, err := lp.nextStrings(int(fieldsCount) * 2)
+			if err != nil {
+				return nil, fmt.Errorf("entry fields decode error: %v", err)
+			}
+			for i := 0; i < len(fieldsAndValues); i += 2 {
+				entry[fieldsAndValues[i]] = fieldsAndValues[i+1]
+			}
+		}
+
+		if _, err = lp.nextInt(); err != nil {
+			return nil, fmt.Errorf("entry lp-count decode error: %v", err)
+		}
+
+		if flags&STREAM_ITEM_FLAG_DELETED != 0 {
+			continue
+		}
+		streamID := fmt.Sprintf("%d-%d", masterTimeMS+timeMSDiff, int64(masterSeqNum)+seqNumDiff)
+		entries = append(entries, memory.EntryWithStreamID{StreamID: streamID, Entry: entry})
+	}
+	return entries, nil
+}
+
+type streamListpackReader struct {
+	elements []string
+	pos      int
+}
+
+func (r *streamListpackReader) nextStrings(count int) ([]string, error) {
+	if count < 0 || count > len(r.elements)-r.pos {
+		return nil, fmt.Errorf("not enough listpack elements, need %d, left %d", count, len(r.elements)-r.pos)
+	}
+	values := r.elements[r.pos : r.pos+count]
+	r.pos += count
+	return values, nil
+}
+
+func (r *streamListpackReader) nextInt() (int64, error) {
+	values, err := r.nextStrings(1)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("listpack element isn't an integer: %v", err)
+	}
+	return value, nil
+}
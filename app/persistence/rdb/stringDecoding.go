@@ -6,6 +6,12 @@ import (
 )
 
 func (dec *decoder) decodeString() (string, error) {
+	if dec.pos < dec.len && dec.b[dec.pos] == 0xC0|SPECIAL_ENCODING_LZF {
+		dec.pos++
+		return dec.decodeLZFString()
+	}
+
+	start := dec.pos
 	len, isLengthAnIntegerString, err := dec.decodeLength()
 	if err != nil {
 		return "", fmt.Errorf("decodeLength error: %v", err)
@@ -13,9 +19,9 @@ func (dec *decoder) decodeString() (string, error) {
 
 	var str string
 	if !isLengthAnIntegerString {
-		str, err = dec.traverseStringLen(len)
+		str, err = dec.traverseLengthString(len, start)
 		if err != nil {
-			return "", nil
+			return "", err
 		}
 	} else {
 		str = strconv.Itoa(len)
@@ -24,27 +30,56 @@ func (dec *decoder) decodeString() (string, error) {
 	return str, nil
 }
 
+// Compressed string is <compressed-len><uncompressed-len><compressed-data>
+func (dec *decoder) decodeLZFString() (string, error) {
+	compressedLen, err := dec.decodeCount()
+	if err != nil {
+		return "", fmt.Errorf("compressed length decode error: %w", err)
+	}
+	uncompressedStart := dec.pos
+	uncompressedLen, _, err := dec.decodeLength()
+	if err != nil {
+		return "", fmt.Errorf("uncompressed length decode error: %v", err)
+	}
+	// Back reference of 3 bytes is expanded the most, up to LZF_MAX_REF bytes, so bigger length is corrupt
+	if uncompressedLen < 0 || uncompressedLen > compressedLen*LZF_MAX_REF {
+		return "", &offsetError{offset: uncompressedStart, err: fmt.Errorf("uncompressed length %d can't be decompressed from %d bytes", uncompressedLen, compressedLen)}
+	}
+
+	compressed, err := dec.traverseStringLen(compressedLen)
+	if err != nil {
+		return "", err
+	}
+
+	decompressed, err := lzfDecompress([]byte(compressed), uncompressedLen)
+	if err != nil {
+		return "", fmt.Errorf("lzf decompress error: %v", err)
+	}
+	return string(decompressed), nil
+}
+
 func (dec *decoder) traverseSpecialString(remainingBits uint8) (int, error) {
 	switch remainingBits {
-	case 0:
+	case SPECIAL_ENCODING_INT8:
 		value, err := dec.traverseUInt8()
 		if err != nil {
 			return 0, fmt.Errorf("failed to read 8-bit integer as string: %v", err)
 		}
-		return int(value), nil
-	case 1:
+		return int(int8(value)), nil
+	case SPECIAL_ENCODING_INT16:
 		value, err := dec.traverseUInt16()
 		if err != nil {
 			return 0, fmt.Errorf("failed to read 16-bit integer as string: %v", err)
 		}
-		return int(value), nil
-	case 2:
+		return int(int16(value)), nil
+	case SPECIAL_ENCODING_INT32:
 		value, err := dec.traverseUInt32()
 		if err != nil {
-			return 0, fmt.Errorf("failed to read 16-bit integer as string: %v", err)
+			return 0, fmt.Errorf("failed to read 32-bit integer as string: %v", err)
 		}
-		return int(value), nil
-	case 3:
+		return int(int32(value)), nil
+	case SPECIAL_ENCODING_LZF:
+		// Compressed string is handled in decodeString, it can't be used as a length
 		return 0, fmt.Errorf("unsupported compressed string format")
 	}
 	return 0, fmt.Errorf("unsupported integer string format")
@@ -24,6 +24,15 @@ func TestStringFunctions(t *testing.T) {
 			expected:    "hello",
 			expectedErr: nil,
 		},
+		{
+			name:   "decodeString: LZF compressed string",
+			buffer: []byte{0xC3, 0x07, 0x18, 0x02, 'a', 'b', 'c', 0xE0, 0x0C, 0x02},
+			runTest: func(dec *decoder) (any, error) {
+				return dec.decodeString()
+			},
+			expected:    "abcabcabcabcabcabcabcabc",
+			expectedErr: nil,
+		},
 		{
 			name:   "traverseSpecialString: Case 0 - 8-bit integer string",
 			buffer: []byte{0x42},
@@ -0,0 +1,65 @@
+package rdb
+
+import (
+	"math"
+	"strconv"
+)
+
+func (enc *encoder) encodeString(s string) {
+	if enc.tryEncodeIntegerString(s) {
+		return
+	}
+	enc.encodeBytes([]byte(s))
+}
+
+func (enc *encoder) encodeBytes(b []byte) {
+	if enc.tryEncodeLZFString(b) {
+		return
+	}
+	enc.encodeLength(uint64(len(b)))
+	enc.buf.Write(b)
+}
+
+// Like in original Redis, compression is used only when it saves at least 4 bytes
+func (enc *encoder) tryEncodeLZFString(b []byte) bool {
+	if len(b) <= LZF_MIN_STR_SIZE {
+		return false
+	}
+
+	compressed := lzfCompress(b, len(b)-4)
+	if compressed == nil {
+		return false
+	}
+
+	enc.buf.WriteByte(0xC0 | SPECIAL_ENCODING_LZF)
+	enc.encodeLength(uint64(len(compressed)))
+	enc.encodeLength(uint64(len(b)))
+	enc.buf.Write(compressed)
+	return true
+}
+
+// Only strings that are canonical decimal representation of 32-bit integer are encoded as integers
+// Otherwise, "007" would be decoded as "7"
+func (enc *encoder) tryEncodeIntegerString(s string) bool {
+	if len(s) == 0 || len(s) > 11 {
+		return false
+	}
+
+	value, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || strconv.FormatInt(value, 10) != s {
+		return false
+	}
+
+	switch {
+	case value >= math.MinInt8 && value <= math.MaxInt8:
+		enc.buf.WriteByte(0xC0 | SPECIAL_ENCODING_INT8)
+		enc.buf.WriteByte(byte(int8(value)))
+	case value >= math.MinInt16 && value <= math.MaxInt16:
+		enc.buf.WriteByte(0xC0 | SPECIAL_ENCODING_INT16)
+		enc.writeUInt16(uint16(int16(value)))
+	default:
+		enc.buf.WriteByte(0xC0 | SPECIAL_ENCODING_INT32)
+		enc.writeUInt32(uint32(int32(value)))
+	}
+	return true
+}
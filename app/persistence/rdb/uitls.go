@@ -3,6 +3,7 @@ package rdb
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
 )
 
 func (dec *decoder) traverseUInt64() (uint64, error) {
@@ -47,8 +48,44 @@ func (dec *decoder) traverseUintXBytes(byteCount int) (uint64, error) {
 	}
 }
 
+func (dec *decoder) traverseUInt64BigEndian() (uint64, error) {
+	if dec.len < dec.pos+8 {
+		return 0, fmt.Errorf("traverseUInt64BigEndian: not enough bytes, length is %d, pos is %d", dec.len, dec.pos)
+	}
+	num := binary.BigEndian.Uint64(dec.b[dec.pos : dec.pos+8])
+	dec.pos += 8
+	return num, nil
+}
+
+func (dec *decoder) traverseUInt32BigEndian() (uint32, error) {
+	if dec.len < dec.pos+4 {
+		return 0, fmt.Errorf("traverseUInt32BigEndian: not enough bytes, length is %d, pos is %d", dec.len, dec.pos)
+	}
+	num := binary.BigEndian.Uint32(dec.b[dec.pos : dec.pos+4])
+	dec.pos += 4
+	return num, nil
+}
+
+func (dec *decoder) traverseInt24() (int, error) {
+	raw, err := dec.traverseStringLen(3)
+	if err != nil {
+		return 0, err
+	}
+	value := int(raw[0]) | int(raw[1])<<8 | int(raw[2])<<16
+	if value >= 1<<23 {
+		value -= 1 << 24
+	}
+	return value, nil
+}
+
+func (dec *decoder) traverseFloat64() (float64, error) {
+	bits, err := dec.traverseUInt64()
+	return math.Float64frombits(bits), err
+}
+
 func (dec *decoder) traverseStringLen(offset int) (string, error) {
-	if dec.len < dec.pos+offset {
+	// Length from file can be huge, so it is compared with the rest of file, which can't overflow
+	if offset < 0 || offset > dec.len-dec.pos {
 		return "", fmt.Errorf("traverseStringLen: can't traverse by %d bytes because rdb file length is %d, got length: %d", offset, dec.len, dec.pos+offset)
 	}
 
@@ -56,3 +93,54 @@ func (dec *decoder) traverseStringLen(offset int) (string, error) {
 	dec.pos += offset
 	return str, nil
 }
+
+func (enc *encoder) writeUInt64(num uint64) {
+	enc.buf.Write(binary.LittleEndian.AppendUint64(nil, num))
+}
+
+func (enc *encoder) writeUInt32(num uint32) {
+	enc.buf.Write(binary.LittleEndian.AppendUint32(nil, num))
+}
+
+func (enc *encoder) writeUInt16(num uint16) {
+	enc.buf.Write(binary.LittleEndian.AppendUint16(nil, num))
+}
+
+func (enc *encoder) writeFloat64(num float64) {
+	enc.writeUInt64(math.Float64bits(num))
+}
+
+func appendUInt64BigEndian(b []byte, num uint64) []byte {
+	return binary.BigEndian.AppendUint64(b, num)
+}
+
+func appendUInt32BigEndian(b []byte, num uint32) []byte {
+	return binary.BigEndian.AppendUint32(b, num)
+}
+
+// Length, that is decoded at start, can't be bigger than the rest of file, e.g. every list element takes at least one byte
+// So corrupt length is reported at its offset, instead of huge allocation
+func (dec *decoder) checkLength(length int, start int) error {
+	if length < 0 || length > dec.len-dec.pos {
+		return &offsetError{offset: start, err: fmt.Errorf("length %d is bigger than the rest of %d bytes", length, dec.len-dec.pos)}
+	}
+	return nil
+}
+
+// String, which length is decoded at start, e.g. at its listpack entry
+func (dec *decoder) traverseLengthString(length int, start int) (string, error) {
+	if err := dec.checkLength(length, start); err != nil {
+		return "", err
+	}
+	return dec.traverseStringLen(length)
+}
+
+// Count of elements, which is checked by the rest of file, before elements are allocated
+func (dec *decoder) decodeCount() (int, error) {
+	start := dec.pos
+	count, _, err := dec.decodeLength()
+	if err != nil {
+		return 0, err
+	}
+	return count, dec.checkLength(count, start)
+}
@@ -0,0 +1,127 @@
+package rdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Ziplist is an older version of listpack, it is still met in RDB files of version < 10:
+// <zlbytes uint32> <zltail uint32> <zllen uint16> <entry> ... <entry> <0xFF>
+// Every entry is <prevlen><encoding><entry-data>
+const (
+	ZIPLIST_HEADER_SIZE = 10
+	ZIPLIST_END         = 0xFF
+	ZIPLIST_BIG_PREVLEN = 0xFE
+
+	ZIPLIST_INT16     = 0xC0
+	ZIPLIST_INT32     = 0xD0
+	ZIPLIST_INT64     = 0xE0
+	ZIPLIST_INT24     = 0xF0
+	ZIPLIST_INT8      = 0xFE
+	ZIPLIST_IMM_MIN   = 0xF1
+	ZIPLIST_IMM_MAX   = 0xFD
+	ZIPLIST_STR_32BIT = 0x80
+	ZIPLIST_STR_MASK  = 0xC0
+	ZIPLIST_STR_6BIT  = 0x00
+	ZIPLIST_STR_14BIT = 0x40
+	ZIPLIST_LEN_6BIT  = 0x3F
+	ZIPLIST_IMM_4BIT  = 0x0F
+)
+
+// Integers are returned as their decimal string representation
+func decodeZiplist(b []byte) (values []string, err error) {
+	dec := &decoder{b: b, pos: 0, len: len(b)}
+	// Offset is in blob, caller moves it to offset in file
+	defer func() {
+		var offsetErr *offsetError
+		if err != nil && !errors.As(err, &offsetErr) {
+			err = &offsetError{offset: dec.pos, err: err}
+		}
+	}()
+
+	totalBytes, err := dec.traverseUInt32()
+	if err != nil {
+		return nil, fmt.Errorf("ziplist total bytes decode error: %v", err)
+	}
+	if int(totalBytes) != len(b) {
+		return nil, fmt.Errorf("ziplist total bytes mismatch, header: %d, got: %d", totalBytes, len(b))
+	}
+	if _, err = dec.traverseStringLen(ZIPLIST_HEADER_SIZE - 4); err != nil {
+		return nil, fmt.Errorf("ziplist header decode error: %v", err)
+	}
+
+	values = make([]string, 0)
+	for {
+		prevLen, err := dec.traverseUInt8()
+		if err != nil {
+			return nil, fmt.Errorf("ziplist entry decode error: %v", err)
+		}
+		if prevLen == ZIPLIST_END {
+			break
+		}
+		if prevLen == ZIPLIST_BIG_PREVLEN {
+			if _, err = dec.traverseUInt32(); err != nil {
+				return nil, fmt.Errorf("ziplist entry prevlen decode error: %v", err)
+			}
+		}
+
+		value, err := dec.decodeZiplistEntry()
+		if err != nil {
+			return nil, fmt.Errorf("ziplist entry decode error: %w", err)
+		}
+		values = append(values, value)
+	}
+
+	if dec.pos != dec.len {
+		return nil, fmt.Errorf("ziplist has %d bytes after end mark", dec.len-dec.pos)
+	}
+	return values, nil
+}
+
+func (dec *decoder) decodeZiplistEntry() (string, error) {
+	start := dec.pos
+	encoding, err := dec.traverseUInt8()
+	if err != nil {
+		return "", err
+	}
+
+	switch encoding & ZIPLIST_STR_MASK {
+	case ZIPLIST_STR_6BIT:
+		return dec.traverseLengthString(int(encoding&ZIPLIST_LEN_6BIT), start)
+	case ZIPLIST_STR_14BIT:
+		next, err := dec.traverseUInt8()
+		if err != nil {
+			return "", err
+		}
+		return dec.traverseLengthString(int(encoding&ZIPLIST_LEN_6BIT)<<8|int(next), start)
+	case ZIPLIST_STR_32BIT:
+		len, err := dec.traverseUInt32BigEndian()
+		if err != nil {
+			return "", err
+		}
+		return dec.traverseLengthString(int(len), start)
+	}
+
+	switch {
+	case encoding == ZIPLIST_INT16:
+		value, err := dec.traverseUInt16()
+		return strconv.Itoa(int(int16(value))), err
+	case encoding == ZIPLIST_INT32:
+		value, err := dec.traverseUInt32()
+		return strconv.Itoa(int(int32(value))), err
+	case encoding == ZIPLIST_INT64:
+		value, err := dec.traverseUInt64()
+		return strconv.FormatInt(int64(value), 10), err
+	case encoding == ZIPLIST_INT24:
+		value, err := dec.traverseInt24()
+		return strconv.Itoa(value), err
+	case encoding == ZIPLIST_INT8:
+		value, err := dec.traverseUInt8()
+		return strconv.Itoa(int(int8(value))), err
+	case encoding >= ZIPLIST_IMM_MIN && encoding <= ZIPLIST_IMM_MAX:
+		return strconv.Itoa(int(encoding&ZIPLIST_IMM_4BIT) - 1), nil
+	default:
+		return "", fmt.Errorf("unknown ziplist entry encoding: %#x", encoding)
+	}
+}
@@ -0,0 +1,57 @@
+package rdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeZiplist(t *testing.T) {
+	tests := []struct {
+		name        string
+		buffer      []byte
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name: "Strings and integers",
+			buffer: []byte{
+				0x1D, 0x00, 0x00, 0x00, 0x17, 0x00, 0x00, 0x00, 0x05, 0x00,
+				0x00, 0x02, 'h', 'i',
+				0x04, 0xF3,
+				0x02, 0xFE, 0x80,
+				0x03, 0xC0, 0x34, 0x12,
+				0x04, 0xF0, 0xFF, 0xFF, 0xFF,
+				ZIPLIST_END,
+			},
+			expected: []string{"hi", "2", "-128", "4660", "-1"},
+		},
+		{
+			name:     "Empty ziplist",
+			buffer:   []byte{0x0B, 0x00, 0x00, 0x00, 0x0A, 0x00, 0x00, 0x00, 0x00, 0x00, ZIPLIST_END},
+			expected: []string{},
+		},
+		{
+			name:        "Total bytes mismatch",
+			buffer:      []byte{0x0C, 0x00, 0x00, 0x00, 0x0A, 0x00, 0x00, 0x00, 0x00, 0x00, ZIPLIST_END},
+			expectedErr: true,
+		},
+		{
+			name:        "Missing end mark",
+			buffer:      []byte{0x0E, 0x00, 0x00, 0x00, 0x0A, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x02, 'h', 'i'},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := decodeZiplist(test.buffer)
+			if test.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, result)
+			}
+		})
+	}
+}
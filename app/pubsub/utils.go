@@ -8,8 +8,9 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/utils"
 )
 
-func CreateRESPChannelAndLenResponse(action string, chanAndLen ChanAndLen) resp.Array {
-	return resp.Array{Value: []resp.Value{
+// Replies of subscribe and unsubscribe are push frames for RESP3 client, like messages
+func CreateRESPChannelAndLenResponse(action string, chanAndLen ChanAndLen) resp.Push {
+	return resp.Push{Value: []resp.Value{
 		resp.BulkString{Value: &action},
 		resp.BulkString{Value: &chanAndLen.Channel},
 		resp.Integer{Value: chanAndLen.SubscribedToLen},
@@ -19,13 +20,8 @@ func CreateRESPChannelAndLenResponse(action string, chanAndLen ChanAndLen) resp.
 func writeMessageToSubscriber(channel, message string, sub *subscriber) error {
 	addr := utils.GetRemoteAddr(sub.conn)
 
-	response := resp.CreateBulkStringArray("message", channel, message)
-	b, err := response.Encode()
-	if err != nil {
-		return err
-	}
-
-	_, err = sub.conn.Write(b)
+	response := resp.Push{Value: resp.CreateBulkStringArray("message", channel, message).Value}
+	err := utils.WriteCommand(response, sub.conn)
 	if err != nil {
 		return fmt.Errorf("write to subscriber %s error: %s", addr, err)
 	}
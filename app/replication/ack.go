@@ -1,6 +0,0 @@
-package replication
-
-type Ack struct {
-	Addr   string
-	Offset int
-}
@@ -0,0 +1,70 @@
+package replication
+
+import "slices"
+
+// Circular buffer with the latest bytes of replication stream, it allows replica to continue replication after reconnect
+type backlog struct {
+	buf []byte
+	// Position in buf for the next write
+	idx int
+	// Count of stream bytes in buf, it is less than buf size until buf is filled for the first time
+	histlen int
+	// Replication offset of the last written byte
+	offset int
+}
+
+func newBacklog(size int) *backlog {
+	return &backlog{buf: make([]byte, size)}
+}
+
+func (bl *backlog) write(b []byte) {
+	size := len(bl.buf)
+	bl.offset += len(b)
+	if size == 0 {
+		return
+	}
+
+	if len(b) >= size {
+		copy(bl.buf, b[len(b)-size:])
+		bl.idx = 0
+		bl.histlen = size
+		return
+	}
+
+	n := copy(bl.buf[bl.idx:], b)
+	copy(bl.buf, b[n:])
+	bl.idx = (bl.idx + len(b)) % size
+	bl.histlen = min(bl.histlen+len(b), size)
+}
+
+// Returns stream bytes after the offset, false if some of them aren't in backlog anymore
+func (bl *backlog) readFrom(offset int) ([]byte, bool) {
+	if offset < bl.offset-bl.histlen || offset > bl.offset {
+		return nil, false
+	}
+
+	size := len(bl.buf)
+	n := bl.offset - offset
+	out := make([]byte, n)
+	if n == 0 {
+		return out, true
+	}
+
+	start := (bl.idx - n + size) % size
+	copied := copy(out, bl.buf[start:min(start+n, size)])
+	copy(out[copied:], bl.buf)
+	return out, true
+}
+
+// Backlog is emptied, e.g. after full resync, because its bytes belong to the previous replication history
+func (bl *backlog) reset(offset int) {
+	bl.idx = 0
+	bl.histlen = 0
+	bl.offset = offset
+}
+
+func (bl *backlog) clone() *backlog {
+	cloned := *bl
+	cloned.buf = slices.Clone(bl.buf)
+	return &cloned
+}
@@ -0,0 +1,46 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBacklog(t *testing.T) {
+	tests := []struct {
+		Name         string
+		Size         int
+		Writes       []string
+		Offset       int
+		Expected     string
+		ExpectedOK   bool
+		ExpectedLast int
+	}{
+		{Name: "Empty backlog", Size: 8, Writes: nil, Offset: 0, Expected: "", ExpectedOK: true, ExpectedLast: 0},
+		{Name: "Read all", Size: 8, Writes: []string{"abc", "de"}, Offset: 0, Expected: "abcde", ExpectedOK: true, ExpectedLast: 5},
+		{Name: "Read tail", Size: 8, Writes: []string{"abc", "de"}, Offset: 3, Expected: "de", ExpectedOK: true, ExpectedLast: 5},
+		{Name: "Read nothing at the last offset", Size: 8, Writes: []string{"abc"}, Offset: 3, Expected: "", ExpectedOK: true, ExpectedLast: 3},
+		{Name: "Offset in the future", Size: 8, Writes: []string{"abc"}, Offset: 4, Expected: "", ExpectedOK: false, ExpectedLast: 3},
+		{Name: "Wrapped around", Size: 8, Writes: []string{"abcdef", "ghij"}, Offset: 2, Expected: "cdefghij", ExpectedOK: true, ExpectedLast: 10},
+		{Name: "Wrapped around tail", Size: 8, Writes: []string{"abcdef", "ghij"}, Offset: 5, Expected: "fghij", ExpectedOK: true, ExpectedLast: 10},
+		{Name: "Overwritten offset", Size: 8, Writes: []string{"abcdef", "ghij"}, Offset: 1, Expected: "", ExpectedOK: false, ExpectedLast: 10},
+		{Name: "Write bigger than backlog", Size: 4, Writes: []string{"ab", "cdefghij"}, Offset: 6, Expected: "ghij", ExpectedOK: true, ExpectedLast: 10},
+		{Name: "Zero size backlog", Size: 0, Writes: []string{"abc"}, Offset: 0, Expected: "", ExpectedOK: false, ExpectedLast: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			bl := newBacklog(test.Size)
+			for _, w := range test.Writes {
+				bl.write([]byte(w))
+			}
+
+			out, ok := bl.readFrom(test.Offset)
+			assert.Equal(t, test.ExpectedOK, ok)
+			if test.ExpectedOK {
+				assert.Equal(t, test.Expected, string(out))
+			}
+			assert.Equal(t, test.ExpectedLast, bl.offset)
+		})
+	}
+}
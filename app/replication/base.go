@@ -1,34 +1,266 @@
 package replication
 
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
+)
+
+// Replicas are served by master and by replica, that forwards replication stream of its master to sub-replicas
 type BaseController interface {
 	Info() *Info
-	SetMasterReplID(replID string)
-	SetMasterReplOfffset(replOffset int)
-	IncrMasterReplOffset(replOffset int)
+	// Listening port is the port, where replica accepts clients, it is shown in INFO
+	AddReplicaConn(replicaConn net.Conn, listeningPort int)
+	RemoveReplicaConn(addr string)
+	GetReplicas() map[string]net.Conn
+	IsReplica(conn net.Conn) bool
+	// Replication stream, written after this call, is buffered for the replica until RDB file is sent
+	// Must be called when the storage snapshot for the replica is taken, returns replication offset of the snapshot
+	StartFullResync(replicaConn net.Conn) int
+	// Replies +CONTINUE and sends the missed part of replication stream from backlog, after that replica is online
+	// Returns false if partial resync isn't possible (e.g. unknown replication ID or offset isn't in backlog)
+	ContinueResync(replicaConn net.Conn, replID string, psyncOffset int) (bool, error)
+	// Sends RDB file of the snapshot and then buffered replication stream, after that replica is online
+	SendRDBFile(replicaConn net.Conn, snapshot *memory.Snapshot) error
+	SendAck(addr string, offset int)
 }
 
+const (
+	// Replica has connected, but full resync isn't started, replication stream isn't sent to it
+	REPLICA_STATE_HANDSHAKE = "handshake"
+	// RDB file is being sent, replication stream is buffered
+	REPLICA_STATE_SYNC = "sync"
+	// Replication stream is sent to the replica
+	REPLICA_STATE_ONLINE = "online"
+)
+
 type baseController struct {
-	info *Info
+	info     *Info
+	args     *config.Args
+	replicas map[string]*connectedReplica
+	backlog  *backlog
+	// Closed and replaced on every ack
+	ackCh chan struct{}
+	// Replicas, replication IDs and offset, backlog and acks are changed together
+	replicationMut sync.Mutex
 }
 
-var _ BaseController = (*baseController)(nil)
+func newBaseController(args *config.Args, info *Info) *baseController {
+	bc := &baseController{
+		info:     info,
+		args:     args,
+		replicas: make(map[string]*connectedReplica),
+		backlog:  newBacklog(args.ReplBacklogSize),
+		ackCh:    make(chan struct{}),
+	}
+	bc.backlog.offset = info.MasterReplOffset
+	return bc
+}
+
+// Replication history of the previous role is kept, so replicas can continue partial resync after role change
+func inheritBaseController(args *config.Args, info *Info, previous BaseController) *baseController {
+	bc := newBaseController(args, info)
+	prev, ok := previous.(interface{ base() *baseController })
+	if !ok {
+		return bc
+	}
+
+	prevBase := prev.base()
+	prevBase.replicationMut.Lock()
+	defer prevBase.replicationMut.Unlock()
 
-func newBaseController(info *Info) *baseController {
-	return &baseController{info: info}
+	bc.backlog = prevBase.backlog.clone()
+	bc.info.MasterReplID = prevBase.info.MasterReplID
+	bc.info.MasterReplOffset = prevBase.info.MasterReplOffset
+	bc.info.MasterReplID2 = prevBase.info.MasterReplID2
+	bc.info.SecondReplOffset = prevBase.info.SecondReplOffset
+	return bc
 }
 
+func (bc *baseController) base() *baseController {
+	return bc
+}
+
+// Returns a copy, replicas are filled on every call
 func (bc *baseController) Info() *Info {
-	return bc.info
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+
+	info := *bc.info
+	info.Slaves = make([]SlaveInfo, 0, len(bc.replicas))
+	now := time.Now()
+	for _, replica := range bc.replicas {
+		info.Slaves = append(info.Slaves, replica.info(now))
+	}
+	sort.Slice(info.Slaves, func(i, j int) bool {
+		return info.Slaves[i].IP < info.Slaves[j].IP || (info.Slaves[i].IP == info.Slaves[j].IP && info.Slaves[i].Port < info.Slaves[j].Port)
+	})
+	return &info
+}
+
+func (bc *baseController) AddReplicaConn(replicaConn net.Conn, listeningPort int) {
+	replica := newConnectedReplica(replicaConn, listeningPort, bc.args.ReplicaOutputBufferLimit)
+
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+
+	if old, ok := bc.replicas[replica.addr]; ok {
+		old.close()
+	}
+	log.Printf("Added replica %s to replicas map", replica.addr)
+	bc.replicas[replica.addr] = replica
+	go replica.writeOutput()
+}
+
+func (bc *baseController) RemoveReplicaConn(addr string) {
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+
+	if replica, ok := bc.replicas[addr]; ok {
+		replica.close()
+		log.Printf("Removed replica %s from replicas map", addr)
+	}
+	delete(bc.replicas, addr)
+}
+
+func (bc *baseController) GetReplicas() map[string]net.Conn {
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+
+	replicaConns := make(map[string]net.Conn, len(bc.replicas))
+	for addr, replica := range bc.replicas {
+		replicaConns[addr] = replica.conn
+	}
+	return replicaConns
+}
+
+func (bc *baseController) IsReplica(conn net.Conn) bool {
+	return bc.getReplica(utils.GetRemoteAddr(conn)) != nil
+}
+
+func (bc *baseController) StartFullResync(replicaConn net.Conn) int {
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+
+	if replica, ok := bc.replicas[utils.GetRemoteAddr(replicaConn)]; ok {
+		replica.setState(REPLICA_STATE_SYNC)
+	}
+	return bc.info.MasterReplOffset
+}
+
+// PSYNC offset is the offset of the first byte, that replica wants to get, like in Redis
+// Replica of the previous replication ID continues, if it hasn't got bytes after the history switch
+// Replication stream isn't written until replica is online, so backlog part isn't mixed with new commands
+func (bc *baseController) ContinueResync(replicaConn net.Conn, replID string, psyncOffset int) (bool, error) {
+	addr := utils.GetRemoteAddr(replicaConn)
+
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+
+	replica, ok := bc.replicas[addr]
+	if !ok {
+		return false, fmt.Errorf("no such replica: %s", addr)
+	}
+	if replID != bc.info.MasterReplID && (replID != bc.info.MasterReplID2 || psyncOffset > bc.info.SecondReplOffset) {
+		return false, nil
+	}
+	missed, ok := bc.backlog.readFrom(psyncOffset - 1)
+	if !ok {
+		return false, nil
+	}
+
+	response := append(fmt.Appendf(nil, "+CONTINUE %s\r\n", bc.info.MasterReplID), missed...)
+	_, err := replicaConn.Write(response)
+	if err != nil {
+		return true, fmt.Errorf("CONTINUE write error: %v", err)
+	}
+	log.Printf("Partial resync with replica %s is finished, %d bytes of backlog are sent", addr, len(missed))
+
+	replica.setState(REPLICA_STATE_ONLINE)
+	return true, nil
+}
+
+func (bc *baseController) SendRDBFile(replicaConn net.Conn, snapshot *memory.Snapshot) error {
+	addr := utils.GetRemoteAddr(replicaConn)
+	replica := bc.getReplica(addr)
+	if replica == nil {
+		return fmt.Errorf("no such replica: %s", addr)
+	}
+
+	b, err := rdb.Encode(snapshot)
+	if err != nil {
+		return fmt.Errorf("RDB encode error: %v", err)
+	}
+
+	response := append(fmt.Appendf(nil, "$%d\r\n", len(b)), b...)
+	_, err = replicaConn.Write(response)
+	if err != nil {
+		return fmt.Errorf("RDB file write error: %v", err)
+	}
+
+	// Replication stream, buffered while RDB file is sent, is written first by the replica writer
+	replica.setState(REPLICA_STATE_ONLINE)
+	log.Printf("Full resync with replica %s is finished, %d bytes of RDB file are sent", addr, len(b))
+	return nil
+}
+
+// Replica acks the offset of replication stream, that it has processed
+func (bc *baseController) SendAck(addr string, offset int) {
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+
+	replica, ok := bc.replicas[addr]
+	if !ok {
+		return
+	}
+	replica.ackOffset = max(replica.ackOffset, offset)
+	replica.ackTime = time.Now()
+	close(bc.ackCh)
+	bc.ackCh = make(chan struct{})
+}
+
+// Every byte of replication stream moves replication offset, it is kept in backlog and sent to replicas
+func (bc *baseController) feed(b []byte) {
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+
+	bc.backlog.write(b)
+	bc.info.MasterReplOffset = bc.backlog.offset
+
+	now := time.Now()
+	for addr, replica := range bc.replicas {
+		if !replica.enqueue(b, now) {
+			log.Printf("Replica %s is disconnected, output buffer limit is exceeded", addr)
+		}
+	}
 }
 
-func (bc *baseController) SetMasterReplID(replID string) {
+// Replication history is switched to the new ID, replicas of the previous one can continue up to the current offset
+// Caller holds replicationMut
+func (bc *baseController) shiftReplID(replID string) {
+	bc.info.MasterReplID2 = bc.info.MasterReplID
+	bc.info.SecondReplOffset = bc.info.MasterReplOffset + 1
 	bc.info.MasterReplID = replID
 }
 
-func (bc *baseController) SetMasterReplOfffset(replOffset int) {
-	bc.info.MasterReplOffset = replOffset
+// Replicas reconnect and resync, e.g. when replication history is changed
+// Caller holds replicationMut
+func (bc *baseController) disconnectReplicas() {
+	for _, replica := range bc.replicas {
+		replica.close()
+	}
 }
 
-func (bc *baseController) IncrMasterReplOffset(replOffset int) {
-	bc.info.MasterReplOffset += replOffset
+func (bc *baseController) getReplica(addr string) *connectedReplica {
+	bc.replicationMut.Lock()
+	defer bc.replicationMut.Unlock()
+	return bc.replicas[addr]
 }
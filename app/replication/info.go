@@ -1,25 +1,100 @@
 package replication
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	MASTER_LINK_STATUS_UP   = "up"
+	MASTER_LINK_STATUS_DOWN = "down"
+	// Shown as the previous replication ID, when there is no such one, like in Redis
+	NO_REPLICATION_ID = "0000000000000000000000000000000000000000"
 )
 
 type Info struct {
 	Role             string
 	MasterReplID     string
 	MasterReplOffset int
+	// Previous replication ID and the offset up to which its replicas can continue partial resync, -1 if there is no such ID
+	MasterReplID2    string
+	SecondReplOffset int
+	// Replicas of master or sub-replicas of replica
+	Slaves []SlaveInfo
+	// Fields below are filled only for replica
+	MasterHost           string
+	MasterPort           int
+	MasterLinkStatus     string
+	MasterSyncInProgress bool
+	// Zero, if link is down
+	MasterLastIO time.Time
+	// Zero, if link is up or replica has never been connected to master
+	MasterLinkDownSince time.Time
+}
+
+// Replica, connected to master
+type SlaveInfo struct {
+	IP    string
+	Port  int
+	State string
+	// The last acked replication offset
+	Offset int
+	// Seconds since the last ack
+	Lag int
 }
 
 func (i *Info) String() string {
-	data := []string{
-		"role:" + i.Role,
-		"master_replid:" + i.MasterReplID,
-		"master_repl_offset:" + strconv.Itoa(i.MasterReplOffset),
+	data := []string{"role:" + i.Role}
+	if i.Role == "slave" {
+		data = append(data, i.replicaFields()...)
+	}
+	data = append(data, "connected_slaves:"+strconv.Itoa(len(i.Slaves)))
+	for n, slave := range i.Slaves {
+		data = append(data, fmt.Sprintf("slave%d:ip=%s,port=%d,state=%s,offset=%d,lag=%d", n, slave.IP, slave.Port, slave.State, slave.Offset, slave.Lag))
 	}
+	data = append(data,
+		"master_replid:"+i.MasterReplID,
+		"master_replid2:"+i.MasterReplID2,
+		"master_repl_offset:"+strconv.Itoa(i.MasterReplOffset),
+		"second_repl_offset:"+strconv.Itoa(i.SecondReplOffset),
+	)
 	return strings.Join(data, "\r\n") + "\r\n"
 }
 
+// Last IO and link down times are -1, if they are unknown, like in Redis
+func (i *Info) replicaFields() []string {
+	syncInProgress := 0
+	if i.MasterSyncInProgress {
+		syncInProgress = 1
+	}
+	data := []string{
+		"master_host:" + i.MasterHost,
+		"master_port:" + strconv.Itoa(i.MasterPort),
+		"master_link_status:" + i.MasterLinkStatus,
+		"master_last_io_seconds_ago:" + strconv.Itoa(secondsSince(i.MasterLastIO)),
+		"master_sync_in_progress:" + strconv.Itoa(syncInProgress),
+		"slave_repl_offset:" + strconv.Itoa(i.MasterReplOffset),
+	}
+	if i.MasterLinkStatus == MASTER_LINK_STATUS_DOWN {
+		data = append(data, "master_link_down_since_seconds:"+strconv.Itoa(secondsSince(i.MasterLinkDownSince)))
+	}
+	return data
+}
+
+func secondsSince(t time.Time) int {
+	if t.IsZero() {
+		return -1
+	}
+	return int(time.Since(t).Seconds())
+}
+
+// Replication ID is 40 random hex characters, like in Redis
 func generateReplicationId() string {
-	return "8371b4fb1155b71f4a04d3e1bc3e18c4a990aeeb"
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
@@ -0,0 +1,84 @@
+package replication
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+)
+
+func TestInfoString(t *testing.T) {
+	tests := []struct {
+		Name     string
+		In       *Info
+		Expected string
+	}{
+		{
+			Name:     "Master without replicas",
+			In:       &Info{Role: "master", MasterReplID: "id", MasterReplOffset: 10, MasterReplID2: NO_REPLICATION_ID, SecondReplOffset: -1},
+			Expected: "role:master\r\nconnected_slaves:0\r\nmaster_replid:id\r\nmaster_replid2:" + NO_REPLICATION_ID + "\r\nmaster_repl_offset:10\r\nsecond_repl_offset:-1\r\n",
+		},
+		{
+			Name: "Master with replicas",
+			In: &Info{Role: "master", MasterReplID: "id", MasterReplOffset: 10, MasterReplID2: NO_REPLICATION_ID, SecondReplOffset: -1, Slaves: []SlaveInfo{
+				{IP: "127.0.0.1", Port: 6380, State: "online", Offset: 10, Lag: 0},
+				{IP: "127.0.0.1", Port: 6381, State: "sync", Offset: 0, Lag: 3},
+			}},
+			Expected: "role:master\r\nconnected_slaves:2\r\nslave0:ip=127.0.0.1,port=6380,state=online,offset=10,lag=0\r\nslave1:ip=127.0.0.1,port=6381,state=sync,offset=0,lag=3\r\nmaster_replid:id\r\nmaster_replid2:" + NO_REPLICATION_ID + "\r\nmaster_repl_offset:10\r\nsecond_repl_offset:-1\r\n",
+		},
+		{
+			Name:     "Replica with link up",
+			In:       &Info{Role: "slave", MasterReplID: "id", MasterReplOffset: 10, MasterReplID2: NO_REPLICATION_ID, SecondReplOffset: -1, MasterHost: "127.0.0.1", MasterPort: 6379, MasterLinkStatus: MASTER_LINK_STATUS_UP, MasterLastIO: time.Now().Add(-2 * time.Second)},
+			Expected: "role:slave\r\nmaster_host:127.0.0.1\r\nmaster_port:6379\r\nmaster_link_status:up\r\nmaster_last_io_seconds_ago:2\r\nmaster_sync_in_progress:0\r\nslave_repl_offset:10\r\nconnected_slaves:0\r\nmaster_replid:id\r\nmaster_replid2:" + NO_REPLICATION_ID + "\r\nmaster_repl_offset:10\r\nsecond_repl_offset:-1\r\n",
+		},
+		{
+			Name:     "Replica never connected",
+			In:       &Info{Role: "slave", MasterReplID: "?", MasterReplOffset: -1, MasterReplID2: NO_REPLICATION_ID, SecondReplOffset: -1, MasterHost: "127.0.0.1", MasterPort: 6379, MasterLinkStatus: MASTER_LINK_STATUS_DOWN},
+			Expected: "role:slave\r\nmaster_host:127.0.0.1\r\nmaster_port:6379\r\nmaster_link_status:down\r\nmaster_last_io_seconds_ago:-1\r\nmaster_sync_in_progress:0\r\nslave_repl_offset:-1\r\nmaster_link_down_since_seconds:-1\r\nconnected_slaves:0\r\nmaster_replid:?\r\nmaster_replid2:" + NO_REPLICATION_ID + "\r\nmaster_repl_offset:-1\r\nsecond_repl_offset:-1\r\n",
+		},
+		{
+			Name:     "Replica with link down during sync",
+			In:       &Info{Role: "slave", MasterReplID: "id", MasterReplOffset: 10, MasterReplID2: NO_REPLICATION_ID, SecondReplOffset: -1, MasterHost: "127.0.0.1", MasterPort: 6379, MasterLinkStatus: MASTER_LINK_STATUS_DOWN, MasterSyncInProgress: true, MasterLinkDownSince: time.Now().Add(-3 * time.Second)},
+			Expected: "role:slave\r\nmaster_host:127.0.0.1\r\nmaster_port:6379\r\nmaster_link_status:down\r\nmaster_last_io_seconds_ago:-1\r\nmaster_sync_in_progress:1\r\nslave_repl_offset:10\r\nmaster_link_down_since_seconds:3\r\nconnected_slaves:0\r\nmaster_replid:id\r\nmaster_replid2:" + NO_REPLICATION_ID + "\r\nmaster_repl_offset:10\r\nsecond_repl_offset:-1\r\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, test.In.String())
+		})
+	}
+}
+
+func TestReplicaLinkState(t *testing.T) {
+	rc := NewReplicaController(&config.Args{}, "127.0.0.1", 6379, nil)
+	info := rc.Info()
+	assert.Equal(t, MASTER_LINK_STATUS_DOWN, info.MasterLinkStatus)
+	assert.True(t, info.MasterLinkDownSince.IsZero())
+
+	rc.SetLinkState(MASTER_LINK_STATE_HANDSHAKE)
+	rc.SetLinkState(MASTER_LINK_STATE_SYNC)
+	info = rc.Info()
+	assert.True(t, info.MasterSyncInProgress)
+	assert.True(t, info.MasterLinkDownSince.IsZero())
+
+	rc.SetLinkState(MASTER_LINK_STATE_CONNECTED)
+	info = rc.Info()
+	assert.Equal(t, MASTER_LINK_STATUS_UP, info.MasterLinkStatus)
+	assert.False(t, info.MasterSyncInProgress)
+	assert.False(t, info.MasterLastIO.IsZero())
+
+	rc.SetLinkState(MASTER_LINK_STATE_CONNECT)
+	info = rc.Info()
+	assert.Equal(t, MASTER_LINK_STATUS_DOWN, info.MasterLinkStatus)
+	assert.False(t, info.MasterLinkDownSince.IsZero())
+	assert.True(t, info.MasterLastIO.IsZero())
+
+	// Link down time isn't reset by failed reconnect attempts
+	downSince := info.MasterLinkDownSince
+	rc.SetLinkState(MASTER_LINK_STATE_HANDSHAKE)
+	rc.SetLinkState(MASTER_LINK_STATE_CONNECT)
+	assert.Equal(t, downSince, rc.Info().MasterLinkDownSince)
+}
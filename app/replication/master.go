@@ -1,127 +1,149 @@
 package replication
 
 import (
-	"encoding/hex"
-	"fmt"
 	"log"
-	"net"
-	"sync"
+	"time"
 
-	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
-	"github.com/codecrafters-io/redis-starter-go/app/utils"
 )
 
 type MasterController interface {
 	BaseController
-	AddReplicaConn(replicaConn net.Conn)
-	RemoveReplicaConn(addr string)
-	GetReplicas() map[string]net.Conn
-	IsReplica(conn net.Conn) bool
-	SendRDBFile(replicaConn net.Conn)
 	Propagate(args []string)
-	GetAcksCh() chan Ack
-	SendAck(addr string, offset int)
-	SetHasPendingWrites(val bool)
-	HasPendingWrites() bool
+	// Saves the current replication offset as the offset of the client's last write, WAIT waits for it
+	RecordClientWrite(addr string)
+	GetClientWriteOffset(addr string) int
+	RemoveClient(addr string)
+	// Returns count of replicas, that have acked the offset, it waits until there are numReplicas of them or timeout passes
+	// Zero timeout means waiting forever, negative one means no waiting
+	WaitAcks(offset, numReplicas int, timeout time.Duration) int
+	// Replica is good, when it is online and its last ack is not older than max lag
+	CountGoodReplicas(maxLag time.Duration) int
 }
 
 type masterController struct {
 	*baseController
-	replicas           map[string]net.Conn
-	acks               chan Ack
-	hasPendingWrites   bool
-	pendingWritesMutex sync.Mutex
+	// Replication offset after the last write command of each client, it is changed under replicationMut
+	clientWriteOffsets map[string]int
 }
 
-func NewMasterController() MasterController {
-	masterInfo := initMasterInfo()
+func NewMasterController(args *config.Args) MasterController {
 	return &masterController{
-		baseController: newBaseController(masterInfo),
-		replicas:       make(map[string]net.Conn),
-		acks:           make(chan Ack, 10),
+		baseController:     newBaseController(args, initMasterInfo()),
+		clientWriteOffsets: make(map[string]int),
 	}
 }
 
-func (mc *masterController) AddReplicaConn(replicaConn net.Conn) {
-	addr := utils.GetRemoteAddr(replicaConn)
-	log.Printf("Added replica %s to replicas map", addr)
-	mc.replicas[addr] = replicaConn
+// Promoted replica keeps replication offset and backlog, but starts new replication ID, because its history can diverge from the old master
+// Replicas of the old master continue partial resync with the previous ID, like in Redis PSYNC2
+func NewPromotedMasterController(args *config.Args, replicaController ReplicaController) MasterController {
+	mc := &masterController{
+		baseController:     inheritBaseController(args, initMasterInfo(), replicaController),
+		clientWriteOffsets: make(map[string]int),
+	}
+	if mc.info.MasterReplOffset < 0 {
+		// Replica has never been synced, so there is no history to continue
+		mc.info = initMasterInfo()
+		mc.backlog.reset(0)
+		return mc
+	}
+	mc.shiftReplID(generateReplicationId())
+	return mc
 }
 
-func (mc *masterController) RemoveReplicaConn(addr string) {
-	if _, ok := mc.replicas[addr]; ok {
-		log.Printf("Removed replica %s from replicas map", addr)
+// Every propagated command is a part of replication stream
+func (mc *masterController) Propagate(args []string) {
+	command, err := resp.CreateBulkStringArray(args...).Encode()
+	if err != nil {
+		log.Printf("Propagate %s encode error: %v", args[0], err)
+		return
 	}
-	delete(mc.replicas, addr)
+	mc.feed(command)
 }
 
-func (mc *masterController) GetReplicas() map[string]net.Conn {
-	return mc.replicas
+func (mc *masterController) RecordClientWrite(addr string) {
+	mc.replicationMut.Lock()
+	defer mc.replicationMut.Unlock()
+	mc.clientWriteOffsets[addr] = mc.info.MasterReplOffset
 }
 
-func (mc *masterController) IsReplica(conn net.Conn) bool {
-	addr := utils.GetRemoteAddr(conn)
-	_, ok := mc.replicas[addr]
-	return ok
+// Client without writes has zero offset, so it doesn't wait for any replica
+func (mc *masterController) GetClientWriteOffset(addr string) int {
+	mc.replicationMut.Lock()
+	defer mc.replicationMut.Unlock()
+	return mc.clientWriteOffsets[addr]
 }
 
-func (mc *masterController) SendRDBFile(replicaConn net.Conn) {
-	// Alternatively here call rdb.ReadRDBFile, but i use hardcoded hex string to pass tests
-	// (In tests i guess they don't use rdb file, so my program returns error that can't find such dir when reading rdb file)
-	b, err := hex.DecodeString(rdb.EMPTY_DB_HEX)
-	if err != nil {
-		log.Fatalf("SendRDBFile error: %v", err)
-	}
-
-	response := append(fmt.Appendf(nil, "$%d\r\n", len(b)), b...)
-	_, err = replicaConn.Write(response)
-	if err != nil {
-		log.Fatalf("SendRDBFile error: %v", err)
-	}
+func (mc *masterController) RemoveClient(addr string) {
+	mc.replicationMut.Lock()
+	defer mc.replicationMut.Unlock()
+	delete(mc.clientWriteOffsets, addr)
 }
 
-func (mc *masterController) Propagate(args []string) {
-	command := resp.CreateBulkStringArray(args...)
-	for addr, conn := range mc.replicas {
-		go mc.propagateCommandToConn(command, addr, conn)
+func (mc *masterController) WaitAcks(offset, numReplicas int, timeout time.Duration) int {
+	if timeout < 0 {
+		mc.replicationMut.Lock()
+		defer mc.replicationMut.Unlock()
+		return mc.countAckedReplicas(offset)
 	}
-}
 
-func (mc *masterController) GetAcksCh() chan Ack {
-	return mc.acks
-}
-
-func (mc *masterController) SendAck(addr string, offset int) {
-	if _, ok := mc.replicas[addr]; !ok {
-		return
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
 	}
-	mc.acks <- Ack{Addr: addr, Offset: offset}
-}
 
-func (mc *masterController) SetHasPendingWrites(val bool) {
-	mc.pendingWritesMutex.Lock()
-	defer mc.pendingWritesMutex.Unlock()
-	mc.hasPendingWrites = val
+	for {
+		mc.replicationMut.Lock()
+		acked := mc.countAckedReplicas(offset)
+		ackCh := mc.ackCh
+		mc.replicationMut.Unlock()
+
+		if acked >= numReplicas {
+			return acked
+		}
+		select {
+		case <-ackCh:
+		case <-timer:
+			return acked
+		}
+	}
 }
 
-func (mc *masterController) HasPendingWrites() bool {
-	mc.pendingWritesMutex.Lock()
-	defer mc.pendingWritesMutex.Unlock()
-	return mc.hasPendingWrites
+func (mc *masterController) CountGoodReplicas(maxLag time.Duration) int {
+	mc.replicationMut.Lock()
+	defer mc.replicationMut.Unlock()
+
+	good := 0
+	now := time.Now()
+	for _, replica := range mc.replicas {
+		slaveInfo := replica.info(now)
+		if slaveInfo.State == REPLICA_STATE_ONLINE && time.Duration(slaveInfo.Lag)*time.Second <= maxLag {
+			good++
+		}
+	}
+	return good
 }
 
-func (mc *masterController) propagateCommandToConn(command resp.Array, addr string, conn net.Conn) {
-	err := utils.WriteCommand(command, conn)
-	if err != nil {
-		log.Printf("Desynchronization with %s (but continue to work), propagateWriteCommand error: %v", addr, err)
+// Caller holds replicationMut
+func (mc *masterController) countAckedReplicas(offset int) int {
+	acked := 0
+	for _, replica := range mc.replicas {
+		if replica.ackOffset >= offset {
+			acked++
+		}
 	}
+	return acked
 }
 
 func initMasterInfo() *Info {
 	return &Info{
 		Role:             "master",
 		MasterReplID:     generateReplicationId(),
+		MasterReplID2:    NO_REPLICATION_ID,
 		MasterReplOffset: 0,
+		SecondReplOffset: -1,
 	}
 }
@@ -0,0 +1,147 @@
+package replication
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Pipe connections have the same address, replicas are distinguished by it
+type testConn struct {
+	net.Conn
+	addr *net.TCPAddr
+}
+
+func (c *testConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// Replica reads replication stream and acks the processed offset after the delay
+func addTestReplica(t *testing.T, mc *masterController, port int, ackDelay time.Duration) string {
+	conn, peerConn := net.Pipe()
+	t.Cleanup(func() { peerConn.Close() })
+
+	replicaConn := &testConn{Conn: conn, addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}}
+	addr := replicaConn.addr.String()
+	mc.AddReplicaConn(replicaConn, port)
+	mc.StartFullResync(replicaConn)
+	mc.replicas[addr].setState(REPLICA_STATE_ONLINE)
+
+	offset := mc.Info().MasterReplOffset
+	go func() {
+		b := make([]byte, 1024)
+		for {
+			n, err := peerConn.Read(b)
+			if err != nil {
+				return
+			}
+			offset += n
+			time.Sleep(ackDelay)
+			mc.SendAck(addr, offset)
+		}
+	}()
+	return addr
+}
+
+func TestWaitAcks(t *testing.T) {
+	mc := NewMasterController(&config.Args{ReplBacklogSize: 1024}).(*masterController)
+	fastAddr := addTestReplica(t, mc, 1, 0)
+	addTestReplica(t, mc, 2, 300*time.Millisecond)
+
+	// Client without writes doesn't wait for replicas
+	assert.Equal(t, 2, mc.WaitAcks(mc.GetClientWriteOffset("client"), 2, -1))
+
+	mc.Propagate([]string{"SET", "key", "value"})
+	mc.RecordClientWrite("client")
+	writeOffset := mc.GetClientWriteOffset("client")
+	assert.Equal(t, mc.Info().MasterReplOffset, writeOffset)
+
+	// Only the fast replica acks the write before timeout
+	start := time.Now()
+	assert.Equal(t, 1, mc.WaitAcks(writeOffset, 2, 100*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	// Zero timeout blocks until the slow replica acks too
+	assert.Equal(t, 2, mc.WaitAcks(writeOffset, 2, 0))
+
+	// Ack of the older offset doesn't count for the newer write
+	mc.Propagate([]string{"SET", "key", "other"})
+	mc.RecordClientWrite("client")
+	mc.SendAck(fastAddr, writeOffset)
+	assert.Equal(t, 0, mc.WaitAcks(mc.GetClientWriteOffset("client"), 2, -1))
+	assert.Equal(t, 1, mc.WaitAcks(mc.GetClientWriteOffset("client"), 1, time.Second))
+
+	// Replicas are listed with acked offsets and their listening ports
+	info := mc.Info()
+	assert.Len(t, info.Slaves, 2)
+	assert.Equal(t, SlaveInfo{IP: "127.0.0.1", Port: 1, State: REPLICA_STATE_ONLINE, Offset: mc.GetClientWriteOffset("client"), Lag: 0}, info.Slaves[0])
+	assert.Equal(t, 2, info.Slaves[1].Port)
+
+	mc.RemoveClient("client")
+	assert.Equal(t, 0, mc.GetClientWriteOffset("client"))
+}
+
+func TestCountGoodReplicas(t *testing.T) {
+	tests := []struct {
+		Name     string
+		State    string
+		AckAge   time.Duration
+		MaxLag   time.Duration
+		Expected int
+	}{
+		{Name: "Online with fresh ack", State: REPLICA_STATE_ONLINE, AckAge: 0, MaxLag: 10 * time.Second, Expected: 1},
+		{Name: "Online with ack at max lag", State: REPLICA_STATE_ONLINE, AckAge: 10 * time.Second, MaxLag: 10 * time.Second, Expected: 1},
+		{Name: "Online with old ack", State: REPLICA_STATE_ONLINE, AckAge: 11 * time.Second, MaxLag: 10 * time.Second, Expected: 0},
+		{Name: "Syncing", State: REPLICA_STATE_SYNC, AckAge: 0, MaxLag: 10 * time.Second, Expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mc := NewMasterController(&config.Args{ReplBacklogSize: 1024}).(*masterController)
+			conn, peerConn := net.Pipe()
+			defer peerConn.Close()
+
+			mc.AddReplicaConn(conn, 6380)
+			replica := mc.replicas[conn.RemoteAddr().String()]
+			replica.setState(test.State)
+			replica.ackTime = time.Now().Add(-test.AckAge)
+
+			assert.Equal(t, test.Expected, mc.CountGoodReplicas(test.MaxLag))
+		})
+	}
+}
+
+func TestPropagateBinarySafe(t *testing.T) {
+	blob := make([]byte, 2*1024*1024)
+	for i := range blob {
+		blob[i] = byte(i * 31)
+	}
+	commands := [][]string{
+		{"RPUSH", "k", "", "\x00", string(blob)},
+		{"ZADD", "z", "1", "", "2", "\x00\r\n\xff"},
+		{"XADD", "s", "1-1", "", "\x00", "f\r\n", ""},
+	}
+
+	mc := NewMasterController(&config.Args{ReplBacklogSize: 4 * 1024 * 1024}).(*masterController)
+	start := mc.Info().MasterReplOffset
+	for _, args := range commands {
+		mc.Propagate(args)
+	}
+
+	// Replica reads the stream by its parser, like from connection with master
+	stream, ok := mc.backlog.readFrom(start)
+	assert.True(t, ok)
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+	for _, args := range commands {
+		rest, value, err := parser.Decode(stream)
+		assert.NoError(t, err)
+		assert.Equal(t, resp.CreateBulkStringArray(args...), value)
+		stream = rest
+	}
+	assert.Empty(t, stream)
+}
@@ -0,0 +1,155 @@
+package replication
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
+)
+
+// Replication stream is written to the replica by the only goroutine, so commands are never reordered
+type connectedReplica struct {
+	conn          net.Conn
+	addr          string
+	listeningPort int
+	state         string
+	// The last offset of replication stream, that replica has processed, and the time of its ack
+	// They are changed under master replicationMut
+	ackOffset int
+	ackTime   time.Time
+	// Replication stream, that isn't written yet, it is written only when replica is online
+	output [][]byte
+	// Bytes in output and in the write in progress
+	outputBytes int
+	limit       config.OutputBufferLimit
+	// Zero, if output is under soft limit
+	softLimitReachedAt time.Time
+	closed             bool
+	mut                sync.Mutex
+	cond               *sync.Cond
+}
+
+func newConnectedReplica(conn net.Conn, listeningPort int, limit config.OutputBufferLimit) *connectedReplica {
+	replica := &connectedReplica{
+		conn:          conn,
+		addr:          utils.GetRemoteAddr(conn),
+		listeningPort: listeningPort,
+		state:         REPLICA_STATE_HANDSHAKE,
+		ackTime:       time.Now(),
+		limit:         limit,
+	}
+	replica.cond = sync.NewCond(&replica.mut)
+	return replica
+}
+
+// Caller holds master replicationMut
+func (r *connectedReplica) info(now time.Time) SlaveInfo {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	ip := r.addr
+	if host, _, err := net.SplitHostPort(r.addr); err == nil {
+		ip = host
+	}
+	return SlaveInfo{
+		IP:     ip,
+		Port:   r.listeningPort,
+		State:  r.state,
+		Offset: r.ackOffset,
+		Lag:    int(now.Sub(r.ackTime).Seconds()),
+	}
+}
+
+// Output buffered before full or partial resync is dropped, replica gets it from RDB file or backlog
+func (r *connectedReplica) setState(state string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if state == REPLICA_STATE_SYNC {
+		r.output = nil
+		r.outputBytes = 0
+		r.softLimitReachedAt = time.Time{}
+	}
+	r.state = state
+	r.cond.Broadcast()
+}
+
+// Returns false, if replica is disconnected, because output buffer limit is exceeded
+func (r *connectedReplica) enqueue(command []byte, now time.Time) bool {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.closed || (r.state != REPLICA_STATE_SYNC && r.state != REPLICA_STATE_ONLINE) {
+		return true
+	}
+
+	r.output = append(r.output, command)
+	r.outputBytes += len(command)
+	if r.isOutputLimitExceeded(now) {
+		r.closeLocked()
+		return false
+	}
+	r.cond.Broadcast()
+	return true
+}
+
+func (r *connectedReplica) isOutputLimitExceeded(now time.Time) bool {
+	if r.limit.HardBytes > 0 && r.outputBytes > r.limit.HardBytes {
+		return true
+	}
+	if r.limit.SoftBytes == 0 || r.outputBytes <= r.limit.SoftBytes {
+		r.softLimitReachedAt = time.Time{}
+		return false
+	}
+	if r.softLimitReachedAt.IsZero() {
+		r.softLimitReachedAt = now
+	}
+	return now.Sub(r.softLimitReachedAt) >= time.Duration(r.limit.SoftSeconds)*time.Second
+}
+
+func (r *connectedReplica) writeOutput() {
+	for {
+		r.mut.Lock()
+		for !r.closed && (r.state != REPLICA_STATE_ONLINE || len(r.output) == 0) {
+			r.cond.Wait()
+		}
+		if r.closed {
+			r.mut.Unlock()
+			return
+		}
+		output := net.Buffers(r.output)
+		r.output = nil
+		r.mut.Unlock()
+
+		n, err := output.WriteTo(r.conn)
+
+		r.mut.Lock()
+		r.outputBytes = max(r.outputBytes-int(n), 0)
+		r.mut.Unlock()
+
+		if err != nil {
+			log.Printf("Desynchronization with %s, replication stream write error: %v", r.addr, err)
+			r.close()
+			return
+		}
+	}
+}
+
+func (r *connectedReplica) close() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.closeLocked()
+}
+
+// Connection is closed, so replica is removed from replicas, when its client handler stops
+func (r *connectedReplica) closeLocked() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.conn.Close()
+	r.cond.Broadcast()
+}
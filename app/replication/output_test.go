@@ -0,0 +1,84 @@
+package replication
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestReplicaOutputLimit(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		Name string
+		// Commands are enqueued with the elapsed time since start
+		Elapsed        []time.Duration
+		Limit          config.OutputBufferLimit
+		ExpectedClosed bool
+	}{
+		{Name: "No limit", Elapsed: []time.Duration{0, 0, 0}, Limit: config.OutputBufferLimit{}, ExpectedClosed: false},
+		{Name: "Under hard limit", Elapsed: []time.Duration{0, 0}, Limit: config.OutputBufferLimit{HardBytes: 20}, ExpectedClosed: false},
+		{Name: "Hard limit exceeded", Elapsed: []time.Duration{0, 0, 0}, Limit: config.OutputBufferLimit{HardBytes: 20}, ExpectedClosed: true},
+		{Name: "Soft limit exceeded for short time", Elapsed: []time.Duration{0, 0, time.Second}, Limit: config.OutputBufferLimit{SoftBytes: 10, SoftSeconds: 2}, ExpectedClosed: false},
+		{Name: "Soft limit exceeded for long time", Elapsed: []time.Duration{0, 0, 2 * time.Second}, Limit: config.OutputBufferLimit{SoftBytes: 10, SoftSeconds: 2}, ExpectedClosed: true},
+		{Name: "Soft limit with zero seconds", Elapsed: []time.Duration{0, 0}, Limit: config.OutputBufferLimit{SoftBytes: 10}, ExpectedClosed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			conn, peerConn := net.Pipe()
+			defer peerConn.Close()
+
+			// Replica in sync state doesn't write output, so it only grows
+			replica := newConnectedReplica(conn, 6380, test.Limit)
+			replica.setState(REPLICA_STATE_SYNC)
+
+			ok := true
+			for _, elapsed := range test.Elapsed {
+				ok = replica.enqueue([]byte("0123456789"), start.Add(elapsed)) && ok
+			}
+			assert.Equal(t, test.ExpectedClosed, !ok)
+			assert.Equal(t, test.ExpectedClosed, replica.closed)
+		})
+	}
+}
+
+func TestReplicaOutputOrder(t *testing.T) {
+	mc := NewMasterController(&config.Args{ReplBacklogSize: 1024}).(*masterController)
+	conn, peerConn := net.Pipe()
+	defer peerConn.Close()
+
+	mc.AddReplicaConn(conn, 6380)
+	mc.StartFullResync(conn)
+
+	var expected bytes.Buffer
+	for i := range 100 {
+		args := []string{"SET", "key", strconv.Itoa(i)}
+		command, err := resp.CreateBulkStringArray(args...).Encode()
+		assert.NoError(t, err)
+		expected.Write(command)
+
+		mc.Propagate(args)
+		// Commands propagated during sync are buffered and written before the later ones
+		if i == 50 {
+			mc.replicas[conn.RemoteAddr().String()].setState(REPLICA_STATE_ONLINE)
+		}
+	}
+
+	got := make([]byte, expected.Len())
+	_, err := io.ReadFull(peerConn, got)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), string(got))
+	assert.Equal(t, expected.Len(), mc.Info().MasterReplOffset)
+
+	mc.RemoveReplicaConn(conn.RemoteAddr().String())
+	assert.Empty(t, mc.GetReplicas())
+}
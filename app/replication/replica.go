@@ -1,39 +1,154 @@
 package replication
 
 import (
+	"bytes"
 	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
 )
 
 type ReplicaController interface {
 	BaseController
 	GetMasterConn() net.Conn
 	SetMasterConn(conn net.Conn)
+	LinkState() string
+	// Link is down since replica leaves connected state, until it gets there again
+	SetLinkState(state string)
+	// Processed bytes of replication stream from master move replication offset and are forwarded to sub-replicas as is
+	Forward(b []byte)
+	// Full resync starts new replication history, so backlog is emptied and sub-replicas have to resync too
+	ResetReplicationStream(replID string, replOffset int)
+	// Master can continue partial resync with new replication ID (e.g. after failover)
+	// Sub-replicas are disconnected to get it, they continue partial resync with the previous one
+	SetMasterReplID(replID string)
 }
 
+const (
+	// Replica dials master
+	MASTER_LINK_STATE_CONNECT = "connect"
+	// PING, REPLCONF and PSYNC are exchanged
+	MASTER_LINK_STATE_HANDSHAKE = "handshake"
+	// RDB file is received and loaded
+	MASTER_LINK_STATE_SYNC = "sync"
+	// Replica gets write commands from master
+	MASTER_LINK_STATE_CONNECTED = "connected"
+)
+
 type replicaController struct {
 	*baseController
+	masterHost string
+	masterPort int
 	masterConn net.Conn
+	// The last time, when command from master was processed
+	lastIO    time.Time
+	linkState string
+	// Zero, if link is up or replica has never been connected to master
+	linkDownSince time.Time
+	mut           sync.Mutex
 }
 
-func NewReplicaController() ReplicaController {
-	replicaInfo := initReplicaInfo()
+// Previous controller is nil on start, otherwise its replication ID and offset are used to ask new master for partial resync
+func NewReplicaController(args *config.Args, masterHost string, masterPort int, previous BaseController) ReplicaController {
 	return &replicaController{
-		baseController: newBaseController(replicaInfo),
+		baseController: inheritBaseController(args, initReplicaInfo(), previous),
+		masterHost:     masterHost,
+		masterPort:     masterPort,
+		linkState:      MASTER_LINK_STATE_CONNECT,
+	}
+}
+
+// Returns a copy, link status is filled on every call
+func (rc *replicaController) Info() *Info {
+	info := rc.baseController.Info()
+
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+
+	info.MasterHost = rc.masterHost
+	info.MasterPort = rc.masterPort
+	info.MasterLinkStatus = MASTER_LINK_STATUS_DOWN
+	if rc.linkState == MASTER_LINK_STATE_CONNECTED {
+		info.MasterLinkStatus = MASTER_LINK_STATUS_UP
+		info.MasterLastIO = rc.lastIO
+	}
+	info.MasterSyncInProgress = rc.linkState == MASTER_LINK_STATE_SYNC
+	info.MasterLinkDownSince = rc.linkDownSince
+	return info
+}
+
+// Forwarded bytes are the last IO with master
+// Bytes are copied, because they are buffered for sub-replicas
+func (rc *replicaController) Forward(b []byte) {
+	rc.mut.Lock()
+	rc.lastIO = time.Now()
+	rc.mut.Unlock()
+
+	rc.feed(bytes.Clone(b))
+}
+
+func (rc *replicaController) ResetReplicationStream(replID string, replOffset int) {
+	rc.replicationMut.Lock()
+	defer rc.replicationMut.Unlock()
+
+	rc.info.MasterReplID = replID
+	rc.info.MasterReplOffset = replOffset
+	rc.info.MasterReplID2 = NO_REPLICATION_ID
+	rc.info.SecondReplOffset = -1
+	rc.backlog.reset(replOffset)
+	rc.disconnectReplicas()
+}
+
+func (rc *replicaController) SetMasterReplID(replID string) {
+	rc.replicationMut.Lock()
+	defer rc.replicationMut.Unlock()
+
+	if replID == rc.info.MasterReplID {
+		return
 	}
+	rc.shiftReplID(replID)
+	rc.disconnectReplicas()
 }
 
 func (rc *replicaController) GetMasterConn() net.Conn {
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
 	return rc.masterConn
 }
 
 func (rc *replicaController) SetMasterConn(conn net.Conn) {
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
 	rc.masterConn = conn
 }
 
+func (rc *replicaController) LinkState() string {
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+	return rc.linkState
+}
+
+func (rc *replicaController) SetLinkState(state string) {
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+
+	switch {
+	case state == MASTER_LINK_STATE_CONNECTED:
+		rc.linkDownSince = time.Time{}
+		rc.lastIO = time.Now()
+	case rc.linkState == MASTER_LINK_STATE_CONNECTED:
+		rc.linkDownSince = time.Now()
+	}
+	rc.linkState = state
+}
+
 func initReplicaInfo() *Info {
 	return &Info{
 		Role:             "slave",
 		MasterReplID:     "?",
+		MasterReplID2:    NO_REPLICATION_ID,
 		MasterReplOffset: -1,
+		SecondReplOffset: -1,
 	}
 }
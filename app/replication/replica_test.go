@@ -0,0 +1,98 @@
+package replication
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+)
+
+func TestReplicaForward(t *testing.T) {
+	rc := NewReplicaController(&config.Args{ReplBacklogSize: 1024}, "127.0.0.1", 6379, nil).(*replicaController)
+	rc.ResetReplicationStream("id", 100)
+
+	conn, peerConn := net.Pipe()
+	defer peerConn.Close()
+	rc.AddReplicaConn(conn, 6380)
+	rc.StartFullResync(conn)
+	rc.replicas[conn.RemoteAddr().String()].setState(REPLICA_STATE_ONLINE)
+
+	// Bytes are forwarded as is, even if they aren't canonical RESP
+	stream := "*1\r\n$4\r\nPING\r\n*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"
+	rc.Forward([]byte(stream))
+
+	got := make([]byte, len(stream))
+	_, err := io.ReadFull(peerConn, got)
+	assert.NoError(t, err)
+	assert.Equal(t, stream, string(got))
+	assert.Equal(t, 100+len(stream), rc.Info().MasterReplOffset)
+
+	// Sub-replica continues with the offsets of the upstream master
+	missed, ok := rc.backlog.readFrom(100)
+	assert.True(t, ok)
+	assert.Equal(t, stream, string(missed))
+
+	// Full resync starts new history, so sub-replicas are disconnected
+	rc.ResetReplicationStream("other", 0)
+	_, err = peerConn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	_, ok = rc.backlog.readFrom(100)
+	assert.False(t, ok)
+}
+
+func TestPromotedMasterContinueResync(t *testing.T) {
+	args := &config.Args{ReplBacklogSize: 1024}
+	rc := NewReplicaController(args, "127.0.0.1", 6379, nil)
+	rc.ResetReplicationStream("old", 0)
+	rc.Forward([]byte("0123456789"))
+
+	mc := NewPromotedMasterController(args, rc)
+	info := mc.Info()
+	assert.NotEqual(t, "old", info.MasterReplID)
+	assert.Equal(t, "old", info.MasterReplID2)
+	assert.Equal(t, 10, info.MasterReplOffset)
+	assert.Equal(t, 11, info.SecondReplOffset)
+	mc.Propagate([]string{"PING"})
+
+	tests := []struct {
+		Name             string
+		ReplID           string
+		PsyncOffset      int
+		ExpectedContinue bool
+		ExpectedReply    string
+	}{
+		{Name: "Previous ID before promotion", ReplID: "old", PsyncOffset: 6, ExpectedContinue: true, ExpectedReply: "+CONTINUE " + info.MasterReplID + "\r\n" + "56789*1\r\n$4\r\nPING\r\n"},
+		{Name: "Previous ID at promotion", ReplID: "old", PsyncOffset: 11, ExpectedContinue: true, ExpectedReply: "+CONTINUE " + info.MasterReplID + "\r\n" + "*1\r\n$4\r\nPING\r\n"},
+		{Name: "Previous ID after promotion", ReplID: "old", PsyncOffset: 12, ExpectedContinue: false},
+		{Name: "Current ID", ReplID: info.MasterReplID, PsyncOffset: 11, ExpectedContinue: true, ExpectedReply: "+CONTINUE " + info.MasterReplID + "\r\n" + "*1\r\n$4\r\nPING\r\n"},
+		{Name: "Unknown ID", ReplID: "unknown", PsyncOffset: 11, ExpectedContinue: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			conn, peerConn := net.Pipe()
+			defer peerConn.Close()
+			mc.AddReplicaConn(conn, 6380)
+
+			replies := make(chan string, 1)
+			if test.ExpectedContinue {
+				go func() {
+					b := make([]byte, len(test.ExpectedReply))
+					io.ReadFull(peerConn, b)
+					replies <- string(b)
+				}()
+			}
+
+			continued, err := mc.ContinueResync(conn, test.ReplID, test.PsyncOffset)
+			assert.NoError(t, err)
+			assert.Equal(t, test.ExpectedContinue, continued)
+			if test.ExpectedContinue {
+				assert.Equal(t, test.ExpectedReply, <-replies)
+			}
+			mc.RemoveReplicaConn(conn.RemoteAddr().String())
+		})
+	}
+}
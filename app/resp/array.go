@@ -36,60 +36,22 @@ func (Array) Decode(b []byte) ([]byte, Value, error) {
 		return nil, nil, fmt.Errorf("array decode error: expected non-empty data")
 	}
 
-	if string(b) == NULL_ARRAY_RESP_2 {
-		return b[len(NULL_ARRAY_RESP_2):], Array{Value: nil}, nil
-	}
-
 	if b[0] != '*' {
 		return nil, nil, fmt.Errorf("array decode error: didn't find '*' sign")
 	}
 
-	resLen, b, err := traverseExpectedLen(b[1:])
+	b, res, err := decodeAggregate(b, 1)
 	if err != nil {
 		return nil, nil, fmt.Errorf("array decode error: %v", err)
 	}
-
-	b, err = traverseCRLF(b)
-	if err != nil {
-		return nil, nil, fmt.Errorf("Array traverse CRLF error: %v", err)
-	}
-
-	res := make([]Value, 0, resLen)
-	var curVal Value
-
-	for range resLen {
-		switch b[0] {
-		case '*':
-			b, curVal, err = Array{}.Decode(b)
-		case '$':
-			b, curVal, err = BulkString{}.Decode(b)
-		case ':':
-			b, curVal, err = Integer{}.Decode(b)
-		case '+':
-			b, curVal, err = SimpleString{}.Decode(b)
-		case '-':
-			b, curVal, err = SimpleError{}.Decode(b)
-		default:
-			return nil, nil, fmt.Errorf("Array decode error: detected unknown RESP type")
-		}
-
-		if err != nil {
-			return nil, nil, fmt.Errorf("Array decode error: %v", err)
-		}
-		res = append(res, curVal)
-	}
-
 	return b, Array{Value: res}, nil
 }
 
+// Empty arg is empty bulk string, not null one, so command with empty value is propagated and appended to AOF as is
 func CreateBulkStringArray(args ...string) Array {
-	values := make([]Value, 0)
+	values := make([]Value, 0, len(args))
 	for _, arg := range args {
-		if arg == "" {
-			values = append(values, BulkString{Value: nil})
-		} else {
-			values = append(values, BulkString{Value: strPtr(arg)})
-		}
+		values = append(values, BulkString{Value: strPtr(arg)})
 	}
 	return Array{Value: values}
 }
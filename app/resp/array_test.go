@@ -52,7 +52,7 @@ func TestArrayEncode(t *testing.T) {
 		{
 			Name:        "Array with empty bulk string",
 			In:          CreateBulkStringArray(""),
-			Expected:    []byte("*1\r\n" + NULL_BULK_STRING_RESP_2),
+			Expected:    []byte("*1\r\n$0\r\n\r\n"),
 			ShouldError: false,
 		},
 		{
@@ -120,6 +120,13 @@ func TestArrayDecode(t *testing.T) {
 			Expected:     Array{Value: []Value{Integer{Value: 1}, Integer{Value: -2}, Integer{Value: 0}}},
 			ShouldError:  false,
 		},
+		{
+			Name:         "Array with missing elements",
+			In:           []byte("*2\r\n$3\r\nGET\r\n"),
+			ExpectedRest: nil,
+			Expected:     nil,
+			ShouldError:  true,
+		},
 		{
 			Name:         "Array with simple strings and errors",
 			In:           []byte("*2\r\n+OK\r\n-ERR invalid\r\n"),
@@ -0,0 +1,39 @@
+package resp
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Integer, that can be out of 64 bit range, it is kept as decimal string
+type BigNumber struct {
+	Value string
+}
+
+func (bn BigNumber) Encode() ([]byte, error) {
+	if _, ok := new(big.Int).SetString(bn.Value, 10); !ok {
+		return nil, fmt.Errorf("big number encode error: %q isn't a decimal integer", bn.Value)
+	}
+	return fmt.Appendf(nil, "(%s\r\n", bn.Value), nil
+}
+
+func (BigNumber) Decode(b []byte) ([]byte, Value, error) {
+	l := len(b)
+	if l == 0 || b == nil {
+		return nil, nil, fmt.Errorf("big number decode error: expected non-empty data")
+	}
+
+	if b[0] != '(' {
+		return nil, nil, fmt.Errorf("big number decode error: didn't find '(' sign")
+	}
+
+	b, payload, err := traversePayloadTillFirstCRLF(b, l)
+	if err != nil {
+		return nil, nil, fmt.Errorf("big number decode error: %v", err)
+	}
+	if _, ok := new(big.Int).SetString(payload, 10); !ok {
+		return nil, nil, fmt.Errorf("big number decode error: %q isn't a decimal integer", payload)
+	}
+
+	return b, BigNumber{Value: payload}, nil
+}
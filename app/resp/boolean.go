@@ -0,0 +1,39 @@
+package resp
+
+import "fmt"
+
+type Boolean struct {
+	Value bool
+}
+
+func (bl Boolean) Encode() ([]byte, error) {
+	if bl.Value {
+		return []byte("#t\r\n"), nil
+	}
+	return []byte("#f\r\n"), nil
+}
+
+func (Boolean) Decode(b []byte) ([]byte, Value, error) {
+	l := len(b)
+	if l == 0 || b == nil {
+		return nil, nil, fmt.Errorf("boolean decode error: expected non-empty data")
+	}
+
+	if b[0] != '#' {
+		return nil, nil, fmt.Errorf("boolean decode error: didn't find '#' sign")
+	}
+
+	b, payload, err := traversePayloadTillFirstCRLF(b, l)
+	if err != nil {
+		return nil, nil, fmt.Errorf("boolean decode error: %v", err)
+	}
+
+	switch payload {
+	case "t":
+		return b, Boolean{Value: true}, nil
+	case "f":
+		return b, Boolean{Value: false}, nil
+	default:
+		return nil, nil, fmt.Errorf("boolean decode error: expected 't' or 'f', got: %q", payload)
+	}
+}
@@ -23,10 +23,6 @@ func (BulkString) Decode(b []byte) ([]byte, Value, error) {
 		return nil, nil, fmt.Errorf("bulk string decode error: expected non-empty data")
 	}
 
-	if string(b) == NULL_BULK_STRING_RESP_2 {
-		return b[len(NULL_BULK_STRING_RESP_2):], BulkString{Value: nil}, nil
-	}
-
 	if b[0] != '$' {
 		return nil, nil, fmt.Errorf("bulk string decode error: didn't find '$' sign")
 	}
@@ -40,6 +36,12 @@ func (BulkString) Decode(b []byte) ([]byte, Value, error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("bulk string traverse CRLF error: %v", err)
 	}
+	if expectedLen == -1 {
+		return b, BulkString{Value: nil}, nil
+	}
+	if expectedLen < -1 {
+		return nil, nil, fmt.Errorf("bulk string decode error: negative len: %d", expectedLen)
+	}
 
 	if len(b) < expectedLen+2 {
 		return nil, nil, fmt.Errorf("bulk string decode error: not enough bytes for content (%d < %d)", len(b), expectedLen+2)
@@ -0,0 +1,64 @@
+package resp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type Double struct {
+	Value float64
+}
+
+func (d Double) Encode() ([]byte, error) {
+	return fmt.Appendf(nil, ",%s\r\n", formatDouble(d.Value)), nil
+}
+
+func (Double) Decode(b []byte) ([]byte, Value, error) {
+	l := len(b)
+	if l == 0 || b == nil {
+		return nil, nil, fmt.Errorf("double decode error: expected non-empty data")
+	}
+
+	if b[0] != ',' {
+		return nil, nil, fmt.Errorf("double decode error: didn't find ',' sign")
+	}
+
+	b, payload, err := traversePayloadTillFirstCRLF(b, l)
+	if err != nil {
+		return nil, nil, fmt.Errorf("double decode error: %v", err)
+	}
+
+	value, err := parseDouble(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("double decode error: %v", err)
+	}
+	return b, Double{Value: value}, nil
+}
+
+// Infinity and NaN are written, like in RESP3 specification: inf, -inf and nan
+func formatDouble(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func parseDouble(s string) (float64, error) {
+	switch s {
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan":
+		return math.NaN(), nil
+	default:
+		return strconv.ParseFloat(s, 64)
+	}
+}
@@ -0,0 +1,110 @@
+package resp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoubleEncode(t *testing.T) {
+	tests := []struct {
+		Name     string
+		In       Value
+		Expected []byte
+	}{
+		{
+			Name:     "Fractional",
+			In:       Double{Value: 1.5},
+			Expected: []byte(",1.5\r\n"),
+		},
+		{
+			Name:     "Negative integral",
+			In:       Double{Value: -10},
+			Expected: []byte(",-10\r\n"),
+		},
+		{
+			Name:     "Positive infinity",
+			In:       Double{Value: math.Inf(1)},
+			Expected: []byte(",inf\r\n"),
+		},
+		{
+			Name:     "Negative infinity",
+			In:       Double{Value: math.Inf(-1)},
+			Expected: []byte(",-inf\r\n"),
+		},
+		{
+			Name:     "NaN",
+			In:       Double{Value: math.NaN()},
+			Expected: []byte(",nan\r\n"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			out, err := test.In.Encode()
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, out)
+		})
+	}
+}
+
+func TestDoubleDecode(t *testing.T) {
+	tests := []struct {
+		Name        string
+		In          []byte
+		Expected    Value
+		ShouldError bool
+	}{
+		{
+			Name:        "Fractional",
+			In:          []byte(",3.25\r\n"),
+			Expected:    Double{Value: 3.25},
+			ShouldError: false,
+		},
+		{
+			Name:        "Exponent",
+			In:          []byte(",1.5e+21\r\n"),
+			Expected:    Double{Value: 1.5e21},
+			ShouldError: false,
+		},
+		{
+			Name:        "Negative infinity",
+			In:          []byte(",-inf\r\n"),
+			Expected:    Double{Value: math.Inf(-1)},
+			ShouldError: false,
+		},
+		{
+			Name:        "Invalid prefix",
+			In:          []byte(":1\r\n"),
+			Expected:    nil,
+			ShouldError: true,
+		},
+		{
+			Name:        "Not a number",
+			In:          []byte(",abc\r\n"),
+			Expected:    nil,
+			ShouldError: true,
+		},
+		{
+			Name:        "Missing CRLF",
+			In:          []byte(",1.5"),
+			Expected:    nil,
+			ShouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, out, err := Double{}.Decode(test.In)
+
+			if test.ShouldError {
+				assert.NotNil(t, err)
+				assert.Equal(t, test.Expected, out)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.Expected, out)
+			}
+		})
+	}
+}
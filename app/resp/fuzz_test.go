@@ -0,0 +1,98 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var fuzzSeeds = []string{
+	"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n",
+	"*3\r\n$3\r\nSET\r\n$0\r\n\r\n$-1\r\n",
+	"*-1\r\n",
+	"+OK\r\n-ERR wrong\r\n:-10\r\n",
+	"%1\r\n+key\r\n~2\r\n#t\r\n_\r\n",
+	">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n,1.5e+10\r\n",
+	"=8\r\ntxt:info\r\n(123456789012345678901234567890\r\n",
+	"SET \"hello world\" 'it\\'s' \"\\x00\"\r\n",
+	"\r\nPING\n",
+	"$4\r\nabc\r\n",
+	"*1\r\n$99999999999\r\n",
+}
+
+// Controller doesn't panic, decoded value is encoded and decoded to the same value
+func FuzzControllerDecode(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	c := NewController(DEFAULT_PROTO_MAX_BULK_LEN)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rest, value, err := c.Decode(b)
+		if err != nil {
+			var protocolErr *ProtocolError
+			if !errors.Is(err, ErrIncomplete) && !errors.As(err, &protocolErr) {
+				t.Fatalf("error is neither incomplete nor protocol one: %v", err)
+			}
+			return
+		}
+		if !bytes.HasSuffix(b, rest) {
+			t.Fatalf("rest %q isn't suffix of input %q", rest, b)
+		}
+
+		encoded, err := value.Encode()
+		if err != nil {
+			t.Fatalf("encode decoded value %#v error: %v", value, err)
+		}
+		_, decoded, err := c.Decode(encoded)
+		if err != nil {
+			t.Fatalf("decode encoded value %q error: %v", encoded, err)
+		}
+		reencoded, err := decoded.Encode()
+		if err != nil || !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("value %q is encoded again as %q, error: %v", encoded, reencoded, err)
+		}
+	})
+}
+
+// Every prefix of decoded value is incomplete, so value is never decoded from a part of it
+func FuzzControllerDecodeIncremental(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	c := NewController(DEFAULT_PROTO_MAX_BULK_LEN)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rest, value, err := c.Decode(b)
+		if err != nil {
+			return
+		}
+		// Prefixes are read by one connection, so its parser resumes scanning of them
+		p := c.NewParser()
+		for end := range len(b) - len(rest) {
+			_, _, err := p.Decode(b[:end])
+			if !errors.Is(err, ErrIncomplete) {
+				t.Fatalf("prefix %q of %q isn't incomplete, error: %v", b[:end], b, err)
+			}
+		}
+		resumedRest, resumedValue, err := p.Decode(b)
+		if err != nil || !bytes.Equal(resumedRest, rest) || !reflect.DeepEqual(resumedValue, value) {
+			t.Fatalf("resumed decode of %q differs, value: %v, rest: %q, error: %v", b, resumedValue, resumedRest, err)
+		}
+	})
+}
+
+// Types are decoded directly too, e.g. by tests, so they don't panic on any data
+func FuzzValueDecode(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	values := []Value{Array{}, BulkString{}, Integer{}, SimpleString{}, SimpleError{}, Null{}, Boolean{}, Double{}, BigNumber{}, VerbatimString{}, Map{}, Set{}, Push{}}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, value := range values {
+			value.Decode(b)
+		}
+	})
+}
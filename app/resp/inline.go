@@ -0,0 +1,147 @@
+package resp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Client without newline in inline request can't grow its buffer forever, like in Redis
+const PROTO_INLINE_MAX_SIZE = 64 * 1024
+
+// Signs of RESP types, data with other first byte is inline command
+const RESP_TYPE_SIGNS = "*$:+-_#,(=%~>"
+
+// Inline command is a line, that is terminated by CRLF or LF, e.g. typed in telnet: SET key "hello world"
+// It is decoded to array of bulk strings, so it is handled like RESP command
+// Empty line is skipped, it returns neither value nor error and value starts after it
+func (p *parser) decodeInline(b []byte) ([]byte, Value, error) {
+	from := p.start + p.lineScanned
+	i := indexByte(b[from:], '\n')
+	if i == -1 {
+		p.lineScanned = len(b) - p.start
+		if p.lineScanned > PROTO_INLINE_MAX_SIZE {
+			return nil, nil, &ProtocolError{Reason: "too big inline request"}
+		}
+		return nil, nil, fmt.Errorf("%w: didn't find '\\n' in the end of inline command", ErrIncomplete)
+	}
+	lineEnd := from + i
+	if lineEnd-p.start > PROTO_INLINE_MAX_SIZE {
+		return nil, nil, &ProtocolError{Reason: "too big inline request"}
+	}
+
+	line := strings.TrimSuffix(string(b[p.start:lineEnd]), "\r")
+	args, err := splitInlineArgs(line)
+	if err != nil {
+		return nil, nil, &ProtocolError{Reason: err.Error()}
+	}
+	if len(args) == 0 {
+		p.start, p.pos, p.lineScanned = lineEnd+1, lineEnd+1, 0
+		return nil, nil, nil
+	}
+
+	values := make([]Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, BulkString{Value: strPtr(arg)})
+	}
+	return b[lineEnd+1:], Array{Value: values}, nil
+}
+
+// Splits line on whitespaces, like sdssplitargs in Redis
+// Double quoted arg supports escapes, e.g. "\x00\n", single quoted one supports only \', closing quote must end the arg
+func splitInlineArgs(line string) ([]string, error) {
+	args := make([]string, 0)
+	i := 0
+	for {
+		for i < len(line) && isInlineSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg strings.Builder
+		inDoubleQuotes, inSingleQuotes := false, false
+		for done := false; !done; {
+			if i == len(line) {
+				if inDoubleQuotes || inSingleQuotes {
+					return nil, fmt.Errorf("unbalanced quotes in request")
+				}
+				break
+			}
+
+			ch := line[i]
+			switch {
+			case inDoubleQuotes:
+				if ch == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHexDigit(line[i+2]) && isHexDigit(line[i+3]) {
+					hex, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					arg.WriteByte(byte(hex))
+					i += 3
+				} else if ch == '\\' && i+1 < len(line) {
+					i++
+					arg.WriteByte(unescapeInlineChar(line[i]))
+				} else if ch == '"' {
+					// Closing quote must be followed by a space or nothing
+					if i+1 < len(line) && !isInlineSpace(line[i+1]) {
+						return nil, fmt.Errorf("unbalanced quotes in request")
+					}
+					done = true
+				} else {
+					arg.WriteByte(ch)
+				}
+			case inSingleQuotes:
+				if ch == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					arg.WriteByte('\'')
+				} else if ch == '\'' {
+					if i+1 < len(line) && !isInlineSpace(line[i+1]) {
+						return nil, fmt.Errorf("unbalanced quotes in request")
+					}
+					done = true
+				} else {
+					arg.WriteByte(ch)
+				}
+			default:
+				switch {
+				case isInlineSpace(ch):
+					done = true
+				case ch == '"':
+					inDoubleQuotes = true
+				case ch == '\'':
+					inSingleQuotes = true
+				default:
+					arg.WriteByte(ch)
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, arg.String())
+	}
+}
+
+func unescapeInlineChar(ch byte) byte {
+	switch ch {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	default:
+		return ch
+	}
+}
+
+func isInlineSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' || ch == '\v' || ch == '\f'
+}
+
+func isHexDigit(ch byte) bool {
+	return ('0' <= ch && ch <= '9') || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
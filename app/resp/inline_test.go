@@ -0,0 +1,91 @@
+package resp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineDecode(t *testing.T) {
+	tests := []struct {
+		Name            string
+		In              []byte
+		Expected        Value
+		ExpectedRest    []byte
+		ShouldError     bool
+		IsProtocolError bool
+	}{
+		{
+			Name:         "Command with CRLF",
+			In:           []byte("SET foo bar\r\n"),
+			Expected:     CreateBulkStringArray("SET", "foo", "bar"),
+			ExpectedRest: []byte{},
+		},
+		{
+			Name:         "Command with LF and extra spaces",
+			In:           []byte("  GET \t foo \nPING\n"),
+			Expected:     CreateBulkStringArray("GET", "foo"),
+			ExpectedRest: []byte("PING\n"),
+		},
+		{
+			Name:         "Quoted args",
+			In:           []byte("SET \"hello world\" 'it\\'s' \"a\\x41\\n\"\r\n"),
+			Expected:     CreateBulkStringArray("SET", "hello world", "it's", "aA\n"),
+			ExpectedRest: []byte{},
+		},
+		{
+			Name:         "Empty quoted arg",
+			In:           []byte("SET key \"\"\r\n"),
+			Expected:     Array{Value: []Value{BulkString{Value: strPtr("SET")}, BulkString{Value: strPtr("key")}, BulkString{Value: strPtr("")}}},
+			ExpectedRest: []byte{},
+		},
+		{
+			Name:         "Empty lines before RESP command",
+			In:           []byte("\r\n\n*1\r\n$4\r\nPING\r\n"),
+			Expected:     CreateBulkStringArray("PING"),
+			ExpectedRest: []byte{},
+		},
+		{
+			Name:        "Incomplete line",
+			In:          []byte("SET foo"),
+			ShouldError: true,
+		},
+		{
+			Name:            "Unbalanced quotes",
+			In:              []byte("SET \"foo bar\r\n"),
+			ShouldError:     true,
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Closing quote without space",
+			In:              []byte("SET 'foo'bar\r\n"),
+			ShouldError:     true,
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Too big request",
+			In:              []byte(strings.Repeat("a", PROTO_INLINE_MAX_SIZE+1)),
+			ShouldError:     true,
+			IsProtocolError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			rest, out, err := NewController(DEFAULT_PROTO_MAX_BULK_LEN).Decode(test.In)
+
+			if test.ShouldError {
+				assert.NotNil(t, err)
+				assert.Nil(t, out)
+				var protocolErr *ProtocolError
+				assert.Equal(t, test.IsProtocolError, errors.As(err, &protocolErr))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.Expected, out)
+				assert.Equal(t, test.ExpectedRest, rest)
+			}
+		})
+	}
+}
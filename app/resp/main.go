@@ -10,21 +10,31 @@ type Value interface {
 }
 
 type Controller interface {
+	// Decodes RESP value or inline command, error is ErrIncomplete, if more bytes can complete data, otherwise it is *ProtocolError
+	// Incomplete value is scanned from its start again, so connection, that reads values by chunks, uses its Parser
 	Decode(b []byte) (rest []byte, value Value, err error)
+	NewParser() Parser
 }
 
-type controller struct{}
+type controller struct {
+	// Bulk string can't be longer, in bytes
+	protoMaxBulkLen int
+}
 
-func NewController() Controller {
-	return &controller{}
+func NewController(protoMaxBulkLen int) Controller {
+	return &controller{protoMaxBulkLen: protoMaxBulkLen}
 }
 
-func (*controller) Decode(b []byte) (rest []byte, value Value, err error) {
-	l := len(b)
-	if l == 0 || b == nil {
-		return nil, nil, fmt.Errorf("expected non-empty data")
-	}
+func (c *controller) Decode(b []byte) (rest []byte, value Value, err error) {
+	return c.NewParser().Decode(b)
+}
+
+func (c *controller) NewParser() Parser {
+	return &parser{protoMaxBulkLen: c.protoMaxBulkLen}
+}
 
+// RESP2 and RESP3 types are decoded, RESP3 ones are sent only to client, that sends HELLO 3
+func decode(b []byte) ([]byte, Value, error) {
 	switch b[0] {
 	case '*':
 		return Array{}.Decode(b)
@@ -36,6 +46,22 @@ func (*controller) Decode(b []byte) (rest []byte, value Value, err error) {
 		return SimpleString{}.Decode(b)
 	case '-':
 		return SimpleError{}.Decode(b)
+	case '_':
+		return Null{}.Decode(b)
+	case '#':
+		return Boolean{}.Decode(b)
+	case ',':
+		return Double{}.Decode(b)
+	case '(':
+		return BigNumber{}.Decode(b)
+	case '=':
+		return VerbatimString{}.Decode(b)
+	case '%':
+		return Map{}.Decode(b)
+	case '~':
+		return Set{}.Decode(b)
+	case '>':
+		return Push{}.Decode(b)
 	default:
 		return nil, nil, fmt.Errorf("detected unknown RESP type: '%c'", b[0])
 	}
@@ -0,0 +1,67 @@
+package resp
+
+import (
+	"fmt"
+)
+
+// Entries keep their order, e.g. CONFIG GET returns parameters in requested order
+type Map struct {
+	Value []MapEntry
+}
+
+type MapEntry struct {
+	Key   Value
+	Value Value
+}
+
+func (m Map) Encode() ([]byte, error) {
+	b, err := encodeAggregate('%', len(m.Value), m.flatten())
+	if err != nil {
+		return nil, fmt.Errorf("map encode error: %v", err)
+	}
+	return b, nil
+}
+
+func (Map) Decode(b []byte) ([]byte, Value, error) {
+	l := len(b)
+	if l == 0 || b == nil {
+		return nil, nil, fmt.Errorf("map decode error: expected non-empty data")
+	}
+
+	if b[0] != '%' {
+		return nil, nil, fmt.Errorf("map decode error: didn't find '%%' sign")
+	}
+
+	b, elements, err := decodeAggregate(b, 2)
+	if err != nil {
+		return nil, nil, fmt.Errorf("map decode error: %v", err)
+	}
+	if elements == nil {
+		return nil, nil, fmt.Errorf("map decode error: map can't be nil")
+	}
+
+	entries := make([]MapEntry, 0, len(elements)/2)
+	for i := 0; i < len(elements); i += 2 {
+		entries = append(entries, MapEntry{Key: elements[i], Value: elements[i+1]})
+	}
+	return b, Map{Value: entries}, nil
+}
+
+// Keys and values one after another, like map is replied to RESP2 client
+func (m Map) flatten() []Value {
+	elements := make([]Value, 0, len(m.Value)*2)
+	for _, entry := range m.Value {
+		elements = append(elements, entry.Key, entry.Value)
+	}
+	return elements
+}
+
+// Map with bulk string keys and values, e.g. CONFIG GET reply, it is flattened to the same array for RESP2 client
+func CreateBulkStringMap(keysAndValues ...string) Map {
+	elements := CreateBulkStringArray(keysAndValues...).Value
+	entries := make([]MapEntry, 0, len(elements)/2)
+	for i := 0; i+1 < len(elements); i += 2 {
+		entries = append(entries, MapEntry{Key: elements[i], Value: elements[i+1]})
+	}
+	return Map{Value: entries}
+}
@@ -0,0 +1,94 @@
+package resp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapEncode(t *testing.T) {
+	tests := []struct {
+		Name     string
+		In       Value
+		Expected []byte
+	}{
+		{
+			Name:     "Bulk string map",
+			In:       CreateBulkStringMap("port", "6379", "dir", "/tmp"),
+			Expected: []byte("%2\r\n$4\r\nport\r\n$4\r\n6379\r\n$3\r\ndir\r\n$4\r\n/tmp\r\n"),
+		},
+		{
+			Name:     "Empty map",
+			In:       Map{},
+			Expected: []byte("%0\r\n"),
+		},
+		{
+			Name: "Map with nested aggregates",
+			In: Map{Value: []MapEntry{
+				{Key: SimpleString{Value: "set"}, Value: Set{Value: []Value{Integer{Value: 1}, Boolean{Value: true}}}},
+				{Key: SimpleString{Value: "null"}, Value: Null{}},
+			}},
+			Expected: []byte("%2\r\n+set\r\n~2\r\n:1\r\n#t\r\n+null\r\n_\r\n"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			out, err := test.In.Encode()
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, out)
+		})
+	}
+}
+
+func TestMapDecode(t *testing.T) {
+	tests := []struct {
+		Name        string
+		In          []byte
+		Expected    Value
+		ShouldError bool
+	}{
+		{
+			Name:        "Bulk string map",
+			In:          []byte("%2\r\n$4\r\nport\r\n$4\r\n6379\r\n$3\r\ndir\r\n$4\r\n/tmp\r\n"),
+			Expected:    CreateBulkStringMap("port", "6379", "dir", "/tmp"),
+			ShouldError: false,
+		},
+		{
+			Name: "Map with RESP3 values",
+			In:   []byte("%3\r\n+double\r\n,1.5\r\n+big\r\n(12345678901234567890\r\n+text\r\n=8\r\ntxt:info\r\n"),
+			Expected: Map{Value: []MapEntry{
+				{Key: SimpleString{Value: "double"}, Value: Double{Value: 1.5}},
+				{Key: SimpleString{Value: "big"}, Value: BigNumber{Value: "12345678901234567890"}},
+				{Key: SimpleString{Value: "text"}, Value: VerbatimString{Format: VERBATIM_FORMAT_TEXT, Value: "info"}},
+			}},
+			ShouldError: false,
+		},
+		{
+			Name:        "Key without value",
+			In:          []byte("%1\r\n+key\r\n"),
+			Expected:    nil,
+			ShouldError: true,
+		},
+		{
+			Name:        "Invalid prefix",
+			In:          []byte("*1\r\n+key\r\n+value\r\n"),
+			Expected:    nil,
+			ShouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, out, err := Map{}.Decode(test.In)
+
+			if test.ShouldError {
+				assert.NotNil(t, err)
+				assert.Equal(t, test.Expected, out)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.Expected, out)
+			}
+		})
+	}
+}
@@ -1,4 +1,21 @@
 package resp
 
+import "fmt"
+
 const NULL_BULK_STRING_RESP_2 = "$-1\r\n"
 const NULL_ARRAY_RESP_2 = "*-1\r\n"
+const NULL_RESP_3 = "_\r\n"
+
+// RESP3 null, it replaces both null bulk string and null array of RESP2
+type Null struct{}
+
+func (Null) Encode() ([]byte, error) {
+	return []byte(NULL_RESP_3), nil
+}
+
+func (Null) Decode(b []byte) ([]byte, Value, error) {
+	if len(b) < len(NULL_RESP_3) || string(b[:len(NULL_RESP_3)]) != NULL_RESP_3 {
+		return nil, nil, fmt.Errorf("null decode error: expected %q", NULL_RESP_3)
+	}
+	return b[len(NULL_RESP_3):], Null{}, nil
+}
@@ -0,0 +1,184 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Default of proto-max-bulk-len, like in Redis
+	DEFAULT_PROTO_MAX_BULK_LEN = 512 * 1024 * 1024
+	// Count of aggregate elements, so hostile length can't make server wait for and allocate huge aggregate
+	PROTO_MAX_MULTIBULK_LEN = 1024 * 1024
+	// Aggregates in aggregates, so hostile nesting can't exhaust stack
+	PROTO_MAX_NESTING_DEPTH = 128
+)
+
+// More bytes can complete data, so it is left in buffer, until the rest of it is read
+var ErrIncomplete = errors.New("incomplete RESP data")
+
+// Data can't become valid with more bytes, so connection of client is closed, like in Redis
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("Protocol error: %s", e.Reason)
+}
+
+// Searches of line ends, tests count the searched bytes with it
+var indexByte = bytes.IndexByte
+
+// Decoder of values, that are read from one connection, e.g. one per client
+// Scanning of incomplete value is resumed from where it stopped, like with multibulklen and bulklen in Redis,
+// so value, that is read by small chunks, is scanned once
+// Buffer must start with the same bytes in every call, until value is decoded or error is returned
+type Parser interface {
+	// Decodes RESP value or inline command, error is ErrIncomplete, if more bytes can complete data, otherwise it is *ProtocolError
+	Decode(b []byte) (rest []byte, value Value, err error)
+}
+
+type parser struct {
+	protoMaxBulkLen int
+	// Offset of value in buffer, empty inline lines before it are skipped
+	start int
+	// Offset of the next element, bytes before it are scanned already
+	pos int
+	// Elements, that are left to scan, of every open aggregate, the innermost one is the last
+	pending []int
+	// Bytes of the line, that starts at pos, which are searched for its end already
+	lineScanned int
+}
+
+func (p *parser) Decode(b []byte) ([]byte, Value, error) {
+	rest, value, err := p.decode(b)
+	if !errors.Is(err, ErrIncomplete) {
+		p.reset()
+	}
+	return rest, value, err
+}
+
+func (p *parser) reset() {
+	p.start, p.pos, p.lineScanned = 0, 0, 0
+	p.pending = p.pending[:0]
+}
+
+func (p *parser) decode(b []byte) ([]byte, Value, error) {
+	for p.pos == p.start {
+		if p.start >= len(b) {
+			return nil, nil, fmt.Errorf("%w: expected non-empty data", ErrIncomplete)
+		}
+		if strings.IndexByte(RESP_TYPE_SIGNS, b[p.start]) != -1 {
+			break
+		}
+		rest, value, err := p.decodeInline(b)
+		if err != nil || value != nil {
+			return rest, value, err
+		}
+	}
+
+	end, err := p.scan(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	rest, value, err := decode(b[p.start:end])
+	if err != nil {
+		return nil, nil, &ProtocolError{Reason: err.Error()}
+	}
+	if len(rest) != 0 {
+		return nil, nil, &ProtocolError{Reason: fmt.Sprintf("%d bytes are left after value", len(rest))}
+	}
+	return b[end:], value, nil
+}
+
+// Finds the end of RESP value without decoding it, so value is decoded only when all its bytes are read
+// Lengths are checked before their data is waited for, e.g. too big bulk string is rejected by its header
+func (p *parser) scan(b []byte) (int, error) {
+	for {
+		if p.pos >= len(b) {
+			return 0, ErrIncomplete
+		}
+		if len(p.pending) > PROTO_MAX_NESTING_DEPTH {
+			return 0, &ProtocolError{Reason: "too deep nesting of aggregates"}
+		}
+
+		sign := b[p.pos]
+		lineEnd, err := p.scanLine(b, sign)
+		if err != nil {
+			return 0, err
+		}
+
+		end := lineEnd + 2
+		switch sign {
+		case '$', '=':
+			bulkLen, err := strconv.Atoi(string(b[p.pos+1 : lineEnd]))
+			if err != nil || bulkLen < -1 || (bulkLen == -1 && sign != '$') || bulkLen > p.protoMaxBulkLen {
+				return 0, &ProtocolError{Reason: "invalid bulk length"}
+			}
+			if bulkLen >= 0 {
+				// Header is kept scanned, so only length of buffer is checked, until bulk data is read
+				if len(b) < end+bulkLen+2 {
+					return 0, ErrIncomplete
+				}
+				if b[end+bulkLen] != '\r' || b[end+bulkLen+1] != '\n' {
+					return 0, &ProtocolError{Reason: "expected '\\r\\n' after bulk data"}
+				}
+				end += bulkLen + 2
+			}
+		case '*', '~', '>', '%':
+			count, err := strconv.Atoi(string(b[p.pos+1 : lineEnd]))
+			if err != nil || count < -1 || (count == -1 && sign != '*') || count > PROTO_MAX_MULTIBULK_LEN {
+				return 0, &ProtocolError{Reason: "invalid multibulk length"}
+			}
+			if sign == '%' {
+				count *= 2
+			}
+			if count > 0 {
+				p.pending = append(p.pending, count)
+				p.pos, p.lineScanned = end, 0
+				continue
+			}
+		}
+
+		p.pos, p.lineScanned = end, 0
+		// Finished element can finish its aggregates too
+		for len(p.pending) > 0 {
+			p.pending[len(p.pending)-1]--
+			if p.pending[len(p.pending)-1] > 0 {
+				break
+			}
+			p.pending = p.pending[:len(p.pending)-1]
+		}
+		if len(p.pending) == 0 {
+			return p.pos, nil
+		}
+	}
+}
+
+// Returns position of CRLF, that ends the line at pos, line without CR and LF can't be longer than inline request
+func (p *parser) scanLine(b []byte, sign byte) (int, error) {
+	from := p.pos + p.lineScanned
+	i := indexByte(b[from:], '\r')
+	if i == -1 {
+		p.lineScanned = len(b) - p.pos
+		if p.lineScanned > PROTO_INLINE_MAX_SIZE {
+			return 0, &ProtocolError{Reason: fmt.Sprintf("too big '%c' line", sign)}
+		}
+		return 0, ErrIncomplete
+	}
+	lineEnd := from + i
+	p.lineScanned = lineEnd - p.pos
+	if p.lineScanned > PROTO_INLINE_MAX_SIZE {
+		return 0, &ProtocolError{Reason: fmt.Sprintf("too big '%c' line", sign)}
+	}
+	if lineEnd+1 == len(b) {
+		return 0, ErrIncomplete
+	}
+	if b[lineEnd+1] != '\n' {
+		return 0, &ProtocolError{Reason: fmt.Sprintf("expected '\\n' after '\\r' in '%c' line", sign)}
+	}
+	return lineEnd, nil
+}
@@ -0,0 +1,231 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControllerDecode(t *testing.T) {
+	tests := []struct {
+		Name            string
+		In              []byte
+		Expected        Value
+		ExpectedRest    []byte
+		IsIncomplete    bool
+		IsProtocolError bool
+	}{
+		{
+			Name:         "Pipelined commands",
+			In:           []byte("*1\r\n$4\r\nPING\r\n*1\r\n$4\r\nPI"),
+			Expected:     CreateBulkStringArray("PING"),
+			ExpectedRest: []byte("*1\r\n$4\r\nPI"),
+		},
+		{
+			Name:         "Nil elements followed by more data",
+			In:           []byte("*2\r\n$-1\r\n*-1\r\n:1\r\n"),
+			Expected:     Array{Value: []Value{BulkString{Value: nil}, Array{Value: nil}}},
+			ExpectedRest: []byte(":1\r\n"),
+		},
+		{
+			Name:         "Bulk string with CRLF inside",
+			In:           []byte("$2\r\n\r\n\r\n"),
+			Expected:     BulkString{Value: strPtr("\r\n")},
+			ExpectedRest: []byte{},
+		},
+		{
+			Name:         "Incomplete length line",
+			In:           []byte("*12"),
+			IsIncomplete: true,
+		},
+		{
+			Name:         "CR without LF yet",
+			In:           []byte("+OK\r"),
+			IsIncomplete: true,
+		},
+		{
+			Name:         "Incomplete bulk data",
+			In:           []byte("*2\r\n$3\r\nGET\r\n$3\r\nke"),
+			IsIncomplete: true,
+		},
+		{
+			Name:         "Missing array elements",
+			In:           []byte("*3\r\n$3\r\nGET\r\n"),
+			IsIncomplete: true,
+		},
+		{
+			Name:            "Invalid multibulk length",
+			In:              []byte("*abc\r\n"),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Too big multibulk length",
+			In:              []byte(fmt.Sprintf("*%d\r\n", PROTO_MAX_MULTIBULK_LEN+1)),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Negative bulk length",
+			In:              []byte("$-2\r\n"),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Too big bulk length",
+			In:              []byte(fmt.Sprintf("*1\r\n$%d\r\n", 1024+1)),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Bulk data longer than its length",
+			In:              []byte("$3\r\nhello\r\n"),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "CR followed by other char",
+			In:              []byte("+OK\rX\n"),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Invalid integer",
+			In:              []byte(":abc\r\n"),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Nil map",
+			In:              []byte("%-1\r\n"),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Too long line without CRLF",
+			In:              []byte("+" + strings.Repeat("a", PROTO_INLINE_MAX_SIZE+1)),
+			IsProtocolError: true,
+		},
+		{
+			Name:            "Too deep nesting",
+			In:              []byte(strings.Repeat("*1\r\n", PROTO_MAX_NESTING_DEPTH+2)),
+			IsProtocolError: true,
+		},
+	}
+
+	c := NewController(1024)
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			rest, out, err := c.Decode(test.In)
+
+			var protocolErr *ProtocolError
+			switch {
+			case test.IsIncomplete:
+				assert.ErrorIs(t, err, ErrIncomplete)
+				assert.Nil(t, out)
+			case test.IsProtocolError:
+				assert.True(t, errors.As(err, &protocolErr), "expected protocol error, got: %v", err)
+				assert.Nil(t, out)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, test.Expected, out)
+				assert.Equal(t, test.ExpectedRest, rest)
+			}
+		})
+	}
+}
+
+func TestControllerDecodeBinarySafe(t *testing.T) {
+	blob := make([]byte, 5*1024*1024)
+	for i := range blob {
+		blob[i] = byte(i * 31)
+	}
+	args := []string{"SET", "", "\x00", "\r\n", "$3\r\n", "\xff\xfe", string(blob)}
+
+	in, err := CreateBulkStringArray(args...).Encode()
+	assert.NoError(t, err)
+
+	c := NewController(DEFAULT_PROTO_MAX_BULK_LEN)
+	// Data is read by chunks, so blob is decoded only when its last byte arrives
+	for _, cut := range []int{1, len(in) / 2, len(in) - 1} {
+		_, _, err := c.Decode(in[:cut])
+		assert.ErrorIs(t, err, ErrIncomplete)
+	}
+
+	rest, out, err := c.Decode(in)
+	assert.NoError(t, err)
+	assert.Empty(t, rest)
+	assert.Equal(t, CreateBulkStringArray(args...), out)
+}
+
+func TestParserDecodeByteByByte(t *testing.T) {
+	args := make([]string, 100000)
+	for i := range args {
+		args[i] = fmt.Sprintf("v%d", i)
+	}
+	args[0] = strings.Repeat("b", 64*1024)
+	multibulk, err := CreateBulkStringArray(args...).Encode()
+	assert.NoError(t, err)
+	nestedValue := Array{Value: []Value{CreateBulkStringArray(args[1:1000]...), Map{Value: []MapEntry{{Key: SimpleString{Value: "k"}, Value: Integer{Value: 1}}}}}}
+	nested, err := nestedValue.Encode()
+	assert.NoError(t, err)
+	inline := "\r\nSET key " + strings.Repeat("v", 60*1024) + "\r\n"
+
+	tests := []struct {
+		Name     string
+		In       []byte
+		Expected Value
+	}{
+		{
+			Name:     "Big multibulk",
+			In:       multibulk,
+			Expected: CreateBulkStringArray(args...),
+		},
+		{
+			Name:     "Nested aggregates",
+			In:       nested,
+			Expected: nestedValue,
+		},
+		{
+			Name:     "Inline command after empty line",
+			In:       []byte(inline),
+			Expected: CreateBulkStringArray("SET", "key", strings.Repeat("v", 60*1024)),
+		},
+	}
+
+	searched := 0
+	indexByte = func(b []byte, c byte) int {
+		i := bytes.IndexByte(b, c)
+		if i == -1 {
+			searched += len(b)
+		} else {
+			searched += i + 1
+		}
+		return i
+	}
+	t.Cleanup(func() {
+		indexByte = bytes.IndexByte
+	})
+
+	p := NewController(DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			searched = 0
+			// The next command is pipelined, so parser is reused after the value, like by connection
+			in := append(test.In, "*1\r\n$4\r\nPING\r\n"...)
+			for end := 1; end < len(test.In); end++ {
+				if _, _, err := p.Decode(in[:end]); !errors.Is(err, ErrIncomplete) {
+					t.Fatalf("prefix of %d bytes isn't incomplete, error: %v", end, err)
+				}
+			}
+
+			rest, out, err := p.Decode(in[:len(test.In)])
+			assert.NoError(t, err)
+			assert.Empty(t, rest)
+			assert.Equal(t, test.Expected, out)
+			// Byte, that ends the line, is searched again only until the next byte is read
+			assert.LessOrEqual(t, searched, 2*len(test.In))
+
+			rest, out, err = p.Decode(in[len(test.In):])
+			assert.NoError(t, err)
+			assert.Empty(t, rest)
+			assert.Equal(t, CreateBulkStringArray("PING"), out)
+		})
+	}
+}
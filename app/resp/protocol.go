@@ -0,0 +1,83 @@
+package resp
+
+const (
+	PROTOCOL_RESP2 = 2
+	PROTOCOL_RESP3 = 3
+)
+
+// Converts reply to protocol of client, commands can build reply of RESP3 types, RESP2 client gets their RESP2 form:
+// map is a flat array of keys and values, set and push are arrays, double, big number and verbatim string are bulk strings,
+// boolean is integer 1 or 0 and null is null bulk string
+// RESP3 client gets null instead of null bulk string and null array
+func ConvertToProtocol(value Value, protocol int) Value {
+	if protocol == PROTOCOL_RESP3 {
+		return toRESP3(value)
+	}
+	return toRESP2(value)
+}
+
+func toRESP2(value Value) Value {
+	switch v := value.(type) {
+	case Array:
+		if v.Value == nil {
+			return v
+		}
+		return Array{Value: convertElements(v.Value, toRESP2)}
+	case Map:
+		return Array{Value: convertElements(v.flatten(), toRESP2)}
+	case Set:
+		return Array{Value: convertElements(v.Value, toRESP2)}
+	case Push:
+		return Array{Value: convertElements(v.Value, toRESP2)}
+	case Double:
+		return BulkString{Value: strPtr(formatDouble(v.Value))}
+	case BigNumber:
+		return BulkString{Value: strPtr(v.Value)}
+	case VerbatimString:
+		return BulkString{Value: strPtr(v.Value)}
+	case Boolean:
+		if v.Value {
+			return Integer{Value: 1}
+		}
+		return Integer{Value: 0}
+	case Null:
+		return BulkString{Value: nil}
+	default:
+		return value
+	}
+}
+
+func toRESP3(value Value) Value {
+	switch v := value.(type) {
+	case BulkString:
+		if v.Value == nil {
+			return Null{}
+		}
+		return v
+	case Array:
+		if v.Value == nil {
+			return Null{}
+		}
+		return Array{Value: convertElements(v.Value, toRESP3)}
+	case Map:
+		entries := make([]MapEntry, 0, len(v.Value))
+		for _, entry := range v.Value {
+			entries = append(entries, MapEntry{Key: toRESP3(entry.Key), Value: toRESP3(entry.Value)})
+		}
+		return Map{Value: entries}
+	case Set:
+		return Set{Value: convertElements(v.Value, toRESP3)}
+	case Push:
+		return Push{Value: convertElements(v.Value, toRESP3)}
+	default:
+		return value
+	}
+}
+
+func convertElements(elements []Value, convert func(Value) Value) []Value {
+	converted := make([]Value, 0, len(elements))
+	for _, element := range elements {
+		converted = append(converted, convert(element))
+	}
+	return converted
+}
@@ -0,0 +1,60 @@
+package resp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertToProtocol(t *testing.T) {
+	tests := []struct {
+		Name     string
+		In       Value
+		Protocol int
+		Expected Value
+	}{
+		{
+			Name:     "Map is flattened for RESP2",
+			In:       CreateBulkStringMap("port", "6379"),
+			Protocol: PROTOCOL_RESP2,
+			Expected: CreateBulkStringArray("port", "6379"),
+		},
+		{
+			Name:     "Push and set are arrays for RESP2",
+			In:       Push{Value: []Value{Set{Value: []Value{Integer{Value: 1}}}}},
+			Protocol: PROTOCOL_RESP2,
+			Expected: Array{Value: []Value{Array{Value: []Value{Integer{Value: 1}}}}},
+		},
+		{
+			Name:     "Scalars are RESP2 types",
+			In:       Array{Value: []Value{Double{Value: math.Inf(-1)}, Boolean{Value: true}, BigNumber{Value: "1"}, VerbatimString{Format: VERBATIM_FORMAT_TEXT, Value: "info"}, Null{}}},
+			Protocol: PROTOCOL_RESP2,
+			Expected: Array{Value: []Value{BulkString{Value: strPtr("-inf")}, Integer{Value: 1}, BulkString{Value: strPtr("1")}, BulkString{Value: strPtr("info")}, BulkString{Value: nil}}},
+		},
+		{
+			Name:     "RESP2 types stay the same for RESP2",
+			In:       Array{Value: nil},
+			Protocol: PROTOCOL_RESP2,
+			Expected: Array{Value: nil},
+		},
+		{
+			Name:     "Nulls are the one null for RESP3",
+			In:       Map{Value: []MapEntry{{Key: SimpleString{Value: "key"}, Value: Array{Value: []Value{BulkString{Value: nil}, Array{Value: nil}}}}}},
+			Protocol: PROTOCOL_RESP3,
+			Expected: Map{Value: []MapEntry{{Key: SimpleString{Value: "key"}, Value: Array{Value: []Value{Null{}, Null{}}}}}},
+		},
+		{
+			Name:     "RESP3 types stay the same for RESP3",
+			In:       Push{Value: []Value{Double{Value: 1.5}, Boolean{Value: false}}},
+			Protocol: PROTOCOL_RESP3,
+			Expected: Push{Value: []Value{Double{Value: 1.5}, Boolean{Value: false}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, ConvertToProtocol(test.In, test.Protocol))
+		})
+	}
+}
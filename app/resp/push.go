@@ -0,0 +1,38 @@
+package resp
+
+import (
+	"fmt"
+)
+
+// Out of band data, that isn't a reply to command, e.g. Pub/Sub message, it is an array for RESP2 client
+type Push struct {
+	Value []Value
+}
+
+func (p Push) Encode() ([]byte, error) {
+	b, err := encodeAggregate('>', len(p.Value), p.Value)
+	if err != nil {
+		return nil, fmt.Errorf("push encode error: %v", err)
+	}
+	return b, nil
+}
+
+func (Push) Decode(b []byte) ([]byte, Value, error) {
+	l := len(b)
+	if l == 0 || b == nil {
+		return nil, nil, fmt.Errorf("push decode error: expected non-empty data")
+	}
+
+	if b[0] != '>' {
+		return nil, nil, fmt.Errorf("push decode error: didn't find '>' sign")
+	}
+
+	b, elements, err := decodeAggregate(b, 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("push decode error: %v", err)
+	}
+	if elements == nil {
+		return nil, nil, fmt.Errorf("push decode error: push can't be nil")
+	}
+	return b, Push{Value: elements}, nil
+}
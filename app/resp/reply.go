@@ -0,0 +1,132 @@
+package resp
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"unsafe"
+)
+
+// Payload of bulk string, that isn't shorter, is referenced by reply buffer instead of copying, e.g. stored image
+const REPLY_ZERO_COPY_MIN_SIZE = 16 * 1024
+
+// Buffer, that is grown by big reply, e.g. LRANGE of whole list, isn't kept after it is written
+const REPLY_BUFFER_MAX_KEPT_SIZE = 1024 * 1024
+
+// Reply buffer of client, replies are encoded into it and are written at once, so pipeline isn't bound by write syscalls
+// Small parts are copied into one chunk, big bulk payloads are written from the strings themselves by writev
+type ReplyBuffer struct {
+	buf  []byte
+	refs []replyRef
+	// Length of referenced payloads
+	refsLen int
+	chunks  net.Buffers
+}
+
+// Payload, that is written right after buf[:at]
+type replyRef struct {
+	at   int
+	data []byte
+}
+
+func (r *ReplyBuffer) Len() int {
+	return len(r.buf) + r.refsLen
+}
+
+// Data is copied, because writer can reuse it after return
+func (r *ReplyBuffer) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	return len(p), nil
+}
+
+// Encodes value like its Encode does, but without intermediate allocations
+func (r *ReplyBuffer) WriteValue(v Value) error {
+	bufLen, refsCount, refsLen := len(r.buf), len(r.refs), r.refsLen
+	if err := r.writeValue(v); err != nil {
+		// Partly encoded value is dropped, so client doesn't get broken reply
+		r.buf, r.refs, r.refsLen = r.buf[:bufLen], r.refs[:refsCount], refsLen
+		return err
+	}
+	return nil
+}
+
+func (r *ReplyBuffer) writeValue(v Value) error {
+	switch v := v.(type) {
+	case BulkString:
+		if v.Value != nil {
+			r.writeBulk(*v.Value)
+			return nil
+		}
+	case Array:
+		if v.Value != nil {
+			return r.writeAggregate('*', v.Value)
+		}
+	case Push:
+		return r.writeAggregate('>', v.Value)
+	case Set:
+		return r.writeAggregate('~', v.Value)
+	}
+
+	encoded, err := v.Encode()
+	if err != nil {
+		return err
+	}
+	r.buf = append(r.buf, encoded...)
+	return nil
+}
+
+func (r *ReplyBuffer) writeBulk(s string) {
+	r.buf = appendLengthLine(r.buf, '$', len(s))
+	if len(s) < REPLY_ZERO_COPY_MIN_SIZE {
+		r.buf = append(r.buf, s...)
+	} else {
+		// Strings are immutable, so payload stays the same, until it is written, even if key is changed meanwhile
+		r.refs = append(r.refs, replyRef{at: len(r.buf), data: unsafe.Slice(unsafe.StringData(s), len(s))})
+		r.refsLen += len(s)
+	}
+	r.buf = append(r.buf, "\r\n"...)
+}
+
+func (r *ReplyBuffer) writeAggregate(sign byte, elements []Value) error {
+	r.buf = appendLengthLine(r.buf, sign, len(elements))
+	for _, element := range elements {
+		if err := r.writeValue(element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes buffered replies and resets buffer, so it is reused by next replies
+func (r *ReplyBuffer) WriteTo(w io.Writer) (int64, error) {
+	r.chunks = r.chunks[:0]
+	prev := 0
+	for _, ref := range r.refs {
+		if ref.at > prev {
+			r.chunks = append(r.chunks, r.buf[prev:ref.at])
+		}
+		r.chunks = append(r.chunks, ref.data)
+		prev = ref.at
+	}
+	if len(r.buf) > prev {
+		r.chunks = append(r.chunks, r.buf[prev:])
+	}
+
+	// Chunks are consumed by WriteTo, so the field keeps the whole slice for reuse
+	chunks := r.chunks
+	n, err := chunks.WriteTo(w)
+
+	clear(r.refs)
+	r.buf, r.refs, r.refsLen = r.buf[:0], r.refs[:0], 0
+	clear(r.chunks)
+	if cap(r.buf) > REPLY_BUFFER_MAX_KEPT_SIZE {
+		r.buf = nil
+	}
+	return n, err
+}
+
+func appendLengthLine(b []byte, sign byte, length int) []byte {
+	b = append(b, sign)
+	b = strconv.AppendInt(b, int64(length), 10)
+	return append(b, "\r\n"...)
+}
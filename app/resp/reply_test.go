@@ -0,0 +1,80 @@
+package resp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplyBufferWriteValue(t *testing.T) {
+	big := strings.Repeat("\x00a\r\n", REPLY_ZERO_COPY_MIN_SIZE)
+
+	tests := []struct {
+		Name string
+		In   Value
+	}{
+		{Name: "Bulk string", In: BulkString{Value: strPtr("hello")}},
+		{Name: "Empty bulk string", In: BulkString{Value: strPtr("")}},
+		{Name: "Null bulk string", In: BulkString{Value: nil}},
+		{Name: "Big bulk string", In: BulkString{Value: &big}},
+		{Name: "Null array", In: Array{Value: nil}},
+		{Name: "Array with big and small bulk strings", In: CreateBulkStringArray("a", big, "", big)},
+		{Name: "Nested array", In: Array{Value: []Value{Integer{Value: 1}, CreateBulkStringArray(big), SimpleString{Value: "OK"}}}},
+		{Name: "Push", In: Push{Value: []Value{BulkString{Value: strPtr("message")}, BulkString{Value: &big}}}},
+		{Name: "Set", In: Set{Value: []Value{Double{Value: 1.5}, Null{}}}},
+		{Name: "Map", In: CreateBulkStringMap("key", big)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			expected, err := test.In.Encode()
+			assert.NoError(t, err)
+
+			var r ReplyBuffer
+			assert.NoError(t, r.WriteValue(test.In))
+			assert.Equal(t, len(expected), r.Len())
+
+			var out bytes.Buffer
+			n, err := r.WriteTo(&out)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(expected)), n)
+			assert.Equal(t, expected, out.Bytes())
+			assert.Zero(t, r.Len())
+		})
+	}
+}
+
+func TestReplyBufferReuse(t *testing.T) {
+	big := strings.Repeat("b", REPLY_ZERO_COPY_MIN_SIZE)
+	var r ReplyBuffer
+
+	// Buffer is reused after write, payloads of previous replies aren't written again
+	for range 3 {
+		_, err := r.Write([]byte("+OK\r\n"))
+		assert.NoError(t, err)
+		assert.NoError(t, r.WriteValue(BulkString{Value: &big}))
+		assert.NoError(t, r.WriteValue(Integer{Value: 7}))
+
+		var out bytes.Buffer
+		_, err = r.WriteTo(&out)
+		assert.NoError(t, err)
+		assert.Equal(t, "+OK\r\n$16384\r\n"+big+"\r\n:7\r\n", out.String())
+	}
+}
+
+func TestReplyBufferWriteValueError(t *testing.T) {
+	var r ReplyBuffer
+	assert.NoError(t, r.WriteValue(SimpleString{Value: "OK"}))
+
+	// Partly encoded array isn't left in buffer
+	big := strings.Repeat("b", REPLY_ZERO_COPY_MIN_SIZE)
+	invalid := Array{Value: []Value{BulkString{Value: &big}, VerbatimString{Format: "text", Value: "a"}}}
+	assert.Error(t, r.WriteValue(invalid))
+
+	var out bytes.Buffer
+	_, err := r.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", out.String())
+}
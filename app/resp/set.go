@@ -0,0 +1,38 @@
+package resp
+
+import (
+	"fmt"
+)
+
+// Unordered collection of unique elements, it is an array for RESP2 client
+type Set struct {
+	Value []Value
+}
+
+func (s Set) Encode() ([]byte, error) {
+	b, err := encodeAggregate('~', len(s.Value), s.Value)
+	if err != nil {
+		return nil, fmt.Errorf("set encode error: %v", err)
+	}
+	return b, nil
+}
+
+func (Set) Decode(b []byte) ([]byte, Value, error) {
+	l := len(b)
+	if l == 0 || b == nil {
+		return nil, nil, fmt.Errorf("set decode error: expected non-empty data")
+	}
+
+	if b[0] != '~' {
+		return nil, nil, fmt.Errorf("set decode error: didn't find '~' sign")
+	}
+
+	b, elements, err := decodeAggregate(b, 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("set decode error: %v", err)
+	}
+	if elements == nil {
+		return nil, nil, fmt.Errorf("set decode error: set can't be nil")
+	}
+	return b, Set{Value: elements}, nil
+}
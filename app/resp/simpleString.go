@@ -2,7 +2,6 @@ package resp
 
 import (
 	"fmt"
-	"log"
 	"strings"
 )
 
@@ -36,12 +35,13 @@ func (SimpleString) Decode(b []byte) ([]byte, Value, error) {
 	return b, SimpleString{Value: res}, nil
 }
 
-func AssertEqualSimpleString(value Value, raw string) {
+func AssertEqualSimpleString(value Value, raw string) error {
 	v, ok := value.(SimpleString)
 	if !ok {
-		log.Fatalf("assertion failed: expected SimpleString, got: %T", value)
+		return fmt.Errorf("assertion failed: expected SimpleString, got: %T", value)
 	}
 	if v.Value != raw {
-		log.Fatalf("assertion failed: expected %s, got: %v", raw, v.Value)
+		return fmt.Errorf("assertion failed: expected %s, got: %v", raw, v.Value)
 	}
+	return nil
 }
@@ -1,16 +1,24 @@
 package resp
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 )
 
+// Payload of line can't have '\r' or '\n', like in RESP specification
 func traversePayloadTillFirstCRLF(b []byte, l int) ([]byte, string, error) {
 	for i := range b {
+		if b[i] == '\n' {
+			return nil, "", fmt.Errorf("wrong char: '\\n' before '\\r\\n'")
+		}
 		if b[i] == '\r' {
 			if i+1 < l && b[i+1] == '\n' {
 				return b[i+2:], string(b[1:i]), nil
 			}
+			if i+1 == l {
+				return nil, "", fmt.Errorf("didn't find '\\n' after '\\r'")
+			}
 			return nil, "", fmt.Errorf("wrong char: %q after '\\r'", b[i+1])
 		}
 	}
@@ -19,21 +27,17 @@ func traversePayloadTillFirstCRLF(b []byte, l int) ([]byte, string, error) {
 }
 
 func traverseExpectedLen(b []byte) (int, []byte, error) {
-	expectedLen := make([]byte, 0)
-
-	for i := range b {
-		if b[i] == '\r' && i+1 < len(b) && b[i+1] == '\n' {
-			break
-		}
-		expectedLen = append(expectedLen, b[i])
+	lenEnd := bytes.Index(b, []byte("\r\n"))
+	if lenEnd == -1 {
+		return 0, nil, fmt.Errorf("didn't find '\\r\\n' after len")
 	}
 
-	expectedLenInt, err := strconv.Atoi(string(expectedLen))
+	expectedLenInt, err := strconv.Atoi(string(b[:lenEnd]))
 	if err != nil {
 		return 0, nil, fmt.Errorf("len atoi error: %v", err)
 	}
 
-	return expectedLenInt, b[len(expectedLen):], nil
+	return expectedLenInt, b[lenEnd:], nil
 }
 
 func traverseCRLF(b []byte) ([]byte, error) {
@@ -45,7 +49,7 @@ func traverseCRLF(b []byte) ([]byte, error) {
 
 func requireEndingCRLF(b []byte) error {
 	l := len(b)
-	if string(b[l-2:]) != "\r\n" {
+	if l < 2 || string(b[l-2:]) != "\r\n" {
 		return fmt.Errorf("didn't find '\\r\\n' in the end")
 	}
 	return nil
@@ -54,3 +58,51 @@ func requireEndingCRLF(b []byte) error {
 func strPtr(s string) *string {
 	return &s
 }
+
+// Decodes length and elements of array, set, push or map, map has elementsPerItem 2 for key and value
+// Elements are nil for -1 length, only array can be nil
+func decodeAggregate(b []byte, elementsPerItem int) ([]byte, []Value, error) {
+	itemsCount, rest, err := traverseExpectedLen(b[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	if itemsCount < -1 {
+		return nil, nil, fmt.Errorf("negative len: %d", itemsCount)
+	}
+	rest, err = traverseCRLF(rest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("traverse CRLF error: %v", err)
+	}
+	if itemsCount == -1 {
+		return rest, nil, nil
+	}
+
+	elementsCount := itemsCount * elementsPerItem
+	// Capacity is limited by data, so hostile length isn't allocated
+	elements := make([]Value, 0, min(elementsCount, len(rest)))
+	for range elementsCount {
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf("not enough elements")
+		}
+		var element Value
+		rest, element, err = decode(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		elements = append(elements, element)
+	}
+	return rest, elements, nil
+}
+
+// Encodes length header and elements of array, set, push or map
+func encodeAggregate(sign byte, itemsCount int, elements []Value) ([]byte, error) {
+	b := fmt.Appendf(nil, "%c%d\r\n", sign, itemsCount)
+	for _, element := range elements {
+		encoded, err := element.Encode()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, encoded...)
+	}
+	return b, nil
+}
@@ -0,0 +1,57 @@
+package resp
+
+import (
+	"fmt"
+)
+
+const (
+	VERBATIM_FORMAT_TEXT     = "txt"
+	VERBATIM_FORMAT_MARKDOWN = "mkd"
+)
+
+// Bulk string with format of its text, e.g. INFO reply is "txt", so client can show it as is
+type VerbatimString struct {
+	Format string
+	Value  string
+}
+
+func (vs VerbatimString) Encode() ([]byte, error) {
+	if len(vs.Format) != 3 {
+		return nil, fmt.Errorf("verbatim string encode error: format must have 3 chars, got: %q", vs.Format)
+	}
+	return fmt.Appendf(nil, "=%d\r\n%s:%s\r\n", len(vs.Value)+4, vs.Format, vs.Value), nil
+}
+
+func (VerbatimString) Decode(b []byte) ([]byte, Value, error) {
+	l := len(b)
+	if l == 0 || b == nil {
+		return nil, nil, fmt.Errorf("verbatim string decode error: expected non-empty data")
+	}
+
+	if b[0] != '=' {
+		return nil, nil, fmt.Errorf("verbatim string decode error: didn't find '=' sign")
+	}
+
+	expectedLen, b, err := traverseExpectedLen(b[1:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("verbatim string parse expected len error: %v", err)
+	}
+
+	b, err = traverseCRLF(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("verbatim string traverse CRLF error: %v", err)
+	}
+
+	if expectedLen < 4 || len(b) < expectedLen+2 {
+		return nil, nil, fmt.Errorf("verbatim string decode error: not enough bytes for format and content")
+	}
+
+	if err = requireEndingCRLF(b[expectedLen : expectedLen+2]); err != nil {
+		return nil, nil, fmt.Errorf("verbatim string decode error: %v", err)
+	}
+	if b[3] != ':' {
+		return nil, nil, fmt.Errorf("verbatim string decode error: didn't find ':' after format")
+	}
+
+	return b[expectedLen+2:], VerbatimString{Format: string(b[:3]), Value: string(b[4:expectedLen])}, nil
+}
@@ -0,0 +1,267 @@
+package sentinel
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func (c *controller) ping(conn net.Conn) resp.Value {
+	subscribeModePong := "pong"
+	subscribeModeEmptyStr := ""
+
+	if c.pubsubController.InSubscribeMode(conn) {
+		return resp.Array{Value: []resp.Value{resp.BulkString{Value: &subscribeModePong}, resp.BulkString{Value: &subscribeModeEmptyStr}}}
+	}
+	return resp.SimpleString{Value: "PONG"}
+}
+
+// Clients subscribe to sentinel events, e.g. to +switch-master
+func (c *controller) subscribeOrUnsubscribe(command string, args []string, conn net.Conn) resp.Value {
+	if len(args) < 1 {
+		return resp.SimpleError{Value: fmt.Sprintf("%s command must have at least 1 arg", command)}
+	}
+
+	var gotResponses []pubsub.ChanAndLen
+	if command == "SUBSCRIBE" {
+		gotResponses = c.pubsubController.Subscribe(conn, args...)
+	} else {
+		gotResponses = c.pubsubController.Unsubscribe(conn, args...)
+	}
+
+	if len(gotResponses) == 1 {
+		return pubsub.CreateRESPChannelAndLenResponse(strings.ToLower(command), gotResponses[0])
+	}
+	multipleRESPResponses := make([]resp.Value, 0, len(gotResponses))
+	for _, gotResponse := range gotResponses {
+		multipleRESPResponses = append(multipleRESPResponses, pubsub.CreateRESPChannelAndLenResponse(strings.ToLower(command), gotResponse))
+	}
+	return resp.Array{Value: multipleRESPResponses}
+}
+
+// Status of every master, like in Redis INFO sentinel, sentinels are counted with this one
+func (c *controller) info(args []string) resp.Value {
+	if len(args) > 1 {
+		return resp.SimpleError{Value: "INFO command error: only 1 argument supported"}
+	}
+	if len(args) == 1 && args[0] != "sentinel" {
+		return resp.SimpleError{Value: fmt.Sprintf("INFO unsupported section: %s", args[0])}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	masters := c.sortedMasters()
+	data := []string{"sentinel_masters:" + strconv.Itoa(len(masters))}
+	for n, m := range masters {
+		status := "ok"
+		if m.isObjectivelyDown() {
+			status = "odown"
+		} else if m.isSubjectivelyDown() {
+			status = "sdown"
+		}
+		data = append(data, fmt.Sprintf("master%d:name=%s,status=%s,address=%s,slaves=%d,sentinels=%d", n, m.name, status, m.addr(), len(m.replicas), len(m.sentinels)+1))
+	}
+	info := strings.Join(data, "\r\n") + "\r\n"
+	return resp.BulkString{Value: &info}
+}
+
+func (c *controller) sentinel(args []string) resp.Value {
+	if len(args) < 1 {
+		return resp.SimpleError{Value: "SENTINEL command must have at least 1 arg"}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	secondCommand := strings.ToLower(args[0])
+	args = args[1:]
+	switch secondCommand {
+	case "get-master-addr-by-name":
+		return c.getMasterAddrByName(args)
+	case "is-master-down-by-addr":
+		return c.isMasterDownByAddr(args)
+	case "myid":
+		runID := c.runID
+		return resp.BulkString{Value: &runID}
+	case "masters":
+		masters := make([]resp.Value, 0, len(c.masters))
+		for _, m := range c.sortedMasters() {
+			masters = append(masters, c.masterFields(m))
+		}
+		return resp.Array{Value: masters}
+	case "master", "replicas", "slaves", "sentinels":
+		if len(args) != 1 {
+			return resp.SimpleError{Value: fmt.Sprintf("SENTINEL %s command must have 1 arg", secondCommand)}
+		}
+		m, ok := c.masters[args[0]]
+		if !ok {
+			return resp.SimpleError{Value: "ERR No such master with that name"}
+		}
+		switch secondCommand {
+		case "master":
+			return c.masterFields(m)
+		case "sentinels":
+			return instancesFields(m, m.sentinels, c.sentinelFields)
+		default:
+			return instancesFields(m, m.replicas, c.replicaFields)
+		}
+	default:
+		return resp.SimpleError{Value: fmt.Sprintf("unknown command SENTINEL '%s'", secondCommand)}
+	}
+}
+
+// Clients discover the current master by its name, null is returned for unknown master
+func (c *controller) getMasterAddrByName(args []string) resp.Value {
+	if len(args) != 1 {
+		return resp.SimpleError{Value: "SENTINEL get-master-addr-by-name command must have 1 arg"}
+	}
+	m, ok := c.masters[args[0]]
+	if !ok {
+		return resp.Array{Value: nil}
+	}
+	return resp.CreateBulkStringArray(m.host, strconv.Itoa(m.port))
+}
+
+// Other sentinel asks, if master is subjectively down, with run ID it also asks for vote in the epoch, * is only a question
+func (c *controller) isMasterDownByAddr(args []string) resp.Value {
+	if len(args) != 4 {
+		return resp.SimpleError{Value: "SENTINEL is-master-down-by-addr command must have 4 args"}
+	}
+	port, err := strconv.Atoi(args[1])
+	if err != nil {
+		return resp.SimpleError{Value: "ERR Invalid port"}
+	}
+	epoch, err := strconv.Atoi(args[2])
+	if err != nil {
+		return resp.SimpleError{Value: "ERR Invalid epoch"}
+	}
+	runID := args[3]
+
+	down := 0
+	leader, leaderEpoch := "*", 0
+	for _, m := range c.masters {
+		if m.host != args[0] || m.port != port {
+			continue
+		}
+		if m.isSubjectivelyDown() {
+			down = 1
+		}
+		if runID != "*" {
+			leader, leaderEpoch = c.voteLeader(m, epoch, runID)
+		}
+		break
+	}
+	if leader == "" {
+		leader = "*"
+	}
+
+	return resp.Array{Value: []resp.Value{
+		resp.Integer{Value: down},
+		resp.BulkString{Value: &leader},
+		resp.Integer{Value: leaderEpoch},
+	}}
+}
+
+// Caller holds mut
+func (c *controller) sortedMasters() []*master {
+	masters := make([]*master, 0, len(c.masters))
+	for _, m := range c.masters {
+		masters = append(masters, m)
+	}
+	sort.Slice(masters, func(i, j int) bool {
+		return masters[i].name < masters[j].name
+	})
+	return masters
+}
+
+// Instances are described by field-value pairs, like in Redis
+func (c *controller) masterFields(m *master) resp.Value {
+	return resp.CreateBulkStringArray(
+		"name", m.name,
+		"ip", m.host,
+		"port", strconv.Itoa(m.port),
+		"flags", flags(m, m.instance),
+		"last-ok-ping-reply", strconv.FormatInt(time.Since(m.lastPingReply).Milliseconds(), 10),
+		"quorum", strconv.Itoa(m.quorum),
+		"num-slaves", strconv.Itoa(len(m.replicas)),
+		"num-other-sentinels", strconv.Itoa(len(m.sentinels)),
+		"config-epoch", strconv.Itoa(m.configEpoch),
+		"failover-state", m.failoverState,
+		"down-after-milliseconds", strconv.Itoa(c.args.SentinelDownAfter),
+		"failover-timeout", strconv.Itoa(c.args.SentinelFailoverTimeout),
+	)
+}
+
+func (c *controller) replicaFields(m *master, replica *instance) resp.Value {
+	return resp.CreateBulkStringArray(
+		"name", replica.addr(),
+		"ip", replica.host,
+		"port", strconv.Itoa(replica.port),
+		"flags", flags(m, replica),
+		"last-ok-ping-reply", strconv.FormatInt(time.Since(replica.lastPingReply).Milliseconds(), 10),
+		"role-reported", orUnknown(replica.role),
+		"master-host", orUnknown(replica.masterHost),
+		"master-port", strconv.Itoa(replica.masterPort),
+		"master-link-status", orUnknown(replica.masterLinkStatus),
+		"slave-repl-offset", strconv.Itoa(replica.replOffset),
+	)
+}
+
+func (c *controller) sentinelFields(m *master, s *instance) resp.Value {
+	return resp.CreateBulkStringArray(
+		"name", s.runID,
+		"ip", s.host,
+		"port", strconv.Itoa(s.port),
+		"runid", s.runID,
+		"flags", flags(m, s),
+		"last-hello-message", strconv.FormatInt(time.Since(s.lastHelloAt).Milliseconds(), 10),
+		"voted-leader", orUnknown(s.leader),
+		"voted-leader-epoch", strconv.Itoa(s.leaderEpoch),
+	)
+}
+
+func instancesFields(m *master, instances map[string]*instance, fields func(m *master, i *instance) resp.Value) resp.Value {
+	keys := make([]string, 0, len(instances))
+	for key := range instances {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]resp.Value, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, fields(m, instances[key]))
+	}
+	return resp.Array{Value: values}
+}
+
+func flags(m *master, i *instance) string {
+	result := []string{i.kind}
+	if i.isSubjectivelyDown() {
+		result = append(result, "s_down")
+	}
+	if i == m.instance && m.isObjectivelyDown() {
+		result = append(result, "o_down")
+	}
+	if i == m.instance && m.isFailoverInProgress() {
+		result = append(result, "failover_in_progress")
+	}
+	if i == m.promoted {
+		result = append(result, "promoted")
+	}
+	return strings.Join(result, ",")
+}
+
+// Empty values are shown as ?, like in Redis
+func orUnknown(value string) string {
+	if value == "" {
+		return "?"
+	}
+	return value
+}
@@ -0,0 +1,357 @@
+package sentinel
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Timer checks down instances and drives failover, network requests are sent in separate goroutines
+func (c *controller) startTimer() {
+	ticker := time.NewTicker(SENTINEL_TIMER_PERIOD)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mut.Lock()
+		for _, m := range c.masters {
+			c.checkMaster(m, time.Now())
+		}
+		c.mut.Unlock()
+	}
+}
+
+// Caller holds mut
+func (c *controller) checkMaster(m *master, now time.Time) {
+	c.checkSubjectivelyDown(m, m.instance, now)
+	for _, replica := range m.replicas {
+		c.checkSubjectivelyDown(m, replica, now)
+	}
+	c.checkObjectivelyDown(m, now)
+	if m.isSubjectivelyDown() {
+		c.askSentinels(m, now)
+	}
+	c.handleFailover(m, now)
+}
+
+// Instance is subjectively down, when it doesn't reply to PING for down-after-milliseconds
+// Caller holds mut
+func (c *controller) checkSubjectivelyDown(m *master, i *instance, now time.Time) {
+	down := now.Sub(i.lastPingReply) > c.downAfter()
+	if down && !i.isSubjectivelyDown() {
+		i.sdownSince = now
+		c.event("+sdown", m.describe(i))
+	} else if !down && i.isSubjectivelyDown() {
+		i.sdownSince = time.Time{}
+		c.event("-sdown", m.describe(i))
+	}
+}
+
+// Master is objectively down, when at least quorum of sentinels (including this one) see it subjectively down
+// Caller holds mut
+func (c *controller) checkObjectivelyDown(m *master, now time.Time) {
+	votes := 0
+	if m.isSubjectivelyDown() {
+		votes = 1
+		for _, s := range m.sentinels {
+			if s.masterDown && now.Sub(s.masterDownReplyAt) <= 5*SENTINEL_ASK_PERIOD {
+				votes++
+			}
+		}
+	}
+
+	odown := votes > 0 && votes >= m.quorum
+	if odown && !m.isObjectivelyDown() {
+		m.odownSince = now
+		c.event("+odown", fmt.Sprintf("%s #quorum %d/%d", m.describe(m.instance), votes, m.quorum))
+	} else if !odown && m.isObjectivelyDown() {
+		m.odownSince = time.Time{}
+		c.event("-odown", m.describe(m.instance))
+	}
+}
+
+// While failover is in progress, sentinels are asked for votes too, otherwise only for the master state
+// Caller holds mut
+func (c *controller) askSentinels(m *master, now time.Time) {
+	runID := "*"
+	if m.isFailoverInProgress() {
+		runID = c.runID
+	}
+
+	for _, s := range m.sentinels {
+		if s.askInProgress || now.Sub(s.lastAskAt) < SENTINEL_ASK_PERIOD {
+			continue
+		}
+		s.askInProgress = true
+		s.lastAskAt = now
+		go c.askSentinel(s, m.host, strconv.Itoa(m.port), strconv.Itoa(c.currentEpoch), runID)
+	}
+}
+
+// Reply is the master state, the leader, that sentinel has voted for, and the epoch of the vote
+func (c *controller) askSentinel(s *instance, masterHost, masterPort, epoch, runID string) {
+	value, err := s.link.request("SENTINEL", "is-master-down-by-addr", masterHost, masterPort, epoch, runID)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	s.askInProgress = false
+	if err != nil || s.isStopped() {
+		return
+	}
+	reply, ok := parseIsMasterDownReply(value)
+	if !ok {
+		return
+	}
+	s.masterDown = reply.down
+	s.masterDownReplyAt = time.Now()
+	if reply.leader != "*" {
+		s.leader = reply.leader
+		s.leaderEpoch = reply.leaderEpoch
+	}
+}
+
+type isMasterDownReply struct {
+	down        bool
+	leader      string
+	leaderEpoch int
+}
+
+func parseIsMasterDownReply(value resp.Value) (*isMasterDownReply, bool) {
+	array, ok := value.(resp.Array)
+	if !ok {
+		return nil, false
+	}
+	fields, err := commands.ExtractCommandAndArgs(array.Value)
+	if err != nil || len(fields) != 3 {
+		return nil, false
+	}
+	leaderEpoch, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, false
+	}
+	return &isMasterDownReply{down: fields[0] == "1", leader: fields[1], leaderEpoch: leaderEpoch}, true
+}
+
+// Sentinel votes once per epoch: for the first sentinel, that asks for vote in it
+// Caller holds mut
+func (c *controller) voteLeader(m *master, epoch int, runID string) (string, int) {
+	c.updateCurrentEpoch(epoch)
+	if m.leaderEpoch < epoch && c.currentEpoch <= epoch {
+		m.leader = runID
+		m.leaderEpoch = c.currentEpoch
+		c.event("+vote-for-leader", fmt.Sprintf("%s %d", m.leader, m.leaderEpoch))
+		// Sentinel, that has voted for other sentinel, doesn't start its own failover for a while
+		if runID != c.runID {
+			m.failoverStartedAt = time.Now().Add(randomDesync())
+		}
+	}
+	return m.leader, m.leaderEpoch
+}
+
+// Leader is the sentinel with the majority of votes in the epoch, that is at least quorum too
+// This sentinel votes for the most voted sentinel or for itself, if it hasn't voted in the epoch yet
+// Caller holds mut
+func (c *controller) getLeader(m *master, epoch int) string {
+	votes := make(map[string]int)
+	for _, s := range m.sentinels {
+		if s.leader != "" && s.leaderEpoch == epoch {
+			votes[s.leader]++
+		}
+	}
+
+	candidate := mostVoted(votes)
+	if candidate == "" {
+		candidate = c.runID
+	}
+	leader, leaderEpoch := c.voteLeader(m, epoch, candidate)
+	if leaderEpoch == epoch {
+		votes[leader]++
+	}
+
+	winner := mostVoted(votes)
+	voters := len(m.sentinels) + 1
+	if winner == "" || votes[winner] < voters/2+1 || votes[winner] < m.quorum {
+		return ""
+	}
+	return winner
+}
+
+// Ties are broken by the least run ID, so the result doesn't depend on map order
+func mostVoted(votes map[string]int) string {
+	winner := ""
+	for runID, count := range votes {
+		if winner == "" || count > votes[winner] || (count == votes[winner] && runID < winner) {
+			winner = runID
+		}
+	}
+	return winner
+}
+
+// Caller holds mut
+func (c *controller) handleFailover(m *master, now time.Time) {
+	switch m.failoverState {
+	case FAILOVER_STATE_NONE:
+		c.startFailoverIfNeeded(m, now)
+	case FAILOVER_STATE_WAIT_START:
+		c.waitFailoverStart(m, now)
+	case FAILOVER_STATE_SELECT_REPLICA:
+		c.selectReplicaForPromotion(m, now)
+	case FAILOVER_STATE_SEND_REPLICAOF_NOONE, FAILOVER_STATE_WAIT_PROMOTION:
+		if now.Sub(m.failoverStateChangedAt) > c.failoverTimeout() {
+			c.abortFailover(m, "-failover-abort-slave-timeout")
+		}
+	}
+}
+
+// Failover isn't retried for twice failover timeout, start time is shifted randomly, so sentinels retry at different times
+// Caller holds mut
+func (c *controller) startFailoverIfNeeded(m *master, now time.Time) {
+	if !m.isObjectivelyDown() || now.Sub(m.failoverStartedAt) < 2*c.failoverTimeout() {
+		return
+	}
+
+	c.updateCurrentEpoch(c.currentEpoch + 1)
+	m.failoverEpoch = c.currentEpoch
+	m.failoverStartedAt = now.Add(randomDesync())
+	m.setFailoverState(FAILOVER_STATE_WAIT_START)
+	c.event("+try-failover", m.describe(m.instance))
+
+	// Sentinels are asked for votes right away
+	for _, s := range m.sentinels {
+		s.lastAskAt = time.Time{}
+	}
+}
+
+// Caller holds mut
+func (c *controller) waitFailoverStart(m *master, now time.Time) {
+	if !m.isSubjectivelyDown() {
+		c.abortFailover(m, "-failover-abort-master-is-back")
+		return
+	}
+
+	leader := c.getLeader(m, m.failoverEpoch)
+	if leader != c.runID {
+		if now.Sub(m.failoverStartedAt) > min(SENTINEL_ELECTION_TIMEOUT, c.failoverTimeout()) {
+			c.abortFailover(m, "-failover-abort-not-elected")
+		}
+		return
+	}
+
+	c.event("+elected-leader", m.describe(m.instance))
+	m.setFailoverState(FAILOVER_STATE_SELECT_REPLICA)
+	c.event("+failover-state-select-slave", m.describe(m.instance))
+}
+
+// Selected replica is promoted with REPLICAOF NO ONE, if it fails, replica is selected again until failover timeout
+// Replicas can have stale INFO right after master is down, so good replica is waited for until failover timeout too
+// Caller holds mut
+func (c *controller) selectReplicaForPromotion(m *master, now time.Time) {
+	replica := c.selectReplica(m, now)
+	if replica == nil {
+		if now.Sub(m.failoverStartedAt) > c.failoverTimeout() {
+			c.abortFailover(m, "-failover-abort-no-good-slave")
+		}
+		return
+	}
+
+	m.promoted = replica
+	c.event("+selected-slave", m.describe(replica))
+	m.setFailoverState(FAILOVER_STATE_SEND_REPLICAOF_NOONE)
+	c.event("+failover-state-send-slaveof-noone", m.describe(replica))
+	go c.promoteReplica(m, replica)
+}
+
+// Replica is good, when it is up, its INFO is fresh and its link with master hasn't been down for too long
+// The best replica has the greatest replication offset, ties are broken by the least address
+// Caller holds mut
+func (c *controller) selectReplica(m *master, now time.Time) *instance {
+	maxLinkDownFor := 10 * c.downAfter()
+	if m.isSubjectivelyDown() {
+		maxLinkDownFor += now.Sub(m.sdownSince)
+	}
+
+	candidates := make([]*instance, 0, len(m.replicas))
+	for _, replica := range m.replicas {
+		if replica.isSubjectivelyDown() || replica.role != INSTANCE_KIND_REPLICA {
+			continue
+		}
+		if now.Sub(replica.infoRefreshedAt) > 5*SENTINEL_FAST_INFO_PERIOD || replica.masterLinkDownFor > maxLinkDownFor {
+			continue
+		}
+		candidates = append(candidates, replica)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].replOffset != candidates[j].replOffset {
+			return candidates[i].replOffset > candidates[j].replOffset
+		}
+		return candidates[i].addr() < candidates[j].addr()
+	})
+	return candidates[0]
+}
+
+func (c *controller) promoteReplica(m *master, replica *instance) {
+	err := c.sendReplicaOf(replica.addr(), "NO", "ONE")
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if m.promoted != replica || m.failoverState != FAILOVER_STATE_SEND_REPLICAOF_NOONE {
+		return
+	}
+	if err != nil {
+		m.promoted = nil
+		m.setFailoverState(FAILOVER_STATE_SELECT_REPLICA)
+		return
+	}
+	m.setFailoverState(FAILOVER_STATE_WAIT_PROMOTION)
+	c.event("+failover-state-wait-promotion", m.describe(replica))
+}
+
+// Promoted replica has reported master role, other replicas are pointed to it and it becomes monitored master
+// Config epoch is the failover epoch, so other sentinels accept the new config from hello messages
+// Caller holds mut
+func (c *controller) finishFailover(m *master) {
+	promoted := m.promoted
+	c.event("+promoted-slave", m.describe(promoted))
+	m.configEpoch = m.failoverEpoch
+
+	c.event("+failover-state-reconf-slaves", m.describe(m.instance))
+	for _, replica := range m.replicas {
+		if replica == promoted {
+			continue
+		}
+		c.event("+slave-reconf-sent", m.describe(replica))
+		go c.sendReplicaOf(replica.addr(), promoted.host, strconv.Itoa(promoted.port))
+	}
+
+	c.event("+failover-end", m.describe(m.instance))
+	c.switchMaster(m, promoted.host, promoted.port)
+}
+
+// Caller holds mut
+func (c *controller) abortFailover(m *master, reason string) {
+	c.event(reason, m.describe(m.instance))
+	m.promoted = nil
+	m.setFailoverState(FAILOVER_STATE_NONE)
+}
+
+func (c *controller) downAfter() time.Duration {
+	return time.Duration(c.args.SentinelDownAfter) * time.Millisecond
+}
+
+func (c *controller) failoverTimeout() time.Duration {
+	return time.Duration(c.args.SentinelFailoverTimeout) * time.Millisecond
+}
+
+func randomDesync() time.Duration {
+	return time.Duration(rand.Int63n(int64(SENTINEL_MAX_DESYNC)))
+}
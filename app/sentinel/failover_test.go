@@ -0,0 +1,235 @@
+package sentinel
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Controller isn't started, so instances aren't monitored and state is changed only by tests
+func newTestController(quorum int) (*controller, *master) {
+	args := &config.Args{
+		Host:                    "127.0.0.1",
+		Port:                    26379,
+		SentinelMonitors:        []config.SentinelMonitor{{Name: "mymaster", Host: "127.0.0.1", Port: 6379, Quorum: quorum}},
+		SentinelDownAfter:       1000,
+		SentinelFailoverTimeout: 10000,
+	}
+	c := newController(args, resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN), pubsub.NewController())
+	return c, c.masters["mymaster"]
+}
+
+func addTestSentinel(c *controller, m *master, runID string, port int) *instance {
+	s := newInstance(INSTANCE_KIND_SENTINEL, "127.0.0.1", port, c.respController)
+	s.runID = runID
+	m.sentinels[runID] = s
+	return s
+}
+
+func TestVoteLeader(t *testing.T) {
+	tests := []struct {
+		Name                string
+		CurrentEpoch        int
+		LeaderEpoch         int
+		Epoch               int
+		ExpectedLeader      string
+		ExpectedLeaderEpoch int
+	}{
+		{Name: "First vote in epoch", CurrentEpoch: 0, LeaderEpoch: 0, Epoch: 1, ExpectedLeader: "candidate", ExpectedLeaderEpoch: 1},
+		{Name: "Already voted in epoch", CurrentEpoch: 1, LeaderEpoch: 1, Epoch: 1, ExpectedLeader: "previous", ExpectedLeaderEpoch: 1},
+		{Name: "Epoch of request is old", CurrentEpoch: 3, LeaderEpoch: 1, Epoch: 2, ExpectedLeader: "previous", ExpectedLeaderEpoch: 1},
+		{Name: "Epoch of request is new", CurrentEpoch: 2, LeaderEpoch: 1, Epoch: 5, ExpectedLeader: "candidate", ExpectedLeaderEpoch: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			c, m := newTestController(2)
+			c.currentEpoch = test.CurrentEpoch
+			m.leader = "previous"
+			m.leaderEpoch = test.LeaderEpoch
+
+			leader, leaderEpoch := c.voteLeader(m, test.Epoch, "candidate")
+			assert.Equal(t, test.ExpectedLeader, leader)
+			assert.Equal(t, test.ExpectedLeaderEpoch, leaderEpoch)
+			assert.Equal(t, max(test.CurrentEpoch, test.Epoch), c.currentEpoch)
+			// Sentinel, that has voted for other one, postpones its own failover
+			assert.Equal(t, test.ExpectedLeader == "candidate", !m.failoverStartedAt.IsZero())
+		})
+	}
+}
+
+func TestGetLeader(t *testing.T) {
+	tests := []struct {
+		Name string
+		// Votes of other sentinels in epoch 1, empty vote means no reply
+		Votes    []string
+		Quorum   int
+		Expected string
+	}{
+		{Name: "No replies", Votes: []string{"", "", "", ""}, Quorum: 2, Expected: ""},
+		{Name: "Majority for this sentinel", Votes: []string{"self", "self", "", ""}, Quorum: 2, Expected: "self"},
+		{Name: "Majority for other sentinel", Votes: []string{"other", "other", "other", ""}, Quorum: 2, Expected: "other"},
+		{Name: "Votes are split", Votes: []string{"other", "third", "", ""}, Quorum: 2, Expected: ""},
+		{Name: "Majority is less than quorum", Votes: []string{"self", "self", "", ""}, Quorum: 4, Expected: ""},
+		{Name: "This sentinel votes for the most voted one", Votes: []string{"other", "other", "", ""}, Quorum: 2, Expected: "other"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			c, m := newTestController(test.Quorum)
+			c.currentEpoch = 1
+			for n, vote := range test.Votes {
+				s := addTestSentinel(c, m, "sentinel"+strconv.Itoa(n), 26380+n)
+				if vote == "self" {
+					vote = c.runID
+				}
+				if vote != "" {
+					s.leader = vote
+					s.leaderEpoch = 1
+				}
+			}
+
+			expected := test.Expected
+			if expected == "self" {
+				expected = c.runID
+			}
+			assert.Equal(t, expected, c.getLeader(m, 1))
+			assert.Equal(t, 1, m.leaderEpoch)
+		})
+	}
+}
+
+func TestSelectReplica(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		Name     string
+		Replicas []*instance
+		Expected string
+	}{
+		{
+			Name: "The greatest offset",
+			Replicas: []*instance{
+				{host: "127.0.0.1", port: 6380, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now, replOffset: 10},
+				{host: "127.0.0.1", port: 6381, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now, replOffset: 20},
+			},
+			Expected: "127.0.0.1:6381",
+		},
+		{
+			Name: "The least address with the same offset",
+			Replicas: []*instance{
+				{host: "127.0.0.1", port: 6381, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now, replOffset: 10},
+				{host: "127.0.0.1", port: 6380, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now, replOffset: 10},
+			},
+			Expected: "127.0.0.1:6380",
+		},
+		{
+			Name: "Down, stale and disconnected replicas are skipped",
+			Replicas: []*instance{
+				{host: "127.0.0.1", port: 6380, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now, replOffset: 30, sdownSince: now},
+				{host: "127.0.0.1", port: 6381, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now.Add(-time.Minute), replOffset: 30},
+				{host: "127.0.0.1", port: 6382, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now, replOffset: 30, masterLinkDownFor: time.Hour},
+				{host: "127.0.0.1", port: 6383, role: INSTANCE_KIND_MASTER, infoRefreshedAt: now, replOffset: 30},
+				{host: "127.0.0.1", port: 6384, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now, replOffset: 10},
+			},
+			Expected: "127.0.0.1:6384",
+		},
+		{
+			Name: "No good replicas",
+			Replicas: []*instance{
+				{host: "127.0.0.1", port: 6380, role: INSTANCE_KIND_REPLICA, infoRefreshedAt: now, sdownSince: now},
+			},
+			Expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			c, m := newTestController(2)
+			m.sdownSince = now
+			for _, replica := range test.Replicas {
+				m.replicas[replica.addr()] = replica
+			}
+
+			got := c.selectReplica(m, now)
+			if test.Expected == "" {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equal(t, test.Expected, got.addr())
+		})
+	}
+}
+
+func TestCheckObjectivelyDown(t *testing.T) {
+	now := time.Now()
+	c, m := newTestController(2)
+	other := addTestSentinel(c, m, "other", 26380)
+
+	// Master isn't down for this sentinel
+	other.masterDown = true
+	other.masterDownReplyAt = now
+	c.checkObjectivelyDown(m, now)
+	assert.False(t, m.isObjectivelyDown())
+
+	// Other sentinel agrees, so quorum is reached
+	m.sdownSince = now
+	c.checkObjectivelyDown(m, now)
+	assert.True(t, m.isObjectivelyDown())
+
+	// Reply of other sentinel is too old
+	c.checkObjectivelyDown(m, now.Add(6*SENTINEL_ASK_PERIOD))
+	assert.False(t, m.isObjectivelyDown())
+}
+
+func TestProcessHello(t *testing.T) {
+	c, m := newTestController(2)
+	m.replicas["127.0.0.1:6380"] = newInstance(INSTANCE_KIND_REPLICA, "127.0.0.1", 6380, c.respController)
+	m.replicas["127.0.0.1:6381"] = newInstance(INSTANCE_KIND_REPLICA, "127.0.0.1", 6381, c.respController)
+	defer func() {
+		m.instance.stop()
+		for _, replica := range m.replicas {
+			replica.stop()
+		}
+	}()
+
+	// Own hello and hello of other master are ignored
+	c.processHello(m, (&hello{host: "127.0.0.1", port: 26379, runID: c.runID, masterName: "mymaster", masterHost: "127.0.0.1", masterPort: 6379}).String())
+	c.processHello(m, (&hello{host: "127.0.0.1", port: 26380, runID: "other", masterName: "othermaster", masterHost: "127.0.0.1", masterPort: 7000}).String())
+	assert.Empty(t, m.sentinels)
+
+	// Sentinel is discovered and its current epoch is accepted
+	c.processHello(m, (&hello{host: "127.0.0.1", port: 26380, runID: "other", currentEpoch: 2, masterName: "mymaster", masterHost: "127.0.0.1", masterPort: 6379}).String())
+	assert.Contains(t, m.sentinels, "other")
+	assert.Equal(t, 2, c.currentEpoch)
+	assert.Equal(t, "127.0.0.1:6379", m.addr())
+
+	// Restarted sentinel with the new run ID replaces the old one
+	c.processHello(m, (&hello{host: "127.0.0.1", port: 26380, runID: "restarted", currentEpoch: 2, masterName: "mymaster", masterHost: "127.0.0.1", masterPort: 6379}).String())
+	assert.NotContains(t, m.sentinels, "other")
+	assert.Contains(t, m.sentinels, "restarted")
+
+	// Config of the greater epoch switches master, old master becomes replica
+	c.processHello(m, (&hello{host: "127.0.0.1", port: 26380, runID: "restarted", currentEpoch: 2, masterName: "mymaster", masterHost: "127.0.0.1", masterPort: 6380, masterConfigEpoch: 2}).String())
+	assert.Equal(t, "127.0.0.1:6380", m.addr())
+	assert.Equal(t, 2, m.configEpoch)
+	assert.ElementsMatch(t, []string{"127.0.0.1:6379", "127.0.0.1:6381"}, keys(m.replicas))
+
+	// Config of the same epoch is ignored
+	c.processHello(m, (&hello{host: "127.0.0.1", port: 26380, runID: "restarted", currentEpoch: 2, masterName: "mymaster", masterHost: "127.0.0.1", masterPort: 6381, masterConfigEpoch: 2}).String())
+	assert.Equal(t, "127.0.0.1:6380", m.addr())
+}
+
+func keys(instances map[string]*instance) []string {
+	result := make([]string, 0, len(instances))
+	for key := range instances {
+		result = append(result, key)
+	}
+	return result
+}
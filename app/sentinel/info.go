@@ -0,0 +1,123 @@
+package sentinel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields of INFO replication of master or replica, that sentinel uses
+type replicationInfo struct {
+	role string
+	// Fields below are filled only for replica
+	masterHost        string
+	masterPort        int
+	masterLinkStatus  string
+	masterLinkDownFor time.Duration
+	replOffset        int
+	// Replicas of master, they are added to monitored instances
+	replicas []replicaAddr
+}
+
+type replicaAddr struct {
+	host string
+	port int
+}
+
+// Unknown and malformed lines are skipped, so sentinel works with any INFO, that has role
+func parseReplicationInfo(s string) (*replicationInfo, error) {
+	info := &replicationInfo{}
+	for _, line := range strings.Split(s, "\r\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "role":
+			info.role = value
+		case "master_host":
+			info.masterHost = value
+		case "master_port":
+			info.masterPort, _ = strconv.Atoi(value)
+		case "master_link_status":
+			info.masterLinkStatus = value
+		case "master_link_down_since_seconds":
+			// It is -1, if replica has never been connected to master
+			seconds, _ := strconv.Atoi(value)
+			info.masterLinkDownFor = time.Duration(max(seconds, 0)) * time.Second
+		case "slave_repl_offset":
+			info.replOffset, _ = strconv.Atoi(value)
+		default:
+			if strings.HasPrefix(key, "slave") {
+				if replica, ok := parseReplicaAddr(value); ok {
+					info.replicas = append(info.replicas, replica)
+				}
+			}
+		}
+	}
+
+	if info.role != INSTANCE_KIND_MASTER && info.role != INSTANCE_KIND_REPLICA {
+		return nil, fmt.Errorf("unknown role in INFO replication: %q", info.role)
+	}
+	return info, nil
+}
+
+// Replica line looks like: ip=127.0.0.1,port=6380,state=online,offset=100,lag=0
+func parseReplicaAddr(value string) (replicaAddr, bool) {
+	var replica replicaAddr
+	for _, field := range strings.Split(value, ",") {
+		key, fieldValue, _ := strings.Cut(field, "=")
+		switch key {
+		case "ip":
+			replica.host = fieldValue
+		case "port":
+			replica.port, _ = strconv.Atoi(fieldValue)
+		}
+	}
+	return replica, replica.host != "" && replica.port > 0
+}
+
+// Sentinel publishes its address, run ID and current epoch with the master config, like in Redis
+type hello struct {
+	host              string
+	port              int
+	runID             string
+	currentEpoch      int
+	masterName        string
+	masterHost        string
+	masterPort        int
+	masterConfigEpoch int
+}
+
+func (h *hello) String() string {
+	return fmt.Sprintf("%s,%d,%s,%d,%s,%s,%d,%d", h.host, h.port, h.runID, h.currentEpoch, h.masterName, h.masterHost, h.masterPort, h.masterConfigEpoch)
+}
+
+func parseHello(s string) (*hello, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 8 {
+		return nil, fmt.Errorf("hello message should have 8 fields, got: %d", len(fields))
+	}
+
+	numbers := make([]int, 0, 4)
+	for _, i := range []int{1, 3, 6, 7} {
+		number, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("hello message field %d should be decimal, got: %s", i, fields[i])
+		}
+		numbers = append(numbers, number)
+	}
+
+	return &hello{
+		host:              fields[0],
+		port:              numbers[0],
+		runID:             fields[2],
+		currentEpoch:      numbers[1],
+		masterName:        fields[4],
+		masterHost:        fields[5],
+		masterPort:        numbers[2],
+		masterConfigEpoch: numbers[3],
+	}, nil
+}
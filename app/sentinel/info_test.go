@@ -0,0 +1,91 @@
+package sentinel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+)
+
+func TestParseReplicationInfo(t *testing.T) {
+	tests := []struct {
+		Name          string
+		In            *replication.Info
+		Expected      *replicationInfo
+		ExpectedError bool
+	}{
+		{
+			Name: "Master with replicas",
+			In: &replication.Info{Role: "master", MasterReplID: "id", MasterReplOffset: 10, Slaves: []replication.SlaveInfo{
+				{IP: "127.0.0.1", Port: 6380, State: "online", Offset: 10},
+				{IP: "127.0.0.1", Port: 6381, State: "sync", Lag: 3},
+			}},
+			Expected: &replicationInfo{role: "master", replicas: []replicaAddr{{host: "127.0.0.1", port: 6380}, {host: "127.0.0.1", port: 6381}}},
+		},
+		{
+			Name:     "Replica with link up",
+			In:       &replication.Info{Role: "slave", MasterReplID: "id", MasterReplOffset: 10, MasterHost: "127.0.0.1", MasterPort: 6379, MasterLinkStatus: replication.MASTER_LINK_STATUS_UP},
+			Expected: &replicationInfo{role: "slave", masterHost: "127.0.0.1", masterPort: 6379, masterLinkStatus: "up", replOffset: 10},
+		},
+		{
+			Name:     "Replica with link down",
+			In:       &replication.Info{Role: "slave", MasterReplID: "id", MasterReplOffset: 10, MasterHost: "127.0.0.1", MasterPort: 6379, MasterLinkStatus: replication.MASTER_LINK_STATUS_DOWN, MasterLinkDownSince: time.Now().Add(-3 * time.Second)},
+			Expected: &replicationInfo{role: "slave", masterHost: "127.0.0.1", masterPort: 6379, masterLinkStatus: "down", masterLinkDownFor: 3 * time.Second, replOffset: 10},
+		},
+		{
+			Name:     "Replica never connected",
+			In:       &replication.Info{Role: "slave", MasterReplID: "?", MasterReplOffset: -1, MasterHost: "127.0.0.1", MasterPort: 6379, MasterLinkStatus: replication.MASTER_LINK_STATUS_DOWN},
+			Expected: &replicationInfo{role: "slave", masterHost: "127.0.0.1", masterPort: 6379, masterLinkStatus: "down", replOffset: -1},
+		},
+		{
+			Name:          "Unknown role",
+			In:            &replication.Info{Role: "sentinel"},
+			ExpectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			got, err := parseReplicationInfo(test.In.String())
+			if test.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, got)
+		})
+	}
+}
+
+func TestParseHello(t *testing.T) {
+	tests := []struct {
+		Name          string
+		In            string
+		Expected      *hello
+		ExpectedError bool
+	}{
+		{
+			Name:     "Valid",
+			In:       "127.0.0.1,26379,id,3,mymaster,127.0.0.1,6379,2",
+			Expected: &hello{host: "127.0.0.1", port: 26379, runID: "id", currentEpoch: 3, masterName: "mymaster", masterHost: "127.0.0.1", masterPort: 6379, masterConfigEpoch: 2},
+		},
+		{Name: "Not enough fields", In: "127.0.0.1,26379,id,3,mymaster,127.0.0.1,6379", ExpectedError: true},
+		{Name: "Port isn't decimal", In: "127.0.0.1,port,id,3,mymaster,127.0.0.1,6379,2", ExpectedError: true},
+		{Name: "Config epoch isn't decimal", In: "127.0.0.1,26379,id,3,mymaster,127.0.0.1,6379,epoch", ExpectedError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			got, err := parseHello(test.In)
+			if test.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, got)
+			assert.Equal(t, test.In, got.String())
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package sentinel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+const (
+	INSTANCE_KIND_MASTER   = "master"
+	INSTANCE_KIND_REPLICA  = "slave"
+	INSTANCE_KIND_SENTINEL = "sentinel"
+)
+
+// Master, replica or other sentinel, fields are changed under controller mut
+type instance struct {
+	kind string
+	host string
+	port int
+	// Commands connection, requests of monitoring goroutines and failover are sent through it
+	link *link
+	// The last time, when instance replied to PING, it is the creation time before the first reply
+	lastPingReply time.Time
+	// Zero, if instance isn't subjectively down
+	sdownSince  time.Time
+	lastInfoAt  time.Time
+	lastHelloAt time.Time
+	// Fields below are filled from INFO replication of master and replica
+	role string
+	// The last time, when instance reported the role or the master, that is different from the previous one
+	roleReportedAt   time.Time
+	infoRefreshedAt  time.Time
+	masterHost       string
+	masterPort       int
+	masterLinkStatus string
+	// Zero, if link of replica with its master is up
+	masterLinkDownFor time.Duration
+	replOffset        int
+	// Fields below are filled only for sentinel
+	runID string
+	// The last reply of sentinel to is-master-down-by-addr
+	masterDown        bool
+	masterDownReplyAt time.Time
+	askInProgress     bool
+	lastAskAt         time.Time
+	// Vote of sentinel for failover leader
+	leader      string
+	leaderEpoch int
+	// Closed to stop monitoring, e.g. when master address is switched
+	stopCh chan struct{}
+}
+
+func newInstance(kind, host string, port int, respController resp.Controller) *instance {
+	i := &instance{
+		kind:          kind,
+		host:          host,
+		port:          port,
+		lastPingReply: time.Now(),
+		stopCh:        make(chan struct{}),
+	}
+	i.link = newLink(i.addr(), respController)
+	return i
+}
+
+func (i *instance) addr() string {
+	return net.JoinHostPort(i.host, strconv.Itoa(i.port))
+}
+
+func (i *instance) isStopped() bool {
+	select {
+	case <-i.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (i *instance) stop() {
+	if i.isStopped() {
+		return
+	}
+	close(i.stopCh)
+	i.link.close()
+}
+
+func (i *instance) isSubjectivelyDown() bool {
+	return !i.sdownSince.IsZero()
+}
+
+const (
+	FAILOVER_STATE_NONE = "none"
+	// Failover is started, sentinel waits for votes
+	FAILOVER_STATE_WAIT_START = "wait_start"
+	// Sentinel is elected as leader and selects replica to promote
+	FAILOVER_STATE_SELECT_REPLICA = "select_slave"
+	// REPLICAOF NO ONE is being sent to the selected replica
+	FAILOVER_STATE_SEND_REPLICAOF_NOONE = "send_slaveof_noone"
+	// Selected replica is waited to report master role in INFO
+	FAILOVER_STATE_WAIT_PROMOTION = "wait_promotion"
+)
+
+// Monitored master with its replicas and sentinels, that monitor it too
+type master struct {
+	*instance
+	name   string
+	quorum int
+	// Epoch of the failover, that has set the current master address
+	configEpoch int
+	// Keyed by address
+	replicas map[string]*instance
+	// Keyed by run ID
+	sentinels map[string]*instance
+	// Zero, if master isn't objectively down
+	odownSince time.Time
+	// Vote of this sentinel for failover leader
+	leader      string
+	leaderEpoch int
+	// Failover is done by the sentinel, that is elected as leader in the failover epoch
+	failoverState          string
+	failoverEpoch          int
+	failoverStartedAt      time.Time
+	failoverStateChangedAt time.Time
+	// Replica, that is promoted by failover
+	promoted *instance
+}
+
+func newMaster(monitor config.SentinelMonitor, respController resp.Controller) *master {
+	return &master{
+		instance:      newInstance(INSTANCE_KIND_MASTER, monitor.Host, monitor.Port, respController),
+		name:          monitor.Name,
+		quorum:        monitor.Quorum,
+		replicas:      make(map[string]*instance),
+		sentinels:     make(map[string]*instance),
+		failoverState: FAILOVER_STATE_NONE,
+	}
+}
+
+func (m *master) isObjectivelyDown() bool {
+	return !m.odownSince.IsZero()
+}
+
+func (m *master) isFailoverInProgress() bool {
+	return m.failoverState != FAILOVER_STATE_NONE
+}
+
+func (m *master) setFailoverState(state string) {
+	m.failoverState = state
+	m.failoverStateChangedAt = time.Now()
+}
+
+// Instance is described in events, like in Redis: master by its name and others with the master, they belong to
+// Replica is named by its address and sentinel by its run ID
+func (m *master) describe(i *instance) string {
+	if i == m.instance {
+		return fmt.Sprintf("%s %s %s %d", INSTANCE_KIND_MASTER, m.name, i.host, i.port)
+	}
+	name := i.addr()
+	if i.runID != "" {
+		name = i.runID
+	}
+	return fmt.Sprintf("%s %s %s %d @ %s %s %d", i.kind, name, i.host, i.port, m.name, m.host, m.port)
+}
+
+// Commands connection is dialed on demand and closed on any error, so the next request dials it again
+type link struct {
+	addr           string
+	respController resp.Controller
+	conn           net.Conn
+	buf            []byte
+	closed         bool
+	// Requests are sent one by one, so replies aren't mixed
+	requestMut sync.Mutex
+	// Connection is closed by stop without waiting for the request in progress
+	connMut sync.Mutex
+}
+
+func newLink(addr string, respController resp.Controller) *link {
+	return &link{addr: addr, respController: respController}
+}
+
+func (l *link) request(commandAndArgs ...string) (resp.Value, error) {
+	l.requestMut.Lock()
+	defer l.requestMut.Unlock()
+
+	conn, err := l.getConn()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := l.roundTrip(conn, commandAndArgs)
+	if err != nil {
+		l.closeConn(conn)
+		return nil, fmt.Errorf("%s request to %s error: %v", commandAndArgs[0], l.addr, err)
+	}
+	return value, nil
+}
+
+func (l *link) roundTrip(conn net.Conn, commandAndArgs []string) (resp.Value, error) {
+	err := conn.SetDeadline(time.Now().Add(SENTINEL_REQUEST_TIMEOUT))
+	if err != nil {
+		return nil, err
+	}
+	b, err := resp.CreateBulkStringArray(commandAndArgs...).Encode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	value, rest, err := readValue(conn, l.buf, l.respController)
+	l.buf = rest
+	return value, err
+}
+
+func (l *link) getConn() (net.Conn, error) {
+	l.connMut.Lock()
+	defer l.connMut.Unlock()
+
+	if l.closed {
+		return nil, fmt.Errorf("link to %s is closed", l.addr)
+	}
+	if l.conn != nil {
+		return l.conn, nil
+	}
+	conn, err := net.DialTimeout("tcp", l.addr, SENTINEL_REQUEST_TIMEOUT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %v", l.addr, err)
+	}
+	l.conn = conn
+	l.buf = nil
+	return conn, nil
+}
+
+func (l *link) closeConn(conn net.Conn) {
+	l.connMut.Lock()
+	defer l.connMut.Unlock()
+
+	conn.Close()
+	if l.conn == conn {
+		l.conn = nil
+	}
+}
+
+func (l *link) close() {
+	l.connMut.Lock()
+	defer l.connMut.Unlock()
+
+	l.closed = true
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+}
+
+// Reads from connection, until the buffer contains the whole RESP value, returns the value and the rest of the buffer
+// Invalid data is returned as error, as more bytes can't make it valid
+func readValue(conn net.Conn, buf []byte, respController resp.Controller) (resp.Value, []byte, error) {
+	tmp := make([]byte, 4096)
+	parser := respController.NewParser()
+	for {
+		if len(buf) > 0 {
+			rest, value, err := parser.Decode(buf)
+			if err == nil {
+				return value, rest, nil
+			}
+			if !errors.Is(err, resp.ErrIncomplete) {
+				return nil, nil, err
+			}
+		}
+		n, err := conn.Read(tmp)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf = append(buf, tmp[:n]...)
+	}
+}
@@ -0,0 +1,154 @@
+package sentinel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
+)
+
+const (
+	// Sentinel timer checks instances and drives failover with this period
+	SENTINEL_TIMER_PERIOD = 100 * time.Millisecond
+	SENTINEL_PING_PERIOD  = time.Second
+	// INFO is sent more often, when master is down or failover is in progress
+	SENTINEL_INFO_PERIOD      = 10 * time.Second
+	SENTINEL_FAST_INFO_PERIOD = time.Second
+	// Hello messages are published to master and replicas, so sentinels discover each other and new master config
+	SENTINEL_HELLO_PERIOD  = 2 * time.Second
+	SENTINEL_HELLO_CHANNEL = "__sentinel__:hello"
+	// Other sentinels are asked about down master with this period, their replies are valid for 5 periods
+	SENTINEL_ASK_PERIOD = time.Second
+	// Failover start is delayed randomly up to this time, so sentinels don't split votes all the time
+	SENTINEL_MAX_DESYNC = time.Second
+	// Leader isn't waited for longer than this time (or failover timeout, if it is less)
+	SENTINEL_ELECTION_TIMEOUT = 10 * time.Second
+	SENTINEL_REQUEST_TIMEOUT  = time.Second
+	// Instance with wrong role or master is reconfigured, when it reports it for this time
+	SENTINEL_RECONFIGURE_DELAY = 4 * SENTINEL_HELLO_PERIOD
+)
+
+type Controller interface {
+	// Starts monitoring of configured masters, it doesn't block
+	Start()
+	HandleCommand(cmd resp.Value, conn net.Conn, writeResponseToConn bool) (resp.Value, error)
+}
+
+type controller struct {
+	args             *config.Args
+	respController   resp.Controller
+	pubsubController pubsub.Controller
+	// Sentinels recognize each other by run ID, it is random on every start
+	runID string
+	// The greatest epoch, that sentinel knows about, it is shared by all masters, like in Redis
+	currentEpoch int
+	masters      map[string]*master
+	// Masters, their replicas and sentinels, epochs and failovers are changed together
+	mut sync.Mutex
+}
+
+func NewController(args *config.Args, respController resp.Controller, pubsubController pubsub.Controller) Controller {
+	return newController(args, respController, pubsubController)
+}
+
+func newController(args *config.Args, respController resp.Controller, pubsubController pubsub.Controller) *controller {
+	c := &controller{
+		args:             args,
+		respController:   respController,
+		pubsubController: pubsubController,
+		runID:            generateRunID(),
+		masters:          make(map[string]*master),
+	}
+	for _, monitor := range args.SentinelMonitors {
+		c.masters[monitor.Name] = newMaster(monitor, respController)
+	}
+	return c
+}
+
+func (c *controller) Start() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	log.Printf("Sentinel ID is %s\n", c.runID)
+	for _, m := range c.masters {
+		c.event("+monitor", fmt.Sprintf("%s quorum %d", m.describe(m.instance), m.quorum))
+		c.startMonitoring(m)
+	}
+	go c.startTimer()
+}
+
+func (c *controller) HandleCommand(cmd resp.Value, conn net.Conn, writeResponseToConn bool) (resp.Value, error) {
+	result := c.handleCommand(cmd, conn)
+	if writeResponseToConn && result != nil {
+		err := utils.WriteCommand(result, conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (c *controller) handleCommand(cmd resp.Value, conn net.Conn) resp.Value {
+	var commandAndArgs []string
+	switch cmd := cmd.(type) {
+	case resp.Array:
+		var err error
+		commandAndArgs, err = commands.ExtractCommandAndArgs(cmd.Value)
+		if err != nil {
+			return resp.SimpleError{Value: fmt.Sprintf("extract command and args from RESP array command error: %v", err)}
+		}
+	case resp.SimpleString:
+		commandAndArgs = []string{cmd.Value}
+	default:
+		return resp.SimpleError{Value: "commands must be sent as RESP array or simple string"}
+	}
+	if len(commandAndArgs) == 0 {
+		return resp.SimpleError{Value: "empty RESP command array"}
+	}
+
+	command := strings.ToUpper(commandAndArgs[0])
+	args := commandAndArgs[1:]
+
+	if c.pubsubController.InSubscribeMode(conn) && !c.pubsubController.IsSubscribeModeCommand(command) {
+		return resp.SimpleError{
+			Value: fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", strings.ToLower(command)),
+		}
+	}
+
+	switch command {
+	case "PING":
+		return c.ping(conn)
+	case "INFO":
+		return c.info(args)
+	case "SENTINEL":
+		return c.sentinel(args)
+	case "SUBSCRIBE", "UNSUBSCRIBE":
+		return c.subscribeOrUnsubscribe(command, args, conn)
+	default:
+		return resp.SimpleError{Value: fmt.Sprintf("unknown command '%s' in sentinel mode", commandAndArgs[0])}
+	}
+}
+
+// Sentinel events are logged and published to the channel with the event name, like in Redis
+// Caller holds mut
+func (c *controller) event(name, message string) {
+	log.Printf("%s %s\n", name, message)
+	c.pubsubController.Publish(name, message)
+}
+
+// Run ID is 40 random hex characters, like in Redis
+func generateRunID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,346 @@
+package sentinel
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
+)
+
+// Master and its replicas are pinged, asked for INFO and get hello messages, sentinels are only asked about down master
+// Caller holds mut
+func (c *controller) startMonitoring(m *master) {
+	c.startInstanceMonitoring(m, m.instance)
+	for _, replica := range m.replicas {
+		c.startInstanceMonitoring(m, replica)
+	}
+}
+
+func (c *controller) startInstanceMonitoring(m *master, i *instance) {
+	go c.monitorInstance(m, i)
+	go c.subscribeToHello(m, i)
+}
+
+func (c *controller) monitorInstance(m *master, i *instance) {
+	ticker := time.NewTicker(SENTINEL_PING_PERIOD)
+	defer ticker.Stop()
+
+	for {
+		c.pingInstance(i)
+
+		c.mut.Lock()
+		infoDue := time.Since(i.lastInfoAt) >= c.infoPeriod(m)
+		helloDue := time.Since(i.lastHelloAt) >= SENTINEL_HELLO_PERIOD
+		c.mut.Unlock()
+
+		if infoDue {
+			c.refreshInfo(m, i)
+		}
+		if helloDue {
+			c.sendHello(m, i)
+		}
+
+		select {
+		case <-i.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Master and replicas are asked for INFO more often, while master is down, so failover sees up to date replicas
+// Caller holds mut
+func (c *controller) infoPeriod(m *master) time.Duration {
+	if m.isSubjectivelyDown() || m.isFailoverInProgress() {
+		return SENTINEL_FAST_INFO_PERIOD
+	}
+	return SENTINEL_INFO_PERIOD
+}
+
+// Busy instance replies with error, like -LOADING or -MASTERDOWN, it is still available, like in Redis
+func (c *controller) pingInstance(i *instance) {
+	value, err := i.link.request("PING")
+	if err != nil {
+		return
+	}
+	switch value := value.(type) {
+	case resp.SimpleString:
+		if value.Value != "PONG" {
+			return
+		}
+	case resp.SimpleError:
+	default:
+		return
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	i.lastPingReply = time.Now()
+}
+
+func (c *controller) refreshInfo(m *master, i *instance) {
+	c.mut.Lock()
+	i.lastInfoAt = time.Now()
+	c.mut.Unlock()
+
+	value, err := i.link.request("INFO", "replication")
+	if err != nil {
+		return
+	}
+	bulkString, ok := value.(resp.BulkString)
+	if !ok || bulkString.Value == nil {
+		return
+	}
+	info, err := parseReplicationInfo(*bulkString.Value)
+	if err != nil {
+		log.Printf("INFO of %s error: %v\n", i.addr(), err)
+		return
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if !i.isStopped() {
+		c.processInfo(m, i, info)
+	}
+}
+
+// Caller holds mut
+func (c *controller) processInfo(m *master, i *instance, info *replicationInfo) {
+	now := time.Now()
+	if info.role != i.role || info.masterHost != i.masterHost || info.masterPort != i.masterPort {
+		i.roleReportedAt = now
+	}
+	i.infoRefreshedAt = now
+	i.role = info.role
+	i.masterHost = info.masterHost
+	i.masterPort = info.masterPort
+	i.masterLinkStatus = info.masterLinkStatus
+	i.masterLinkDownFor = info.masterLinkDownFor
+	i.replOffset = info.replOffset
+
+	if i == m.instance && info.role == INSTANCE_KIND_MASTER {
+		c.addReplicas(m, info.replicas)
+	}
+
+	if m.failoverState == FAILOVER_STATE_WAIT_PROMOTION && i == m.promoted && info.role == INSTANCE_KIND_MASTER {
+		c.finishFailover(m)
+		return
+	}
+
+	if i != m.instance {
+		c.reconfigureReplica(m, i)
+	}
+}
+
+// Replicas are never forgotten, so they are still known, when master is down
+// Caller holds mut
+func (c *controller) addReplicas(m *master, replicas []replicaAddr) {
+	for _, replica := range replicas {
+		addr := net.JoinHostPort(replica.host, strconv.Itoa(replica.port))
+		if _, ok := m.replicas[addr]; ok || addr == m.addr() {
+			continue
+		}
+		r := newInstance(INSTANCE_KIND_REPLICA, replica.host, replica.port, c.respController)
+		m.replicas[addr] = r
+		c.event("+slave", m.describe(r))
+		c.startInstanceMonitoring(m, r)
+	}
+}
+
+// Replica, that reports master role (e.g. old master, that is back after failover) or other master, is pointed to the current master
+// It is done only when master is up and configuration is stable for some time, so replica isn't reconfigured during failover
+// Caller holds mut
+func (c *controller) reconfigureReplica(m *master, i *instance) {
+	if m.isFailoverInProgress() || m.isSubjectivelyDown() || time.Since(i.roleReportedAt) < SENTINEL_RECONFIGURE_DELAY {
+		return
+	}
+
+	switch {
+	case i.role == INSTANCE_KIND_MASTER:
+		c.event("+convert-to-slave", m.describe(i))
+	case i.role == INSTANCE_KIND_REPLICA && (i.masterHost != m.host || i.masterPort != m.port):
+		c.event("+fix-slave-config", m.describe(i))
+	default:
+		return
+	}
+	// Replica isn't reconfigured again, until it reports the new configuration or the delay passes
+	i.roleReportedAt = time.Now()
+	go c.sendReplicaOf(i.addr(), m.host, strconv.Itoa(m.port))
+}
+
+// Separate connection is used, so the command is sent, even if the instance is stopped by master switch
+func (c *controller) sendReplicaOf(addr, masterHost, masterPort string) error {
+	l := newLink(addr, c.respController)
+	defer l.close()
+
+	value, err := l.request("REPLICAOF", masterHost, masterPort)
+	if err == nil {
+		if simpleError, ok := value.(resp.SimpleError); ok {
+			err = fmt.Errorf("REPLICAOF %s %s to %s error: %s", masterHost, masterPort, addr, simpleError.Value)
+		}
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+	}
+	return err
+}
+
+func (c *controller) sendHello(m *master, i *instance) {
+	c.mut.Lock()
+	i.lastHelloAt = time.Now()
+	h := &hello{
+		host:              c.args.Host,
+		port:              c.args.Port,
+		runID:             c.runID,
+		currentEpoch:      c.currentEpoch,
+		masterName:        m.name,
+		masterHost:        m.host,
+		masterPort:        m.port,
+		masterConfigEpoch: m.configEpoch,
+	}
+	c.mut.Unlock()
+
+	i.link.request("PUBLISH", SENTINEL_HELLO_CHANNEL, h.String())
+}
+
+// Hello messages of other sentinels are received from master and replicas, so they are received after failover too
+func (c *controller) subscribeToHello(m *master, i *instance) {
+	for !i.isStopped() {
+		c.readHello(m, i)
+
+		select {
+		case <-i.stopCh:
+			return
+		case <-time.After(SENTINEL_PING_PERIOD):
+		}
+	}
+}
+
+func (c *controller) readHello(m *master, i *instance) error {
+	conn, err := net.DialTimeout("tcp", i.addr(), SENTINEL_REQUEST_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-i.stopCh:
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	err = utils.WriteCommand(resp.CreateBulkStringArray("SUBSCRIBE", SENTINEL_HELLO_CHANNEL), conn)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	for {
+		value, rest, err := readValue(conn, buf, c.respController)
+		if err != nil {
+			return err
+		}
+		buf = rest
+
+		message, ok := value.(resp.Array)
+		if !ok {
+			continue
+		}
+		fields, err := commands.ExtractCommandAndArgs(message.Value)
+		if err != nil || len(fields) != 3 || fields[0] != "message" {
+			continue
+		}
+
+		c.mut.Lock()
+		if !i.isStopped() {
+			c.processHello(m, fields[2])
+		}
+		c.mut.Unlock()
+	}
+}
+
+// Caller holds mut
+func (c *controller) processHello(m *master, message string) {
+	h, err := parseHello(message)
+	if err != nil {
+		log.Printf("Hello message error: %v\n", err)
+		return
+	}
+	if h.runID == c.runID || h.masterName != m.name {
+		return
+	}
+
+	s, ok := m.sentinels[h.runID]
+	if !ok {
+		// Sentinel, restarted with the new run ID, replaces the old one with the same address
+		for runID, other := range m.sentinels {
+			if other.host == h.host && other.port == h.port {
+				other.stop()
+				delete(m.sentinels, runID)
+			}
+		}
+		s = newInstance(INSTANCE_KIND_SENTINEL, h.host, h.port, c.respController)
+		s.runID = h.runID
+		m.sentinels[h.runID] = s
+		c.event("+sentinel", m.describe(s))
+	}
+	s.lastHelloAt = time.Now()
+
+	c.updateCurrentEpoch(h.currentEpoch)
+	if h.masterConfigEpoch <= m.configEpoch {
+		return
+	}
+	// Master config of the greater epoch is the result of the later failover
+	m.configEpoch = h.masterConfigEpoch
+	if h.masterHost != m.host || h.masterPort != m.port {
+		c.event("+config-update-from", m.describe(s))
+		c.switchMaster(m, h.masterHost, h.masterPort)
+	}
+}
+
+// Caller holds mut
+func (c *controller) updateCurrentEpoch(epoch int) {
+	if epoch > c.currentEpoch {
+		c.currentEpoch = epoch
+		c.event("+new-epoch", strconv.Itoa(epoch))
+	}
+}
+
+// Promoted replica becomes monitored master, other replicas and the old master become its replicas
+// Caller holds mut
+func (c *controller) switchMaster(m *master, host string, port int) {
+	oldHost, oldPort := m.host, m.port
+	replicas := make([]replicaAddr, 0, len(m.replicas)+1)
+	for _, replica := range m.replicas {
+		if replica.host != host || replica.port != port {
+			replicas = append(replicas, replicaAddr{host: replica.host, port: replica.port})
+		}
+		replica.stop()
+	}
+	replicas = append(replicas, replicaAddr{host: oldHost, port: oldPort})
+	m.instance.stop()
+
+	m.instance = newInstance(INSTANCE_KIND_MASTER, host, port, c.respController)
+	m.replicas = make(map[string]*instance, len(replicas))
+	for _, replica := range replicas {
+		r := newInstance(INSTANCE_KIND_REPLICA, replica.host, replica.port, c.respController)
+		m.replicas[r.addr()] = r
+	}
+	m.odownSince = time.Time{}
+	m.promoted = nil
+	m.failoverStartedAt = time.Time{}
+	m.setFailoverState(FAILOVER_STATE_NONE)
+	for _, s := range m.sentinels {
+		s.masterDown = false
+	}
+
+	c.event("+switch-master", fmt.Sprintf("%s %s %d %s %d", m.name, oldHost, oldPort, host, port))
+	c.startMonitoring(m)
+}
@@ -6,12 +6,22 @@ import (
 	"io"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/client"
+	"github.com/codecrafters-io/redis-starter-go/app/cluster"
 	"github.com/codecrafters-io/redis-starter-go/app/commands"
 	"github.com/codecrafters-io/redis-starter-go/app/config"
 	"github.com/codecrafters-io/redis-starter-go/app/geo"
 	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/migration"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/aof"
 	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
 	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
@@ -19,6 +29,25 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/utils"
 )
 
+// Size of client read buffer, like PROTO_IOBUF_LEN in Redis
+const READ_BUFFER_SIZE = 16 * 1024
+
+// Active expire cycle runs with this period, like with hz 10 in Redis
+const ACTIVE_EXPIRE_CYCLE_PERIOD = 100 * time.Millisecond
+
+// Read buffers are reused by next connections, so many short connections don't allocate them
+var readBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, READ_BUFFER_SIZE)
+		return &buf
+	},
+}
+
+// Client commands are handled by commands controller or, in sentinel mode, by sentinel controller
+type clientHandler interface {
+	HandleCommand(cmd resp.Value, conn net.Conn, writeResponseToConn bool) (resp.Value, error)
+}
+
 type base struct {
 	args                  *config.Args
 	storage               memory.MultiTypeStorage
@@ -27,20 +56,98 @@ type base struct {
 	transactionController transaction.Controller
 	commandController     commands.Controller
 	geoController         geo.Controller
+	rdbController         rdb.Controller
+	aofController         aof.Controller
+	clusterController     cluster.Controller
+	migrationController   migration.Controller
+	clientHandler         clientHandler
 }
 
 func newBase(args *config.Args) *base {
+	storage := memory.NewMultiTypeStorage()
+	respController := resp.NewController(args.ProtoMaxBulkLen)
 	return &base{
 		args:                  args,
-		storage:               memory.NewMultiTypeStorage(),
-		respController:        resp.NewController(),
+		storage:               storage,
+		respController:        respController,
 		pubsubController:      pubsub.NewController(),
 		transactionController: transaction.NewController(),
 		geoController:         geo.NewController(),
+		rdbController:         rdb.NewController(args, storage),
+		aofController:         aof.NewController(args, storage),
+		clusterController:     cluster.NewController(args, respController, storage),
+		migrationController:   migration.NewController(respController),
 	}
 }
 
 func (base *base) initStorage() {
+	base.loadStorage()
+	base.rdbController.ResetDirty()
+	base.rdbController.StartSaveRules()
+	if !base.args.AppendOnly {
+		return
+	}
+
+	err := base.aofController.Open()
+	if err != nil {
+		log.Fatalf("AOF open error: %v\n", err)
+	}
+}
+
+// On SIGINT or SIGTERM the final RDB snapshot is saved (if save rules are configured) and AOF is fsynced
+func (base *base) handleShutdownSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	log.Printf("Received %s, shutting down\n", sig)
+
+	exitCode := 0
+	if err := base.rdbController.Close(); err != nil {
+		log.Printf("Final RDB save error: %v\n", err)
+		exitCode = 1
+	}
+	if err := base.aofController.Close(); err != nil {
+		log.Printf("AOF close error: %v\n", err)
+		exitCode = 1
+	}
+	os.Exit(exitCode)
+}
+
+// AOF has priority over RDB file, because it is usually more up to date
+func (base *base) loadStorage() {
+	if !base.args.AppendOnly {
+		base.initStorageWithRDBFile()
+		return
+	}
+
+	// Replayed commands are handled as if they are sent by a separate client
+	conn, peerConn := net.Pipe()
+	defer conn.Close()
+	defer peerConn.Close()
+
+	loaded, err := base.aofController.Load(func(commandAndArgs []string) {
+		base.replayAOFCommand(commandAndArgs, conn)
+	})
+	if err != nil {
+		log.Fatalf("AOF load error: %v\n", err)
+	}
+	if !loaded {
+		base.initStorageWithRDBFile()
+	}
+}
+
+func (base *base) replayAOFCommand(commandAndArgs []string, conn net.Conn) {
+	result, err := base.commandController.HandleCommand(resp.CreateBulkStringArray(commandAndArgs...), conn, false)
+	if err != nil {
+		log.Printf("AOF replay command %q error: %v\n", commandAndArgs, err)
+		return
+	}
+	if simpleError, ok := result.(resp.SimpleError); ok {
+		log.Printf("AOF replay command %q error: %s\n", commandAndArgs, simpleError.Value)
+	}
+}
+
+func (base *base) initStorageWithRDBFile() {
 	if base.args.DBDir == "" || base.args.DBFilename == "" {
 		return
 	}
@@ -67,23 +174,15 @@ func (base *base) processRDBFile(b []byte) {
 		return
 	}
 
-	items, err := rdb.Decode(b)
+	snapshot, err := rdb.Decode(b)
 	if err != nil {
 		log.Printf("Skip RDB storage seed, RDB decode error: %v\n", err)
 		return
 	}
-	base.putRDBStringItemsIntoStorage(items)
-}
 
-func (base *base) putRDBStringItemsIntoStorage(items map[string]memory.String) {
-	for key, item := range items {
-		if base.storage.StringStorage().ItemHasExpiration(&item) {
-			if !base.storage.StringStorage().ItemExpired(&item) {
-				base.storage.StringStorage().SetWithExpiry(key, item.Value, item.Expires)
-			}
-		} else {
-			base.storage.StringStorage().Set(key, item.Value)
-		}
+	err = base.storage.Restore(snapshot)
+	if err != nil {
+		log.Printf("RDB storage seed error: %v\n", err)
 	}
 }
 
@@ -97,17 +196,35 @@ func (base *base) listenTCP() net.Listener {
 	return listener
 }
 
+func (base *base) acceptClientConnections(listener net.Listener, handleConn func(conn net.Conn)) {
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("Error accepting connection: %v\n", err)
+			continue
+		}
+		handleConn(client.NewConn(conn))
+	}
+}
+
 func (base *base) handleClient(initialBuffer []byte, conn net.Conn, writeResponseToConn bool) {
 	defer conn.Close()
 
-	buf := make([]byte, 0, 4096)
-	if initialBuffer != nil {
-		buf = append(buf, initialBuffer...)
-	}
-	tmp := make([]byte, 1024)
+	// Buffer, that is grown by big command, isn't pooled, only the initial one is put back
+	bufPtr := readBufferPool.Get().(*[]byte)
+	defer readBufferPool.Put(bufPtr)
+	buf := append((*bufPtr)[:0], initialBuffer...)
+	cc, batched := conn.(*client.Conn)
+	parser := base.respController.NewParser()
 
 	for {
-		n, err := conn.Read(tmp)
+		// Big command is read into grown buffer, the rest of read data is kept in its beginning
+		if len(buf) == cap(buf) {
+			buf = slices.Grow(buf, len(buf))
+		}
+		n, err := conn.Read(buf[len(buf):cap(buf)])
 		if err != nil {
 			addr := utils.GetRemoteAddr(conn)
 			if errors.Is(err, io.EOF) {
@@ -118,34 +235,85 @@ func (base *base) handleClient(initialBuffer []byte, conn net.Conn, writeRespons
 			log.Printf("Connection %s read error: %v", addr, err)
 			return
 		}
-		buf = append(buf, tmp[:n]...)
-		buf = base.processCommands(buf, conn, writeResponseToConn)
+		buf = buf[:len(buf)+n]
+
+		// Replies to all commands of read data are written at once
+		if batched {
+			cc.StartBatch()
+		}
+		var rest []byte
+		rest, err = base.processCommands(buf, parser, conn, writeResponseToConn)
+		if batched {
+			if flushErr := cc.EndBatch(); err == nil {
+				err = flushErr
+			}
+		}
+		if err != nil {
+			log.Printf("Connection %s closed: %v", utils.GetRemoteAddr(conn), err)
+			base.pubsubController.UnsubscribeFromAllChannels(conn)
+			return
+		}
+		buf = buf[:copy(buf, rest)]
+		if len(buf) == 0 && cap(buf) > READ_BUFFER_SIZE {
+			buf = (*bufPtr)[:0]
+		}
 	}
 }
 
-func (base *base) processCommands(buf []byte, conn net.Conn, writeResponseToConn bool) []byte {
+// Incomplete command is left in buffer, until the rest of it is read, protocol error is replied and connection is closed, like in Redis
+// Parser keeps scan state of incomplete command, so command, that is read by chunks, is scanned once
+func (base *base) processCommands(buf []byte, parser resp.Parser, conn net.Conn, writeResponseToConn bool) ([]byte, error) {
 	for len(buf) > 0 {
-		rest, value, err := base.respController.Decode(buf)
+		rest, value, err := parser.Decode(buf)
+		if errors.Is(err, resp.ErrIncomplete) {
+			return buf, nil
+		}
 		if err != nil {
-			log.Printf("RESP controller decode error: %v", err)
-			return buf
+			if writeResponseToConn {
+				utils.WriteCommand(resp.SimpleError{Value: fmt.Sprintf("ERR %v", err)}, conn)
+			}
+			return nil, err
 		}
 
-		_, err = base.commandController.HandleCommand(value, conn, writeResponseToConn)
+		err = base.handleClientCommand(value, conn, writeResponseToConn)
 		if err != nil {
 			log.Printf("handle command error: %v, continue to work", err)
 		}
 
 		buf = rest
 	}
-	return buf
+	return buf, nil
+}
+
+// Command with unexpected args, e.g. typed inline, can't crash the server, client gets error reply instead
+func (base *base) handleClientCommand(value resp.Value, conn net.Conn, writeResponseToConn bool) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Command %v panic: %v\n%s", value, r, debug.Stack())
+			if writeResponseToConn {
+				err = utils.WriteCommand(resp.SimpleError{Value: fmt.Sprintf("ERR command failed: %v", r)}, conn)
+			}
+		}
+	}()
+
+	_, err = base.clientHandler.HandleCommand(value, conn, writeResponseToConn)
+	return err
 }
 
-func (base *base) startExpiredStringKeysCleanup() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
+// Replica storage ignores cleanup, expired keys are deleted by master DEL
+// Active expire cycle deletes most expired keys soon, full cleanup finds the rest of them
+func (base *base) startExpiredKeysCleanup() {
+	cleanupTicker := time.NewTicker(1 * time.Hour)
+	defer cleanupTicker.Stop()
+	cycleTicker := time.NewTicker(ACTIVE_EXPIRE_CYCLE_PERIOD)
+	defer cycleTicker.Stop()
 
-	for range ticker.C {
-		base.storage.StringStorage().CleanExpiredKeys()
+	for {
+		select {
+		case <-cleanupTicker.C:
+			base.commandController.CleanExpiredKeys()
+		case <-cycleTicker.C:
+			base.commandController.ActiveExpireCycle()
+		}
 	}
 }
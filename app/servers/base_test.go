@@ -0,0 +1,146 @@
+package servers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/client"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Server handles clients on loopback, like real one, but without persistence, replicas connect to it too
+func startTestServer(tb testing.TB) (*server, string) {
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+	})
+
+	s := newServer(&config.Args{
+		Host:            "127.0.0.1",
+		ReplBacklogSize: 1024 * 1024,
+		ProtoMaxBulkLen: resp.DEFAULT_PROTO_MAX_BULK_LEN,
+	}).(*server)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listen error: %v", err)
+	}
+	tb.Cleanup(func() {
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleClient(nil, client.NewConn(conn), true)
+		}
+	}()
+	return s, listener.Addr().String()
+}
+
+// Reply is read by the parser of connection, so it can be read by chunks
+func sendCommand(t *testing.T, conn net.Conn, parser resp.Parser, args ...string) resp.Value {
+	command, err := resp.CreateBulkStringArray(args...).Encode()
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if _, err := conn.Write(command); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	var buf []byte
+	b := make([]byte, 1024)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		buf = append(buf, b[:n]...)
+		_, value, err := parser.Decode(buf)
+		if !errors.Is(err, resp.ErrIncomplete) {
+			if err != nil {
+				t.Fatalf("reply decode error: %v", err)
+			}
+			return value
+		}
+	}
+}
+
+// Every iteration is one command, commands are sent by pipelines of the given depth, e.g. 10000 commands per write
+func BenchmarkPipeline(b *testing.B) {
+	_, addr := startTestServer(b)
+
+	tests := []struct {
+		command   string
+		valueSize int
+		depth     int
+	}{
+		{command: "SET", valueSize: 16, depth: 1},
+		{command: "SET", valueSize: 16, depth: 100},
+		{command: "SET", valueSize: 16, depth: 10000},
+		{command: "GET", valueSize: 16, depth: 1},
+		{command: "GET", valueSize: 16, depth: 100},
+		{command: "GET", valueSize: 16, depth: 10000},
+		{command: "GET", valueSize: 64 * 1024, depth: 100},
+	}
+
+	for _, test := range tests {
+		b.Run(fmt.Sprintf("%s/value=%d/depth=%d", test.command, test.valueSize, test.depth), func(b *testing.B) {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				b.Fatalf("dial error: %v", err)
+			}
+			defer conn.Close()
+			reader := bufio.NewReaderSize(conn, 64*1024)
+
+			key := fmt.Sprintf("key:%d", test.valueSize)
+			value := strings.Repeat("v", test.valueSize)
+			set, _ := resp.CreateBulkStringArray("SET", key, value).Encode()
+			setReply := "+OK\r\n"
+			if _, err := conn.Write(set); err != nil {
+				b.Fatalf("write error: %v", err)
+			}
+			if _, err := io.ReadFull(reader, make([]byte, len(setReply))); err != nil {
+				b.Fatalf("read error: %v", err)
+			}
+
+			command, reply := set, setReply
+			if test.command == "GET" {
+				command, _ = resp.CreateBulkStringArray("GET", key).Encode()
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			}
+			batch := []byte(strings.Repeat(string(command), test.depth))
+			replies := make([]byte, len(reply)*test.depth)
+
+			b.SetBytes(int64(len(command) + len(reply)))
+			b.ResetTimer()
+			for sent := 0; sent < b.N; sent += test.depth {
+				n := min(test.depth, b.N-sent)
+				// Pipeline is written, while replies are read, so neither side waits for full socket buffer
+				writeErr := make(chan error, 1)
+				go func() {
+					_, err := conn.Write(batch[:n*len(command)])
+					writeErr <- err
+				}()
+				if _, err := io.ReadFull(reader, replies[:n*len(reply)]); err != nil {
+					b.Fatalf("read error: %v", err)
+				}
+				if err := <-writeErr; err != nil {
+					b.Fatalf("write error: %v", err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "cmds/s")
+		})
+	}
+}
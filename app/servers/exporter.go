@@ -0,0 +1,44 @@
+package servers
+
+import (
+	"log"
+
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/export"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+)
+
+// Loads dataset from AOF or RDB file, writes it as RESP command stream and exits without listening
+type exporter struct {
+	*base
+}
+
+func newExporter(args *config.Args) Server {
+	e := &exporter{base: newBase(args)}
+	// Commands controller is needed to replay AOF file
+	e.commandController = commands.NewController(
+		e.args,
+		e.storage,
+		replication.NewMasterController(args),
+		e.pubsubController,
+		e.transactionController,
+		e.geoController,
+		e.rdbController,
+		e.aofController,
+		e.clusterController,
+		e.migrationController,
+		nil,
+	)
+	return e
+}
+
+func (e *exporter) Start() {
+	e.loadStorage()
+
+	err := export.WriteFile(e.args.ExportCommands, e.storage.Snapshot(), export.Filter{})
+	if err != nil {
+		log.Fatalf("Export commands error: %v\n", err)
+	}
+	log.Printf("Dataset is exported as commands to %s\n", e.args.ExportCommands)
+}
@@ -7,9 +7,11 @@ type Server interface {
 }
 
 func SpawnServer(args *config.Args) Server {
-	if args.ReplicaOf == nil {
-		return newMaster(args)
-	} else {
-		return newReplica(args)
+	if args.ExportCommands != "" {
+		return newExporter(args)
 	}
+	if args.Sentinel {
+		return newSentinel(args)
+	}
+	return newServer(args)
 }
@@ -10,108 +10,188 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 
-	"github.com/codecrafters-io/redis-starter-go/app/commands"
-	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
 	"github.com/codecrafters-io/redis-starter-go/app/replication"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 	"github.com/codecrafters-io/redis-starter-go/app/utils"
 )
 
+const (
+	MASTER_DIAL_TIMEOUT = 5 * time.Second
+	// Reconnect delay is doubled after every failed attempt and reset after successful sync
+	MASTER_RECONNECT_MIN_DELAY = 100 * time.Millisecond
+	MASTER_RECONNECT_MAX_DELAY = 5 * time.Second
+	// Replica acks its replication offset with this period, so master knows its lag
+	MASTER_ACK_PERIOD = time.Second
+)
+
+// Link with master, it is started when server becomes replica and stopped when server is promoted or repointed
 type replica struct {
 	*base
 	replicationController replication.ReplicaController
+	masterHost            string
+	masterPort            int
 	masterConnBuffer      []byte
+	// Scan state of incomplete command in masterConnBuffer
+	masterParser resp.Parser
+	// Closed to stop the link
+	stopCh chan struct{}
+	// Closed when the link is stopped
+	doneCh chan struct{}
 }
 
-func newReplica(args *config.Args) Server {
-	r := &replica{
-		base:                  newBase(args),
-		replicationController: replication.NewReplicaController(),
+func newReplica(base *base, replicationController replication.ReplicaController, masterHost string, masterPort int) *replica {
+	return &replica{
+		base:                  base,
+		replicationController: replicationController,
+		masterHost:            masterHost,
+		masterPort:            masterPort,
 		masterConnBuffer:      make([]byte, 0),
+		stopCh:                make(chan struct{}),
+		doneCh:                make(chan struct{}),
 	}
-	r.commandController = commands.NewController(
-		r.args,
-		r.storage,
-		r.replicationController,
-		r.pubsubController,
-		r.transactionController,
-		r.geoController,
-	)
-	return r
 }
 
-func (r *replica) Start() {
-	var wg sync.WaitGroup
+// Master connection is closed to interrupt handshake or reading of write commands
+func (r *replica) stop() {
+	close(r.stopCh)
+	r.closeMasterConn()
+	<-r.doneCh
+}
 
-	r.initStorage()
-	listener := r.listenTCP()
+func (r *replica) isStopped() bool {
+	select {
+	case <-r.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Replica goes through connect, handshake, sync and connected states
+// After connection with master is lost or any step fails, replica connects again and asks for partial resync
+func (r *replica) connectToMaster() {
+	defer close(r.doneCh)
 
-	wg.Add(1)
-	go func(listener net.Listener) {
-		defer wg.Done()
-		r.acceptClientConnections(listener)
-	}(listener)
+	delay := MASTER_RECONNECT_MIN_DELAY
+	for {
+		err := r.syncWithMaster()
+		if err == nil {
+			delay = MASTER_RECONNECT_MIN_DELAY
+			stopAcks := make(chan struct{})
+			go r.sendAcks(stopAcks)
+			r.handleMaster()
+			close(stopAcks)
+		}
+		if !r.isStopped() {
+			if err != nil {
+				log.Printf("Sync with master error: %v\n", err)
+			} else {
+				log.Println("Connection with master is lost")
+			}
+		}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		r.connectToMaster()
-	}()
+		r.replicationController.SetLinkState(replication.MASTER_LINK_STATE_CONNECT)
+		r.closeMasterConn()
+		if r.isStopped() {
+			log.Printf("Link with master %s is stopped\n", net.JoinHostPort(r.masterHost, strconv.Itoa(r.masterPort)))
+			return
+		}
 
-	wg.Wait()
+		log.Printf("Reconnect to master in %v\n", delay)
+		select {
+		case <-r.stopCh:
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, MASTER_RECONNECT_MAX_DELAY)
+	}
 }
 
-func (r *replica) acceptClientConnections(listener net.Listener) {
-	defer listener.Close()
+func (r *replica) sendAcks(stopAcks chan struct{}) {
+	ticker := time.NewTicker(MASTER_ACK_PERIOD)
+	defer ticker.Stop()
 
 	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Printf("Error accepting connection: %v\n", err)
-			continue
+		select {
+		case <-stopAcks:
+			return
+		case <-ticker.C:
+			offset := r.replicationController.Info().MasterReplOffset
+			ackCommand := resp.CreateBulkStringArray("REPLCONF", "ACK", strconv.Itoa(offset))
+			if err := utils.WriteCommand(ackCommand, r.replicationController.GetMasterConn()); err != nil {
+				log.Printf("REPLCONF ACK write to master error: %v\n", err)
+				return
+			}
 		}
+	}
+}
 
-		go r.handleClient(nil, conn, true)
+func (r *replica) closeMasterConn() {
+	if conn := r.replicationController.GetMasterConn(); conn != nil {
+		conn.Close()
 	}
 }
 
-func (r *replica) connectToMaster() {
-	r.dialMaster()
-	r.processMasterHandshake()
-	r.handleMaster()
+func (r *replica) syncWithMaster() error {
+	r.replicationController.SetLinkState(replication.MASTER_LINK_STATE_CONNECT)
+	if err := r.dialMaster(); err != nil {
+		return err
+	}
+	// Link can be stopped before the new connection is set, then stop doesn't close it
+	if r.isStopped() {
+		return fmt.Errorf("link with master is stopped")
+	}
+
+	r.replicationController.SetLinkState(replication.MASTER_LINK_STATE_HANDSHAKE)
+	if err := r.processMasterHandshake(); err != nil {
+		return err
+	}
+
+	r.replicationController.SetLinkState(replication.MASTER_LINK_STATE_CONNECTED)
+	return nil
 }
 
-func (r *replica) dialMaster() {
-	address := fmt.Sprintf("%s:%d", r.args.ReplicaOf.Host, r.args.ReplicaOf.Port)
-	conn, err := net.Dial("tcp", address)
+func (r *replica) dialMaster() error {
+	address := net.JoinHostPort(r.masterHost, strconv.Itoa(r.masterPort))
+	conn, err := net.DialTimeout("tcp", address, MASTER_DIAL_TIMEOUT)
 	if err != nil {
-		log.Fatalf("Failed to dial master address: %s\n: %v", address, err)
+		return fmt.Errorf("failed to dial master address %s: %v", address, err)
 	}
 	r.replicationController.SetMasterConn(conn)
+	r.masterConnBuffer = make([]byte, 0)
+	r.masterParser = r.respController.NewParser()
+	return nil
 }
 
-func (r *replica) processMasterHandshake() {
-	r.processMasterHandshakePING()
-	r.processMasterHandshakeREPLCONF()
-	r.processMasterHandshakePSYNC()
+func (r *replica) processMasterHandshake() error {
+	if err := r.processMasterHandshakePING(); err != nil {
+		return err
+	}
+	if err := r.processMasterHandshakeREPLCONF(); err != nil {
+		return err
+	}
+	return r.processMasterHandshakePSYNC()
 }
 
-func (r *replica) processMasterHandshakePING() {
+func (r *replica) processMasterHandshakePING() error {
 	pingCommand := resp.CreateBulkStringArray("PING")
 	err := utils.WriteCommand(pingCommand, r.replicationController.GetMasterConn())
 	if err != nil {
-		log.Fatalf("Master handshake PING (1/3) write error: %s\n", err)
+		return fmt.Errorf("master handshake PING (1/3) write error: %v", err)
 	}
 	pingResult, err := r.readValueFromMaster()
 	if err != nil {
-		log.Fatalf("Master handshake PING (1/3) read value from master error: %s\n", err)
+		return fmt.Errorf("master handshake PING (1/3) read value from master error: %v", err)
+	}
+	if err := resp.AssertEqualSimpleString(pingResult, "PONG"); err != nil {
+		return fmt.Errorf("master handshake PING (1/3) error: %v", err)
 	}
-	resp.AssertEqualSimpleString(pingResult, "PONG")
+	return nil
 }
 
-func (r *replica) processMasterHandshakeREPLCONF() {
+func (r *replica) processMasterHandshakeREPLCONF() error {
 	commands := []resp.Array{
 		resp.CreateBulkStringArray("REPLCONF", "listening-port", strconv.Itoa(r.args.Port)),
 		resp.CreateBulkStringArray("REPLCONF", "capa", "psync2"),
@@ -120,63 +200,141 @@ func (r *replica) processMasterHandshakeREPLCONF() {
 	for _, command := range commands {
 		err := utils.WriteCommand(command, r.replicationController.GetMasterConn())
 		if err != nil {
-			log.Fatalf("Master handshake REPLCONF (2/3) write error: %s\n", err)
+			return fmt.Errorf("master handshake REPLCONF (2/3) write error: %v", err)
 		}
 		replconfResult, err := r.readValueFromMaster()
 		if err != nil {
-			log.Fatalf("Master handshake REPLCONF (2/3) read value from master error: %s\n", err)
+			return fmt.Errorf("master handshake REPLCONF (2/3) read value from master error: %v", err)
+		}
+		if err := resp.AssertEqualSimpleString(replconfResult, "OK"); err != nil {
+			return fmt.Errorf("master handshake REPLCONF (2/3) error: %v", err)
 		}
-		resp.AssertEqualSimpleString(replconfResult, "OK")
 	}
+	return nil
 }
 
-func (r *replica) processMasterHandshakePSYNC() {
+// Replica, that has been synced before, sends its replication ID and offset of the next byte it wants to get
+func (r *replica) processMasterHandshakePSYNC() error {
+	info := r.replicationController.Info()
 	psyncCommand := resp.CreateBulkStringArray("PSYNC", "?", "-1")
+	if info.MasterReplID != "?" {
+		psyncCommand = resp.CreateBulkStringArray("PSYNC", info.MasterReplID, strconv.Itoa(info.MasterReplOffset+1))
+	}
+
 	err := utils.WriteCommand(psyncCommand, r.replicationController.GetMasterConn())
 	if err != nil {
-		log.Fatalf("Master handshake PSYNC (3/3) write error: %s\n", err)
+		return fmt.Errorf("master handshake PSYNC (3/3) write error: %v", err)
 	}
 	psyncResult, err := r.readValueFromMaster()
 	if err != nil {
-		log.Fatalf("Master handshake PSYNC (3/3) read value from master error: %s\n", err)
+		return fmt.Errorf("master handshake PSYNC (3/3) read value from master error: %v", err)
 	}
 
 	simpleString, ok := psyncResult.(resp.SimpleString)
 	if !ok {
-		log.Fatalf("Master handshake PSYNC (3/3) psync response has wrong RESP type, expected: %s, got %T", reflect.TypeOf(resp.SimpleString{}).String(), simpleString)
+		return fmt.Errorf("master handshake PSYNC (3/3) psync response has wrong RESP type, expected: %s, got %T", reflect.TypeOf(resp.SimpleString{}).String(), psyncResult)
 	}
 	splitted := strings.Split(simpleString.Value, " ")
+	if splitted[0] == "CONTINUE" {
+		r.processMasterHandshakeCONTINUE(splitted[1:])
+		return nil
+	}
+	if splitted[0] != "FULLRESYNC" || len(splitted) != 3 {
+		return fmt.Errorf("master handshake PSYNC (3/3) psync response is unknown: %s", simpleString.Value)
+	}
 	replID := splitted[1]
 	replOffset := splitted[2]
 
 	atoiReplOffset, err := strconv.Atoi(replOffset)
 	if err != nil {
-		log.Fatalf("Master handshake PSYNC (3/3) psync response has wrong replication offset: %v", err)
+		return fmt.Errorf("master handshake PSYNC (3/3) psync response has wrong replication offset: %v", err)
 	}
-	r.replicationController.SetMasterReplID(replID)
-	r.replicationController.SetMasterReplOfffset(atoiReplOffset)
 
+	r.replicationController.SetLinkState(replication.MASTER_LINK_STATE_SYNC)
 	rdbPayload, restBytes, err := r.readRDBFileFromMaster()
 	if err != nil {
-		log.Fatalf("Master handshake PSYNC (3/3) read RDB file from master error: %s\n", err)
+		return fmt.Errorf("master handshake PSYNC (3/3) read RDB file from master error: %v", err)
+	}
+
+	// Storage is kept, if RDB file is corrupted, link is reconnected and full resync is requested again
+	snapshot, err := rdb.Decode(rdbPayload)
+	if err != nil {
+		return fmt.Errorf("master handshake PSYNC (3/3) RDB file decode error: %v", err)
+	}
+
+	// Full resync replaces the whole storage, AOF is rewritten, because received keys aren't in it
+	r.storage.Flush()
+	if err := r.storage.Restore(snapshot); err != nil {
+		// Partly restored storage has no replication history, so the next PSYNC asks for full resync
+		r.replicationController.ResetReplicationStream("?", -1)
+		return fmt.Errorf("master handshake PSYNC (3/3) RDB file restore error: %v", err)
 	}
-	r.processRDBFile(rdbPayload)
 
-	if len(restBytes) > 0 {
-		r.masterConnBuffer = r.processCommands(restBytes, r.replicationController.GetMasterConn(), false)
-	} else {
-		r.masterConnBuffer = nil
+	// Replication ID and offset are changed only after the whole RDB file is loaded
+	// Otherwise, replica would ask for partial resync with storage, that doesn't match them
+	r.replicationController.ResetReplicationStream(replID, atoiReplOffset)
+	if r.args.AppendOnly {
+		if err := r.aofController.Rewrite(); err != nil {
+			log.Printf("AOF rewrite after full resync error: %v\n", err)
+		}
+	}
+
+	r.masterConnBuffer = r.processMasterCommands(restBytes)
+	return nil
+}
+
+// Storage and replication offset are kept, missed commands follow the reply
+// Master can reply with new replication ID (e.g. after failover), then it is used from now on
+func (r *replica) processMasterHandshakeCONTINUE(args []string) {
+	if len(args) == 1 {
+		r.replicationController.SetMasterReplID(args[0])
 	}
+	log.Printf("Partial resync with master is accepted, continue from offset %d\n", r.replicationController.Info().MasterReplOffset)
+
+	r.masterConnBuffer = r.processMasterCommands(r.masterConnBuffer)
 }
 
 func (r *replica) handleMaster() {
-	r.handleClient(r.masterConnBuffer, r.replicationController.GetMasterConn(), false)
+	conn := r.replicationController.GetMasterConn()
+	tmp := make([]byte, 1024)
+	for {
+		n, err := conn.Read(tmp)
+		if err != nil {
+			return
+		}
+		r.masterConnBuffer = r.processMasterCommands(append(r.masterConnBuffer, tmp[:n]...))
+	}
+}
+
+// Each command is forwarded to sub-replicas with the exact bytes, received from master
+func (r *replica) processMasterCommands(buf []byte) []byte {
+	conn := r.replicationController.GetMasterConn()
+	for len(buf) > 0 {
+		rest, value, err := r.masterParser.Decode(buf)
+		if errors.Is(err, resp.ErrIncomplete) {
+			return buf
+		}
+		// Commands after invalid data can't be found, so replica reconnects and resyncs from its offset
+		if err != nil {
+			log.Printf("Master stream decode error: %v, close connection with master\n", err)
+			conn.Close()
+			return nil
+		}
+
+		r.commandController.HandleMasterCommand(value, buf[:len(buf)-len(rest)], conn)
+		buf = rest
+	}
+	return buf
 }
 
+// Closed connection is an error, because replica always waits for data from master
 func (r *replica) readFromMaster() ([]byte, int, error) {
 	b := make([]byte, 1024)
 	n, err := r.replicationController.GetMasterConn().Read(b)
 	if errors.Is(err, io.EOF) {
+		if n == 0 {
+			return b, n, fmt.Errorf("connection closed by master")
+		}
 		return b, n, nil
 	}
 
@@ -212,8 +370,7 @@ func (r *replica) readRDBFileFromMaster() ([]byte, []byte, error) {
 	}
 
 	if n == 0 || b[0] != '$' {
-		r.masterConnBuffer = b
-		return nil, b, nil
+		return nil, nil, fmt.Errorf("expected RDB file as '$<length>\r\n<contents>', got %q", b[:min(n, 16)])
 	}
 
 	b, n, i, err := r.findRDBLengthDelimiter(b, n)
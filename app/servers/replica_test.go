@@ -0,0 +1,246 @@
+package servers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/memory"
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Replica syncs with master on loopback, like real one, but without persistence
+func startTestReplica(t *testing.T, masterAddr string) *server {
+	host, portStr, _ := net.SplitHostPort(masterAddr)
+	port, _ := strconv.Atoi(portStr)
+
+	s := newServer(&config.Args{
+		Host:            "127.0.0.1",
+		ReplBacklogSize: 1024 * 1024,
+		ProtoMaxBulkLen: resp.DEFAULT_PROTO_MAX_BULK_LEN,
+		ReplicaOf:       &config.ReplicaOfConfig{Host: host, Port: port},
+	}).(*server)
+	s.startReplica(s.replicationController.(replication.ReplicaController), host, port)
+	t.Cleanup(s.replica.stop)
+	return s
+}
+
+func TestReplicationBinarySafe(t *testing.T) {
+	master, masterAddr := startTestServer(t)
+	replica := startTestReplica(t, masterAddr)
+	// Commands are sent after full resync, so they reach replica by replication stream
+	assert.Eventually(t, func() bool {
+		return replica.replicationController.Info().MasterLinkStatus == replication.MASTER_LINK_STATUS_UP
+	}, 5*time.Second, 10*time.Millisecond)
+
+	blob := make([]byte, 2*1024*1024)
+	for i := range blob {
+		blob[i] = byte(i * 31)
+	}
+	commands := [][]string{
+		{"RPUSH", "k", "", "\x00", string(blob)},
+		{"ZADD", "z", "1", "", "2", "\x00\r\n\xff"},
+		{"XADD", "s", "1-1", "", "\x00", "f\r\n", ""},
+	}
+
+	conn, err := net.Dial("tcp", masterAddr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+	for _, args := range commands {
+		_, isError := sendCommand(t, conn, parser, args...).(resp.SimpleError)
+		assert.False(t, isError)
+	}
+
+	masterOffset := master.replicationController.Info().MasterReplOffset
+	assert.Eventually(t, func() bool {
+		return replica.replicationController.Info().MasterReplOffset == masterOffset
+	}, 5*time.Second, 10*time.Millisecond)
+
+	snapshot := master.storage.Snapshot()
+	assert.Equal(t, []string{"", "\x00", string(blob)}, snapshot.Lists["k"])
+	assert.Equal(t, snapshot, replica.storage.Snapshot())
+}
+
+// Master, that always replies to PSYNC with full resync of the given RDB file, every PSYNC is sent to psyncs
+func startFakeMaster(t *testing.T, rdbFile []byte) (string, chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	psyncs := make(chan []string, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeMaster(conn, rdbFile, psyncs)
+		}
+	}()
+	return listener.Addr().String(), psyncs
+}
+
+func serveFakeMaster(conn net.Conn, rdbFile []byte, psyncs chan []string) {
+	defer conn.Close()
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+	buf := make([]byte, 0)
+	tmp := make([]byte, 1024)
+	for {
+		rest, value, err := parser.Decode(buf)
+		if errors.Is(err, resp.ErrIncomplete) {
+			n, err := conn.Read(tmp)
+			if err != nil {
+				return
+			}
+			buf = append(buf, tmp[:n]...)
+			continue
+		}
+		if err != nil {
+			return
+		}
+		buf = rest
+
+		var args []string
+		for _, arg := range value.(resp.Array).Value {
+			args = append(args, *arg.(resp.BulkString).Value)
+		}
+		switch args[0] {
+		case "PING":
+			conn.Write([]byte("+PONG\r\n"))
+		case "REPLCONF":
+			conn.Write([]byte("+OK\r\n"))
+		case "PSYNC":
+			psyncs <- args
+			conn.Write(fmt.Appendf(nil, "+FULLRESYNC %s 0\r\n$%d\r\n%s", strings.Repeat("a", 40), len(rdbFile), rdbFile))
+		}
+	}
+}
+
+// RDB file with list length of 2^40 is rejected, replica keeps its storage and asks for full resync again
+func TestReplicaCorruptRDBLength(t *testing.T) {
+	snapshot := memory.NewSnapshot()
+	snapshot.Lists["list"] = []string{"a", "b"}
+	b, err := rdb.Encode(snapshot)
+	assert.NoError(t, err)
+	// Quicklist is replaced by linked list, which length is used for allocation
+	typeOffset := bytes.Index(b, []byte{rdb.QUICKLIST_2_ENCODING, 4, 'l', 'i', 's', 't'})
+	corrupted := append([]byte{}, b[:typeOffset]...)
+	corrupted = append(corrupted, rdb.LIST_ENCODING, 4, 'l', 'i', 's', 't')
+	corrupted = binary.BigEndian.AppendUint64(append(corrupted, rdb.LENGTH_64BIT), 1<<40)
+	corrupted = append(corrupted, b[typeOffset+7:]...)
+
+	masterAddr, psyncs := startFakeMaster(t, corrupted)
+	replica := startTestReplica(t, masterAddr)
+	replica.storage.StringStorage().Set("kept", "value")
+
+	for range 2 {
+		select {
+		case args := <-psyncs:
+			// Replication ID isn't taken from rejected full resync
+			assert.Equal(t, []string{"PSYNC", "?", "-1"}, args)
+		case <-time.After(5 * time.Second):
+			t.Fatal("replica didn't reconnect to master")
+		}
+	}
+	assert.Equal(t, "value", replica.storage.Snapshot().Strings["kept"].Value)
+	assert.Empty(t, replica.storage.Snapshot().Lists)
+}
+
+// Clients change the same keys concurrently by pipelines, replica gets changes in the order master made them
+func TestReplicationConcurrentWrites(t *testing.T) {
+	master, masterAddr := startTestServer(t)
+	replica := startTestReplica(t, masterAddr)
+	assert.Eventually(t, func() bool {
+		return replica.replicationController.Info().MasterLinkStatus == replication.MASTER_LINK_STATUS_UP
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for client := range 8 {
+		var pipeline []byte
+		for i := range 1000 {
+			value := strconv.Itoa(client*1000 + i)
+			commands := [][]string{{"SET", "string", value}, {"LPUSH", "list", value}}
+			if client%2 == 0 {
+				commands = append(commands, []string{"BLPOP", "list", "1"})
+			}
+			for _, args := range commands {
+				command, _ := resp.CreateBulkStringArray(args...).Encode()
+				pipeline = append(pipeline, command...)
+			}
+		}
+		repliesCount := 1000 * (2 + 1 - client%2)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", masterAddr)
+			assert.NoError(t, err)
+			defer conn.Close()
+			go conn.Write(pipeline)
+
+			parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+			var buf []byte
+			b := make([]byte, 64*1024)
+			for repliesCount > 0 {
+				rest, _, err := parser.Decode(buf)
+				if errors.Is(err, resp.ErrIncomplete) {
+					n, err := conn.Read(b)
+					if !assert.NoError(t, err) {
+						return
+					}
+					buf = append(buf, b[:n]...)
+					continue
+				}
+				assert.NoError(t, err)
+				buf = rest
+				repliesCount--
+			}
+		}()
+	}
+	wg.Wait()
+
+	masterOffset := master.replicationController.Info().MasterReplOffset
+	assert.Eventually(t, func() bool {
+		return replica.replicationController.Info().MasterReplOffset == masterOffset
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, master.storage.Snapshot(), replica.storage.Snapshot())
+}
+
+// Expired string, that nobody reads, is deleted by active expire cycle of master, replica gets its DEL
+func TestReplicationActiveExpire(t *testing.T) {
+	master, masterAddr := startTestServer(t)
+	replica := startTestReplica(t, masterAddr)
+	assert.Eventually(t, func() bool {
+		return replica.replicationController.Info().MasterLinkStatus == replication.MASTER_LINK_STATUS_UP
+	}, 5*time.Second, 10*time.Millisecond)
+
+	conn, err := net.Dial("tcp", masterAddr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+	assert.Equal(t, resp.SimpleString{Value: "OK"}, sendCommand(t, conn, parser, "SET", "key", "value", "PX", "50"))
+	assert.Equal(t, resp.SimpleString{Value: "OK"}, sendCommand(t, conn, parser, "SET", "persistent", "value"))
+	assert.Eventually(t, func() bool {
+		return replica.storage.StringStorage().Has("key")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	time.Sleep(100 * time.Millisecond)
+	master.commandController.ActiveExpireCycle()
+	assert.Eventually(t, func() bool {
+		return !replica.storage.StringStorage().Has("key")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, replica.storage.StringStorage().Has("persistent"))
+}
@@ -0,0 +1,30 @@
+package servers
+
+import (
+	"net"
+
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/sentinel"
+)
+
+// Sentinel doesn't serve data and doesn't load or save it, it monitors masters and promotes replica, when master is down
+type sentinelServer struct {
+	*base
+	sentinelController sentinel.Controller
+}
+
+func newSentinel(args *config.Args) Server {
+	s := &sentinelServer{base: newBase(args)}
+	s.sentinelController = sentinel.NewController(args, s.respController, s.pubsubController)
+	s.clientHandler = s.sentinelController
+	return s
+}
+
+func (s *sentinelServer) Start() {
+	listener := s.listenTCP()
+	s.sentinelController.Start()
+
+	s.acceptClientConnections(listener, func(conn net.Conn) {
+		go s.handleClient(nil, conn, true)
+	})
+}
@@ -0,0 +1,164 @@
+package servers
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/config"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/utils"
+)
+
+// Server is master or replica, the role can be switched at runtime by REPLICAOF command
+type server struct {
+	*base
+	replicationController replication.BaseController
+	// Link with master, nil if server is master
+	replica *replica
+	roleMut sync.Mutex
+}
+
+var _ commands.RoleSwitcher = (*server)(nil)
+
+func newServer(args *config.Args) Server {
+	s := &server{base: newBase(args)}
+	s.replicationController = replication.NewMasterController(args)
+	if args.ReplicaOf != nil {
+		s.replicationController = replication.NewReplicaController(args, args.ReplicaOf.Host, args.ReplicaOf.Port, nil)
+	}
+	s.commandController = commands.NewController(
+		s.args,
+		s.storage,
+		s.replicationController,
+		s.pubsubController,
+		s.transactionController,
+		s.geoController,
+		s.rdbController,
+		s.aofController,
+		s.clusterController,
+		s.migrationController,
+		s,
+	)
+	s.clientHandler = s.commandController
+	return s
+}
+
+func (s *server) Start() {
+	s.initStorage()
+	go s.handleShutdownSignals()
+	listener := s.listenTCP()
+	if s.args.ClusterEnabled {
+		if err := s.clusterController.Start(); err != nil {
+			log.Fatalf("Cluster start error: %v\n", err)
+		}
+	}
+	go s.startExpiredKeysCleanup()
+	go s.pingReplicas()
+
+	if rc, ok := s.replicationController.(replication.ReplicaController); ok {
+		s.startReplica(rc, s.args.ReplicaOf.Host, s.args.ReplicaOf.Port)
+	}
+	s.acceptClientConnections(listener, func(conn net.Conn) {
+		s.handleClientWithCleanup(nil, conn, true)
+	})
+}
+
+func (s *server) ReplicaOf(host string, port int) bool {
+	s.roleMut.Lock()
+	defer s.roleMut.Unlock()
+
+	if s.replica != nil {
+		if s.replica.masterHost == host && s.replica.masterPort == port {
+			return false
+		}
+		s.replica.stop()
+	}
+
+	// Replicas of this server resync with it, when it is synced with the new master
+	s.disconnectReplicas()
+
+	// Replication history is kept, so the new master can continue it, e.g. if it was replica of this server
+	rc := replication.NewReplicaController(s.args, host, port, s.replicationController)
+	s.setReplicationController(rc)
+	s.args.ReplicaOf = &config.ReplicaOfConfig{Host: host, Port: port}
+	s.startReplica(rc, host, port)
+	log.Printf("Server is replica of %s now\n", s.args.ReplicaOf)
+	return true
+}
+
+func (s *server) ReplicaOfNoOne() {
+	s.roleMut.Lock()
+	defer s.roleMut.Unlock()
+
+	if s.replica == nil {
+		return
+	}
+	s.replica.stop()
+	s.replica = nil
+
+	// Sub-replicas continue partial resync with the promoted server
+	s.disconnectReplicas()
+	s.setReplicationController(replication.NewPromotedMasterController(s.args, s.replicationController.(replication.ReplicaController)))
+	s.args.ReplicaOf = nil
+	log.Printf("Server is promoted to master with replication ID %s\n", s.replicationController.Info().MasterReplID)
+}
+
+func (s *server) ReplicaOfConfig() *config.ReplicaOfConfig {
+	s.roleMut.Lock()
+	defer s.roleMut.Unlock()
+
+	return s.args.ReplicaOf
+}
+
+// PING is propagated, so replicas see that master is alive, when there are no write commands
+func (s *server) pingReplicas() {
+	ticker := time.NewTicker(time.Duration(s.args.ReplPingReplicaPeriod) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.roleMut.Lock()
+		mc, ok := s.replicationController.(replication.MasterController)
+		s.roleMut.Unlock()
+
+		if ok && len(mc.GetReplicas()) > 0 {
+			mc.Propagate([]string{"PING"})
+		}
+	}
+}
+
+func (s *server) disconnectReplicas() {
+	for _, conn := range s.replicationController.GetReplicas() {
+		conn.Close()
+	}
+}
+
+func (s *server) startReplica(rc replication.ReplicaController, host string, port int) {
+	s.replica = newReplica(s.base, rc, host, port)
+	go s.replica.connectToMaster()
+}
+
+func (s *server) setReplicationController(replicationController replication.BaseController) {
+	s.commandController.SetReplicationController(replicationController)
+	s.replicationController = replicationController
+}
+
+func (s *server) handleClientWithCleanup(initialBuffer []byte, conn net.Conn, writeResponseToConn bool) {
+	go func() {
+		defer s.cleanUpConn(conn)
+		s.handleClient(initialBuffer, conn, writeResponseToConn)
+	}()
+}
+
+func (s *server) cleanUpConn(conn net.Conn) {
+	s.roleMut.Lock()
+	defer s.roleMut.Unlock()
+
+	addr := utils.GetRemoteAddr(conn)
+	s.replicationController.RemoveReplicaConn(addr)
+	if mc, ok := s.replicationController.(replication.MasterController); ok {
+		mc.RemoveClient(addr)
+	}
+}
@@ -0,0 +1,240 @@
+package servers
+
+import (
+	"encoding/binary"
+	"hash/crc64"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestAuth(t *testing.T) {
+	_, addr := startTestServer(t)
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+
+	tests := []struct {
+		Name     string
+		Args     []string
+		Expected resp.Value
+	}{
+		{Name: "Any password", Args: []string{"AUTH", "secret"}, Expected: resp.SimpleString{Value: "OK"}},
+		{Name: "Default user", Args: []string{"AUTH", "default", "secret"}, Expected: resp.SimpleString{Value: "OK"}},
+		{Name: "Other user", Args: []string{"AUTH", "admin", "secret"}, Expected: resp.SimpleError{Value: "WRONGPASS invalid username-password pair or user is disabled."}},
+		{Name: "No password", Args: []string{"AUTH"}, Expected: resp.SimpleError{Value: "AUTH command must have 1 or 2 args"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, sendCommand(t, conn, parser, test.Args...))
+		})
+	}
+}
+
+// Target rediska accepts AUTH, which is sent before RESTORE, so key is moved
+func TestMigrateAuth(t *testing.T) {
+	source, sourceAddr := startTestServer(t)
+	target, targetAddr := startTestServer(t)
+	host, port, _ := net.SplitHostPort(targetAddr)
+
+	conn, err := net.Dial("tcp", sourceAddr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+
+	assert.Equal(t, resp.SimpleString{Value: "OK"}, sendCommand(t, conn, parser, "SET", "key", "value"))
+	reply := sendCommand(t, conn, parser, "MIGRATE", host, port, "key", "0", "5000", "AUTH", "secret")
+	assert.Equal(t, resp.SimpleString{Value: "OK"}, reply)
+
+	assert.Empty(t, source.storage.Snapshot().Strings)
+	assert.Equal(t, "value", target.storage.Snapshot().Strings["key"].Value)
+}
+
+// Payload has valid footer, so its list length of 2^40 reaches decoder, which rejects it instead of allocating
+func TestRestoreCorruptLength(t *testing.T) {
+	server, addr := startTestServer(t)
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+
+	payload := binary.BigEndian.AppendUint64([]byte{rdb.LIST_ENCODING, rdb.LENGTH_64BIT}, 1<<40)
+	payload = binary.LittleEndian.AppendUint16(payload, rdb.RDB_VERSION)
+	table := crc64.MakeTable(rdb.CRC64_JONES_REFLECTED_POLY)
+	payload = binary.LittleEndian.AppendUint64(payload, ^crc64.Update(^uint64(0), table, payload))
+
+	reply, isError := sendCommand(t, conn, parser, "RESTORE", "key", "0", string(payload)).(resp.SimpleError)
+	assert.True(t, isError)
+	assert.Contains(t, reply.Value, "ERR Bad data format")
+
+	assert.Equal(t, resp.SimpleString{Value: "PONG"}, sendCommand(t, conn, parser, "PING"))
+	assert.Empty(t, server.storage.Snapshot().Lists)
+}
+
+// Server switches role by REPLICAOF or SLAVEOF, CONFIG GET replicaof shows the current master
+func TestReplicaOf(t *testing.T) {
+	_, masterAddr := startTestServer(t)
+	host, port, _ := net.SplitHostPort(masterAddr)
+	server, addr := startTestServer(t)
+	t.Cleanup(func() {
+		server.ReplicaOfNoOne()
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+	configGet := func() resp.Value {
+		return sendCommand(t, conn, parser, "CONFIG", "GET", "replicaof")
+	}
+	role := func() string {
+		server.roleMut.Lock()
+		defer server.roleMut.Unlock()
+		return server.replicationController.Info().Role
+	}
+
+	tests := []struct {
+		Name              string
+		Args              []string
+		Expected          resp.Value
+		ExpectedRole      string
+		ExpectedReplicaOf string
+	}{
+		{Name: "Master to no one", Args: []string{"REPLICAOF", "NO", "ONE"}, Expected: resp.SimpleString{Value: "OK"}, ExpectedRole: "master", ExpectedReplicaOf: ""},
+		{Name: "Replica of master", Args: []string{"REPLICAOF", host, port}, Expected: resp.SimpleString{Value: "OK"}, ExpectedRole: "slave", ExpectedReplicaOf: host + " " + port},
+		{Name: "Same master", Args: []string{"REPLICAOF", host, port}, Expected: resp.SimpleString{Value: "OK Already connected to specified master"}, ExpectedRole: "slave", ExpectedReplicaOf: host + " " + port},
+		{Name: "SLAVEOF no one", Args: []string{"SLAVEOF", "no", "one"}, Expected: resp.SimpleString{Value: "OK"}, ExpectedRole: "master", ExpectedReplicaOf: ""},
+		{Name: "SLAVEOF master", Args: []string{"SLAVEOF", host, port}, Expected: resp.SimpleString{Value: "OK"}, ExpectedRole: "slave", ExpectedReplicaOf: host + " " + port},
+		{Name: "Invalid port", Args: []string{"REPLICAOF", host, "port"}, Expected: resp.SimpleError{Value: "ERR Invalid master port"}, ExpectedRole: "slave", ExpectedReplicaOf: host + " " + port},
+	}
+
+	// Other client reads replicaof, while it is switched, e.g. race detector checks it
+	readerConn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer readerConn.Close()
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		readerParser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				sendCommand(t, readerConn, readerParser, "CONFIG", "GET", "replicaof")
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, sendCommand(t, conn, parser, test.Args...))
+			assert.Equal(t, test.ExpectedRole, role())
+			assert.Equal(t, resp.CreateBulkStringArray("replicaof", test.ExpectedReplicaOf), configGet())
+		})
+	}
+}
+
+// Inline command is typed by user, e.g. in nc, so its args aren't checked by client library
+func TestInlineCommandArgs(t *testing.T) {
+	_, addr := startTestServer(t)
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	tests := []struct {
+		Name     string
+		Line     string
+		Expected string
+	}{
+		{Name: "SET with option without value", Line: "SET k v NX\r\n", Expected: "-ERR syntax error\r\n"},
+		{Name: "CONFIG without subcommand", Line: "CONFIG\r\n", Expected: "-ERR wrong number of arguments for 'config' command\r\n"},
+		{Name: "Server is alive", Line: "PING\r\n", Expected: "+PONG\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := conn.Write([]byte(test.Line))
+			assert.NoError(t, err)
+			reply := make([]byte, len(test.Expected))
+			_, err = io.ReadFull(conn, reply)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, string(reply))
+		})
+	}
+}
+
+type panicHandler struct{}
+
+func (panicHandler) HandleCommand(resp.Value, net.Conn, bool) (resp.Value, error) {
+	var args []string
+	return resp.SimpleString{Value: args[0]}, nil
+}
+
+// Panic of command is replied as error, connection keeps working
+func TestHandleClientCommandPanic(t *testing.T) {
+	s, _ := startTestServer(t)
+	s.clientHandler = panicHandler{}
+	conn, peerConn := net.Pipe()
+	defer conn.Close()
+	defer peerConn.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.handleClientCommand(resp.CreateBulkStringArray("GET"), conn, true)
+	}()
+	reply := make([]byte, 1024)
+	n, err := peerConn.Read(reply)
+	assert.NoError(t, err)
+	assert.Equal(t, "-ERR command failed: runtime error: index out of range [0] with length 0\r\n", string(reply[:n]))
+	assert.NoError(t, <-errCh)
+}
+
+// KEYS uses the same glob matching as EXPORT MATCH
+func TestKeys(t *testing.T) {
+	_, addr := startTestServer(t)
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	parser := resp.NewController(resp.DEFAULT_PROTO_MAX_BULK_LEN).NewParser()
+
+	for _, key := range []string{"user:1", "user:2", "user:10", "order:1", "a*b"} {
+		assert.Equal(t, resp.SimpleString{Value: "OK"}, sendCommand(t, conn, parser, "SET", key, "value"))
+	}
+
+	tests := []struct {
+		Name     string
+		Pattern  string
+		Expected []string
+	}{
+		{Name: "All keys", Pattern: "*", Expected: []string{"user:1", "user:2", "user:10", "order:1", "a*b"}},
+		{Name: "Prefix", Pattern: "user:*", Expected: []string{"user:1", "user:2", "user:10"}},
+		{Name: "One char", Pattern: "user:?", Expected: []string{"user:1", "user:2"}},
+		{Name: "Class", Pattern: "[ou]*:1", Expected: []string{"user:1", "order:1"}},
+		{Name: "Escaped star", Pattern: `a\*b`, Expected: []string{"a*b"}},
+		{Name: "No match", Pattern: "session:*", Expected: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			reply, ok := sendCommand(t, conn, parser, "KEYS", test.Pattern).(resp.Array)
+			assert.True(t, ok)
+			keys := make([]string, 0, len(reply.Value))
+			for _, key := range reply.Value {
+				keys = append(keys, *key.(resp.BulkString).Value)
+			}
+			assert.ElementsMatch(t, test.Expected, keys)
+		})
+	}
+}
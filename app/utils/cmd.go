@@ -3,11 +3,19 @@ package utils
 import (
 	"net"
 
+	"github.com/codecrafters-io/redis-starter-go/app/client"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
 
+// Reply is converted to protocol of client, so command can build it from RESP3 types
+// Client connection buffers it, while its read batch is handled
 func WriteCommand(cmd resp.Value, conn net.Conn) error {
-	encoded, err := cmd.Encode()
+	value := resp.ConvertToProtocol(cmd, client.Protocol(conn))
+	if c, ok := conn.(*client.Conn); ok {
+		return c.WriteValue(value)
+	}
+
+	encoded, err := value.Encode()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,99 @@
+package utils
+
+// Glob-style matching, same as Redis KEYS and SCAN MATCH patterns
+// Supports '*', '?', '[...]' with '^' negation and 'a-z' ranges, and '\' escapes
+func MatchPattern(pattern, s string) bool {
+	p := 0
+	i := 0
+	// Position to backtrack to after the last '*'
+	starP := -1
+	starI := 0
+
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP = p
+				starI = i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				matched, next, ok := matchClass(pattern, p, s[i])
+				if ok && matched {
+					p = next
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == s[i] {
+					p += 2
+					i++
+					continue
+				}
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+
+		if starP == -1 {
+			return false
+		}
+		starI++
+		i = starI
+		p = starP + 1
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// Returns whether c matches the class starting at pattern[p] and the position after the class
+// Unterminated class is not valid, ok is false then
+func matchClass(pattern string, p int, c byte) (bool, int, bool) {
+	p++
+	negate := false
+	if p < len(pattern) && pattern[p] == '^' {
+		negate = true
+		p++
+	}
+
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			if pattern[p+1] == c {
+				matched = true
+			}
+			p += 2
+		case p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']':
+			start, end := pattern[p], pattern[p+2]
+			if start > end {
+				start, end = end, start
+			}
+			if c >= start && c <= end {
+				matched = true
+			}
+			p += 3
+		default:
+			if pattern[p] == c {
+				matched = true
+			}
+			p++
+		}
+	}
+
+	if p >= len(pattern) {
+		return false, p, false
+	}
+	return matched != negate, p + 1, true
+}
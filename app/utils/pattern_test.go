@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Pattern  string
+		In       string
+		Expected bool
+	}{
+		{Name: "Star matches everything", Pattern: "*", In: "anything", Expected: true},
+		{Name: "Star matches empty string", Pattern: "*", In: "", Expected: true},
+		{Name: "Exact match", Pattern: "user", In: "user", Expected: true},
+		{Name: "Exact mismatch", Pattern: "user", In: "users", Expected: false},
+		{Name: "Prefix with star", Pattern: "user:*", In: "user:42", Expected: true},
+		{Name: "Star in the middle", Pattern: "h*llo", In: "heeeello", Expected: true},
+		{Name: "Several stars with backtracking", Pattern: "*a*b", In: "xaxxaxb", Expected: true},
+		{Name: "Several stars mismatch", Pattern: "*a*b", In: "xaxxaxc", Expected: false},
+		{Name: "Question mark", Pattern: "h?llo", In: "hallo", Expected: true},
+		{Name: "Question mark needs one char", Pattern: "h?llo", In: "hllo", Expected: false},
+		{Name: "Class", Pattern: "h[ae]llo", In: "hello", Expected: true},
+		{Name: "Class mismatch", Pattern: "h[ae]llo", In: "hillo", Expected: false},
+		{Name: "Negated class", Pattern: "h[^e]llo", In: "hallo", Expected: true},
+		{Name: "Negated class mismatch", Pattern: "h[^e]llo", In: "hello", Expected: false},
+		{Name: "Range", Pattern: "key[0-9]", In: "key7", Expected: true},
+		{Name: "Range mismatch", Pattern: "key[0-9]", In: "keyx", Expected: false},
+		{Name: "Escaped star", Pattern: `a\*b`, In: "a*b", Expected: true},
+		{Name: "Escaped star isn't wildcard", Pattern: `a\*b`, In: "axb", Expected: false},
+		{Name: "Unterminated class", Pattern: "a[bc", In: "ab", Expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, MatchPattern(test.Pattern, test.In))
+		})
+	}
+}
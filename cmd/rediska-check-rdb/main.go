@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/codecrafters-io/redis-starter-go/app/persistence/rdb"
+)
+
+// Offline RDB file check, e.g: go run ./cmd/rediska-check-rdb dump.rdb
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s <rdb-file>\n", os.Args[0])
+		os.Exit(2)
+	}
+	path := os.Args[1]
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't read RDB file %s: %v\n", path, err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("Checking RDB file %s (%d bytes)\n", path, len(b))
+	report, err := rdb.Check(b)
+	printReport(report)
+
+	if err != nil {
+		fmt.Println("--- RDB ERROR DETECTED ---")
+		var decodeErr *rdb.DecodeError
+		if errors.As(err, &decodeErr) {
+			fmt.Printf("Corruption at offset %d (%#x): %v\n", decodeErr.Offset, decodeErr.Offset, decodeErr.Err)
+		} else {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("RDB looks OK!")
+}
+
+func printReport(report *rdb.Report) {
+	if report.Name != "" {
+		fmt.Printf("Header: %s, version: %d\n", report.Name, report.Version)
+	}
+
+	metadataKeys := make([]string, 0, len(report.Metadata))
+	for key := range report.Metadata {
+		metadataKeys = append(metadataKeys, key)
+	}
+	slices.Sort(metadataKeys)
+	for _, key := range metadataKeys {
+		fmt.Printf("AUX field %s = '%s'\n", key, report.Metadata[key])
+	}
+
+	for _, database := range report.Databases {
+		fmt.Printf("Database #%d: %d keys (%d with expiration), RESIZEDB declared %d keys (%d with expiration)\n",
+			database.DBSelector, database.DecodedKeysCount, database.DecodedKeysWithExpirationCount, database.KeysCount, database.KeysWithExpirationCount)
+
+		valueTypes := make([]string, 0, len(database.TypesCount))
+		for valueType := range database.TypesCount {
+			valueTypes = append(valueTypes, valueType)
+		}
+		slices.Sort(valueTypes)
+		for _, valueType := range valueTypes {
+			fmt.Printf("  %s: %d\n", valueType, database.TypesCount[valueType])
+		}
+	}
+
+	if report.Checksum != 0 {
+		fmt.Printf("Checksum: %016x\n", report.Checksum)
+	}
+}